@@ -34,15 +34,86 @@ func SetupDatabase() {
 	db.AutoMigrate(
 		&entity.PregnantWoman{},
 		&entity.Doctor{},
-		&entity.PreviousPregnancy{},
+		&entity.ObstetricHistory{},
 		&entity.MedicalHistory{},
 		&entity.Vaccination{},
 		&entity.AntenatalVisit{},
 		&entity.LabResult{},
 		&entity.FetalKickCount{},
 		&entity.Appointment{},
+		&entity.Fetus{},
+		&entity.FetalObservation{},
+		&entity.PregnancyStatusTransition{},
+		&entity.DoctorAvailability{},
+		&entity.AvailabilityException{},
+		&entity.AppointmentSlot{},
+		&entity.AncScheduleTemplate{},
+		&entity.AncContact{},
+		&entity.Notification{},
+		&entity.CalendarFeed{},
+		&entity.QueueTicket{},
+		&entity.FollowUpItem{},
+		&entity.FollowUpOutreach{},
+		&entity.PostpartumVisit{},
+		&entity.EpdsScreening{},
+		&entity.Prescription{},
+		&entity.AdherenceLog{},
+		&entity.DangerSignReport{},
+		&entity.MonitoringTarget{},
+		&entity.HomeReading{},
+		&entity.GdmScreening{},
+		&entity.RecordCorrection{},
+		&entity.RecordAddendum{},
+		&entity.Icd10Code{},
+		&entity.NoteTemplate{},
+		&entity.ClinicalNote{},
+		&entity.NoteDiagnosis{},
+		&entity.NotePlanItem{},
+		&entity.LabOrder{},
+		&entity.LabTest{},
+		&entity.LabReferenceRange{},
+		&entity.LabObservation{},
+		&entity.StoredFile{},
+		&entity.PatientDocument{},
+		&entity.DocumentVersion{},
+		&entity.FhirLink{},
+		&entity.Hl7InboundMessage{},
 	)
 
+	if err := migrateObstetricHistory(db); err != nil {
+		panic("failed to migrate obstetric history: " + err.Error())
+	}
+	if err := migrateAppointmentLinks(db); err != nil {
+		panic("failed to migrate appointments: " + err.Error())
+	}
+	if err := migrateAppointmentSlots(db); err != nil {
+		panic("failed to migrate appointment slots: " + err.Error())
+	}
+	if err := migrateVaccineTypeCodes(db); err != nil {
+		panic("failed to migrate vaccine types: " + err.Error())
+	}
+	// The catalog must exist before the old lab result columns are copied into observations
+	seedLabTests(db)
+	if err := migrateLabObservations(db); err != nil {
+		panic("failed to migrate lab results: " + err.Error())
+	}
+	if err := migrateLabResultFiles(db, Storage()); err != nil {
+		panic("failed to migrate lab result files: " + err.Error())
+	}
+
+	// Pregnancies closed before the status lifecycle was introduced were all deliveries
+	db.Model(&entity.Pregnancy{}).Where("status = ?", "Ended").Update("status", entity.PregnancyStatusDelivered)
+
+	// Backfill a fetus record for pregnancies created before multiple-gestation support
+	var pregnancies []entity.Pregnancy
+	db.Where("id NOT IN (?)", db.Model(&entity.Fetus{}).Select("pregnancy_id")).Find(&pregnancies)
+	for _, p := range pregnancies {
+		pregnancyID := p.ID
+		for no := 1; no <= max(p.FetusCount, 1); no++ {
+			db.Create(&entity.Fetus{PregnancyID: &pregnancyID, FetusNo: no, Label: string(rune('A' + no - 1))})
+		}
+	}
+
 	hashedPassword, _ := HashPassword("123456")
 
 	// Create Doctor
@@ -67,29 +138,184 @@ func SetupDatabase() {
 	db.FirstOrCreate(&Woman, &entity.PregnantWoman{Username: "Mommy"})
 
 	// Seed Vaccine Types
-	vaccineTypes := []entity.VaccineType{
-		{Name: "บาดทะยัก-คอตีบ (dT)"},
-		{Name: "ไข้หวัดใหญ่ (Influenza)"},
-		{Name: "โควิด 19 (Covid-19)"},
-	}
-	for _, vt := range vaccineTypes {
-		db.FirstOrCreate(&vt, entity.VaccineType{Name: vt.Name})
+	for _, vt := range seedVaccineTypes {
+		db.Where(entity.VaccineType{Name: vt.Name}).Attrs(entity.VaccineType{Code: vt.Code}).FirstOrCreate(&vt)
 	}
 
-	// Create Appointment and Assign to Mommy
+	seedAncScheduleTemplates(db)
+	seedIcd10Codes(db)
+
+	// Create Appointment for Mommy
 	// 25 Nov 2025 09:00:00
 	apptDate, _ := time.Parse("2006-01-02 15:04:05", "2025-11-25 09:00:00")
 	Appt := entity.Appointment{
 		AppointmentDate: apptDate,
 		Title:           "นัดตรวจครรภ์ครั้งถัดไป",
 		Location:        "อาคารผู้ป่วยนอก",
+		Status:          entity.AppointmentStatusScheduled,
+		PregnantWomanID: &Woman.ID,
+		DoctorID:        &Doctor.ID,
 	}
 	db.FirstOrCreate(&Appt, &entity.Appointment{Title: "นัดตรวจครรภ์ครั้งถัดไป"})
+}
+
+// seedVaccineTypes are the vaccines given in pregnancy. The code, not the name, drives the dT schedule.
+var seedVaccineTypes = []entity.VaccineType{
+	{Name: "บาดทะยัก-คอตีบ (dT)", Code: entity.VaccineCodeDT},
+	{Name: "ไข้หวัดใหญ่ (Influenza)", Code: entity.VaccineCodeInfluenza},
+	{Name: "โควิด 19 (Covid-19)", Code: entity.VaccineCodeCovid19},
+}
+
+// seedAncScheduleTemplates creates the built-in ANC schedules (MOPH 5 visits is the default)
+func seedAncScheduleTemplates(db *gorm.DB) {
+	templates := []entity.AncScheduleTemplate{
+		{
+			Code:      "MOPH5",
+			Name:      "ฝากครรภ์คุณภาพ 5 ครั้ง (กระทรวงสาธารณสุข)",
+			IsDefault: true,
+			Contacts: []entity.AncContact{
+				{ContactNo: 1, WeekFrom: 0, WeekTo: 12, TargetWeek: 12, Checks: "ซักประวัติ,ชั่งน้ำหนัก/วัดส่วนสูง,วัดความดันโลหิต,ตรวจร่างกาย,ประเมินความเสี่ยง", Labs: "CBC,Blood group/Rh,VDRL,HIV,HBsAg,Thalassemia screening,Urine protein/sugar", Description: "ฝากครรภ์ครั้งแรก อายุครรภ์ไม่เกิน 12 สัปดาห์"},
+				{ContactNo: 2, WeekFrom: 13, WeekTo: 20, TargetWeek: 18, Checks: "ชั่งน้ำหนัก,วัดความดันโลหิต,วัดระดับยอดมดลูก,ฟังเสียงหัวใจทารก", Labs: "Urine protein/sugar,Ultrasound", Description: "อายุครรภ์ 13-20 สัปดาห์"},
+				{ContactNo: 3, WeekFrom: 21, WeekTo: 26, TargetWeek: 26, Checks: "ชั่งน้ำหนัก,วัดความดันโลหิต,วัดระดับยอดมดลูก,ฟังเสียงหัวใจทารก", Labs: "Urine protein/sugar,GCT 50 g (กลุ่มเสี่ยง)", Description: "อายุครรภ์ 21-26 สัปดาห์"},
+				{ContactNo: 4, WeekFrom: 27, WeekTo: 32, TargetWeek: 32, Checks: "ชั่งน้ำหนัก,วัดความดันโลหิต,วัดระดับยอดมดลูก,ฟังเสียงหัวใจทารก,นับลูกดิ้น", Labs: "CBC,VDRL,HIV,Urine protein/sugar", Description: "อายุครรภ์ 27-32 สัปดาห์"},
+				{ContactNo: 5, WeekFrom: 33, WeekTo: 38, TargetWeek: 38, Checks: "ชั่งน้ำหนัก,วัดความดันโลหิต,วัดระดับยอดมดลูก,ตรวจท่าทารก,เตรียมคลอด", Labs: "Urine protein/sugar", Description: "อายุครรภ์ 33-38 สัปดาห์"},
+			},
+		},
+		{
+			Code: "WHO8",
+			Name: "WHO 2016 ANC model (8 contacts)",
+			Contacts: []entity.AncContact{
+				{ContactNo: 1, WeekFrom: 0, WeekTo: 12, TargetWeek: 12, Checks: "History,Weight/Height,Blood pressure,Physical exam", Labs: "Hb,Blood group/Rh,Syphilis,HIV,HBsAg,Urine", Description: "Up to 12 weeks"},
+				{ContactNo: 2, WeekFrom: 13, WeekTo: 20, TargetWeek: 20, Checks: "Blood pressure,Fundal height,Fetal heart", Labs: "Ultrasound", Description: "20 weeks"},
+				{ContactNo: 3, WeekFrom: 21, WeekTo: 26, TargetWeek: 26, Checks: "Blood pressure,Fundal height,Fetal heart", Labs: "Urine,OGTT (if at risk)", Description: "26 weeks"},
+				{ContactNo: 4, WeekFrom: 27, WeekTo: 30, TargetWeek: 30, Checks: "Blood pressure,Fundal height,Fetal heart", Labs: "Hb", Description: "30 weeks"},
+				{ContactNo: 5, WeekFrom: 31, WeekTo: 34, TargetWeek: 34, Checks: "Blood pressure,Fundal height,Fetal heart", Labs: "Urine", Description: "34 weeks"},
+				{ContactNo: 6, WeekFrom: 35, WeekTo: 36, TargetWeek: 36, Checks: "Blood pressure,Fundal height,Fetal presentation", Labs: "Hb", Description: "36 weeks"},
+				{ContactNo: 7, WeekFrom: 37, WeekTo: 38, TargetWeek: 38, Checks: "Blood pressure,Fundal height,Fetal presentation", Description: "38 weeks"},
+				{ContactNo: 8, WeekFrom: 39, WeekTo: 40, TargetWeek: 40, Checks: "Blood pressure,Fundal height,Fetal presentation,Birth plan", Description: "40 weeks"},
+			},
+		},
+	}
+
+	for _, t := range templates {
+		var existing entity.AncScheduleTemplate
+		if err := db.Where("code = ?", t.Code).First(&existing).Error; err == nil {
+			continue
+		}
+		db.Create(&t)
+	}
+}
+
+// seedIcd10Codes creates the local ICD-10 table of obstetric codes used in clinical notes
+func seedIcd10Codes(db *gorm.DB) {
+	codes := []entity.Icd10Code{
+		{Code: "Z34.0", Title: "Supervision of normal first pregnancy", TitleTh: "ฝากครรภ์ปกติ ครรภ์แรก"},
+		{Code: "Z34.8", Title: "Supervision of other normal pregnancy", TitleTh: "ฝากครรภ์ปกติ ครรภ์หลัง"},
+		{Code: "Z35.5", Title: "Supervision of elderly primigravida", TitleTh: "ฝากครรภ์ ครรภ์แรกอายุมาก"},
+		{Code: "Z35.9", Title: "Supervision of high-risk pregnancy, unspecified", TitleTh: "ฝากครรภ์ความเสี่ยงสูง"},
+		{Code: "Z36.9", Title: "Antenatal screening, unspecified", TitleTh: "ตรวจคัดกรองระหว่างตั้งครรภ์"},
+		{Code: "Z39.2", Title: "Routine postpartum follow-up", TitleTh: "ตรวจหลังคลอดตามนัด"},
+		{Code: "O10.0", Title: "Pre-existing essential hypertension complicating pregnancy, childbirth and the puerperium", TitleTh: "ความดันโลหิตสูงก่อนตั้งครรภ์"},
+		{Code: "O13", Title: "Gestational hypertension without significant proteinuria", TitleTh: "ความดันโลหิตสูงขณะตั้งครรภ์"},
+		{Code: "O14.0", Title: "Mild to moderate pre-eclampsia", TitleTh: "ครรภ์เป็นพิษ"},
+		{Code: "O14.1", Title: "Severe pre-eclampsia", TitleTh: "ครรภ์เป็นพิษรุนแรง"},
+		{Code: "O15.0", Title: "Eclampsia in pregnancy", TitleTh: "ชักจากครรภ์เป็นพิษ"},
+		{Code: "O20.0", Title: "Threatened abortion", TitleTh: "แท้งคุกคาม"},
+		{Code: "O21.0", Title: "Mild hyperemesis gravidarum", TitleTh: "แพ้ท้อง"},
+		{Code: "O21.1", Title: "Hyperemesis gravidarum with metabolic disturbance", TitleTh: "แพ้ท้องรุนแรง"},
+		{Code: "O23.4", Title: "Unspecified infection of urinary tract in pregnancy", TitleTh: "ติดเชื้อทางเดินปัสสาวะขณะตั้งครรภ์"},
+		{Code: "O24.4", Title: "Diabetes mellitus arising in pregnancy", TitleTh: "เบาหวานขณะตั้งครรภ์"},
+		{Code: "O26.8", Title: "Other specified pregnancy-related conditions", TitleTh: "ภาวะอื่นที่เกี่ยวกับการตั้งครรภ์"},
+		{Code: "O28.9", Title: "Abnormal findings on antenatal screening of mother, unspecified", TitleTh: "ผลคัดกรองระหว่างตั้งครรภ์ผิดปกติ"},
+		{Code: "O30.0", Title: "Twin pregnancy", TitleTh: "ครรภ์แฝดสอง"},
+		{Code: "O32.1", Title: "Maternal care for breech presentation", TitleTh: "ทารกท่าก้น"},
+		{Code: "O34.2", Title: "Maternal care due to uterine scar from previous surgery", TitleTh: "แผลผ่าตัดคลอดเดิม"},
+		{Code: "O36.5", Title: "Maternal care for poor fetal growth", TitleTh: "ทารกเจริญเติบโตช้าในครรภ์"},
+		{Code: "O36.8", Title: "Maternal care for other specified fetal problems", TitleTh: "ปัญหาทารกในครรภ์อื่น ๆ (เช่น ลูกดิ้นน้อย)"},
+		{Code: "O40", Title: "Polyhydramnios", TitleTh: "น้ำคร่ำมาก"},
+		{Code: "O41.0", Title: "Oligohydramnios", TitleTh: "น้ำคร่ำน้อย"},
+		{Code: "O42.9", Title: "Premature rupture of membranes, unspecified", TitleTh: "ถุงน้ำคร่ำแตกก่อนเจ็บครรภ์"},
+		{Code: "O44.1", Title: "Placenta praevia with haemorrhage", TitleTh: "รกเกาะต่ำมีเลือดออก"},
+		{Code: "O45.9", Title: "Premature separation of placenta, unspecified", TitleTh: "รกลอกตัวก่อนกำหนด"},
+		{Code: "O47.0", Title: "False labour before 37 completed weeks of gestation", TitleTh: "เจ็บครรภ์เตือนก่อน 37 สัปดาห์"},
+		{Code: "O48", Title: "Prolonged pregnancy", TitleTh: "ครรภ์เกินกำหนด"},
+		{Code: "O60.0", Title: "Preterm labour without delivery", TitleTh: "เจ็บครรภ์คลอดก่อนกำหนด"},
+		{Code: "O98.1", Title: "Syphilis complicating pregnancy, childbirth and the puerperium", TitleTh: "ซิฟิลิสขณะตั้งครรภ์"},
+		{Code: "O98.4", Title: "Viral hepatitis complicating pregnancy, childbirth and the puerperium", TitleTh: "ไวรัสตับอักเสบขณะตั้งครรภ์"},
+		{Code: "O98.7", Title: "HIV disease complicating pregnancy, childbirth and the puerperium", TitleTh: "ติดเชื้อเอชไอวีขณะตั้งครรภ์"},
+		{Code: "O99.0", Title: "Anaemia complicating pregnancy, childbirth and the puerperium", TitleTh: "โลหิตจางขณะตั้งครรภ์"},
+		{Code: "D50.9", Title: "Iron deficiency anaemia, unspecified", TitleTh: "โลหิตจางจากการขาดธาตุเหล็ก"},
+		{Code: "D56.9", Title: "Thalassaemia, unspecified", TitleTh: "ธาลัสซีเมีย"},
+		{Code: "F53.0", Title: "Mild mental and behavioural disorders associated with the puerperium (postnatal depression)", TitleTh: "ภาวะซึมเศร้าหลังคลอด"},
+	}
+
+	for _, code := range codes {
+		db.Where(entity.Icd10Code{Code: code.Code}).FirstOrCreate(&code)
+	}
+}
+
+// seedLabTests creates the default lab test catalog with LOINC codes for HL7 matching.
+// Tests already in the catalog are left as the clinic configured them.
+func seedLabTests(db *gorm.DB) {
+	limit := func(v float64) *float64 { return &v }
+	tests := []entity.LabTest{
+		{Code: "HB", Name: "Hemoglobin", Unit: "g/dL", ExternalCodes: "718-7", ValueType: entity.LabValueNumeric, ReferenceRanges: []entity.LabReferenceRange{
+			{Trimester: 1, Low: limit(11)}, {Trimester: 2, Low: limit(10.5)}, {Trimester: 3, Low: limit(11)},
+		}},
+		{Code: "HCT", Name: "Hematocrit", Unit: "%", ExternalCodes: "4544-3", ValueType: entity.LabValueNumeric, ReferenceRanges: []entity.LabReferenceRange{
+			{Trimester: 1, Low: limit(33)}, {Trimester: 2, Low: limit(32)}, {Trimester: 3, Low: limit(33)},
+		}},
+		{Code: "MCV", Name: "Mean corpuscular volume", Unit: "fL", ExternalCodes: "787-2", ValueType: entity.LabValueNumeric, ReferenceRanges: []entity.LabReferenceRange{
+			{Low: limit(80)},
+		}},
+		{Code: "HB_TYPING", Name: "Hb typing", ValueType: entity.LabValueText},
+		{Code: "DCIP", Name: "DCIP", ValueType: entity.LabValueChoice, Choices: "Negative,Positive", ReferenceRanges: []entity.LabReferenceRange{
+			{NormalValues: "Negative"},
+		}},
+		{Code: "ANTI_HIV", Name: "Anti-HIV", ExternalCodes: "7918-6", ValueType: entity.LabValueChoice, Choices: "Negative,Positive,Inconclusive", ReferenceRanges: []entity.LabReferenceRange{
+			{NormalValues: "Negative"},
+		}},
+		{Code: "VDRL", Name: "VDRL (Syphilis)", ExternalCodes: "5292-8", ValueType: entity.LabValueChoice, Choices: "Non-reactive,Reactive", ReferenceRanges: []entity.LabReferenceRange{
+			{NormalValues: "Non-reactive"},
+		}},
+		{Code: "HBSAG", Name: "HBsAg", ExternalCodes: "5196-1", ValueType: entity.LabValueChoice, Choices: "Negative,Positive", ReferenceRanges: []entity.LabReferenceRange{
+			{NormalValues: "Negative"},
+		}},
+		{Code: "BLOOD_GROUP", Name: "Blood group", ExternalCodes: "883-9", ValueType: entity.LabValueChoice, Choices: "A,B,AB,O"},
+		{Code: "RH", Name: "Rh", ExternalCodes: "10331-7", ValueType: entity.LabValueChoice, Choices: "Positive,Negative", ReferenceRanges: []entity.LabReferenceRange{
+			{NormalValues: "Positive"},
+		}},
+		{Code: "URINE_PROTEIN", Name: "Urine protein", ExternalCodes: "20454-5", ValueType: entity.LabValueChoice, Choices: "Negative,Trace,1+,2+,3+,4+", ReferenceRanges: []entity.LabReferenceRange{
+			{NormalValues: "Negative,Trace"},
+		}},
+		{Code: "URINE_SUGAR", Name: "Urine sugar", ExternalCodes: "25428-4", ValueType: entity.LabValueChoice, Choices: "Negative,Trace,1+,2+,3+,4+", ReferenceRanges: []entity.LabReferenceRange{
+			{NormalValues: "Negative,Trace"},
+		}},
+		{Code: "URINE_CULTURE", Name: "Urine culture", ValueType: entity.LabValueChoice, Choices: "No growth,Growth", ReferenceRanges: []entity.LabReferenceRange{
+			{NormalValues: "No growth"},
+		}},
+		{Code: "GCT50", Name: "50 g glucose challenge test (1 hr)", Unit: "mg/dL", ExternalCodes: "1504-0", ValueType: entity.LabValueNumeric, ReferenceRanges: []entity.LabReferenceRange{
+			{High: limit(139)},
+		}},
+		{Code: "OGTT75_FASTING", Name: "75 g OGTT fasting", Unit: "mg/dL", ExternalCodes: "1558-6", ValueType: entity.LabValueNumeric, ReferenceRanges: []entity.LabReferenceRange{
+			{High: limit(91)},
+		}},
+		{Code: "OGTT75_1H", Name: "75 g OGTT 1 hr", Unit: "mg/dL", ValueType: entity.LabValueNumeric, ReferenceRanges: []entity.LabReferenceRange{
+			{High: limit(179)},
+		}},
+		{Code: "OGTT75_2H", Name: "75 g OGTT 2 hr", Unit: "mg/dL", ValueType: entity.LabValueNumeric, ReferenceRanges: []entity.LabReferenceRange{
+			{High: limit(152)},
+		}},
+	}
 
-	// Update Mommy
-	var mommy entity.PregnantWoman
-	if err := db.Where("username = ?", "Mommy").First(&mommy).Error; err == nil {
-		mommy.AppointmentID = &Appt.ID
-		db.Save(&mommy)
+	for _, test := range tests {
+		var count int64
+		db.Model(&entity.LabTest{}).Where("code = ?", test.Code).Count(&count)
+		if count == 0 {
+			db.Create(&test)
+			continue
+		}
+		// external_codes is NULL only on tests created before the column existed; a clinic that cleared them saved ''
+		db.Model(&entity.LabTest{}).Where("code = ? AND external_codes IS NULL", test.Code).Update("external_codes", test.ExternalCodes)
 	}
 }
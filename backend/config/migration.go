@@ -0,0 +1,490 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/service"
+	"gorm.io/gorm"
+)
+
+// migrateObstetricHistory copies rows of the old previous_pregnancies and
+// pregnancy_histories tables into obstetric_histories. The old tables are
+// renamed with a legacy_ prefix afterwards so the copy only runs once.
+func migrateObstetricHistory(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		migrator := tx.Migrator()
+
+		if migrator.HasTable("previous_pregnancies") {
+			var rows []struct {
+				PregnantWomanID *uint
+				PregnancyID     *uint
+				PregnancyNo     int
+				BabyNo          int
+				DeliveryDate    time.Time
+				GestationalAge  int
+				DeliveryMethod  string
+				BirthWeight     float64
+				Sex             string
+				DeliveryPlace   string
+				Complications   string
+				ChildStatus     string
+			}
+			if err := tx.Table("previous_pregnancies").Where("deleted_at IS NULL").Find(&rows).Error; err != nil {
+				return err
+			}
+			for _, r := range rows {
+				history := entity.ObstetricHistory{
+					PregnantWomanID: r.PregnantWomanID,
+					PregnancyID:     r.PregnancyID,
+					PregnancyNo:     r.PregnancyNo,
+					BabyNo:          max(r.BabyNo, 1),
+					Outcome:         service.InferObstetricOutcome(r.GestationalAge, r.ChildStatus),
+					DeliveryDate:    r.DeliveryDate,
+					GestationalAge:  r.GestationalAge,
+					DeliveryMethod:  r.DeliveryMethod,
+					BirthWeight:     r.BirthWeight,
+					Sex:             r.Sex,
+					DeliveryPlace:   r.DeliveryPlace,
+					Complications:   r.Complications,
+					ChildStatus:     r.ChildStatus,
+				}
+				if err := tx.Create(&history).Error; err != nil {
+					return err
+				}
+			}
+			if err := migrator.RenameTable("previous_pregnancies", "legacy_previous_pregnancies"); err != nil {
+				return err
+			}
+			fmt.Printf("migrated %d previous pregnancies\n", len(rows))
+		}
+
+		if migrator.HasTable("pregnancy_histories") {
+			var rows []struct {
+				PID                    *uint `gorm:"column:p_id"`
+				Gravida                int
+				DeliveryOrAbortionDate time.Time
+				GestationAge           int
+				BabyWeight             float64
+				DeliveryPlace          string
+				GenderName             string
+			}
+			query := tx.Table("pregnancy_histories").Where("pregnancy_histories.deleted_at IS NULL")
+			if migrator.HasTable("genders") {
+				query = query.Select("pregnancy_histories.*, genders.name AS gender_name").
+					Joins("LEFT JOIN genders ON genders.id = pregnancy_histories.gender_id")
+			}
+			if err := query.Find(&rows).Error; err != nil {
+				return err
+			}
+			for _, r := range rows {
+				history := entity.ObstetricHistory{
+					PregnantWomanID: r.PID,
+					PregnancyNo:     r.Gravida,
+					BabyNo:          1,
+					Outcome:         service.InferObstetricOutcome(r.GestationAge, ""),
+					DeliveryDate:    r.DeliveryOrAbortionDate,
+					GestationalAge:  r.GestationAge,
+					BirthWeight:     r.BabyWeight,
+					Sex:             r.GenderName,
+					DeliveryPlace:   r.DeliveryPlace,
+				}
+				if err := tx.Create(&history).Error; err != nil {
+					return err
+				}
+			}
+			if err := migrator.RenameTable("pregnancy_histories", "legacy_pregnancy_histories"); err != nil {
+				return err
+			}
+			fmt.Printf("migrated %d pregnancy histories\n", len(rows))
+		}
+
+		return nil
+	})
+}
+
+// migrateAppointmentLinks moves the old one-appointment-per-patient link
+// (pregnant_women.a_id) onto appointments.pregnant_woman_id and fills in a
+// status for appointments created before statuses existed.
+func migrateAppointmentLinks(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		migrator := tx.Migrator()
+
+		if migrator.HasColumn(&entity.PregnantWoman{}, "a_id") {
+			if err := tx.Exec(`UPDATE appointments SET pregnant_woman_id = (
+				SELECT id FROM pregnant_women WHERE pregnant_women.a_id = appointments.id LIMIT 1
+			) WHERE pregnant_woman_id IS NULL`).Error; err != nil {
+				return err
+			}
+			if migrator.HasConstraint(&entity.PregnantWoman{}, "fk_appointments_pregnant_women") {
+				if err := migrator.DropConstraint(&entity.PregnantWoman{}, "fk_appointments_pregnant_women"); err != nil {
+					return err
+				}
+			}
+			if err := migrator.DropColumn(&entity.PregnantWoman{}, "a_id"); err != nil {
+				return err
+			}
+			// Dropping a column rebuilds the table in SQLite, so restore its indexes
+			if err := migrator.AutoMigrate(&entity.PregnantWoman{}); err != nil {
+				return err
+			}
+			fmt.Println("migrated appointment links from pregnant_women.a_id")
+		}
+
+		// Past appointments without a status are assumed to have been attended
+		now := time.Now()
+		if err := tx.Model(&entity.Appointment{}).
+			Where("(status IS NULL OR status = '') AND appointment_date >= ?", now).
+			Update("status", entity.AppointmentStatusScheduled).Error; err != nil {
+			return err
+		}
+		return tx.Model(&entity.Appointment{}).
+			Where("(status IS NULL OR status = '') AND appointment_date < ?", now).
+			Update("status", entity.AppointmentStatusCompleted).Error
+	})
+}
+
+// migrateAppointmentSlots gives reservations made before slots had an end the
+// default slot length, and reserves the doctor's time for open appointments
+// booked before reservations existed. Appointments that overlap one already
+// reserved are reported and left for the clinic to move.
+func migrateAppointmentSlots(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		length := service.DefaultSlotMinutes * time.Minute
+
+		var open []entity.AppointmentSlot
+		if err := tx.Where("slot_end IS NULL OR slot_end <= slot_start").Find(&open).Error; err != nil {
+			return err
+		}
+		for _, slot := range open {
+			if err := tx.Model(&slot).Update("slot_end", slot.SlotStart.Add(length).UTC()).Error; err != nil {
+				return err
+			}
+		}
+
+		var appts []entity.Appointment
+		if err := tx.Where("doctor_id IS NOT NULL AND status IN ? AND id NOT IN (?)",
+			[]string{entity.AppointmentStatusScheduled, entity.AppointmentStatusCheckedIn},
+			tx.Model(&entity.AppointmentSlot{}).Where("appointment_id IS NOT NULL").Select("appointment_id"),
+		).Order("appointment_date, id").Find(&appts).Error; err != nil {
+			return err
+		}
+		reserved := 0
+		for _, a := range appts {
+			start, end := a.AppointmentDate.UTC(), a.AppointmentDate.Add(length).UTC()
+			var overlapping int64
+			if err := tx.Model(&entity.AppointmentSlot{}).
+				Where("doctor_id = ? AND slot_start < ? AND slot_end > ?", *a.DoctorID, end, start).
+				Count(&overlapping).Error; err != nil {
+				return err
+			}
+			if overlapping > 0 {
+				fmt.Printf("appointment %d overlaps another appointment of doctor %d at %s; no slot reserved\n", a.ID, *a.DoctorID, start.Format(time.RFC3339))
+				continue
+			}
+			appointmentID := a.ID
+			if err := tx.Create(&entity.AppointmentSlot{DoctorID: a.DoctorID, SlotStart: start, SlotEnd: end, AppointmentID: &appointmentID}).Error; err != nil {
+				return err
+			}
+			reserved++
+		}
+		if len(open) > 0 || reserved > 0 {
+			fmt.Printf("migrated appointment slots: %d end times set, %d appointments reserved\n", len(open), reserved)
+		}
+		return nil
+	})
+}
+
+// migrateVaccineTypeCodes gives the built-in vaccine types created before
+// types had a code their code. Other types without a code are listed so the
+// clinic can set one; until then they do not count towards any schedule.
+func migrateVaccineTypeCodes(db *gorm.DB) error {
+	var types []entity.VaccineType
+	if err := db.Where("code IS NULL OR code = ''").Find(&types).Error; err != nil {
+		return err
+	}
+	for _, vt := range types {
+		code := ""
+		for _, seed := range seedVaccineTypes {
+			if seed.Name == vt.Name {
+				code = seed.Code
+			}
+		}
+		if code == "" {
+			fmt.Printf("vaccine type %d %q has no code\n", vt.ID, vt.Name)
+			continue
+		}
+		if err := db.Model(&vt).Update("code", code).Error; err != nil {
+			return err
+		}
+		fmt.Printf("vaccine type %d %q: code set to %s\n", vt.ID, vt.Name, code)
+	}
+	return nil
+}
+
+// migrateLabObservations copies the old fixed lab_results columns (hct, hb,
+// hb_typing and the DCIP / Anti-HIV check_results) into lab_observations and
+// drops the columns so the copy only runs once. check_results is renamed
+// with a legacy_ prefix.
+func migrateLabObservations(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		migrator := tx.Migrator()
+
+		if !migrator.HasColumn(&entity.LabResult{}, "hb") {
+			return nil
+		}
+
+		var tests []entity.LabTest
+		if err := tx.Preload("ReferenceRanges").Find(&tests).Error; err != nil {
+			return err
+		}
+		catalog := map[string]entity.LabTest{}
+		for _, t := range tests {
+			catalog[t.Code] = t
+		}
+
+		var rows []struct {
+			ID          uint
+			PregnancyID *uint
+			TestDate    time.Time
+			Hct         float64
+			Hb          float64
+			HbTyping    string
+			DcpName     string
+			AntiHivName string
+		}
+		query := tx.Table("lab_results").Select("lab_results.id, lab_results.pregnancy_id, lab_results.test_date, " +
+			"lab_results.hct, lab_results.hb, lab_results.hb_typing")
+		if migrator.HasTable("check_results") {
+			query = tx.Table("lab_results").Select("lab_results.id, lab_results.pregnancy_id, lab_results.test_date, " +
+				"lab_results.hct, lab_results.hb, lab_results.hb_typing, dcp.name AS dcp_name, hiv.name AS anti_hiv_name").
+				Joins("LEFT JOIN check_results dcp ON dcp.id = lab_results.dcp_result_id").
+				Joins("LEFT JOIN check_results hiv ON hiv.id = lab_results.anti_hiv_result_id")
+		}
+		if err := query.Find(&rows).Error; err != nil {
+			return err
+		}
+
+		copied := map[string]int{}
+		for _, r := range rows {
+			trimester := 0
+			if r.PregnancyID != nil {
+				var pregnancy entity.Pregnancy
+				if err := tx.Unscoped().First(&pregnancy, *r.PregnancyID).Error; err == nil {
+					if lmp := service.PregnancyLMP(pregnancy); !lmp.IsZero() {
+						trimester = service.Trimester(service.GestationalWeeks(lmp, r.TestDate))
+					}
+				}
+			}
+
+			resultID := r.ID
+			add := func(code string, number *float64, text string) error {
+				test, ok := catalog[code]
+				if !ok {
+					return fmt.Errorf("lab test %s is missing from the catalog", code)
+				}
+				testID := test.ID
+				obs := entity.LabObservation{
+					LabResultID: &resultID,
+					LabTestID:   &testID,
+					Code:        code,
+					ValueNumber: number,
+					ValueText:   text,
+					Unit:        test.Unit,
+				}
+				service.EvaluateObservation(test, &obs, trimester)
+				if err := tx.Create(&obs).Error; err != nil {
+					return err
+				}
+				copied[code]++
+				return nil
+			}
+
+			if r.Hb > 0 {
+				hb := r.Hb
+				if err := add(service.LabTestHb, &hb, ""); err != nil {
+					return err
+				}
+			}
+			if r.Hct > 0 {
+				hct := r.Hct
+				if err := add(service.LabTestHct, &hct, ""); err != nil {
+					return err
+				}
+			}
+			if r.HbTyping != "" {
+				if err := add(service.LabTestHbTyping, nil, r.HbTyping); err != nil {
+					return err
+				}
+			}
+			if v := service.NormalizeCheckResult(r.DcpName); v != "" {
+				if err := add(service.LabTestDcip, nil, v); err != nil {
+					return err
+				}
+			}
+			if v := service.NormalizeCheckResult(r.AntiHivName); v != "" {
+				if err := add(service.LabTestAntiHIV, nil, v); err != nil {
+					return err
+				}
+			}
+		}
+
+		// The columns are dropped for good, so every value they hold must have been copied.
+		// A mismatch (a negative Hb, a check result id without its check_results row) rolls
+		// the whole migration back and leaves the columns for the data to be fixed first.
+		legacy := []struct{ column, where, code string }{
+			{"hb", "hb IS NOT NULL AND hb <> 0", service.LabTestHb},
+			{"hct", "hct IS NOT NULL AND hct <> 0", service.LabTestHct},
+			{"hb_typing", "hb_typing IS NOT NULL AND hb_typing <> ''", service.LabTestHbTyping},
+			{"dcp_result_id", "dcp_result_id IS NOT NULL AND dcp_result_id <> 0", service.LabTestDcip},
+			{"anti_hiv_result_id", "anti_hiv_result_id IS NOT NULL AND anti_hiv_result_id <> 0", service.LabTestAntiHIV},
+		}
+		var summary []string
+		for _, l := range legacy {
+			if !migrator.HasColumn(&entity.LabResult{}, l.column) {
+				continue
+			}
+			var values int64
+			if err := tx.Table("lab_results").Where(l.where).Count(&values).Error; err != nil {
+				return err
+			}
+			if int(values) != copied[l.code] {
+				return fmt.Errorf("lab_results.%s holds %d values but only %d were copied to %s observations; fix those rows before upgrading",
+					l.column, values, copied[l.code], l.code)
+			}
+			summary = append(summary, fmt.Sprintf("%s %d", l.column, values))
+		}
+
+		for _, name := range []string{"fk_check_results_lab_results", "fk_lab_results_dcp_result", "fk_lab_results_anti_hiv_result"} {
+			if migrator.HasConstraint(&entity.LabResult{}, name) {
+				if err := migrator.DropConstraint(&entity.LabResult{}, name); err != nil {
+					return err
+				}
+			}
+		}
+		for _, column := range []string{"hct", "hb", "hb_typing", "dcp_result_id", "anti_hiv_result_id"} {
+			if migrator.HasColumn(&entity.LabResult{}, column) {
+				if err := migrator.DropColumn(&entity.LabResult{}, column); err != nil {
+					return err
+				}
+				// SQLite drops a column by rewriting the CREATE TABLE text and skips
+				// a column it does not find there, so make sure it is really gone
+				if migrator.HasColumn(&entity.LabResult{}, column) {
+					return fmt.Errorf("could not drop lab_results.%s", column)
+				}
+			}
+		}
+		// Dropping a column rebuilds the table in SQLite, so restore its indexes
+		if err := migrator.AutoMigrate(&entity.LabResult{}); err != nil {
+			return err
+		}
+		if migrator.HasTable("check_results") {
+			if err := migrator.RenameTable("check_results", "legacy_check_results"); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("migrated %d lab results to observations (%s)\n", len(rows), strings.Join(summary, ", "))
+		return nil
+	})
+}
+
+// legacyUploadTypes guesses the type of files uploaded before uploads were sniffed
+var legacyUploadTypes = map[string]string{
+	".pdf":  "application/pdf",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+}
+
+// migrateLabResultFiles moves the public lab_results.file_path uploads into the
+// configured object store under random keys, so they are only served through
+// the authorized download endpoint, and drops the column so the copy only runs
+// once. A file that cannot be read stops the migration before anything is
+// dropped. The old files are left in place for the operator to remove.
+func migrateLabResultFiles(db *gorm.DB, store service.ObjectStore) error {
+	var stored []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		migrator := tx.Migrator()
+
+		if !migrator.HasColumn(&entity.LabResult{}, "file_path") {
+			return nil
+		}
+
+		var rows []struct {
+			ID       uint
+			FilePath string
+			PID      *uint `gorm:"column:p_id"`
+		}
+		if err := tx.Table("lab_results").Select("lab_results.id, lab_results.file_path, pregnancies.p_id").
+			Joins("LEFT JOIN pregnancies ON pregnancies.id = lab_results.pregnancy_id").
+			Where("lab_results.file_path IS NOT NULL AND lab_results.file_path <> ''").
+			Find(&rows).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, r := range rows {
+			data, err := os.ReadFile(filepath.FromSlash(r.FilePath))
+			if err != nil {
+				return fmt.Errorf("lab result %d: %v; restore the file or clear file_path before upgrading", r.ID, err)
+			}
+
+			name := filepath.Base(filepath.FromSlash(r.FilePath))
+			// ไฟล์เก่าตั้งชื่อเป็น 20060102150405_<ชื่อเดิม>
+			if len(name) > 15 && name[14] == '_' {
+				name = name[15:]
+			}
+			contentType := legacyUploadTypes[strings.ToLower(filepath.Ext(name))]
+			if contentType == "" {
+				contentType = http.DetectContentType(data)
+			}
+
+			key, err := service.NewObjectKey("lab_results", contentType, now)
+			if err != nil {
+				return err
+			}
+			if err := store.Put(key, data, contentType); err != nil {
+				return fmt.Errorf("lab result %d: %v", r.ID, err)
+			}
+			stored = append(stored, key)
+
+			file := entity.StoredFile{
+				StorageKey:      key,
+				FileName:        service.SanitizeFilename(name),
+				ContentType:     contentType,
+				Size:            int64(len(data)),
+				PregnantWomanID: r.PID,
+				UploadedByRole:  "doctor",
+			}
+			if err := tx.Create(&file).Error; err != nil {
+				return err
+			}
+			if err := tx.Table("lab_results").Where("id = ?", r.ID).Update("stored_file_id", file.ID).Error; err != nil {
+				return err
+			}
+			fmt.Printf("lab result %d: %s copied to %s storage as %s\n", r.ID, r.FilePath, store.Name(), key)
+		}
+
+		if err := migrator.DropColumn(&entity.LabResult{}, "file_path"); err != nil {
+			return err
+		}
+		// Dropping a column rebuilds the table in SQLite, so restore its indexes
+		if err := migrator.AutoMigrate(&entity.LabResult{}); err != nil {
+			return err
+		}
+		fmt.Printf("migrated %d lab result files to stored files; the old files can now be removed\n", len(rows))
+		return nil
+	})
+	if err != nil {
+		// Nothing refers to the copies once the transaction is rolled back
+		for _, key := range stored {
+			store.Delete(key)
+		}
+	}
+	return err
+}
@@ -0,0 +1,177 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// legacyLabResult is lab_results as it was before observations: fixed hct, hb
+// and hb_typing columns and two links into check_results
+type legacyLabResult struct {
+	gorm.Model
+	PregnancyID     *uint
+	TestDate        time.Time
+	Hct             float64
+	Hb              float64
+	HbTyping        string
+	DcpResultID     *uint
+	DcpResult       *legacyCheckResult `gorm:"foreignKey:DcpResultID"`
+	AntiHivResultID *uint
+	AntiHivResult   *legacyCheckResult `gorm:"foreignKey:AntiHivResultID"`
+}
+
+func (legacyLabResult) TableName() string { return "lab_results" }
+
+type legacyCheckResult struct {
+	ID   uint
+	Name string
+}
+
+func (legacyCheckResult) TableName() string { return "check_results" }
+
+func openLegacyLabDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the old schema first, then the upgrade adds the new tables and columns
+	if err := db.AutoMigrate(&legacyCheckResult{}, &legacyLabResult{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&entity.Pregnancy{}, &entity.LabTest{}, &entity.LabReferenceRange{}, &entity.LabResult{}, &entity.LabObservation{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create([]legacyCheckResult{{ID: 1, Name: "ลบ"}, {ID: 2, Name: "Positive"}}).Error; err != nil {
+		t.Fatal(err)
+	}
+	seedLabTests(db)
+
+	pregnancy := entity.Pregnancy{Status: entity.PregnancyStatusActive, LMP: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)}
+	if err := db.Create(&pregnancy).Error; err != nil {
+		t.Fatal(err)
+	}
+	// 15 weeks: second trimester, where Hb below 10.5 is low
+	dcp, hiv := uint(1), uint(2)
+	legacy := legacyLabResult{PregnancyID: &pregnancy.ID, TestDate: time.Date(2026, 6, 14, 0, 0, 0, 0, time.UTC),
+		Hct: 31, Hb: 10.2, HbTyping: "AA", DcpResultID: &dcp, AntiHivResultID: &hiv}
+	if err := db.Create(&legacy).Error; err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestMigrateLabObservationsCopiesLegacyValues(t *testing.T) {
+	db := openLegacyLabDB(t)
+	if err := migrateLabObservations(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var observations []entity.LabObservation
+	db.Order("id").Find(&observations)
+	got := map[string]entity.LabObservation{}
+	for _, o := range observations {
+		got[o.Code] = o
+	}
+	if len(observations) != 5 {
+		t.Fatalf("%d observations: %+v", len(observations), observations)
+	}
+	if hb := got["HB"]; hb.ValueNumber == nil || *hb.ValueNumber != 10.2 || hb.Unit != "g/dL" || hb.Flag != entity.LabFlagLow {
+		t.Errorf("HB = %+v", hb)
+	}
+	if hct := got["HCT"]; hct.ValueNumber == nil || *hct.ValueNumber != 31 {
+		t.Errorf("HCT = %+v", hct)
+	}
+	if got["HB_TYPING"].ValueText != "AA" || got["DCIP"].ValueText != "Negative" || got["ANTI_HIV"].ValueText != "Positive" {
+		t.Errorf("text values = %q, %q, %q", got["HB_TYPING"].ValueText, got["DCIP"].ValueText, got["ANTI_HIV"].ValueText)
+	}
+
+	migrator := db.Migrator()
+	for _, column := range []string{"hct", "hb", "hb_typing", "dcp_result_id", "anti_hiv_result_id"} {
+		if migrator.HasColumn(&entity.LabResult{}, column) {
+			t.Errorf("lab_results.%s was not dropped", column)
+		}
+	}
+	if migrator.HasTable("check_results") || !migrator.HasTable("legacy_check_results") {
+		t.Error("check_results was not renamed to legacy_check_results")
+	}
+
+	// the columns are gone, so a second start copies nothing
+	if err := migrateLabObservations(db); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	db.Model(&entity.LabObservation{}).Count(&count)
+	if count != 5 {
+		t.Fatalf("%d observations after running the migration again", count)
+	}
+}
+
+func TestMigrateLabObservationsRollsBackWhenValuesAreLeftBehind(t *testing.T) {
+	tests := []struct {
+		name   string
+		sql    string
+		column string
+	}{
+		{"check result without its row", "UPDATE lab_results SET anti_hiv_result_id = 99", "anti_hiv_result_id"},
+		{"check result without a name", "INSERT INTO check_results (id, name) VALUES (3, ' '); UPDATE lab_results SET dcp_result_id = 3", "dcp_result_id"},
+		{"negative Hb", "UPDATE lab_results SET hb = -1", "hb"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openLegacyLabDB(t)
+			for _, sql := range strings.Split(tt.sql, "; ") {
+				if err := db.Exec(sql).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := migrateLabObservations(db)
+			if err == nil || !strings.Contains(err.Error(), "lab_results."+tt.column+" holds") {
+				t.Fatalf("err = %v, want a count mismatch on %s", err, tt.column)
+			}
+
+			var count int64
+			db.Model(&entity.LabObservation{}).Count(&count)
+			if count != 0 {
+				t.Errorf("%d observations kept after the rollback", count)
+			}
+			var hb float64
+			db.Table("lab_results").Select("hb").Row().Scan(&hb)
+			if hb == 0 || !db.Migrator().HasColumn(&entity.LabResult{}, tt.column) {
+				t.Error("legacy columns were dropped although the copy did not match")
+			}
+			if !db.Migrator().HasTable("check_results") {
+				t.Error("check_results was renamed although the migration rolled back")
+			}
+		})
+	}
+}
+
+// A column added by hand (unquoted, last in the CREATE TABLE text) is one the
+// SQLite migrator cannot drop: the migration must fail rather than copy twice
+func TestMigrateLabObservationsFailsWhenAColumnStays(t *testing.T) {
+	db := openLegacyLabDB(t)
+	if err := db.Exec("ALTER TABLE lab_results DROP COLUMN hb_typing").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec("ALTER TABLE lab_results ADD COLUMN hb_typing text").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	err := migrateLabObservations(db)
+	if err == nil || !strings.Contains(err.Error(), "could not drop lab_results.hb_typing") {
+		t.Fatalf("err = %v", err)
+	}
+	var count int64
+	db.Model(&entity.LabObservation{}).Count(&count)
+	if count != 0 || !db.Migrator().HasColumn(&entity.LabResult{}, "hb") {
+		t.Fatalf("%d observations and hb dropped = %v after a failed migration", count, !db.Migrator().HasColumn(&entity.LabResult{}, "hb"))
+	}
+}
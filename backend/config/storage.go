@@ -0,0 +1,18 @@
+package config
+
+import "github.com/bestiesmile1845/Projecteiei/service"
+
+var storage service.ObjectStore
+
+func Storage() service.ObjectStore {
+	return storage
+}
+
+// SetupStorage opens the object store configured by STORAGE_BACKEND
+func SetupStorage() {
+	store, err := service.ObjectStoreFromEnv()
+	if err != nil {
+		panic("failed to set up file storage: " + err.Error())
+	}
+	storage = store
+}
@@ -0,0 +1,27 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// currentActor returns the role and ID of the logged in user set by middlewares.Authorizes
+func currentActor(c *gin.Context) (string, uint) {
+	role := c.GetString("role")
+	var id uint
+	if val, ok := c.Get("userID"); ok {
+		id, _ = val.(uint)
+	}
+	return role, id
+}
+
+// requireDoctor stops the request unless the logged in user is a doctor
+func requireDoctor(c *gin.Context) (uint, bool) {
+	role, id := currentActor(c)
+	if role != "doctor" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Doctor access only"})
+		return 0, false
+	}
+	return id, true
+}
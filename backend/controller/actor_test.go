@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// callAs runs a handler for a logged in user the way middlewares.Authorizes leaves the context
+func callAs(role string, userID uint, handler gin.HandlerFunc, params gin.Params, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = params
+	c.Set("role", role)
+	c.Set("userID", userID)
+	handler(c)
+	return w
+}
+
+// Mothers and doctors live in separate tables, so a mother's ID is also some
+// doctor's ID: doctor endpoints must check the role, not only the ID
+func TestDoctorOnlyHandlersRefuseMothers(t *testing.T) {
+	id := gin.Params{{Key: "id", Value: "1"}, {Key: "patientId", Value: "1"}}
+	handlers := map[string]gin.HandlerFunc{
+		"ChangePregnancyStatus":   ChangePregnancyStatus,
+		"DoctorCreateAppointment": DoctorCreateAppointment,
+		"DoctorCreatePregnancy":   DoctorCreatePregnancy,
+		"EndPregnancy":            EndPregnancy,
+		"GetPatientAppointments":  GetPatientAppointments,
+		"ReopenPregnancy":         ReopenPregnancy,
+		"UpdateAppointmentStatus": UpdateAppointmentStatus,
+		"UpdateFetusCount":        UpdateFetusCount,
+	}
+	for name, handler := range handlers {
+		w := callAs("pregnant", 1, handler, id, `{"status":"Completed"}`)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s answered a mother with %d: %s", name, w.Code, w.Body)
+		}
+	}
+}
+
+func TestMotherOnlyHandlersRefuseDoctors(t *testing.T) {
+	handlers := map[string]gin.HandlerFunc{
+		"GetMyNotifications":  GetMyNotifications,
+		"UpdateMyNotifyToken": UpdateMyNotifyToken,
+	}
+	for name, handler := range handlers {
+		w := callAs("doctor", 1, handler, nil, `{}`)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s answered a doctor with %d: %s", name, w.Code, w.Body)
+		}
+	}
+}
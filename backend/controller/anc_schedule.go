@@ -0,0 +1,477 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/config"
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AncContactPlan is one contact of a pregnancy's ANC schedule with what is due at it
+type AncContactPlan struct {
+	ContactNo   int                 `json:"contact_no"`
+	WeekFrom    int                 `json:"week_from"`
+	WeekTo      int                 `json:"week_to"`
+	TargetWeek  int                 `json:"target_week"`
+	WindowStart time.Time           `json:"window_start"`
+	WindowEnd   time.Time           `json:"window_end"`
+	Status      string              `json:"status"` // Upcoming, Due, Completed, Missed
+	Checks      []string            `json:"checks"`
+	Labs        []string            `json:"labs"`
+	Appointment *entity.Appointment `json:"appointment"`
+}
+
+// loadAncTemplate returns the template of the pregnancy, or the default one
+func loadAncTemplate(db *gorm.DB, pregnancy *entity.Pregnancy) (*entity.AncScheduleTemplate, error) {
+	var template entity.AncScheduleTemplate
+	query := db.Preload("Contacts", func(db *gorm.DB) *gorm.DB {
+		return db.Order("contact_no ASC")
+	})
+	var err error
+	if pregnancy.AncScheduleTemplateID != nil {
+		err = query.First(&template, *pregnancy.AncScheduleTemplateID).Error
+	} else {
+		err = query.Where("is_default = ?", true).First(&template).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// planAncAppointments creates (or moves) one planned appointment per ANC contact and
+// reserves the doctor's time for it, taking the next free slot when the planned time is booked.
+// Appointments that were already rescheduled by hand, or are no longer Scheduled, are left alone.
+func planAncAppointments(tx *gorm.DB, pregnancy *entity.Pregnancy, doctorID *uint) error {
+	lmp := service.PregnancyLMP(*pregnancy)
+	if lmp.IsZero() {
+		return nil
+	}
+
+	template, err := loadAncTemplate(tx, pregnancy)
+	if err != nil {
+		// No template configured: nothing to plan
+		return nil
+	}
+	if pregnancy.AncScheduleTemplateID == nil {
+		pregnancy.AncScheduleTemplateID = &template.ID
+		if err := tx.Model(pregnancy).Update("anc_schedule_template_id", template.ID).Error; err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	for _, contact := range template.Contacts {
+		date, ok := service.PlanAncContactDate(lmp, contact, now)
+
+		var existing entity.Appointment
+		err := tx.Where("pregnancy_id = ? AND anc_contact_id = ?", pregnancy.ID, contact.ID).First(&existing).Error
+		if err == nil {
+			if existing.Status != entity.AppointmentStatusScheduled || existing.RescheduleCount > 0 {
+				continue
+			}
+			if err := releaseSlot(tx, existing.ID); err != nil {
+				return err
+			}
+			if ok {
+				start, err := reserveNextFreeSlot(tx, existing.DoctorID, date, existing.ID)
+				if err != nil {
+					return err
+				}
+				err = tx.Model(&existing).Updates(map[string]interface{}{
+					"appointment_date": start,
+					"sequence":         gorm.Expr("sequence + 1"),
+				}).Error
+			} else {
+				// After re-dating the contact window may already be over
+				err = tx.Model(&existing).Updates(map[string]interface{}{
+					"status":        entity.AppointmentStatusCancelled,
+					"cancel_reason": "ANC window passed after re-dating",
+					"cancelled_at":  now,
+					"sequence":      gorm.Expr("sequence + 1"),
+				}).Error
+			}
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		contactID := contact.ID
+		appt := entity.Appointment{
+			AppointmentDate: date,
+			Title:           fmt.Sprintf("ฝากครรภ์ครั้งที่ %d (อายุครรภ์ %d สัปดาห์)", contact.ContactNo, contact.TargetWeek),
+			Status:          entity.AppointmentStatusScheduled,
+			Notes:           contact.Description,
+			PregnantWomanID: pregnancy.PregnantWomanID,
+			DoctorID:        doctorID,
+			PregnancyID:     &pregnancy.ID,
+			AncContactID:    &contactID,
+		}
+		if err := tx.Create(&appt).Error; err != nil {
+			return err
+		}
+		start, err := reserveNextFreeSlot(tx, appt.DoctorID, date, appt.ID)
+		if err != nil {
+			return err
+		}
+		if !start.Equal(date) {
+			if err := tx.Model(&appt).Update("appointment_date", start).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// buildAncSchedule combines the template, visits and planned appointments of a pregnancy
+func buildAncSchedule(db *gorm.DB, pregnancy *entity.Pregnancy, now time.Time) ([]AncContactPlan, error) {
+	lmp := service.PregnancyLMP(*pregnancy)
+	if lmp.IsZero() {
+		return []AncContactPlan{}, nil
+	}
+
+	template, err := loadAncTemplate(db, pregnancy)
+	if err != nil {
+		return []AncContactPlan{}, nil
+	}
+
+	var visits []entity.AntenatalVisit
+	if err := db.Where("pregnancy_id = ?", pregnancy.ID).Find(&visits).Error; err != nil {
+		return nil, err
+	}
+	visitDates := make([]time.Time, 0, len(visits))
+	for _, v := range visits {
+		visitDates = append(visitDates, v.VisitDate)
+	}
+
+	var appointments []entity.Appointment
+	if err := db.Where("pregnancy_id = ? AND anc_contact_id IS NOT NULL", pregnancy.ID).Find(&appointments).Error; err != nil {
+		return nil, err
+	}
+	byContact := map[uint]entity.Appointment{}
+	for _, a := range appointments {
+		byContact[*a.AncContactID] = a
+	}
+
+	plans := []AncContactPlan{}
+	for _, contact := range template.Contacts {
+		from, to, _ := service.AncContactWindow(lmp, contact)
+		plan := AncContactPlan{
+			ContactNo:   contact.ContactNo,
+			WeekFrom:    contact.WeekFrom,
+			WeekTo:      contact.WeekTo,
+			TargetWeek:  contact.TargetWeek,
+			WindowStart: from,
+			WindowEnd:   to.AddDate(0, 0, -1),
+			Status:      service.AncContactStatus(lmp, contact, visitDates, now),
+			Checks:      service.SplitList(contact.Checks),
+			Labs:        service.SplitList(contact.Labs),
+		}
+		if appt, ok := byContact[contact.ID]; ok {
+			plan.Appointment = &appt
+		}
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
+
+// GET /anc-schedule-templates
+func ListAncScheduleTemplates(c *gin.Context) {
+	db := config.DB()
+
+	var templates []entity.AncScheduleTemplate
+	if err := db.Preload("Contacts", func(db *gorm.DB) *gorm.DB {
+		return db.Order("contact_no ASC")
+	}).Find(&templates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": templates})
+}
+
+// POST /doctor/anc-schedule-templates - Add an ANC schedule template with its contacts
+func CreateAncScheduleTemplate(c *gin.Context) {
+	if _, ok := requireDoctor(c); !ok {
+		return
+	}
+
+	var template entity.AncScheduleTemplate
+	if err := c.ShouldBindJSON(&template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := service.ValidateAncTemplate(&template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := config.DB()
+
+	var count int64
+	db.Model(&entity.AncScheduleTemplate{}).Where("code = ?", template.Code).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Template code already exists"})
+		return
+	}
+
+	template.ID = 0
+	for i := range template.Contacts {
+		template.Contacts[i].ID = 0
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&template).Error; err != nil {
+			return err
+		}
+		return setDefaultAncTemplate(tx, &template)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "ANC schedule template created", "data": template})
+}
+
+// PUT /doctor/anc-schedule-templates/:id - Update a template. Contacts are matched by
+// contact_no so planned appointments keep their contact; contacts left out are removed.
+// Pregnancies already planned keep their appointments until they are re-dated.
+func UpdateAncScheduleTemplate(c *gin.Context) {
+	if _, ok := requireDoctor(c); !ok {
+		return
+	}
+
+	db := config.DB()
+
+	var template entity.AncScheduleTemplate
+	if err := db.Preload("Contacts").First(&template, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "ANC schedule template not found"})
+		return
+	}
+	existing := map[int]entity.AncContact{}
+	for _, contact := range template.Contacts {
+		existing[contact.ContactNo] = contact
+	}
+
+	model, code := template.Model, template.Code
+	template.Contacts = nil
+	if err := c.ShouldBindJSON(&template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	template.Model, template.Code = model, code
+	if err := service.ValidateAncTemplate(&template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		kept := map[int]bool{}
+		for i := range template.Contacts {
+			contact := &template.Contacts[i]
+			contact.ID = 0
+			if old, ok := existing[contact.ContactNo]; ok {
+				contact.Model = old.Model
+			}
+			contact.AncScheduleTemplateID = &template.ID
+			kept[contact.ContactNo] = true
+		}
+		for no, old := range existing {
+			if !kept[no] {
+				if err := tx.Delete(&old).Error; err != nil {
+					return err
+				}
+			}
+		}
+		if err := tx.Session(&gorm.Session{FullSaveAssociations: true}).Save(&template).Error; err != nil {
+			return err
+		}
+		return setDefaultAncTemplate(tx, &template)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ANC schedule template updated", "data": template})
+}
+
+// DELETE /doctor/anc-schedule-templates/:id - Remove a template no pregnancy uses
+func DeleteAncScheduleTemplate(c *gin.Context) {
+	if _, ok := requireDoctor(c); !ok {
+		return
+	}
+
+	db := config.DB()
+
+	var template entity.AncScheduleTemplate
+	if err := db.First(&template, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "ANC schedule template not found"})
+		return
+	}
+	if template.IsDefault {
+		c.JSON(http.StatusConflict, gin.H{"error": "Make another template the default before deleting this one"})
+		return
+	}
+	var used int64
+	db.Model(&entity.Pregnancy{}).Where("anc_schedule_template_id = ?", template.ID).Count(&used)
+	if used > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Template is used by %d pregnancies", used)})
+		return
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("anc_schedule_template_id = ?", template.ID).Delete(&entity.AncContact{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&template).Error
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ANC schedule template deleted"})
+}
+
+// setDefaultAncTemplate keeps a single default template
+func setDefaultAncTemplate(tx *gorm.DB, template *entity.AncScheduleTemplate) error {
+	if !template.IsDefault {
+		return nil
+	}
+	return tx.Model(&entity.AncScheduleTemplate{}).Where("id <> ? AND is_default = ?", template.ID, true).Update("is_default", false).Error
+}
+
+// GET /pregnancies/:id/anc-schedule - Planned contacts with checks/labs due and their status
+func GetAncSchedule(c *gin.Context) {
+	id := c.Param("id")
+	db := config.DB()
+
+	var pregnancy entity.Pregnancy
+	if err := db.First(&pregnancy, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pregnancy not found"})
+		return
+	}
+
+	plans, err := buildAncSchedule(db, &pregnancy, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": plans})
+}
+
+// PUT /doctor/pregnancy/:id/edc - Re-date the pregnancy (e.g. after dating ultrasound) and re-plan visits
+func RedatePregnancy(c *gin.Context) {
+	id := c.Param("id")
+	var input struct {
+		EDC time.Time `json:"edc"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.EDC.IsZero() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "edc is required"})
+		return
+	}
+
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+
+	db := config.DB()
+
+	pregnancy, err := requireActivePregnancy(db, id)
+	if err != nil {
+		respondPregnancyError(c, err)
+		return
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{
+			"edc": input.EDC,
+			"lmp": input.EDC.AddDate(0, 0, -280),
+		}
+		if err := tx.Model(pregnancy).Updates(updates).Error; err != nil {
+			return err
+		}
+		return planAncAppointments(tx, pregnancy, &doctorID)
+	}); err != nil {
+		respondAncPlanError(c, err)
+		return
+	}
+
+	plans, _ := buildAncSchedule(db, pregnancy, time.Now())
+	c.JSON(http.StatusOK, gin.H{"message": "Pregnancy re-dated", "data": gin.H{"pregnancy": pregnancy, "anc_schedule": plans}})
+}
+
+// respondAncPlanError answers a failed ANC planning; a full calendar is a conflict the doctor can resolve
+func respondAncPlanError(c *gin.Context, err error) {
+	if errors.Is(err, errSlotTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": "No free slot for a planned ANC visit within a week of its date; add availability and try again"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// GET /doctor/anc-schedule/missed - Active pregnancies with ANC contacts that were missed
+func GetMissedAncContacts(c *gin.Context) {
+	if _, ok := requireDoctor(c); !ok {
+		return
+	}
+
+	db := config.DB()
+
+	var pregnancies []entity.Pregnancy
+	if err := db.Preload("PregnantWoman", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id", "full_name", "hn", "phone_number")
+	}).Where("status = ?", entity.PregnancyStatusActive).Find(&pregnancies).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	type missedRow struct {
+		PregnancyID   uint                  `json:"pregnancy_id"`
+		PregnantWoman *entity.PregnantWoman `json:"pregnant_woman"`
+		Missed        []AncContactPlan      `json:"missed"`
+	}
+
+	now := time.Now()
+	rows := []missedRow{}
+	for i := range pregnancies {
+		plans, err := buildAncSchedule(db, &pregnancies[i], now)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var missed []AncContactPlan
+		for _, plan := range plans {
+			if plan.Status == service.AncContactMissed {
+				missed = append(missed, plan)
+			}
+		}
+		if len(missed) > 0 {
+			rows = append(rows, missedRow{
+				PregnancyID:   pregnancies[i].ID,
+				PregnantWoman: pregnancies[i].PregnantWoman,
+				Missed:        missed,
+			})
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": rows})
+}
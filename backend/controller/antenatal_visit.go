@@ -15,7 +15,7 @@ func GetAntenatalVisitsByPregnancyID(c *gin.Context) {
 
 	db := config.DB()
 
-	if err := db.Where("pregnancy_id = ?", id).Find(&visits).Error; err != nil {
+	if err := db.Preload("FetalObservations.Fetus").Preload("Addenda").Where("pregnancy_id = ?", id).Find(&visits).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
@@ -25,6 +25,11 @@ func GetAntenatalVisitsByPregnancyID(c *gin.Context) {
 
 // POST /antenatal-visits
 func CreateAntenatalVisit(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+
 	var visit entity.AntenatalVisit
 
 	if err := c.ShouldBindJSON(&visit); err != nil {
@@ -34,8 +39,24 @@ func CreateAntenatalVisit(c *gin.Context) {
 
 	db := config.DB()
 
-	if err := db.Create(&visit).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	// No new visits on a closed pregnancy
+	if _, err := requireActivePregnancy(db, visit.PregnancyID); err != nil {
+		respondPregnancyError(c, err)
+		return
+	}
+
+	for i := range visit.FetalObservations {
+		if err := validateFetusOfPregnancy(db, &visit.FetalObservations[i].FetusID, visit.PregnancyID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// Saved as a draft until the doctor signs it
+	prepareDraftVisit(&visit, doctorID)
+
+	// Link to the appointment the mother checked in for
+	if !createVisitWithAppointment(c, db, &visit) {
 		return
 	}
 
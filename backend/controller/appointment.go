@@ -0,0 +1,328 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/config"
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// parseAppointmentDate accepts ISO date-time ("2025-11-25T09:00:00Z") or a plain date ("2025-11-25")
+func parseAppointmentDate(value string) (time.Time, error) {
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		date, err = time.Parse("2006-01-02", value)
+		if err != nil {
+			return time.Time{}, errors.New("Invalid date format")
+		}
+	}
+	return date.UTC(), nil
+}
+
+// preloadAppointmentDoctor loads only the public fields of the doctor
+func preloadAppointmentDoctor(db *gorm.DB) *gorm.DB {
+	return db.Preload("Doctor", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id", "full_name", "phone_number", "email")
+	})
+}
+
+// attachNextAppointment sets patient.Appointment to her next scheduled appointment
+func attachNextAppointment(db *gorm.DB, patient *entity.PregnantWoman) {
+	var next entity.Appointment
+	if err := preloadAppointmentDoctor(db).
+		Where("pregnant_woman_id = ? AND status IN ? AND appointment_date >= ?",
+			patient.ID, []string{entity.AppointmentStatusScheduled, entity.AppointmentStatusCheckedIn}, time.Now()).
+		Order("appointment_date ASC").
+		First(&next).Error; err == nil {
+		patient.Appointment = &next
+	}
+}
+
+// findAppointmentForActor loads an appointment and makes sure a mother only touches her own
+func findAppointmentForActor(c *gin.Context, db *gorm.DB, id string) (*entity.Appointment, bool) {
+	var appt entity.Appointment
+	if err := db.First(&appt, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Appointment not found"})
+		return nil, false
+	}
+
+	role, userID := currentActor(c)
+	if role == "pregnant" && (appt.PregnantWomanID == nil || *appt.PregnantWomanID != userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not your appointment"})
+		return nil, false
+	}
+	return &appt, true
+}
+
+// listAppointments filters a base query to upcoming or past appointments
+func listAppointments(c *gin.Context, query *gorm.DB) {
+	now := time.Now()
+	open := []string{entity.AppointmentStatusScheduled, entity.AppointmentStatusCheckedIn}
+
+	switch c.DefaultQuery("when", "upcoming") {
+	case "upcoming":
+		query = query.Where("appointment_date >= ? AND status IN ?", now, open).Order("appointment_date ASC")
+	case "past":
+		query = query.Where("appointment_date < ? OR status NOT IN ?", now, open).Order("appointment_date DESC")
+	case "all":
+		query = query.Order("appointment_date DESC")
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "when must be upcoming, past or all"})
+		return
+	}
+
+	var appointments []entity.Appointment
+	if err := preloadAppointmentDoctor(query).Find(&appointments).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{"data": []entity.Appointment{}})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": appointments})
+}
+
+// GET /appointments?when=upcoming|past|all - Appointments of the logged in mother or doctor
+func GetMyAppointments(c *gin.Context) {
+	role, userID := currentActor(c)
+	db := config.DB()
+
+	query := db.Model(&entity.Appointment{})
+	if role == "doctor" {
+		query = query.Preload("PregnantWoman", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id", "full_name", "hn", "phone_number")
+		}).Where("doctor_id = ?", userID)
+	} else {
+		query = query.Where("pregnant_woman_id = ?", userID)
+	}
+
+	listAppointments(c, query)
+}
+
+// GET /doctor/patient/:patientId/appointments?when=upcoming|past|all
+func GetPatientAppointments(c *gin.Context) {
+	if _, ok := requireDoctor(c); !ok {
+		return
+	}
+
+	patientId := c.Param("patientId")
+	db := config.DB()
+
+	listAppointments(c, db.Model(&entity.Appointment{}).Where("pregnant_woman_id = ?", patientId))
+}
+
+// PUT /appointments/:id/reschedule
+func RescheduleAppointment(c *gin.Context) {
+	id := c.Param("id")
+	var input struct {
+		AppointmentDate string `json:"appointment_date"`
+		Location        string `json:"location"`
+		Reason          string `json:"reason"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	date, err := parseAppointmentDate(input.AppointmentDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if date.Before(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "New appointment date must be in the future"})
+		return
+	}
+
+	db := config.DB()
+
+	appt, ok := findAppointmentForActor(c, db, id)
+	if !ok {
+		return
+	}
+
+	if !service.IsAppointmentOpen(appt.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only scheduled appointments can be rescheduled"})
+		return
+	}
+
+	// Mothers have to stay within the booking policy
+	if role, _ := currentActor(c); role == "pregnant" {
+		now := time.Now()
+		if err := service.CheckCancelWindow(appt.AppointmentDate, now); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := service.CheckBookingWindow(date, now); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// The new time must be a free slot of the doctor, the same as a new booking
+	slot := service.Slot{Start: date, End: date.Add(service.DefaultSlotMinutes * time.Minute)}
+	if appt.DoctorID != nil {
+		slots, err := freeSlots(db, *appt.DoctorID, date, date.Add(time.Minute), appt.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(slots) == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "This time slot is not available"})
+			return
+		}
+		slot = slots[0]
+	} else if role, _ := currentActor(c); role == "pregnant" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This appointment has no doctor; please contact the clinic to move it"})
+		return
+	}
+
+	appt.AppointmentDate = slot.Start
+	appt.RescheduleCount++
+	appt.Sequence++
+	if input.Location != "" {
+		appt.Location = input.Location
+	} else if slot.Location != "" {
+		appt.Location = slot.Location
+	}
+	if input.Reason != "" {
+		appt.Notes = input.Reason
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := releaseSlot(tx, appt.ID); err != nil {
+			return err
+		}
+		if err := reserveSlot(tx, appt.DoctorID, slot.Start, slot.End, appt.ID); err != nil {
+			return err
+		}
+		return tx.Save(appt).Error
+	})
+	if errors.Is(err, errSlotTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Appointment rescheduled", "data": appt})
+}
+
+// POST /appointments/:id/cancel
+func CancelAppointment(c *gin.Context) {
+	id := c.Param("id")
+	var input struct {
+		Reason string `json:"reason"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := config.DB()
+
+	appt, ok := findAppointmentForActor(c, db, id)
+	if !ok {
+		return
+	}
+
+	if err := service.ValidateAppointmentTransition(appt.Status, entity.AppointmentStatusCancelled); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	if role, _ := currentActor(c); role == "pregnant" {
+		if err := service.CheckCancelWindow(appt.AppointmentDate, now); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	appt.Status = entity.AppointmentStatusCancelled
+	appt.CancelReason = input.Reason
+	appt.CancelledAt = &now
+	appt.Sequence++
+
+	// Cancelling gives the slot back to other mothers
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := releaseSlot(tx, appt.ID); err != nil {
+			return err
+		}
+		return tx.Save(appt).Error
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Appointment cancelled", "data": appt})
+}
+
+// PUT /doctor/appointments/:id/status - Mark an appointment checked-in, completed or no-show
+func UpdateAppointmentStatus(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+	var input struct {
+		Status string `json:"status"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Status == entity.AppointmentStatusCancelled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Use POST /appointments/:id/cancel to cancel"})
+		return
+	}
+
+	db := config.DB()
+
+	var appt entity.Appointment
+	if err := db.First(&appt, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Appointment not found"})
+		return
+	}
+
+	// Any staff member may check a mother in (as with the QR scan), but only
+	// her own doctor closes the appointment as completed or no-show
+	if input.Status != entity.AppointmentStatusCheckedIn && appt.DoctorID != nil && *appt.DoctorID != doctorID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Appointment is booked with another doctor"})
+		return
+	}
+
+	if err := service.ValidateAppointmentTransition(appt.Status, input.Status); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	switch input.Status {
+	case entity.AppointmentStatusCheckedIn:
+		// Check-in hands out a queue number
+		respondCheckIn(c, &appt, checkInAppointment(db, &appt, now))
+		return
+	case entity.AppointmentStatusCompleted:
+		appt.CompletedAt = &now
+	}
+	appt.Status = input.Status
+	appt.Sequence++
+
+	if err := db.Save(&appt).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Appointment status updated", "data": appt})
+}
@@ -0,0 +1,477 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/config"
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errSlotTaken = errors.New("This time slot is already booked")
+
+// isUniqueViolation reports whether err comes from a UNIQUE index
+func isUniqueViolation(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+// reserveSlot holds the doctor's time [start, end) for an appointment. The slot is
+// written first, so a concurrent booking waits for this transaction, and the
+// reservation fails when it overlaps any other reservation of the doctor.
+func reserveSlot(tx *gorm.DB, doctorID *uint, start, end time.Time, appointmentID uint) error {
+	if doctorID == nil {
+		return nil
+	}
+	if !end.After(start) {
+		end = start.Add(service.DefaultSlotMinutes * time.Minute)
+	}
+	slot := entity.AppointmentSlot{
+		DoctorID:      doctorID,
+		SlotStart:     start.UTC(),
+		SlotEnd:       end.UTC(),
+		AppointmentID: &appointmentID,
+	}
+	if err := tx.Create(&slot).Error; err != nil {
+		if isUniqueViolation(err) {
+			return errSlotTaken
+		}
+		return err
+	}
+	var overlapping int64
+	if err := tx.Model(&entity.AppointmentSlot{}).
+		Where("doctor_id = ? AND id <> ? AND slot_start < ? AND slot_end > ?", *doctorID, slot.ID, slot.SlotEnd, slot.SlotStart).
+		Count(&overlapping).Error; err != nil {
+		return err
+	}
+	if overlapping > 0 {
+		// Undo the insert so the caller can try another time in the same transaction
+		if err := tx.Unscoped().Delete(&slot).Error; err != nil {
+			return err
+		}
+		return errSlotTaken
+	}
+	return nil
+}
+
+// maxSlotSearchDays is how far reserveNextFreeSlot looks for a free time
+const maxSlotSearchDays = 7
+
+// reserveNextFreeSlot reserves the doctor's first free time at or after at: a
+// published slot, or for a doctor without published hours that day a
+// default-length slot until AncLastPlannedHour. The search moves on to the
+// next days when a day is full. It returns the reserved start.
+func reserveNextFreeSlot(tx *gorm.DB, doctorID *uint, at time.Time, appointmentID uint) (time.Time, error) {
+	if doctorID == nil {
+		return at, nil
+	}
+	length := service.DefaultSlotMinutes * time.Minute
+	for day := 0; day < maxSlotSearchDays; day++ {
+		from := at
+		local := at.In(service.ClinicLocation)
+		midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, service.ClinicLocation).AddDate(0, 0, day)
+		if day > 0 {
+			from = midnight.Add(time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute)
+		}
+		next := midnight.AddDate(0, 0, 1)
+
+		published, err := publishedSlots(tx, *doctorID, midnight, next)
+		if err != nil {
+			return time.Time{}, err
+		}
+		var candidates []service.Slot
+		if len(published) > 0 {
+			for _, s := range published {
+				if !s.Start.Before(from) {
+					candidates = append(candidates, s)
+				}
+			}
+		} else {
+			last := midnight.Add(service.AncLastPlannedHour * time.Hour)
+			for start := from; !start.After(last); start = start.Add(length) {
+				candidates = append(candidates, service.Slot{Start: start.UTC(), End: start.Add(length).UTC()})
+			}
+		}
+
+		for _, s := range candidates {
+			err := reserveSlot(tx, doctorID, s.Start, s.End, appointmentID)
+			if err == nil {
+				return s.Start, nil
+			}
+			if !errors.Is(err, errSlotTaken) {
+				return time.Time{}, err
+			}
+		}
+	}
+	return time.Time{}, errSlotTaken
+}
+
+// releaseSlot frees the doctor's time held by an appointment
+func releaseSlot(tx *gorm.DB, appointmentID uint) error {
+	return tx.Unscoped().Where("appointment_id = ?", appointmentID).Delete(&entity.AppointmentSlot{}).Error
+}
+
+// GET /doctor/availability - Weekly templates and upcoming exceptions of the logged in doctor
+func GetMyAvailability(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+	db := config.DB()
+
+	var templates []entity.DoctorAvailability
+	db.Where("doctor_id = ?", doctorID).Order("weekday ASC, start_time ASC").Find(&templates)
+
+	var exceptions []entity.AvailabilityException
+	db.Where("doctor_id = ? AND date >= ?", doctorID, time.Now().AddDate(0, 0, -1)).Order("date ASC").Find(&exceptions)
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"templates": templates, "exceptions": exceptions}})
+}
+
+// POST /doctor/availability - Add a weekly availability template
+func CreateAvailability(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+
+	var input entity.DoctorAvailability
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Weekday < 0 || input.Weekday > 6 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "weekday must be between 0 (Sunday) and 6 (Saturday)"})
+		return
+	}
+	if err := service.ValidateAvailabilityHours(input.StartTime, input.EndTime, input.SlotMinutes); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.SlotMinutes == 0 {
+		input.SlotMinutes = service.DefaultSlotMinutes
+	}
+
+	input.ID = 0
+	input.DoctorID = &doctorID
+
+	db := config.DB()
+
+	if err := db.Create(&input).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Availability created", "data": input})
+}
+
+// DELETE /doctor/availability/:id
+func DeleteAvailability(c *gin.Context) {
+	id := c.Param("id")
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+	db := config.DB()
+
+	if tx := db.Where("id = ? AND doctor_id = ?", id, doctorID).Delete(&entity.DoctorAvailability{}); tx.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Availability not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Availability deleted"})
+}
+
+// POST /doctor/availability/exceptions - Day off or special hours on a date
+func CreateAvailabilityException(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Date        string `json:"date"` // "2025-11-25"
+		Unavailable bool   `json:"unavailable"`
+		StartTime   string `json:"start_time"`
+		EndTime     string `json:"end_time"`
+		SlotMinutes int    `json:"slot_minutes"`
+		Location    string `json:"location"`
+		Reason      string `json:"reason"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	date, err := time.ParseInLocation("2006-01-02", input.Date, service.ClinicLocation)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format"})
+		return
+	}
+	if !input.Unavailable {
+		if err := service.ValidateAvailabilityHours(input.StartTime, input.EndTime, input.SlotMinutes); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	exception := entity.AvailabilityException{
+		DoctorID:    &doctorID,
+		Date:        date.UTC(),
+		Unavailable: input.Unavailable,
+		StartTime:   input.StartTime,
+		EndTime:     input.EndTime,
+		SlotMinutes: input.SlotMinutes,
+		Location:    input.Location,
+		Reason:      input.Reason,
+	}
+
+	db := config.DB()
+
+	if err := db.Create(&exception).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Availability exception created", "data": exception})
+}
+
+// DELETE /doctor/availability/exceptions/:id
+func DeleteAvailabilityException(c *gin.Context) {
+	id := c.Param("id")
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+	db := config.DB()
+
+	if tx := db.Where("id = ? AND doctor_id = ?", id, doctorID).Delete(&entity.AvailabilityException{}); tx.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Availability exception not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Availability exception deleted"})
+}
+
+// publishedSlots generates the doctor's slots in [from, to) from the weekly templates and exceptions
+func publishedSlots(db *gorm.DB, doctorID uint, from, to time.Time) ([]service.Slot, error) {
+	var templates []entity.DoctorAvailability
+	if err := db.Where("doctor_id = ?", doctorID).Find(&templates).Error; err != nil {
+		return nil, err
+	}
+
+	var exceptions []entity.AvailabilityException
+	if err := db.Where("doctor_id = ? AND date >= ? AND date < ?", doctorID, from.AddDate(0, 0, -1).UTC(), to.UTC()).Find(&exceptions).Error; err != nil {
+		return nil, err
+	}
+
+	return service.GenerateSlots(doctorID, templates, exceptions, from, to), nil
+}
+
+// freeSlots generates the doctor's slots in [from, to) and removes the ones overlapping
+// a reservation. The reservation of exceptAppointmentID (the one being moved) is ignored.
+func freeSlots(db *gorm.DB, doctorID uint, from, to time.Time, exceptAppointmentID uint) ([]service.Slot, error) {
+	slots, err := publishedSlots(db, doctorID, from, to)
+	if err != nil || len(slots) == 0 {
+		return slots, err
+	}
+
+	var reserved []entity.AppointmentSlot
+	if err := db.Where("doctor_id = ? AND slot_start < ? AND slot_end > ? AND appointment_id <> ?",
+		doctorID, slots[len(slots)-1].End.UTC(), slots[0].Start.UTC(), exceptAppointmentID).Find(&reserved).Error; err != nil {
+		return nil, err
+	}
+
+	free := []service.Slot{}
+	for _, slot := range slots {
+		taken := false
+		for _, r := range reserved {
+			if r.SlotStart.Before(slot.End) && r.SlotEnd.After(slot.Start) {
+				taken = true
+				break
+			}
+		}
+		if !taken {
+			free = append(free, slot)
+		}
+	}
+	return free, nil
+}
+
+// slotEnd is the end of the doctor's published slot starting at start, or of a
+// default-length slot for a time outside the published hours
+func slotEnd(db *gorm.DB, doctorID *uint, start time.Time) (time.Time, error) {
+	end := start.Add(service.DefaultSlotMinutes * time.Minute)
+	if doctorID == nil {
+		return end, nil
+	}
+	slots, err := publishedSlots(db, *doctorID, start, start.Add(time.Minute))
+	if err != nil {
+		return end, err
+	}
+	for _, slot := range slots {
+		if slot.Start.Equal(start) {
+			return slot.End, nil
+		}
+	}
+	return end, nil
+}
+
+// GET /doctors - Doctors a mother can book with
+func ListDoctors(c *gin.Context) {
+	db := config.DB()
+
+	var doctors []entity.Doctor
+	if err := db.Select("id", "full_name", "phone_number", "email").Find(&doctors).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": doctors})
+}
+
+// GET /doctors/:id/slots?from=2025-11-25&to=2025-12-01 - Free slots of a doctor
+func GetDoctorSlots(c *gin.Context) {
+	var doctor entity.Doctor
+	db := config.DB()
+	if err := db.First(&doctor, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Doctor not found"})
+		return
+	}
+
+	now := time.Now()
+	from := now
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, service.ClinicLocation)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date"})
+			return
+		}
+		from = parsed
+	}
+	to := from.AddDate(0, 0, 7)
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, service.ClinicLocation)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date"})
+			return
+		}
+		to = parsed.AddDate(0, 0, 1)
+	}
+	if !to.After(from) || to.Sub(from) > service.MaxSlotSearchDays*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Date range must be between 1 and 31 days"})
+		return
+	}
+
+	// Mothers only see slots they are allowed to book
+	if role, _ := currentActor(c); role == "pregnant" {
+		if earliest := now.Add(service.BookingMinLeadTime); from.Before(earliest) {
+			from = earliest
+		}
+		if latest := now.Add(service.BookingMaxAhead); to.After(latest) {
+			to = latest
+		}
+	}
+
+	slots, err := freeSlots(db, doctor.ID, from, to, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": slots})
+}
+
+// POST /appointments/book - Mother books a free slot
+func BookAppointment(c *gin.Context) {
+	var input struct {
+		DoctorID uint   `json:"doctor_id"`
+		Start    string `json:"start"` // RFC3339 start of the slot
+		Title    string `json:"title"`
+		Notes    string `json:"notes"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role, userID := currentActor(c)
+	if role != "pregnant" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only mothers can book through this endpoint"})
+		return
+	}
+
+	start, err := time.Parse(time.RFC3339, input.Start)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start, expected RFC3339"})
+		return
+	}
+	if err := service.CheckBookingWindow(start, time.Now()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := config.DB()
+
+	// The requested start must be one of the doctor's published slots
+	slots, err := freeSlots(db, input.DoctorID, start, start.Add(time.Minute), 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(slots) == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "This time slot is not available"})
+		return
+	}
+	slot := slots[0]
+
+	title := input.Title
+	if title == "" {
+		title = "นัดตรวจครรภ์"
+	}
+
+	appt := entity.Appointment{
+		AppointmentDate: slot.Start,
+		Title:           title,
+		Location:        slot.Location,
+		Notes:           input.Notes,
+		Status:          entity.AppointmentStatusScheduled,
+		PregnantWomanID: &userID,
+		DoctorID:        &input.DoctorID,
+	}
+
+	var pregnancy entity.Pregnancy
+	if err := db.Where("p_id = ? AND status = ?", userID, entity.PregnancyStatusActive).First(&pregnancy).Error; err == nil {
+		appt.PregnancyID = &pregnancy.ID
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&appt).Error; err != nil {
+			return err
+		}
+		return reserveSlot(tx, appt.DoctorID, slot.Start, slot.End, appt.ID)
+	})
+	if errors.Is(err, errSlotTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Appointment booked",
+		"data":    appt,
+		"ics_url": fmt.Sprintf("/appointments/%d/ics", appt.ID),
+	})
+}
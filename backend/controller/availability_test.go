@@ -0,0 +1,231 @@
+package controller
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/service"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var errTestRollback = errors.New("roll back")
+
+// clinicTime is a time on Monday 2 November 2026 at the clinic
+func clinicTime(hour, minute int) time.Time {
+	return time.Date(2026, 11, 2, hour, minute, 0, 0, service.ClinicLocation)
+}
+
+// seedMondayClinic publishes 09:00-10:00 on Mondays in 15-minute slots
+func seedMondayClinic(t *testing.T, db *gorm.DB, doctorID uint) {
+	t.Helper()
+	hours := entity.DoctorAvailability{DoctorID: &doctorID, Weekday: int(time.Monday), StartTime: "09:00", EndTime: "10:00", SlotMinutes: 15}
+	if err := db.Create(&hours).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustReserve(t *testing.T, db *gorm.DB, doctorID uint, start, end time.Time, appointmentID uint) {
+	t.Helper()
+	if err := reserveSlot(db, &doctorID, start, end, appointmentID); err != nil {
+		t.Fatalf("reserve %v for appointment %d: %v", start, appointmentID, err)
+	}
+}
+
+func TestReserveSlotRefusesOverlaps(t *testing.T) {
+	db := openTestDB(t, "slots")
+	doctor, other := uint(1), uint(2)
+	mustReserve(t, db, doctor, clinicTime(9, 0), clinicTime(9, 30), 1)
+
+	tests := []struct {
+		name       string
+		doctorID   *uint
+		start, end time.Time
+		taken      bool
+	}{
+		{"same start", &doctor, clinicTime(9, 0), clinicTime(9, 15), true},
+		{"starts inside", &doctor, clinicTime(9, 15), clinicTime(9, 45), true},
+		{"ends inside", &doctor, clinicTime(8, 45), clinicTime(9, 15), true},
+		{"covers it", &doctor, clinicTime(8, 45), clinicTime(9, 45), true},
+		{"default length into it", &doctor, clinicTime(8, 50), time.Time{}, true},
+		{"ends at its start", &doctor, clinicTime(8, 30), clinicTime(9, 0), false},
+		{"starts at its end", &doctor, clinicTime(9, 30), clinicTime(10, 0), false},
+		{"default length before it", &doctor, clinicTime(8, 45), time.Time{}, false},
+		{"another doctor", &other, clinicTime(9, 0), clinicTime(9, 30), false},
+		{"no doctor", nil, clinicTime(9, 0), clinicTime(9, 30), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := db.Transaction(func(tx *gorm.DB) error {
+				err := reserveSlot(tx, tt.doctorID, tt.start, tt.end, 2)
+				if errors.Is(err, errSlotTaken) != tt.taken {
+					t.Fatalf("err = %v, taken = %v", err, tt.taken)
+				}
+				if err != nil && !errors.Is(err, errSlotTaken) {
+					t.Fatal(err)
+				}
+
+				// a refused reservation leaves nothing behind, so the caller can try another time
+				var held, want int64
+				tx.Model(&entity.AppointmentSlot{}).Where("appointment_id = ?", 2).Count(&held)
+				if tt.doctorID != nil && !tt.taken {
+					want = 1
+				}
+				if held != want {
+					t.Fatalf("%d reservations held, want %d", held, want)
+				}
+				return errTestRollback
+			})
+			if !errors.Is(err, errTestRollback) {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestReserveSlotOnePerAppointment(t *testing.T) {
+	db := openTestDB(t, "slots")
+	doctor := uint(1)
+	mustReserve(t, db, doctor, clinicTime(9, 0), clinicTime(9, 15), 1)
+
+	// the same appointment cannot hold two times at once; moving it releases the first
+	if err := reserveSlot(db, &doctor, clinicTime(11, 0), clinicTime(11, 15), 1); err == nil {
+		t.Fatal("an appointment reserved a second slot")
+	}
+}
+
+func TestReserveNextFreeSlot(t *testing.T) {
+	db := openTestDB(t, "slots")
+	doctor := uint(1)
+	seedMondayClinic(t, db, doctor)
+	mustReserve(t, db, doctor, clinicTime(9, 0), clinicTime(9, 15), 1)
+	mustReserve(t, db, doctor, clinicTime(9, 15), clinicTime(9, 30), 2)
+
+	start, err := reserveNextFreeSlot(db, &doctor, clinicTime(9, 0), 3)
+	if err != nil || !start.Equal(clinicTime(9, 30)) {
+		t.Fatalf("next free slot = %v, %v; want 09:30", start, err)
+	}
+	mustReserve(t, db, doctor, clinicTime(9, 45), clinicTime(10, 0), 4)
+
+	// Monday is full; Tuesday has no published hours, so a default slot at the same time
+	start, err = reserveNextFreeSlot(db, &doctor, clinicTime(9, 0), 5)
+	if want := clinicTime(9, 0).AddDate(0, 0, 1); err != nil || !start.Equal(want) {
+		t.Fatalf("next free slot on a full day = %v, %v; want %v", start, err, want)
+	}
+	var slot entity.AppointmentSlot
+	db.Where("appointment_id = ?", 5).First(&slot)
+	if slot.SlotEnd.Sub(slot.SlotStart) != service.DefaultSlotMinutes*time.Minute {
+		t.Fatalf("slot outside published hours is %v long", slot.SlotEnd.Sub(slot.SlotStart))
+	}
+}
+
+func TestFreeSlotsForReschedule(t *testing.T) {
+	db := openTestDB(t, "slots")
+	doctor := uint(1)
+	seedMondayClinic(t, db, doctor)
+	mustReserve(t, db, doctor, clinicTime(9, 0), clinicTime(9, 15), 1)
+	// booked by a doctor off the grid, across the 09:15 and 09:30 slots
+	mustReserve(t, db, doctor, clinicTime(9, 25), clinicTime(9, 40), 2)
+
+	starts := func(slots []service.Slot) []string {
+		var out []string
+		for _, s := range slots {
+			out = append(out, s.Start.In(service.ClinicLocation).Format("15:04"))
+		}
+		return out
+	}
+	free, err := freeSlots(db, doctor, clinicTime(0, 0), clinicTime(23, 0), 0)
+	if got := starts(free); err != nil || len(got) != 1 || got[0] != "09:45" {
+		t.Fatalf("free slots = %v, %v", got, err)
+	}
+	// the appointment being moved does not block its own time
+	free, err = freeSlots(db, doctor, clinicTime(0, 0), clinicTime(23, 0), 1)
+	if got := starts(free); err != nil || len(got) != 2 || got[0] != "09:00" || got[1] != "09:45" {
+		t.Fatalf("free slots while moving appointment 1 = %v, %v", got, err)
+	}
+}
+
+func TestRescheduleKeepsSlotWhenTargetIsTaken(t *testing.T) {
+	db := openTestDB(t, "slots")
+	doctor := uint(1)
+	mustReserve(t, db, doctor, clinicTime(9, 0), clinicTime(9, 15), 1)
+	mustReserve(t, db, doctor, clinicTime(9, 30), clinicTime(9, 45), 2)
+
+	move := func(start time.Time) error {
+		return db.Transaction(func(tx *gorm.DB) error {
+			if err := releaseSlot(tx, 1); err != nil {
+				return err
+			}
+			return reserveSlot(tx, &doctor, start, start.Add(15*time.Minute), 1)
+		})
+	}
+	if err := move(clinicTime(9, 30)); !errors.Is(err, errSlotTaken) {
+		t.Fatalf("moving onto a booked slot: %v", err)
+	}
+	var slot entity.AppointmentSlot
+	if err := db.Where("appointment_id = ?", 1).First(&slot).Error; err != nil || !slot.SlotStart.Equal(clinicTime(9, 0)) {
+		t.Fatalf("appointment 1 lost its slot after a refused move: %+v, %v", slot, err)
+	}
+
+	if err := move(clinicTime(9, 15)); err != nil {
+		t.Fatal(err)
+	}
+	var moved entity.AppointmentSlot
+	if err := db.Where("appointment_id = ?", 1).First(&moved).Error; err != nil || !moved.SlotStart.Equal(clinicTime(9, 15)) {
+		t.Fatalf("moved slot = %+v, %v", moved, err)
+	}
+}
+
+// Bookings racing for overlapping times: every reservation writes its slot
+// before checking, so whichever commits second sees the first and gives up
+func TestReserveSlotConcurrentBookings(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "slots.db")+"?_busy_timeout=10000"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&entity.AppointmentSlot{}); err != nil {
+		t.Fatal(err)
+	}
+	doctor := uint(1)
+
+	const bookings = 12
+	var wg sync.WaitGroup
+	errs := make([]error, bookings)
+	for i := 0; i < bookings; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// 15-minute bookings every 5 minutes: each overlaps its neighbours
+			start := clinicTime(9, 0).Add(time.Duration(i%6) * 5 * time.Minute)
+			errs[i] = db.Transaction(func(tx *gorm.DB) error {
+				return reserveSlot(tx, &doctor, start, start.Add(15*time.Minute), uint(i+1))
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	booked := 0
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			booked++
+		case !errors.Is(err, errSlotTaken):
+			t.Fatalf("booking %d: %v", i+1, err)
+		}
+	}
+	var slots []entity.AppointmentSlot
+	db.Order("slot_start").Find(&slots)
+	if len(slots) != booked || booked == 0 {
+		t.Fatalf("%d bookings succeeded, %d slots held", booked, len(slots))
+	}
+	for i := 1; i < len(slots); i++ {
+		if slots[i].SlotStart.Before(slots[i-1].SlotEnd) {
+			t.Fatalf("double booking: %v-%v and %v-%v", slots[i-1].SlotStart, slots[i-1].SlotEnd, slots[i].SlotStart, slots[i].SlotEnd)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/config"
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// calendarFeedHistory is how far back a calendar feed goes
+const calendarFeedHistory = 180 * 24 * time.Hour
+
+// publicBaseURL is where subscription URLs point. It comes from PUBLIC_BASE_URL:
+// the Host and X-Forwarded-Proto headers are set by the client and would let
+// anyone get a secret feed link pointing at their own server.
+func publicBaseURL(c *gin.Context) (*url.URL, bool) {
+	base, err := service.PublicBaseURLFromEnv()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Calendar feeds are not configured: " + err.Error()})
+		return nil, false
+	}
+	return base, true
+}
+
+// feedURLs builds the subscription URLs of a feed token
+func feedURLs(base *url.URL, token string) gin.H {
+	feed, webcal := service.CalendarFeedURLs(base, token)
+	return gin.H{"url": feed, "webcal_url": webcal}
+}
+
+// getOrCreateCalendarFeed returns the feed of a user, creating a token on first use
+func getOrCreateCalendarFeed(db *gorm.DB, role string, userID uint) (*entity.CalendarFeed, error) {
+	var feed entity.CalendarFeed
+	err := db.Where("role = ? AND user_id = ?", role, userID).First(&feed).Error
+	if err == nil {
+		return &feed, nil
+	}
+	token, err := service.NewFeedToken()
+	if err != nil {
+		return nil, err
+	}
+	feed = entity.CalendarFeed{Role: role, UserID: userID, Token: token}
+	if err := db.Create(&feed).Error; err != nil {
+		return nil, err
+	}
+	return &feed, nil
+}
+
+// GET /calendar/feed - Subscription URL of the logged in user's appointment calendar
+func GetMyCalendarFeed(c *gin.Context) {
+	base, ok := publicBaseURL(c)
+	if !ok {
+		return
+	}
+	role, userID := currentActor(c)
+	db := config.DB()
+
+	feed, err := getOrCreateCalendarFeed(db, role, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": feedURLs(base, feed.Token)})
+}
+
+// POST /calendar/feed/rotate - Replace the feed token; the old URL stops working
+func RotateCalendarFeed(c *gin.Context) {
+	base, ok := publicBaseURL(c)
+	if !ok {
+		return
+	}
+	role, userID := currentActor(c)
+	db := config.DB()
+
+	feed, err := getOrCreateCalendarFeed(db, role, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := service.NewFeedToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	now := time.Now()
+	feed.Token = token
+	feed.RotatedAt = &now
+	if err := db.Save(feed).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Calendar feed token rotated", "data": feedURLs(base, feed.Token)})
+}
+
+// GET /calendar/feeds/:token - Public iCalendar feed, authorised by the secret token in the URL
+func GetCalendarFeed(c *gin.Context) {
+	token := strings.TrimSuffix(c.Param("token"), ".ics")
+	db := config.DB()
+
+	var feed entity.CalendarFeed
+	if token == "" || db.Where("token = ?", token).First(&feed).Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendar feed not found"})
+		return
+	}
+
+	query := preloadAppointmentDoctor(db).Preload("Slot").
+		Where("appointment_date >= ?", time.Now().Add(-calendarFeedHistory).UTC()).
+		Order("appointment_date ASC")
+	name := "นัดหมายฝากครรภ์"
+	if feed.Role == "doctor" {
+		query = query.Preload("PregnantWoman", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id", "full_name")
+		}).Where("doctor_id = ?", feed.UserID)
+		name = "นัดหมายผู้ป่วยฝากครรภ์"
+	} else {
+		query = query.Where("pregnant_woman_id = ?", feed.UserID)
+	}
+
+	var appointments []entity.Appointment
+	if err := query.Find(&appointments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ics := service.BuildICalendar(name, appointments, service.ReminderOffsetsFromEnv())
+	c.Header("Cache-Control", "no-cache")
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(ics))
+}
+
+// GET /appointments/:id/ics - Download a single appointment as an .ics file
+func GetAppointmentICS(c *gin.Context) {
+	id := c.Param("id")
+	db := config.DB()
+
+	appt, ok := findAppointmentForActor(c, db, id)
+	if !ok {
+		return
+	}
+	preloadAppointmentDoctor(db).Preload("Slot").First(appt, appt.ID)
+
+	ics := service.BuildICalendar("", []entity.Appointment{*appt}, service.ReminderOffsetsFromEnv())
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=appointment-%d.ics", appt.ID))
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(ics))
+}
@@ -0,0 +1,400 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/config"
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/scheduler"
+	"github.com/bestiesmile1845/Projecteiei/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// preloadNoteParts loads the coded diagnoses and plan items of clinical notes
+func preloadNoteParts(db *gorm.DB) *gorm.DB {
+	return db.Preload("Diagnoses", func(db *gorm.DB) *gorm.DB {
+		return db.Order("is_primary DESC, id ASC")
+	}).Preload("PlanItems", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	})
+}
+
+// attachClinicalNotes sets patient.ClinicalNotes to her notes across all pregnancies, oldest first
+func attachClinicalNotes(db *gorm.DB, patient *entity.PregnantWoman) {
+	var notes []entity.ClinicalNote
+	if err := preloadNoteParts(db).
+		Joins("JOIN pregnancies ON pregnancies.id = clinical_notes.pregnancy_id").
+		Where("pregnancies.p_id = ?", patient.ID).
+		Order("clinical_notes.note_date ASC, clinical_notes.id ASC").
+		Find(&notes).Error; err != nil {
+		return
+	}
+	if len(notes) > 0 {
+		patient.ClinicalNotes = notes
+	}
+}
+
+// findTemplateForDoctor loads a template the doctor may use (their own or a shared one)
+func findTemplateForDoctor(db *gorm.DB, id uint, doctorID uint) (*entity.NoteTemplate, error) {
+	var template entity.NoteTemplate
+	if err := db.Where("id = ? AND (doctor_id = ? OR shared = ?)", id, doctorID, true).First(&template).Error; err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// GET /icd10-codes?q=&limit= - Search the local ICD-10 table by code or title
+func SearchIcd10Codes(c *gin.Context) {
+	limit := 20
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+			return
+		}
+		limit = n
+	}
+
+	db := config.DB()
+
+	query := db.Model(&entity.Icd10Code{})
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		like := "%" + strings.ToLower(q) + "%"
+		query = query.Where("code LIKE ? OR LOWER(title) LIKE ? OR title_th LIKE ?",
+			service.NormalizeIcd10Code(q)+"%", like, "%"+q+"%")
+	}
+
+	var codes []entity.Icd10Code
+	if err := query.Order("code ASC").Limit(limit).Find(&codes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": codes})
+}
+
+// GET /doctor/note-templates - The doctor's own templates and those shared by colleagues
+func GetNoteTemplates(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+
+	db := config.DB()
+
+	var templates []entity.NoteTemplate
+	if err := db.Where("doctor_id = ? OR shared = ?", doctorID, true).Order("name ASC").Find(&templates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": templates})
+}
+
+// POST /doctor/note-templates - Create a reusable SOAP template
+func CreateNoteTemplate(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+
+	var template entity.NoteTemplate
+	if err := c.ShouldBindJSON(&template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template.Name = strings.TrimSpace(template.Name)
+	if err := service.ValidateNoteTemplate(template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	template.ID = 0
+	template.DoctorID = &doctorID
+
+	db := config.DB()
+
+	if err := db.Create(&template).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Template created", "data": template})
+}
+
+// PUT /doctor/note-templates/:id - Update one of the doctor's own templates
+func UpdateNoteTemplate(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+	db := config.DB()
+
+	var template entity.NoteTemplate
+	if err := db.Where("id = ? AND doctor_id = ?", id, doctorID).First(&template).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	model := template.Model
+	if err := c.ShouldBindJSON(&template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	template.Model = model
+	template.DoctorID = &doctorID
+	template.Name = strings.TrimSpace(template.Name)
+	if err := service.ValidateNoteTemplate(template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := db.Save(&template).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Template updated", "data": template})
+}
+
+// DELETE /doctor/note-templates/:id - Delete one of the doctor's own templates
+func DeleteNoteTemplate(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+
+	db := config.DB()
+
+	if tx := db.Where("id = ? AND doctor_id = ?", c.Param("id"), doctorID).Delete(&entity.NoteTemplate{}); tx.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Template deleted"})
+}
+
+// POST /doctor/pregnancy/:id/notes - Write a SOAP note with ICD-10 diagnoses and plan items
+func CreateClinicalNote(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+	var input struct {
+		AntenatalVisitID *uint      `json:"antenatal_visit_id"`
+		TemplateID       *uint      `json:"template_id"`
+		NoteDate         *time.Time `json:"note_date"`
+		Subjective       string     `json:"subjective"`
+		Objective        string     `json:"objective"`
+		Assessment       string     `json:"assessment"`
+		Plan             string     `json:"plan"`
+		Diagnoses        []struct {
+			Code      string `json:"code"`
+			IsPrimary bool   `json:"is_primary"`
+		} `json:"diagnoses"`
+		PlanItems []struct {
+			Description string     `json:"description"`
+			DueDate     *time.Time `json:"due_date"`
+			FollowUp    bool       `json:"follow_up"`
+		} `json:"plan_items"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := config.DB()
+
+	var pregnancy entity.Pregnancy
+	if err := db.First(&pregnancy, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": errPregnancyNotFound.Error()})
+		return
+	}
+
+	note := entity.ClinicalNote{
+		PregnancyID: &pregnancy.ID,
+		DoctorID:    &doctorID,
+		NoteDate:    time.Now(),
+		Subjective:  strings.TrimSpace(input.Subjective),
+		Objective:   strings.TrimSpace(input.Objective),
+		Assessment:  strings.TrimSpace(input.Assessment),
+		Plan:        strings.TrimSpace(input.Plan),
+	}
+	if input.NoteDate != nil {
+		note.NoteDate = *input.NoteDate
+	}
+
+	if input.AntenatalVisitID != nil {
+		var visit entity.AntenatalVisit
+		if err := db.Where("id = ? AND pregnancy_id = ?", *input.AntenatalVisitID, pregnancy.ID).First(&visit).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Antenatal visit not found in this pregnancy"})
+			return
+		}
+		note.AntenatalVisitID = &visit.ID
+	}
+
+	if input.TemplateID != nil {
+		template, err := findTemplateForDoctor(db, *input.TemplateID, doctorID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Template not found"})
+			return
+		}
+		note.NoteTemplateID = &template.ID
+		service.ApplyNoteTemplate(&note, *template)
+	}
+
+	// รหัสโรคต้องมีในตาราง ICD-10 ของคลินิก
+	for _, d := range input.Diagnoses {
+		var code entity.Icd10Code
+		if err := db.Where("code = ?", service.NormalizeIcd10Code(d.Code)).First(&code).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown ICD-10 code " + d.Code})
+			return
+		}
+		note.Diagnoses = append(note.Diagnoses, entity.NoteDiagnosis{
+			Icd10CodeID: &code.ID,
+			Code:        code.Code,
+			Description: code.Title,
+			IsPrimary:   d.IsPrimary,
+		})
+	}
+	if len(note.Diagnoses) > 0 {
+		hasPrimary := false
+		for _, d := range note.Diagnoses {
+			hasPrimary = hasPrimary || d.IsPrimary
+		}
+		if !hasPrimary {
+			note.Diagnoses[0].IsPrimary = true
+		}
+	}
+
+	for _, item := range input.PlanItems {
+		note.PlanItems = append(note.PlanItems, entity.NotePlanItem{
+			Description: strings.TrimSpace(item.Description),
+			DueDate:     item.DueDate,
+			FollowUp:    item.FollowUp,
+			Status:      entity.PlanItemOpen,
+		})
+	}
+
+	if err := service.ValidateClinicalNote(note); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&note).Error; err != nil {
+			return err
+		}
+		// Plan items marked for follow-up go onto the worklist with their due date right away
+		for _, item := range note.PlanItems {
+			if !item.FollowUp {
+				continue
+			}
+			if err := scheduler.OpenFollowUp(tx, scheduler.PlanItemFollowUp(item, pregnancy)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Clinical note created", "data": note})
+}
+
+// GET /pregnancies/:id/notes - Clinical notes of a pregnancy, oldest first
+func GetPregnancyClinicalNotes(c *gin.Context) {
+	db := config.DB()
+
+	pregnancy, ok := findPregnancyForActor(c, db, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var notes []entity.ClinicalNote
+	if err := preloadNoteParts(db).Where("pregnancy_id = ?", pregnancy.ID).
+		Order("note_date ASC, id ASC").Find(&notes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": notes})
+}
+
+// GET /doctor/patients/:id/notes - Chronological notes timeline across all pregnancies of a patient
+func GetPatientNotesTimeline(c *gin.Context) {
+	if _, ok := requireDoctor(c); !ok {
+		return
+	}
+
+	db := config.DB()
+
+	var patient entity.PregnantWoman
+	if err := db.Select("id").First(&patient, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Patient not found"})
+		return
+	}
+
+	attachClinicalNotes(db, &patient)
+	notes := patient.ClinicalNotes
+	if notes == nil {
+		notes = []entity.ClinicalNote{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": notes})
+}
+
+// POST /doctor/notes/plan-items/:id/complete - Mark a plan item done and close its follow-up
+func CompleteNotePlanItem(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+
+	db := config.DB()
+
+	var item entity.NotePlanItem
+	if err := db.First(&item, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Plan item not found"})
+		return
+	}
+	if item.Status == entity.PlanItemDone {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Plan item is already done"})
+		return
+	}
+
+	now := time.Now()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&item).Updates(map[string]interface{}{
+			"status":  entity.PlanItemDone,
+			"done_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&entity.FollowUpItem{}).
+			Where("note_plan_item_id = ? AND status = ?", item.ID, entity.FollowUpStatusOpen).
+			Updates(map[string]interface{}{
+				"status":       entity.FollowUpStatusClosed,
+				"closed_at":    now,
+				"closed_by_id": doctorID,
+				"close_reason": "Plan item completed",
+			}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Plan item completed", "data": item})
+}
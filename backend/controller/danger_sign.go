@@ -0,0 +1,259 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/config"
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/scheduler"
+	"github.com/bestiesmile1845/Projecteiei/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// recomputeDangerSignReport re-triages a corrected report
+func recomputeDangerSignReport(db *gorm.DB, record interface{}) error {
+	report := record.(*entity.DangerSignReport)
+	symptoms, err := service.NormalizeDangerSigns(strings.Split(report.Symptoms, ","))
+	if err != nil {
+		return err
+	}
+	if report.Temperature != nil && (*report.Temperature < 30 || *report.Temperature > 45) {
+		return errors.New("temperature must be in °C")
+	}
+	if report.ReportedAt.After(time.Now()) {
+		return errors.New("reported_at cannot be in the future")
+	}
+
+	var pregnancy entity.Pregnancy
+	if report.PregnancyID != nil {
+		db.First(&pregnancy, *report.PregnancyID)
+	}
+	report.GestationalWeeks = service.GestationalWeeks(service.PregnancyLMP(pregnancy), report.ReportedAt)
+	result := service.TriageDangerSigns(service.DangerSignInput{
+		Symptoms:         symptoms,
+		HeavyBleeding:    report.HeavyBleeding,
+		Temperature:      report.Temperature,
+		GestationalWeeks: report.GestationalWeeks,
+	})
+	report.Symptoms = strings.Join(symptoms, ",")
+	report.Triage = result.Level
+	report.TriageReasons = strings.Join(result.Reasons, "; ")
+	report.Instructions = result.Instructions
+	return nil
+}
+
+// POST /pregnancies/:id/danger-signs - Mother reports danger signs and gets triage advice back
+func ReportDangerSigns(c *gin.Context) {
+	id := c.Param("id")
+	var input struct {
+		Symptoms      []string   `json:"symptoms"`
+		HeavyBleeding bool       `json:"heavy_bleeding"`
+		Temperature   *float64   `json:"temperature"`
+		Notes         string     `json:"notes"`
+		ReportedAt    *time.Time `json:"reported_at"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	symptoms, err := service.NormalizeDangerSigns(input.Symptoms)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Temperature != nil && (*input.Temperature < 30 || *input.Temperature > 45) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "temperature must be in °C"})
+		return
+	}
+
+	db := config.DB()
+
+	pregnancy, ok := findPregnancyForActor(c, db, id)
+	if !ok {
+		return
+	}
+	if pregnancy.Status != entity.PregnancyStatusActive {
+		respondPregnancyError(c, errPregnancyNotActive)
+		return
+	}
+
+	reportedAt := time.Now()
+	if input.ReportedAt != nil && !input.ReportedAt.After(reportedAt) {
+		reportedAt = *input.ReportedAt
+	}
+	ga := service.GestationalWeeks(service.PregnancyLMP(*pregnancy), reportedAt)
+
+	result := service.TriageDangerSigns(service.DangerSignInput{
+		Symptoms:         symptoms,
+		HeavyBleeding:    input.HeavyBleeding,
+		Temperature:      input.Temperature,
+		GestationalWeeks: ga,
+	})
+
+	role, userID := currentActor(c)
+	report := entity.DangerSignReport{
+		PregnancyID:      &pregnancy.ID,
+		ReportedAt:       reportedAt.UTC(),
+		Symptoms:         strings.Join(symptoms, ","),
+		HeavyBleeding:    input.HeavyBleeding,
+		Temperature:      input.Temperature,
+		Notes:            input.Notes,
+		GestationalWeeks: ga,
+		Triage:           result.Level,
+		TriageReasons:    strings.Join(result.Reasons, "; "),
+		Instructions:     result.Instructions,
+		SubmittedBy:      role,
+		SubmittedByID:    userID,
+	}
+
+	urgent := service.IsUrgentTriage(report.Triage)
+	s := scheduler.Current()
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&report).Error; err != nil {
+			return err
+		}
+		if !urgent || s == nil || pregnancy.PregnantWomanID == nil {
+			return nil
+		}
+		var woman entity.PregnantWoman
+		if err := tx.First(&woman, *pregnancy.PregnantWomanID).Error; err != nil {
+			return err
+		}
+		subject, body := service.DangerSignAlertText(woman, report)
+		queued, err := s.AlertCareTeam(tx, scheduler.CareTeamAlert{
+			Key:         fmt.Sprintf("danger-sign:%d", report.ID),
+			PregnancyID: pregnancy.ID,
+			Subject:     subject,
+			Body:        body,
+		}, reportedAt)
+		if err != nil {
+			return err
+		}
+		report.AlertsQueued = queued
+		return tx.Model(&report).Update("alerts_queued", queued).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	message := "Danger signs recorded"
+	switch {
+	case urgent && report.AlertsQueued > 0:
+		s.DeliverSoon()
+		message = "Danger signs recorded; the care team has been alerted"
+	case urgent:
+		// Still on the care team's urgent list, but nobody was messaged
+		message = "Danger signs recorded on the care team's urgent list, but no alert could be sent; please call the clinic or go to the hospital now"
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": message, "data": report})
+}
+
+// GET /pregnancies/:id/danger-signs
+func GetDangerSignReports(c *gin.Context) {
+	id := c.Param("id")
+	db := config.DB()
+
+	pregnancy, ok := findPregnancyForActor(c, db, id)
+	if !ok {
+		return
+	}
+
+	var reports []entity.DangerSignReport
+	if err := db.Where("pregnancy_id = ?", pregnancy.ID).Order("reported_at DESC").Find(&reports).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": reports})
+}
+
+// GET /doctor/danger-signs?triage=Emergency&acknowledged=false - Urgent reports for the care team
+func GetUrgentDangerSigns(c *gin.Context) {
+	if _, ok := requireDoctor(c); !ok {
+		return
+	}
+
+	db := config.DB()
+	query := db.Preload("Pregnancy.PregnantWoman", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id", "full_name", "hn", "phone_number")
+	})
+	if triage := c.Query("triage"); triage != "" {
+		query = query.Where("triage = ?", triage)
+	} else {
+		query = query.Where("triage IN ?", []string{entity.TriageUrgent, entity.TriageEmergency})
+	}
+	if c.DefaultQuery("acknowledged", "false") == "false" {
+		query = query.Where("acknowledged_at IS NULL")
+	}
+
+	var reports []entity.DangerSignReport
+	if err := query.Order("CASE triage WHEN 'Emergency' THEN 0 WHEN 'Urgent' THEN 1 ELSE 2 END, reported_at DESC").
+		Find(&reports).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	type reportRow struct {
+		entity.DangerSignReport
+		PregnantWoman *entity.PregnantWoman `json:"pregnant_woman"`
+	}
+	rows := []reportRow{}
+	for _, r := range reports {
+		row := reportRow{DangerSignReport: r}
+		if r.Pregnancy != nil {
+			row.PregnantWoman = r.Pregnancy.PregnantWoman
+		}
+		rows = append(rows, row)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": rows})
+}
+
+// POST /doctor/danger-signs/:id/acknowledge - Care team confirms the mother was contacted
+func AcknowledgeDangerSign(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+	var input struct {
+		Notes string `json:"notes"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := config.DB()
+
+	var report entity.DangerSignReport
+	if err := db.First(&report, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+	if report.AcknowledgedAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Report has already been acknowledged"})
+		return
+	}
+
+	now := time.Now()
+	report.AcknowledgedByID = &doctorID
+	report.AcknowledgedAt = &now
+	report.ResponseNotes = input.Notes
+	if err := db.Save(&report).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Report acknowledged", "data": report})
+}
@@ -1,12 +1,16 @@
  package controller
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/bestiesmile1845/Projecteiei/config"
 	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/service"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // POST /doctor/medical-history - Create or update medical history
@@ -68,15 +72,34 @@ func DoctorCreateMedicalHistory(c *gin.Context) {
 	}
 }
 
-// POST /doctor/previous-pregnancy - Create previous pregnancy record
-func DoctorCreatePreviousPregnancy(c *gin.Context) {
-	var input entity.PreviousPregnancy
+// POST /doctor/previous-pregnancy - Create obstetric history record
+func DoctorCreateObstetricHistory(c *gin.Context) {
+	var input entity.ObstetricHistory
 
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	if input.Outcome == "" {
+		input.Outcome = service.InferObstetricOutcome(input.GestationalAge, input.ChildStatus)
+	}
+	if !entity.IsObstetricOutcome(input.Outcome) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid outcome"})
+		return
+	}
+	if input.DeliveryAttendant != "" && !entity.IsDeliveryAttendant(input.DeliveryAttendant) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery_attendant"})
+		return
+	}
+	if input.PregnancyNo < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "pregnancy_no must be 1 or more"})
+		return
+	}
+	if input.BabyNo == 0 {
+		input.BabyNo = 1
+	}
+
 	db := config.DB()
 
 	if err := db.Create(&input).Error; err != nil {
@@ -88,57 +111,47 @@ func DoctorCreatePreviousPregnancy(c *gin.Context) {
 }
 
 // GET /doctor/patient/:patientId/previous-pregnancies
-func GetPreviousPregnancies(c *gin.Context) {
+func GetObstetricHistories(c *gin.Context) {
 	patientId := c.Param("patientId")
 	db := config.DB()
 
-	var pregnancies []entity.PreviousPregnancy
-	if err := db.Where("pregnant_woman_id = ?", patientId).Order("pregnancy_no ASC").Find(&pregnancies).Error; err != nil {
-		c.JSON(http.StatusOK, gin.H{"data": []entity.PreviousPregnancy{}})
+	var histories []entity.ObstetricHistory
+	if err := db.Where("pregnant_woman_id = ?", patientId).Order("pregnancy_no ASC, baby_no ASC").Find(&histories).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{"data": []entity.ObstetricHistory{}})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": pregnancies})
+	c.JSON(http.StatusOK, gin.H{"data": histories})
 }
 
 // GET /previous-pregnancies/pregnant-woman/:id
-func GetPreviousPregnanciesByPregnantWomanID(c *gin.Context) {
+func GetObstetricHistoriesByPregnantWomanID(c *gin.Context) {
 	id := c.Param("id")
 	db := config.DB()
 
-	var pregnancies []entity.PreviousPregnancy
-	if err := db.Where("pregnant_woman_id = ?", id).Order("pregnancy_no ASC").Find(&pregnancies).Error; err != nil {
-		c.JSON(http.StatusOK, gin.H{"data": []entity.PreviousPregnancy{}})
+	var histories []entity.ObstetricHistory
+	if err := db.Where("pregnant_woman_id = ?", id).Order("pregnancy_no ASC, baby_no ASC").Find(&histories).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{"data": []entity.ObstetricHistory{}})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": pregnancies})
+	c.JSON(http.StatusOK, gin.H{"data": histories})
 }
 
 // POST /doctor/lab-result - Create lab result with file upload
 func DoctorCreateLabResult(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+
 	// Parse multipart form (32MB limit)
 	if err := c.Request.ParseMultipartForm(32 << 20); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "File too large or invalid multipart form"})
 		return
 	}
 
-	// 1. Handle File Upload
-	var filePath string
-	file, err := c.FormFile("File")
-	if err == nil {
-		// Create uploads directory if it doesn't exist (handled by mkdir in most cases, but good to be safe)
-		// Assuming "uploads/lab_results" structure
-		filename := time.Now().Format("20060102150405") + "_" + file.Filename
-		dst := "uploads/lab_results/" + filename
-		if err := c.SaveUploadedFile(file, dst); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file: " + err.Error()})
-			return
-		}
-		filePath = dst
-	}
-
-	// 2. Bind other fields
+	// 1. Bind other fields
 	// Let's use a DTO struct for binding form data
 	
 	// Let's us a DTO struct inside for binding form data
@@ -149,6 +162,8 @@ func DoctorCreateLabResult(c *gin.Context) {
 		Hb           float64   `form:"Hb"`
 		HbTyping     string    `form:"HbTyping"`
 		OtherRemarks string    `form:"OtherRemarks"`
+		LabOrderID   uint      `form:"LabOrderID"` // คำสั่งตรวจที่ผลนี้ตอบ (ถ้ามี)
+		Observations string    `form:"Observations"` // JSON [{"code":"VDRL","value":"Non-reactive"}]
 	}
 
 	if err := c.ShouldBind(&formDto); err != nil {
@@ -161,17 +176,85 @@ func DoctorCreateLabResult(c *gin.Context) {
 
 	db := config.DB()
 
+	pregnancy, err := requireActivePregnancy(db, formDto.PregnancyID)
+	if err != nil {
+		respondPregnancyError(c, err)
+		return
+	}
+
+	// Hct, Hb and HbTyping are still accepted from the old form and stored as observations
+	var inputs []labObservationInput
+	if formDto.Observations != "" {
+		if err := json.Unmarshal([]byte(formDto.Observations), &inputs); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Observations must be a JSON array: " + err.Error()})
+			return
+		}
+	}
+	if formDto.Hct > 0 {
+		inputs = append(inputs, labObservationInput{Code: service.LabTestHct, Value: formDto.Hct})
+	}
+	if formDto.Hb > 0 {
+		inputs = append(inputs, labObservationInput{Code: service.LabTestHb, Value: formDto.Hb})
+	}
+	if formDto.HbTyping != "" {
+		inputs = append(inputs, labObservationInput{Code: service.LabTestHbTyping, Value: formDto.HbTyping})
+	}
+	observations, err := buildLabObservations(db, pregnancy, testDate, inputs)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var order *entity.LabOrder
+	if formDto.LabOrderID != 0 {
+		order = &entity.LabOrder{}
+		if err := db.First(order, formDto.LabOrderID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Lab order not found"})
+			return
+		}
+		if order.PregnancyID == nil || *order.PregnancyID != formDto.PregnancyID {
+			c.JSON(http.StatusConflict, gin.H{"error": errLabResultMismatch.Error()})
+			return
+		}
+		if err := service.CheckLabOrderTransition(order.Status, entity.LabOrderResulted); err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// 2. The file is validated and stored under a random key once the request is known to be valid
+	upload, err := receiveUpload(c, "File", "lab_results", pregnancy.PregnantWomanID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	labResult := entity.LabResult{
 		PregnancyID:  &formDto.PregnancyID,
 		TestDate:     testDate,
-		Hct:          formDto.Hct,
-		Hb:           formDto.Hb,
-		HbTyping:     formDto.HbTyping,
 		OtherRemarks: formDto.OtherRemarks,
-		FilePath:     filePath,
+		Observations: observations,
+		StoredFile:   upload,
+		DoctorID:     &doctorID,
+		RecordStatus: entity.RecordStatusDraft,
 	}
 
-	if err := db.Create(&labResult).Error; err != nil {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&labResult).Error; err != nil {
+			return err
+		}
+		if order != nil {
+			return attachLabResultToOrder(tx, order, &labResult, time.Now())
+		}
+		return nil
+	})
+	if err != nil {
+		discardUpload(upload)
+		var conflict labOrderConflictError
+		if errors.As(err, &conflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -185,7 +268,7 @@ func GetLabResultsByPregnancyID(c *gin.Context) {
 	db := config.DB()
 
 	var results []entity.LabResult
-	if err := db.Where("pregnancy_id = ?", id).Order("test_date DESC").Find(&results).Error; err != nil {
+	if err := db.Preload("Observations").Preload("StoredFile").Preload("Addenda").Where("pregnancy_id = ?", id).Order("test_date DESC").Find(&results).Error; err != nil {
 		c.JSON(http.StatusOK, gin.H{"data": []entity.LabResult{}})
 		return
 	}
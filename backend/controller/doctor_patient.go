@@ -1,12 +1,14 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
-	"time"
 
 	"github.com/bestiesmile1845/Projecteiei/config"
 	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/service"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // GET /doctor/patients - Get all patients (pregnant women)
@@ -16,11 +18,15 @@ func GetDoctorPatients(c *gin.Context) {
 	db := config.DB()
 
 	// For now, return all patients. In production, you'd filter by doctor assignment
-	if err := db.Preload("Pregnancies").Preload("MedicalHistories").Find(&patients).Error; err != nil {
+	if err := db.Preload("Pregnancies").Preload("MedicalHistories").Preload("ObstetricHistories").Find(&patients).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
 
+	for i := range patients {
+		attachGTPAL(&patients[i])
+	}
+
 	c.JSON(http.StatusOK, patients)
 }
 
@@ -34,24 +40,42 @@ func GetDoctorPatientDetail(c *gin.Context) {
 	if err := db.Preload("Pregnancies").
 		Preload("MedicalHistories").
 		Preload("Vaccinations").
-		Preload("PregnancyHistories").
-		Preload("Appointment").
+		Preload("ObstetricHistories", func(db *gorm.DB) *gorm.DB {
+			return db.Order("pregnancy_no ASC, baby_no ASC")
+		}).
 		First(&patient, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Patient not found"})
 		return
 	}
 
+	attachGTPAL(&patient)
+	attachNextAppointment(db, &patient)
+	attachAdherence(db, &patient)
+	attachClinicalNotes(db, &patient)
+
 	c.JSON(http.StatusOK, patient)
 }
 
+// attachGTPAL computes the GTPAL summary from the preloaded history and pregnancies
+func attachGTPAL(patient *entity.PregnantWoman) {
+	gtpal := service.ComputeGTPAL(patient.ObstetricHistories, patient.Pregnancies)
+	patient.GTPAL = &gtpal
+}
+
 // POST /doctor/patient/:id/appointment
 type CreateAppointmentInput struct {
 	AppointmentDate string `json:"appointment_date"`
 	Title           string `json:"title"`
 	Location        string `json:"location"`
+	Notes           string `json:"notes"`
 }
 
 func DoctorCreateAppointment(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+
 	id := c.Param("id")
 	var input CreateAppointmentInput
 
@@ -62,40 +86,54 @@ func DoctorCreateAppointment(c *gin.Context) {
 
 	db := config.DB()
 
-	// Parse date
-	// Expected format: "2025-11-25T09:00:00Z" or similar ISO
-	date, err := time.Parse(time.RFC3339, input.AppointmentDate)
+	// Expected format: "2025-11-25T09:00:00Z" or "2025-11-25"
+	date, err := parseAppointmentDate(input.AppointmentDate)
 	if err != nil {
-		// Try simple date format if ISO fails
-		date, err = time.Parse("2006-01-02", input.AppointmentDate)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format"})
-			return
-		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var patient entity.PregnantWoman
+	if err := db.First(&patient, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Patient not found"})
+		return
 	}
 
-	// Create Appointment
 	appt := entity.Appointment{
 		AppointmentDate: date,
 		Title:           input.Title,
 		Location:        input.Location,
+		Notes:           input.Notes,
+		Status:          entity.AppointmentStatusScheduled,
+		PregnantWomanID: &patient.ID,
+		DoctorID:        &doctorID,
 	}
 
-	if err := db.Create(&appt).Error; err != nil {
+	// Link to the active pregnancy if there is one
+	var pregnancy entity.Pregnancy
+	if err := db.Where("p_id = ? AND status = ?", patient.ID, entity.PregnancyStatusActive).First(&pregnancy).Error; err == nil {
+		appt.PregnancyID = &pregnancy.ID
+	}
+
+	// Reserve the doctor's time so the slot cannot be double-booked. Doctors may
+	// book outside their published hours; such appointments take a default-length slot.
+	end, err := slotEnd(db, appt.DoctorID, appt.AppointmentDate)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-
-	// Link to Patient
-	var patient entity.PregnantWoman
-	if err := db.First(&patient, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Patient not found"})
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&appt).Error; err != nil {
+			return err
+		}
+		return reserveSlot(tx, appt.DoctorID, appt.AppointmentDate, end, appt.ID)
+	})
+	if errors.Is(err, errSlotTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 		return
 	}
-
-	patient.AppointmentID = &appt.ID
-	if err := db.Save(&patient).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update patient appointment"})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
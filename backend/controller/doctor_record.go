@@ -10,6 +10,11 @@ import (
 
 // POST /doctor/antenatal-visit - Doctor creates new visit record
 func DoctorCreateAntenatalVisit(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+
 	var visit entity.AntenatalVisit
 
 	if err := c.ShouldBindJSON(&visit); err != nil {
@@ -19,8 +24,25 @@ func DoctorCreateAntenatalVisit(c *gin.Context) {
 
 	db := config.DB()
 
-	if err := db.Create(&visit).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	// No new visits on a closed pregnancy
+	if _, err := requireActivePregnancy(db, visit.PregnancyID); err != nil {
+		respondPregnancyError(c, err)
+		return
+	}
+
+	// Each fetal observation must belong to a fetus of this pregnancy
+	for i := range visit.FetalObservations {
+		if err := validateFetusOfPregnancy(db, &visit.FetalObservations[i].FetusID, visit.PregnancyID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// Saved as a draft until the doctor signs it
+	prepareDraftVisit(&visit, doctorID)
+
+	// Link to the appointment the mother checked in for
+	if !createVisitWithAppointment(c, db, &visit) {
 		return
 	}
 
@@ -49,7 +71,7 @@ func GetPatientVisits(c *gin.Context) {
 	var visits []entity.AntenatalVisit
 	pregnancyId := patient.Pregnancies[len(patient.Pregnancies)-1].ID
 
-	if err := db.Where("pregnancy_id = ?", pregnancyId).Find(&visits).Error; err != nil {
+	if err := db.Preload("FetalObservations.Fetus").Preload("Addenda").Where("pregnancy_id = ?", pregnancyId).Find(&visits).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
@@ -1,12 +1,14 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/bestiesmile1845/Projecteiei/config"
 	"github.com/bestiesmile1845/Projecteiei/entity"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // POST /kick-counts
@@ -28,14 +30,19 @@ func CreateFetalKickCount(c *gin.Context) {
 	}
 
 	// Check if pregnancy is active
-	var pregnancy entity.Pregnancy
-	if err := db.First(&pregnancy, kickCount.PregnancyID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Pregnancy not found"})
+	pregnancy, err := requireActivePregnancy(db, kickCount.PregnancyID)
+	if err != nil {
+		respondPregnancyError(c, err)
 		return
 	}
 
-	if pregnancy.Status != "Active" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Pregnancy is not active"})
+	// For twins/triplets each fetus has its own daily count
+	if pregnancy.FetusCount > 1 && kickCount.FetusID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "FetusID is required for multiple pregnancy"})
+		return
+	}
+	if err := validateFetusOfPregnancy(db, &kickCount.FetusID, kickCount.PregnancyID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -54,13 +61,21 @@ func CreateFetalKickCount(c *gin.Context) {
 
 	// Check if a record exists for this pregnancy on this date
 	var existingRecord entity.FetalKickCount
-	err := db.Where("pregnancy_id = ? AND count_date >= ? AND count_date < ?", kickCount.PregnancyID, startOfDay, endOfDay).First(&existingRecord).Error
+	query := db.Where("pregnancy_id = ? AND count_date >= ? AND count_date < ?", kickCount.PregnancyID, startOfDay, endOfDay)
+	if kickCount.FetusID != nil {
+		// Counts saved before the fetus was required have no fetus
+		query = query.Where("fetus_id = ? OR fetus_id IS NULL", *kickCount.FetusID)
+	} else {
+		query = query.Where("fetus_id IS NULL")
+	}
+	err = query.First(&existingRecord).Error
 
 	if err == nil {
 		// Record exists, update it
 		existingRecord.KickCountMorning = kickCount.KickCountMorning
 		existingRecord.KickCountLunch = kickCount.KickCountLunch
 		existingRecord.KickCountEvening = kickCount.KickCountEvening
+		existingRecord.FetusID = kickCount.FetusID
 		// Update CountDate to the new one if needed, or keep the original
 		// existingRecord.CountDate = kickCount.CountDate 
 
@@ -87,10 +102,22 @@ func GetFetalKickCountsByPregnancyID(c *gin.Context) {
 	db := config.DB()
 
 	// Find records by PregnancyID and Sort by Date
-	if err := db.Where("pregnancy_id = ?", id).Order("count_date ASC").Find(&kickCounts).Error; err != nil {
+	if err := db.Preload("Fetus").Where("pregnancy_id = ?", id).Order("count_date ASC").Find(&kickCounts).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, kickCounts)
 }
+
+// recomputeFetalKickCount keeps a corrected daily count sensible
+func recomputeFetalKickCount(db *gorm.DB, record interface{}) error {
+	kickCount := record.(*entity.FetalKickCount)
+	if kickCount.CountDate.IsZero() {
+		return errors.New("CountDate is required")
+	}
+	if kickCount.KickCountMorning < 0 || kickCount.KickCountLunch < 0 || kickCount.KickCountEvening < 0 {
+		return errors.New("Kick counts cannot be negative")
+	}
+	return nil
+}
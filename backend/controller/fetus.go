@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/bestiesmile1845/Projecteiei/config"
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// fetusLabels ใช้ตั้งชื่อทารกในครรภ์แฝด (Twin A, Twin B, ...)
+var fetusLabels = []string{"A", "B", "C", "D", "E", "F"}
+
+const maxFetusCount = 6
+
+// syncFetuses makes sure the pregnancy has exactly `count` fetus records.
+// Extra fetuses are soft-deleted from the highest number down.
+func syncFetuses(tx *gorm.DB, pregnancy *entity.Pregnancy, count int) error {
+	if count < 1 {
+		count = 1
+	}
+
+	var fetuses []entity.Fetus
+	if err := tx.Where("pregnancy_id = ?", pregnancy.ID).Order("fetus_no ASC").Find(&fetuses).Error; err != nil {
+		return err
+	}
+
+	for no := len(fetuses) + 1; no <= count; no++ {
+		fetus := entity.Fetus{
+			PregnancyID: &pregnancy.ID,
+			FetusNo:     no,
+			Label:       fetusLabels[no-1],
+		}
+		if err := tx.Create(&fetus).Error; err != nil {
+			return err
+		}
+	}
+
+	if len(fetuses) > count {
+		if err := tx.Where("pregnancy_id = ? AND fetus_no > ?", pregnancy.ID, count).Delete(&entity.Fetus{}).Error; err != nil {
+			return err
+		}
+	}
+
+	return tx.Model(pregnancy).Update("fetus_count", count).Error
+}
+
+// validateFetusOfPregnancy checks that the fetus belongs to the given pregnancy.
+// A record without a fetus is assigned the only fetus of a singleton pregnancy;
+// a multiple pregnancy requires the fetus to be given.
+func validateFetusOfPregnancy(db *gorm.DB, fetusID **uint, pregnancyID *uint) error {
+	if *fetusID == nil {
+		if pregnancyID == nil {
+			return nil
+		}
+		var fetuses []entity.Fetus
+		if err := db.Where("pregnancy_id = ?", *pregnancyID).Find(&fetuses).Error; err != nil {
+			return err
+		}
+		switch len(fetuses) {
+		case 0:
+			return nil
+		case 1:
+			*fetusID = &fetuses[0].ID
+			return nil
+		}
+		return fmt.Errorf("fetus_id is required for a multiple pregnancy")
+	}
+	var fetus entity.Fetus
+	if err := db.First(&fetus, **fetusID).Error; err != nil {
+		return fmt.Errorf("fetus %d not found", **fetusID)
+	}
+	if pregnancyID == nil || fetus.PregnancyID == nil || *fetus.PregnancyID != *pregnancyID {
+		return fmt.Errorf("fetus %d does not belong to this pregnancy", **fetusID)
+	}
+	return nil
+}
+
+// GET /pregnancies/:id/fetuses
+func GetFetusesByPregnancyID(c *gin.Context) {
+	id := c.Param("id")
+	db := config.DB()
+
+	var fetuses []entity.Fetus
+	if err := db.Where("pregnancy_id = ?", id).Order("fetus_no ASC").Find(&fetuses).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{"data": []entity.Fetus{}})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": fetuses})
+}
+
+// PUT /doctor/pregnancy/:id/fetuses - Update number of fetuses (e.g. after ultrasound)
+func UpdateFetusCount(c *gin.Context) {
+	if _, ok := requireDoctor(c); !ok {
+		return
+	}
+
+	id := c.Param("id")
+	var input struct {
+		FetusCount int `json:"fetus_count"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.FetusCount < 1 || input.FetusCount > maxFetusCount {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("fetus_count must be between 1 and %d", maxFetusCount)})
+		return
+	}
+
+	db := config.DB()
+
+	pregnancy, err := requireActivePregnancy(db, id)
+	if err != nil {
+		respondPregnancyError(c, err)
+		return
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return syncFetuses(tx, pregnancy, input.FetusCount)
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	db.Preload("Fetuses", func(db *gorm.DB) *gorm.DB {
+		return db.Order("fetus_no ASC")
+	}).First(pregnancy, pregnancy.ID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Fetus count updated", "data": pregnancy})
+}
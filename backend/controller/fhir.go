@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/config"
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const fhirContentType = "application/fhir+json; charset=utf-8"
+
+// respondFhir writes a FHIR resource or Bundle as application/fhir+json
+func respondFhir(c *gin.Context, status int, body interface{}) {
+	c.Header("Content-Type", fhirContentType)
+	c.JSON(status, body)
+}
+
+// respondFhirError writes an OperationOutcome; FHIR clients expect it instead of {"error": ...}
+func respondFhirError(c *gin.Context, status int, code, diagnostics string) {
+	respondFhir(c, status, service.FhirOperationOutcome("error", code, diagnostics))
+}
+
+// fhirBaseURL is the absolute base the Bundle fullUrls are built on
+func fhirBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host + "/fhir"
+}
+
+// GET /fhir/Patient/:id/$everything - A mother's record as a FHIR R4 Bundle
+func FhirPatientEverything(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil {
+		respondFhirError(c, http.StatusNotFound, "not-found", "Patient not found")
+		return
+	}
+	role, userID := currentActor(c)
+	if role != "doctor" && (role != "pregnant" || uint(id) != userID) {
+		respondFhirError(c, http.StatusNotFound, "not-found", "Patient not found")
+		return
+	}
+
+	db := config.DB()
+
+	var woman entity.PregnantWoman
+	if err := db.First(&woman, id).Error; err != nil {
+		respondFhirError(c, http.StatusNotFound, "not-found", "Patient not found")
+		return
+	}
+
+	bundle, err := fhirPatientBundle(db, woman, fhirBaseURL(c))
+	if err != nil {
+		respondFhirError(c, http.StatusInternalServerError, "exception", err.Error())
+		return
+	}
+	respondFhir(c, http.StatusOK, bundle)
+}
+
+// fhirPatientBundle builds the searchset Bundle of a mother's record; base is the server's FHIR URL
+func fhirPatientBundle(db *gorm.DB, woman entity.PregnantWoman, base string) (service.FhirBundle, error) {
+	var pregnancies []entity.Pregnancy
+	if err := db.Preload("AntenatalVisits", func(db *gorm.DB) *gorm.DB {
+		return db.Order("visit_date ASC")
+	}).Preload("LabResults", func(db *gorm.DB) *gorm.DB {
+		return db.Order("test_date ASC")
+	}).Preload("LabResults.Observations.LabTest").
+		Where("p_id = ?", woman.ID).Order("lmp ASC").Find(&pregnancies).Error; err != nil {
+		return service.FhirBundle{}, err
+	}
+
+	var vaccinations []entity.Vaccination
+	if err := db.Preload("VaccineType").Where("p_id = ?", woman.ID).Find(&vaccinations).Error; err != nil {
+		return service.FhirBundle{}, err
+	}
+
+	bundle := service.FhirBundle{
+		ResourceType: "Bundle",
+		Type:         "searchset",
+		Timestamp:    service.FhirDateTime(time.Now()),
+	}
+	add := func(res service.FhirResource) {
+		r := res
+		bundle.Entry = append(bundle.Entry, service.FhirBundleEntry{
+			FullURL:  base + "/" + r.ResourceType + "/" + r.ID,
+			Resource: &r,
+		})
+	}
+
+	patient := service.FhirPatient(woman)
+	patientRef := service.FhirReferenceTo("Patient", patient.ID)
+	add(patient)
+
+	for _, p := range pregnancies {
+		episode, condition := service.FhirPregnancy(p, patientRef)
+		add(episode)
+		add(condition)
+		for _, v := range p.AntenatalVisits {
+			encounter, observations := service.FhirAntenatalVisit(v, patientRef)
+			add(encounter)
+			for _, obs := range observations {
+				add(obs)
+			}
+		}
+		for _, r := range p.LabResults {
+			report, observations := service.FhirLabResult(r, patientRef)
+			add(report)
+			for _, obs := range observations {
+				add(obs)
+			}
+		}
+	}
+	for _, v := range vaccinations {
+		for _, immunization := range service.FhirVaccination(v, patientRef) {
+			add(immunization)
+		}
+	}
+
+	total := len(bundle.Entry)
+	bundle.Total = &total
+	return bundle, nil
+}
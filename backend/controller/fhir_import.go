@@ -0,0 +1,623 @@
+package controller
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/config"
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// fhirImportError aborts an import; the whole Bundle is rolled back
+type fhirImportError struct {
+	status int
+	code   string // OperationOutcome issue code
+	entry  int
+	msg    string
+}
+
+func (e *fhirImportError) Error() string {
+	return fmt.Sprintf("entry %d: %s", e.entry, e.msg)
+}
+
+// fhirRecordKind ties a local identifier kind to its table and to the patient that owns it
+type fhirRecordKind struct {
+	identifier string // kind in service.FhirLocalIdentifier
+	recordType string // FhirLink.RecordType
+	owner      func(db *gorm.DB, patientID uint) *gorm.DB
+}
+
+var (
+	fhirPatientKind   = fhirRecordKind{"patient", "pregnant_woman", nil}
+	fhirPregnancyKind = fhirRecordKind{"pregnancy", "pregnancy", func(db *gorm.DB, patientID uint) *gorm.DB {
+		return db.Where("p_id = ?", patientID)
+	}}
+	fhirVisitKind = fhirRecordKind{"antenatal-visit", "antenatal_visit", func(db *gorm.DB, patientID uint) *gorm.DB {
+		return db.Where("pregnancy_id IN (?)", db.Model(&entity.Pregnancy{}).Select("id").Where("p_id = ?", patientID))
+	}}
+	fhirLabResultKind = fhirRecordKind{"lab-result", "lab_result", func(db *gorm.DB, patientID uint) *gorm.DB {
+		return db.Where("pregnancy_id IN (?)", db.Model(&entity.Pregnancy{}).Select("id").Where("p_id = ?", patientID))
+	}}
+	fhirVaccinationKind = fhirRecordKind{"vaccination", "vaccination", func(db *gorm.DB, patientID uint) *gorm.DB {
+		return db.Where("p_id = ?", patientID)
+	}}
+)
+
+// fhirImporter upserts the entries of one Bundle inside a transaction
+type fhirImporter struct {
+	tx       *gorm.DB
+	doctorID uint
+	entries  []service.FhirBundleEntry
+	index    map[string]int // fullUrl and Type/id -> entry
+	local    map[int]uint   // entry -> local id of the record it was imported into
+	results  []service.FhirEntryResponse
+}
+
+func newFhirImporter(tx *gorm.DB, doctorID uint, entries []service.FhirBundleEntry) *fhirImporter {
+	im := &fhirImporter{
+		tx:       tx,
+		doctorID: doctorID,
+		entries:  entries,
+		index:    map[string]int{},
+		local:    map[int]uint{},
+		results:  make([]service.FhirEntryResponse, len(entries)),
+	}
+	for i, entry := range entries {
+		if entry.FullURL != "" {
+			im.index[entry.FullURL] = i
+		}
+		if entry.Resource != nil && entry.Resource.ID != "" {
+			im.index[entry.Resource.ResourceType+"/"+entry.Resource.ID] = i
+		}
+	}
+	return im
+}
+
+func (im *fhirImporter) fail(status int, code string, entry int, format string, args ...interface{}) error {
+	return &fhirImportError{status: status, code: code, entry: entry, msg: fmt.Sprintf(format, args...)}
+}
+
+// resolve finds the entry a reference points to: a fullUrl (urn:uuid:..., absolute URL) or Type/id
+func (im *fhirImporter) resolve(ref *service.FhirReference, resourceType string) (int, bool) {
+	if ref == nil || ref.Reference == "" {
+		return 0, false
+	}
+	i, ok := im.index[ref.Reference]
+	if !ok {
+		// absolute references to the same server end in Type/id
+		parts := strings.Split(strings.TrimRight(ref.Reference, "/"), "/")
+		if len(parts) >= 2 {
+			i, ok = im.index[parts[len(parts)-2]+"/"+parts[len(parts)-1]]
+		}
+	}
+	if !ok || im.entries[i].Resource.ResourceType != resourceType {
+		return 0, false
+	}
+	return i, true
+}
+
+// resolveImported resolves a reference to an entry that has already been imported and returns its local id
+func (im *fhirImporter) resolveImported(entry int, ref *service.FhirReference, resourceType string) (uint, error) {
+	i, ok := im.resolve(ref, resourceType)
+	if !ok {
+		return 0, im.fail(http.StatusBadRequest, "invalid", entry, "reference to a %s that is not in the Bundle", resourceType)
+	}
+	id, ok := im.local[i]
+	if !ok {
+		return 0, im.fail(http.StatusBadRequest, "invalid", entry, "referenced %s entry %d was not imported", resourceType, i)
+	}
+	return id, nil
+}
+
+// existing loads the record an imported resource updates: by this system's identifier, or by an
+// identifier linked from an earlier import. It reports false when the resource is new.
+func (im *fhirImporter) existing(res service.FhirResource, kind fhirRecordKind, patientID uint, record interface{}) (bool, error) {
+	scoped := func() *gorm.DB {
+		if kind.owner == nil {
+			return im.tx
+		}
+		return kind.owner(im.tx, patientID)
+	}
+
+	// identifiers of this system only count when the record belongs to the same patient
+	if id, ok := service.FhirLocalID(res, kind.identifier); ok {
+		err := scoped().First(record, id).Error
+		if err == nil {
+			return true, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, err
+		}
+	}
+
+	for _, identifier := range res.Identifier {
+		if identifier.System == "" || identifier.Value == "" || strings.HasPrefix(identifier.System, service.FhirIdentifierBase) {
+			continue
+		}
+		var link entity.FhirLink
+		err := im.tx.Where("resource_type = ? AND system = ? AND value = ? AND record_type = ?",
+			res.ResourceType, identifier.System, identifier.Value, kind.recordType).First(&link).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		err = scoped().First(record, link.RecordID).Error
+		if err == nil {
+			return true, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// pregnancyAt finds the pregnancy of the patient that was ongoing on a date, for resources
+// from other systems that do not say which pregnancy they belong to
+func (im *fhirImporter) pregnancyAt(entry int, patientID uint, value string) (uint, error) {
+	at, err := service.ParseFhirDateTime(value)
+	if err != nil || at.IsZero() {
+		return 0, im.fail(http.StatusBadRequest, "invalid", entry, "a date is needed to find the pregnancy")
+	}
+	var pregnancy entity.Pregnancy
+	if err := im.tx.Where("p_id = ? AND (lmp <= ? OR lmp IS NULL) AND (outcome_date IS NULL OR outcome_date >= ?)", patientID, at, at).
+		Order("lmp DESC").First(&pregnancy).Error; err != nil {
+		return 0, im.fail(http.StatusUnprocessableEntity, "not-found", entry, "no pregnancy of the patient covers %s", service.FhirDate(at))
+	}
+	return pregnancy.ID, nil
+}
+
+// link remembers the external identifiers of a resource so a later import updates the same record
+func (im *fhirImporter) link(res service.FhirResource, kind fhirRecordKind, recordID uint) error {
+	for _, identifier := range res.Identifier {
+		if identifier.System == "" || identifier.Value == "" || strings.HasPrefix(identifier.System, service.FhirIdentifierBase) {
+			continue
+		}
+		link := entity.FhirLink{
+			ResourceType: res.ResourceType,
+			System:       identifier.System,
+			Value:        identifier.Value,
+			RecordType:   kind.recordType,
+			RecordID:     recordID,
+		}
+		if err := im.tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "resource_type"}, {Name: "system"}, {Name: "value"}},
+			DoUpdates: clause.AssignmentColumns([]string{"record_type", "record_id", "updated_at"}),
+		}).Create(&link).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// done records the response of an imported entry
+func (im *fhirImporter) done(entry int, created bool, resourceType string, id uint) {
+	status := "200 OK"
+	if created {
+		status = "201 Created"
+	}
+	im.local[entry] = id
+	im.results[entry] = service.FhirEntryResponse{Status: status, Location: resourceType + "/" + strconv.FormatUint(uint64(id), 10)}
+}
+
+// consumed marks an entry that was imported as part of another one (an Observation of an Encounter, ...)
+func (im *fhirImporter) consumed(entry, parent int) {
+	im.results[entry] = service.FhirEntryResponse{Status: im.results[parent].Status, Location: im.results[parent].Location}
+}
+
+// run imports the entries by resource type so that references point at records that already exist
+func (im *fhirImporter) run() error {
+	steps := []struct {
+		resourceType string
+		importEntry  func(int, service.FhirResource) error
+	}{
+		{"Patient", im.importPatient},
+		{"EpisodeOfCare", im.importPregnancy},
+		{"Encounter", im.importVisit},
+		{"DiagnosticReport", im.importLabResult},
+		{"Immunization", im.importImmunization},
+	}
+	for _, step := range steps {
+		for i, entry := range im.entries {
+			if entry.Resource != nil && entry.Resource.ResourceType == step.resourceType {
+				if err := step.importEntry(i, *entry.Resource); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for i, entry := range im.entries {
+		if im.results[i].Status != "" {
+			continue
+		}
+		what := "entry without a resource"
+		if entry.Resource != nil {
+			what = entry.Resource.ResourceType
+			if entry.Resource.ResourceType == "Observation" || entry.Resource.ResourceType == "Condition" {
+				what += " that is not part of an imported Encounter, DiagnosticReport or EpisodeOfCare"
+			}
+		}
+		// ไม่ล้มทั้ง Bundle เพราะ HIS มักส่งทรัพยากรอื่นมาด้วย แต่แจ้งว่าไม่ได้นำเข้า
+		im.results[i] = service.FhirEntryResponse{
+			Status:  "200 OK",
+			Outcome: service.FhirOperationOutcome("warning", "not-supported", what+" was not imported"),
+		}
+	}
+	return nil
+}
+
+func (im *fhirImporter) importPatient(entry int, res service.FhirResource) error {
+	var woman entity.PregnantWoman
+	found, err := im.existing(res, fhirPatientKind, 0, &woman)
+	if err != nil {
+		return err
+	}
+
+	cid, hn := service.FhirPatientKeys(res)
+	if found && (cid != "" || hn != "") && !(cid != "" && cid == woman.CitizenID) && !(hn != "" && hn == woman.HN) {
+		// รหัสของระบบนี้อาจมาจากอีกเครื่องหนึ่ง ถือว่าเป็นคนเดียวกันเมื่อเลขบัตรหรือ HN ตรงกันเท่านั้น
+		found, woman = false, entity.PregnantWoman{}
+	}
+	if !found && cid != "" {
+		found = im.tx.Where("citizen_id = ?", cid).First(&woman).Error == nil
+	}
+	if !found && hn != "" {
+		found = im.tx.Where("hn = ?", hn).First(&woman).Error == nil
+	}
+
+	if err := service.ApplyFhirPatient(res, &woman); err != nil {
+		return im.fail(http.StatusBadRequest, "invalid", entry, "%s", err.Error())
+	}
+	if woman.FullName == "" {
+		return im.fail(http.StatusBadRequest, "required", entry, "Patient needs a name")
+	}
+	woman.Age = 0
+	if !woman.BirthDate.IsZero() {
+		now := time.Now()
+		woman.Age = now.Year() - woman.BirthDate.Year()
+		if now.YearDay() < woman.BirthDate.YearDay() {
+			woman.Age--
+		}
+	}
+
+	if found {
+		if err := im.tx.Model(&woman).Select("full_name", "birth_date", "age", "hn", "citizen_id", "phone_number", "email").
+			Updates(&woman).Error; err != nil {
+			return err
+		}
+	} else {
+		// บัญชีที่นำเข้ายังเข้าสู่ระบบไม่ได้จนกว่าจะตั้งรหัสผ่าน
+		random := make([]byte, 6)
+		if _, err := rand.Read(random); err != nil {
+			return err
+		}
+		woman.Username = "fhir-" + hex.EncodeToString(random)
+		if err := im.tx.Omit(clause.Associations).Create(&woman).Error; err != nil {
+			return err
+		}
+	}
+	if err := im.link(res, fhirPatientKind, woman.ID); err != nil {
+		return err
+	}
+	im.done(entry, !found, "Patient", woman.ID)
+	return nil
+}
+
+func (im *fhirImporter) importPregnancy(entry int, res service.FhirResource) error {
+	patientID, err := im.resolveImported(entry, res.Patient, "Patient")
+	if err != nil {
+		return err
+	}
+
+	var condition *service.FhirResource
+	conditionEntry := -1
+	for _, diagnosis := range res.Diagnosis {
+		if i, ok := im.resolve(&diagnosis.Condition, "Condition"); ok {
+			condition, conditionEntry = im.entries[i].Resource, i
+			break
+		}
+	}
+
+	var pregnancy entity.Pregnancy
+	found, err := im.existing(res, fhirPregnancyKind, patientID, &pregnancy)
+	if err != nil {
+		return err
+	}
+	pregnancy.PregnantWomanID = &patientID
+	if err := service.ApplyFhirPregnancy(res, condition, &pregnancy); err != nil {
+		return im.fail(http.StatusBadRequest, "invalid", entry, "%s", err.Error())
+	}
+	if pregnancy.FetusCount < 1 || pregnancy.FetusCount > maxFetusCount {
+		pregnancy.FetusCount = 1
+	}
+
+	if found {
+		if err := im.tx.Model(&pregnancy).Select("lmp", "edc", "pregnancy_no", "fetus_count", "outcome_date").
+			Updates(&pregnancy).Error; err != nil {
+			return err
+		}
+	} else {
+		if pregnancy.Status == entity.PregnancyStatusActive {
+			if err := ensureNoOtherActivePregnancy(im.tx, &pregnancy); err != nil {
+				return im.fail(http.StatusConflict, "conflict", entry, "%s", err.Error())
+			}
+		}
+		if pregnancy.PregnancyNo == 0 {
+			var count int64
+			im.tx.Model(&entity.Pregnancy{}).Where("p_id = ?", patientID).Count(&count)
+			pregnancy.PregnancyNo = int(count) + 1
+		}
+		if err := im.tx.Omit(clause.Associations).Create(&pregnancy).Error; err != nil {
+			return err
+		}
+	}
+	if err := syncFetuses(im.tx, &pregnancy, pregnancy.FetusCount); err != nil {
+		return err
+	}
+	if err := im.link(res, fhirPregnancyKind, pregnancy.ID); err != nil {
+		return err
+	}
+	im.done(entry, !found, "EpisodeOfCare", pregnancy.ID)
+	if conditionEntry >= 0 {
+		im.consumed(conditionEntry, entry)
+	}
+	return nil
+}
+
+func (im *fhirImporter) importVisit(entry int, res service.FhirResource) error {
+	patientID, err := im.resolveImported(entry, res.Subject, "Patient")
+	if err != nil {
+		return err
+	}
+	var pregnancyID uint
+	if len(res.EpisodeOfCare) > 0 {
+		if pregnancyID, err = im.resolveImported(entry, &res.EpisodeOfCare[0], "EpisodeOfCare"); err != nil {
+			return err
+		}
+	} else {
+		start := ""
+		if res.Period != nil {
+			start = res.Period.Start
+		}
+		if pregnancyID, err = im.pregnancyAt(entry, patientID, start); err != nil {
+			return err
+		}
+	}
+
+	var observations []service.FhirResource
+	var observationEntries []int
+	for i, e := range im.entries {
+		if e.Resource != nil && e.Resource.ResourceType == "Observation" {
+			if j, ok := im.resolve(e.Resource.Encounter, "Encounter"); ok && j == entry {
+				observations = append(observations, *e.Resource)
+				observationEntries = append(observationEntries, i)
+			}
+		}
+	}
+
+	var visit entity.AntenatalVisit
+	found, err := im.existing(res, fhirVisitKind, patientID, &visit)
+	if err != nil {
+		return err
+	}
+	if found && visit.RecordStatus == entity.RecordStatusSigned {
+		return im.fail(http.StatusConflict, "conflict", entry, "%s", errRecordSigned.Error())
+	}
+
+	// ค่าที่ Bundle ไม่ได้ส่งมาจะถูกล้าง เพราะ Encounter พร้อม Observation คือข้อมูลทั้งหมดของการตรวจครั้งนั้น
+	updated := entity.AntenatalVisit{Model: visit.Model, AppointmentID: visit.AppointmentID, DoctorID: visit.DoctorID}
+	updated.PregnancyID = &pregnancyID
+	if err := service.ApplyFhirEncounter(res, observations, &updated); err != nil {
+		return im.fail(http.StatusBadRequest, "invalid", entry, "%s", err.Error())
+	}
+	updated.RecordStatus = entity.RecordStatusDraft
+	if found {
+		if err := im.tx.Omit(clause.Associations).Save(&updated).Error; err != nil {
+			return err
+		}
+	} else {
+		updated.DoctorID = &im.doctorID
+		if err := im.tx.Omit(clause.Associations).Create(&updated).Error; err != nil {
+			return err
+		}
+	}
+	if err := im.link(res, fhirVisitKind, updated.ID); err != nil {
+		return err
+	}
+	im.done(entry, !found, "Encounter", updated.ID)
+	for _, i := range observationEntries {
+		im.consumed(i, entry)
+	}
+	return nil
+}
+
+func (im *fhirImporter) importLabResult(entry int, res service.FhirResource) error {
+	patientID, err := im.resolveImported(entry, res.Subject, "Patient")
+	if err != nil {
+		return err
+	}
+	var pregnancyID uint
+	if ext, ok := service.FhirExtensionValue(res, "pregnancy"); ok && ext.ValueReference != nil {
+		if pregnancyID, err = im.resolveImported(entry, ext.ValueReference, "EpisodeOfCare"); err != nil {
+			return err
+		}
+	} else if pregnancyID, err = im.pregnancyAt(entry, patientID, res.EffectiveDateTime); err != nil {
+		return err
+	}
+	var pregnancy entity.Pregnancy
+	if err := im.tx.First(&pregnancy, pregnancyID).Error; err != nil {
+		return err
+	}
+
+	var observations []service.FhirResource
+	var observationEntries []int
+	for _, ref := range res.Result {
+		i, ok := im.resolve(&ref, "Observation")
+		if !ok {
+			return im.fail(http.StatusBadRequest, "invalid", entry, "result %s is not an Observation in the Bundle", ref.Reference)
+		}
+		observations = append(observations, *im.entries[i].Resource)
+		observationEntries = append(observationEntries, i)
+	}
+
+	testDate, values, err := service.FhirLabValues(res, observations)
+	if err != nil {
+		return im.fail(http.StatusBadRequest, "invalid", entry, "%s", err.Error())
+	}
+	inputs := make([]labObservationInput, 0, len(values))
+	for _, v := range values {
+		inputs = append(inputs, labObservationInput{Code: v.Code, Value: v.Value})
+	}
+	// ค่าปกติและการแปลผลคำนวณใหม่จากแคตตาล็อกของระบบนี้
+	labObservations, err := buildLabObservations(im.tx, &pregnancy, testDate, inputs)
+	if err != nil {
+		return im.fail(http.StatusBadRequest, "invalid", entry, "%s", err.Error())
+	}
+
+	var result entity.LabResult
+	found, err := im.existing(res, fhirLabResultKind, patientID, &result)
+	if err != nil {
+		return err
+	}
+	if found && result.RecordStatus == entity.RecordStatusSigned {
+		return im.fail(http.StatusConflict, "conflict", entry, "%s", errRecordSigned.Error())
+	}
+
+	result.PregnancyID = &pregnancyID
+	result.TestDate = testDate
+	result.OtherRemarks = res.Conclusion
+	result.RecordStatus = entity.RecordStatusDraft
+	if found {
+		if err := im.tx.Omit(clause.Associations).Save(&result).Error; err != nil {
+			return err
+		}
+		if err := im.tx.Where("lab_result_id = ?", result.ID).Delete(&entity.LabObservation{}).Error; err != nil {
+			return err
+		}
+	} else {
+		result.DoctorID = &im.doctorID
+		if err := im.tx.Omit(clause.Associations).Create(&result).Error; err != nil {
+			return err
+		}
+	}
+	for i := range labObservations {
+		labObservations[i].LabResultID = &result.ID
+	}
+	if len(labObservations) > 0 {
+		if err := im.tx.Create(&labObservations).Error; err != nil {
+			return err
+		}
+	}
+	if err := im.link(res, fhirLabResultKind, result.ID); err != nil {
+		return err
+	}
+	im.done(entry, !found, "DiagnosticReport", result.ID)
+	for _, i := range observationEntries {
+		im.consumed(i, entry)
+	}
+	return nil
+}
+
+func (im *fhirImporter) importImmunization(entry int, res service.FhirResource) error {
+	patientID, err := im.resolveImported(entry, res.Patient, "Patient")
+	if err != nil {
+		return err
+	}
+	vaccineName := service.FhirConceptText(res.VaccineCode)
+	if vaccineName == "" {
+		return im.fail(http.StatusBadRequest, "required", entry, "Immunization needs vaccineCode text or display")
+	}
+
+	var vaccineType entity.VaccineType
+	vaccineCode := service.FhirCodeIn(res.VaccineCode, service.FhirVaccineCodes)
+	if err := im.tx.Where(entity.VaccineType{Name: vaccineName}).Attrs(entity.VaccineType{Code: vaccineCode}).FirstOrCreate(&vaccineType).Error; err != nil {
+		return err
+	}
+
+	// โดสของวัคซีนชนิดเดียวกันบันทึกรวมในระเบียนเดียว
+	var vaccination entity.Vaccination
+	found, err := im.existing(res, fhirVaccinationKind, patientID, &vaccination)
+	if err != nil {
+		return err
+	}
+	if !found {
+		found = im.tx.Where("p_id = ? AND vaccine_type_id = ?", patientID, vaccineType.ID).First(&vaccination).Error == nil
+	}
+	vaccination.PregnantWomanID = &patientID
+	vaccination.VaccineTypeID = &vaccineType.ID
+	if err := service.ApplyFhirImmunization(res, &vaccination); err != nil {
+		return im.fail(http.StatusBadRequest, "invalid", entry, "%s", err.Error())
+	}
+	if err := im.tx.Omit(clause.Associations).Save(&vaccination).Error; err != nil {
+		return err
+	}
+	if err := im.link(res, fhirVaccinationKind, vaccination.ID); err != nil {
+		return err
+	}
+	im.done(entry, !found, "Immunization", vaccination.ID)
+	return nil
+}
+
+// POST /fhir - Import a FHIR R4 Bundle; records are matched by identifier and created or updated
+func FhirImportBundle(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+
+	var bundle service.FhirBundle
+	if err := json.NewDecoder(c.Request.Body).Decode(&bundle); err != nil {
+		respondFhirError(c, http.StatusBadRequest, "invalid", "invalid JSON: "+err.Error())
+		return
+	}
+	if bundle.ResourceType != "Bundle" {
+		respondFhirError(c, http.StatusBadRequest, "invalid", "resourceType must be Bundle")
+		return
+	}
+	switch bundle.Type {
+	case "transaction", "batch", "collection", "searchset", "document":
+	default:
+		respondFhirError(c, http.StatusBadRequest, "invalid", "unsupported Bundle type "+bundle.Type)
+		return
+	}
+
+	var im *fhirImporter
+	err := config.DB().Transaction(func(tx *gorm.DB) error {
+		im = newFhirImporter(tx, doctorID, bundle.Entry)
+		return im.run()
+	})
+	if err != nil {
+		var importErr *fhirImportError
+		if errors.As(err, &importErr) {
+			respondFhirError(c, importErr.status, importErr.code, importErr.Error())
+			return
+		}
+		respondFhirError(c, http.StatusInternalServerError, "exception", err.Error())
+		return
+	}
+
+	response := service.FhirBundle{
+		ResourceType: "Bundle",
+		Type:         "transaction-response",
+		Timestamp:    service.FhirDateTime(time.Now()),
+	}
+	for i := range im.results {
+		result := im.results[i]
+		response.Entry = append(response.Entry, service.FhirBundleEntry{Response: &result})
+	}
+	respondFhir(c, http.StatusOK, response)
+}
@@ -0,0 +1,366 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/service"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB opens an in-memory database with the record and booking tables and a two-test lab catalog
+func openTestDB(t *testing.T, name string) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"_"+name+"?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&entity.PregnantWoman{}, &entity.Pregnancy{}, &entity.Fetus{}, &entity.AntenatalVisit{},
+		&entity.LabTest{}, &entity.LabReferenceRange{}, &entity.LabResult{}, &entity.LabObservation{},
+		&entity.LabOrder{}, &entity.VaccineType{}, &entity.Vaccination{}, &entity.FhirLink{}, &entity.Hl7InboundMessage{},
+		&entity.Appointment{}, &entity.AppointmentSlot{}, &entity.DoctorAvailability{}, &entity.AvailabilityException{}); err != nil {
+		t.Fatal(err)
+	}
+
+	low := func(v float64) *float64 { return &v }
+	for _, test := range []entity.LabTest{
+		{Code: "HB", Name: "Hemoglobin", Unit: "g/dL", ValueType: entity.LabValueNumeric, ReferenceRanges: []entity.LabReferenceRange{
+			{Trimester: 1, Low: low(11)}, {Trimester: 2, Low: low(10.5)}, {Trimester: 3, Low: low(11)},
+		}},
+		{Code: "ANTI_HIV", Name: "Anti-HIV", ValueType: entity.LabValueChoice, Choices: "Negative,Positive,Inconclusive", ReferenceRanges: []entity.LabReferenceRange{
+			{NormalValues: "Negative"},
+		}},
+	} {
+		if err := db.Create(&test).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func fhirTestTime(value string) time.Time {
+	t, _ := time.Parse("2006-01-02", value)
+	return t
+}
+
+// seedFhirPatient records a mother with a delivered pregnancy (her history), the current
+// pregnancy with two visits and a lab result, and a vaccination
+func seedFhirPatient(t *testing.T, db *gorm.DB) entity.PregnantWoman {
+	t.Helper()
+	woman := entity.PregnantWoman{Username: "somying", FullName: "สมหญิง ใจดี", CitizenID: "1103700012345", HN: "HN-0042",
+		PhoneNumber: "0812345678", BirthDate: fhirTestTime("1995-03-14")}
+	delivered := fhirTestTime("2025-01-18")
+	pregnancies := []entity.Pregnancy{
+		{PregnancyNo: 1, Status: entity.PregnancyStatusDelivered, FetusCount: 1, LMP: fhirTestTime("2024-04-15"),
+			EDC: fhirTestTime("2025-01-20"), OutcomeDate: &delivered},
+		{PregnancyNo: 2, Status: entity.PregnancyStatusActive, FetusCount: 1, LMP: fhirTestTime("2026-05-01"),
+			EDC: fhirTestTime("2027-02-05")},
+	}
+	hb := 10.2
+	if err := db.Create(&woman).Error; err != nil {
+		t.Fatal(err)
+	}
+	for i := range pregnancies {
+		pregnancies[i].PregnantWomanID = &woman.ID
+	}
+	if err := db.Create(&pregnancies).Error; err != nil {
+		t.Fatal(err)
+	}
+	history, current := pregnancies[0].ID, pregnancies[1].ID
+
+	visits := []entity.AntenatalVisit{
+		{PregnancyID: &history, VisitDate: time.Date(2024, 12, 20, 9, 0, 0, 0, time.UTC), GestationalAge: 35, Weight: 63,
+			BloodPressure: "120/80", RecordStatus: entity.RecordStatusSigned},
+		{PregnancyID: &current, VisitDate: time.Date(2026, 7, 1, 9, 30, 0, 0, time.UTC), GestationalAge: 8, Weight: 55.5,
+			BloodPressure: "110/70", UrineProtein: "Negative", MedicalDiagnosis: "Normal pregnancy", RecordStatus: entity.RecordStatusSigned},
+		{PregnancyID: &current, VisitDate: time.Date(2026, 8, 26, 10, 0, 0, 0, time.UTC), GestationalAge: 16, Weight: 57.2,
+			BloodPressure: "112/72", HeightFundus: 16, FetalHeartSound: "150", RecordStatus: entity.RecordStatusDraft},
+	}
+	if err := db.Create(&visits).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var tests []entity.LabTest
+	db.Order("id").Find(&tests)
+	lab := entity.LabResult{PregnancyID: &current, TestDate: fhirTestTime("2026-07-01"), OtherRemarks: "repeat Hb in 4 weeks",
+		RecordStatus: entity.RecordStatusDraft, Observations: []entity.LabObservation{
+			{LabTestID: &tests[0].ID, Code: "HB", ValueNumber: &hb, Unit: "g/dL", Flag: entity.LabFlagLow},
+			{LabTestID: &tests[1].ID, Code: "ANTI_HIV", ValueText: "Negative", Flag: entity.LabFlagNormal},
+		}}
+	if err := db.Create(&lab).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	vaccine := entity.VaccineType{Name: "dT"}
+	dose1, dose2 := fhirTestTime("2026-07-01"), fhirTestTime("2026-08-26")
+	vaccination := entity.Vaccination{PregnantWomanID: &woman.ID, VaccineType: &vaccine,
+		Dose1DateDuringPreg: &dose1, Dose2DateDuringPreg: &dose2}
+	if err := db.Create(&vaccination).Error; err != nil {
+		t.Fatal(err)
+	}
+	return woman
+}
+
+// exportFhirPatient exports the mother's record and reads it back the way a receiving server does
+func exportFhirPatient(t *testing.T, db *gorm.DB, woman entity.PregnantWoman) service.FhirBundle {
+	t.Helper()
+	bundle, err := fhirPatientBundle(db, woman, "https://source.example/fhir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded service.FhirBundle
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
+
+// asExternalSystem rewrites the identifiers of this system into those of another HIS, so the
+// target can only match records again through FhirLink
+func asExternalSystem(bundle service.FhirBundle) {
+	for _, entry := range bundle.Entry {
+		for i, identifier := range entry.Resource.Identifier {
+			if strings.HasPrefix(identifier.System, service.FhirIdentifierBase) && identifier.Type == nil {
+				entry.Resource.Identifier[i].System = "https://his.example/id/" + strings.TrimPrefix(identifier.System, service.FhirIdentifierBase)
+			}
+		}
+	}
+}
+
+func importFhirBundle(db *gorm.DB, bundle service.FhirBundle) ([]service.FhirEntryResponse, error) {
+	var results []service.FhirEntryResponse
+	err := db.Transaction(func(tx *gorm.DB) error {
+		im := newFhirImporter(tx, 1, bundle.Entry)
+		if err := im.run(); err != nil {
+			return err
+		}
+		results = im.results
+		return nil
+	})
+	return results, err
+}
+
+// importedStatuses counts the response status of the entries that were imported as records
+func importedStatuses(bundle service.FhirBundle, results []service.FhirEntryResponse) map[string]int {
+	statuses := map[string]int{}
+	for i, entry := range bundle.Entry {
+		switch entry.Resource.ResourceType {
+		case "Patient", "EpisodeOfCare", "Encounter", "DiagnosticReport", "Immunization":
+			statuses[results[i].Status]++
+		}
+	}
+	return statuses
+}
+
+func loadFhirPatient(t *testing.T, db *gorm.DB, cid string) (entity.PregnantWoman, []entity.Pregnancy, entity.Vaccination) {
+	t.Helper()
+	var woman entity.PregnantWoman
+	if err := db.Where("citizen_id = ?", cid).First(&woman).Error; err != nil {
+		t.Fatal(err)
+	}
+	var pregnancies []entity.Pregnancy
+	if err := db.Preload("AntenatalVisits", func(db *gorm.DB) *gorm.DB {
+		return db.Order("visit_date ASC")
+	}).Preload("LabResults.Observations", func(db *gorm.DB) *gorm.DB {
+		return db.Order("code DESC")
+	}).Where("p_id = ?", woman.ID).Order("lmp ASC").Find(&pregnancies).Error; err != nil {
+		t.Fatal(err)
+	}
+	var vaccination entity.Vaccination
+	db.Preload("VaccineType").Where("p_id = ?", woman.ID).First(&vaccination)
+	return woman, pregnancies, vaccination
+}
+
+func TestFhirExportImportRoundTrip(t *testing.T) {
+	source := openTestDB(t, "source")
+	target := openTestDB(t, "target")
+	sent := seedFhirPatient(t, source)
+	bundle := exportFhirPatient(t, source, sent)
+
+	results, err := importFhirBundle(target, bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, result := range results {
+		if result.Outcome != nil {
+			t.Errorf("entry %d (%s) was not imported: %+v", i, bundle.Entry[i].Resource.ResourceType, result.Outcome)
+		}
+	}
+	// Patient, 2 EpisodeOfCare, 3 Encounter, 1 DiagnosticReport; the second dose updates the vaccination of the first
+	if got := importedStatuses(bundle, results); got["201 Created"] != 8 || got["200 OK"] != 1 {
+		t.Fatalf("statuses = %v", got)
+	}
+
+	_, wantPregnancies, wantVaccination := loadFhirPatient(t, source, sent.CitizenID)
+	woman, pregnancies, vaccination := loadFhirPatient(t, target, sent.CitizenID)
+	if woman.FullName != sent.FullName || woman.HN != sent.HN || woman.PhoneNumber != sent.PhoneNumber ||
+		!woman.BirthDate.Equal(sent.BirthDate) {
+		t.Errorf("patient = %+v", woman)
+	}
+
+	if len(pregnancies) != len(wantPregnancies) {
+		t.Fatalf("%d pregnancies, want %d", len(pregnancies), len(wantPregnancies))
+	}
+	for i, p := range pregnancies {
+		want := wantPregnancies[i]
+		if p.Status != want.Status || p.PregnancyNo != want.PregnancyNo || !p.LMP.Equal(want.LMP) || !p.EDC.Equal(want.EDC) ||
+			(p.OutcomeDate == nil) != (want.OutcomeDate == nil) {
+			t.Errorf("pregnancy %d = %+v, want %+v", i, p, want)
+		}
+
+		if len(p.AntenatalVisits) != len(want.AntenatalVisits) {
+			t.Fatalf("pregnancy %d: %d visits, want %d", i, len(p.AntenatalVisits), len(want.AntenatalVisits))
+		}
+		for j, v := range p.AntenatalVisits {
+			w := want.AntenatalVisits[j]
+			if !v.VisitDate.Equal(w.VisitDate) || v.GestationalAge != w.GestationalAge || v.Weight != w.Weight ||
+				v.BloodPressure != w.BloodPressure || v.HeightFundus != w.HeightFundus || v.FetalHeartSound != w.FetalHeartSound ||
+				v.UrineProtein != w.UrineProtein || v.MedicalDiagnosis != w.MedicalDiagnosis {
+				t.Errorf("pregnancy %d visit %d = %+v, want %+v", i, j, v, w)
+			}
+			// ผลที่นำเข้าต้องให้แพทย์ของที่นี่ลงนามเอง
+			if v.RecordStatus != entity.RecordStatusDraft {
+				t.Errorf("imported visit is %s", v.RecordStatus)
+			}
+		}
+
+		if len(p.LabResults) != len(want.LabResults) {
+			t.Fatalf("pregnancy %d: %d lab results, want %d", i, len(p.LabResults), len(want.LabResults))
+		}
+		for j, r := range p.LabResults {
+			w := want.LabResults[j]
+			if !r.TestDate.Equal(w.TestDate) || r.OtherRemarks != w.OtherRemarks || len(r.Observations) != len(w.Observations) {
+				t.Fatalf("lab result = %+v, want %+v", r, w)
+			}
+			for k, o := range r.Observations {
+				wo := w.Observations[k]
+				if o.Code != wo.Code || o.ValueText != wo.ValueText || o.Flag != wo.Flag ||
+					(o.ValueNumber == nil) != (wo.ValueNumber == nil) || (o.ValueNumber != nil && *o.ValueNumber != *wo.ValueNumber) {
+					t.Errorf("observation %s = %+v, want %+v", wo.Code, o, wo)
+				}
+			}
+		}
+	}
+
+	if vaccination.VaccineType == nil || vaccination.VaccineType.Name != "dT" ||
+		vaccination.Dose1DateDuringPreg == nil || !vaccination.Dose1DateDuringPreg.Equal(*wantVaccination.Dose1DateDuringPreg) ||
+		vaccination.Dose2DateDuringPreg == nil || !vaccination.Dose2DateDuringPreg.Equal(*wantVaccination.Dose2DateDuringPreg) {
+		t.Errorf("vaccination = %+v", vaccination)
+	}
+}
+
+func TestFhirReimportUpdatesLinkedRecords(t *testing.T) {
+	source := openTestDB(t, "source")
+	target := openTestDB(t, "target")
+	sent := seedFhirPatient(t, source)
+	bundle := exportFhirPatient(t, source, sent)
+	asExternalSystem(bundle)
+
+	if _, err := importFhirBundle(target, bundle); err != nil {
+		t.Fatal(err)
+	}
+	counts := func() map[string]int64 {
+		got := map[string]int64{}
+		for table, model := range map[string]interface{}{
+			"pregnant_women": &entity.PregnantWoman{}, "pregnancies": &entity.Pregnancy{}, "antenatal_visits": &entity.AntenatalVisit{},
+			"lab_results": &entity.LabResult{}, "lab_observations": &entity.LabObservation{}, "vaccinations": &entity.Vaccination{},
+			"fhir_links": &entity.FhirLink{},
+		} {
+			var n int64
+			target.Model(model).Count(&n)
+			got[table] = n
+		}
+		return got
+	}
+	before := counts()
+	if before["fhir_links"] == 0 {
+		t.Fatal("the first import left no FhirLink")
+	}
+
+	// the HIS sends the same record again with a corrected weight
+	for _, entry := range bundle.Entry {
+		if entry.Resource.ResourceType == "Observation" && strings.HasSuffix(entry.Resource.ID, "-"+service.VisitFindingWeight) &&
+			entry.Resource.ValueQuantity.Value == 57.2 {
+			entry.Resource.ValueQuantity.Value = 57.8
+		}
+	}
+	results, err := importFhirBundle(target, bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := importedStatuses(bundle, results); got["201 Created"] != 0 {
+		t.Fatalf("re-import created records again: %v", got)
+	}
+	after := counts()
+	for table, n := range before {
+		if after[table] != n {
+			t.Errorf("%s: %d rows after the re-import, %d before", table, after[table], n)
+		}
+	}
+
+	var link entity.FhirLink
+	if err := target.Where("resource_type = ? AND system = ?", "Encounter", "https://his.example/id/antenatal-visit").
+		Order("id DESC").First(&link).Error; err != nil {
+		t.Fatal(err)
+	}
+	var visit entity.AntenatalVisit
+	target.First(&visit, link.RecordID)
+	if visit.Weight != 57.8 {
+		t.Errorf("linked visit weight = %v, want the corrected 57.8", visit.Weight)
+	}
+}
+
+func TestFhirImportRefusesSignedRecords(t *testing.T) {
+	tests := []struct {
+		name  string
+		table string
+	}{
+		{"visit", "antenatal_visits"},
+		{"lab result", "lab_results"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := openTestDB(t, "source")
+			target := openTestDB(t, "target")
+			sent := seedFhirPatient(t, source)
+			bundle := exportFhirPatient(t, source, sent)
+			asExternalSystem(bundle)
+			if _, err := importFhirBundle(target, bundle); err != nil {
+				t.Fatal(err)
+			}
+
+			// a doctor here signs the imported records
+			now := time.Now()
+			target.Table(tt.table).Where("1 = 1").Updates(map[string]interface{}{"record_status": entity.RecordStatusSigned, "signed_at": now})
+			target.Model(&entity.PregnantWoman{}).Where("citizen_id = ?", sent.CitizenID).Update("phone_number", "0899999999")
+
+			_, err := importFhirBundle(target, bundle)
+			var importErr *fhirImportError
+			if !errors.As(err, &importErr) || importErr.status != http.StatusConflict {
+				t.Fatalf("re-import over a signed %s = %v, want a 409", tt.name, err)
+			}
+
+			// the whole Bundle is rolled back, including the Patient imported before the refused entry
+			woman, _, _ := loadFhirPatient(t, target, sent.CitizenID)
+			if woman.PhoneNumber != "0899999999" {
+				t.Errorf("patient phone = %s after a refused import", woman.PhoneNumber)
+			}
+			var drafts int64
+			target.Table(tt.table).Where("record_status <> ?", entity.RecordStatusSigned).Count(&drafts)
+			if drafts != 0 {
+				t.Errorf("%d signed %s rows were overwritten", drafts, tt.table)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/config"
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/scheduler"
+	"github.com/bestiesmile1845/Projecteiei/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// POST /doctor/follow-ups/refresh - Refresh the worklist now instead of waiting for the scheduler
+func RefreshFollowUpWorklist(c *gin.Context) {
+	if _, ok := requireDoctor(c); !ok {
+		return
+	}
+
+	if err := scheduler.RefreshFollowUps(config.DB(), time.Now(), scheduler.FollowUpGapWeeks()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Follow-up worklist refreshed"})
+}
+
+// GET /doctor/follow-ups?status=Open|Closed|all&kind= - Follow-up worklist
+func GetFollowUpWorklist(c *gin.Context) {
+	if _, ok := requireDoctor(c); !ok {
+		return
+	}
+
+	db := config.DB()
+
+	query := db.Preload("PregnantWoman", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id", "full_name", "hn", "phone_number")
+	}).Preload("OutreachAttempts", func(db *gorm.DB) *gorm.DB {
+		return db.Order("attempted_at ASC")
+	})
+	switch status := c.DefaultQuery("status", entity.FollowUpStatusOpen); status {
+	case "all":
+	case entity.FollowUpStatusOpen, entity.FollowUpStatusClosed:
+		query = query.Where("status = ?", status)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be Open, Closed or all"})
+		return
+	}
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+
+	var items []entity.FollowUpItem
+	if err := query.Order("due_date ASC").Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": items})
+}
+
+// POST /doctor/follow-ups/:id/outreach - Record a call or message to the mother
+func RecordFollowUpOutreach(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+	var input struct {
+		Method      string     `json:"method"`
+		Outcome     string     `json:"outcome"`
+		Notes       string     `json:"notes"`
+		AttemptedAt *time.Time `json:"attempted_at"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := service.ValidateOutreach(input.Method, input.Outcome); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := config.DB()
+
+	var item entity.FollowUpItem
+	if err := db.First(&item, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Follow-up item not found"})
+		return
+	}
+
+	attemptedAt := time.Now()
+	if input.AttemptedAt != nil {
+		attemptedAt = *input.AttemptedAt
+	}
+
+	outreach := entity.FollowUpOutreach{
+		FollowUpItemID: &item.ID,
+		Method:         input.Method,
+		Outcome:        input.Outcome,
+		Notes:          input.Notes,
+		DoctorID:       &doctorID,
+		AttemptedAt:    attemptedAt,
+	}
+	if err := db.Create(&outreach).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Outreach recorded", "data": outreach})
+}
+
+// POST /doctor/follow-ups/:id/close - Close a follow-up item
+func CloseFollowUp(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+	var input struct {
+		Reason string `json:"reason"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if strings.TrimSpace(input.Reason) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason is required"})
+		return
+	}
+
+	db := config.DB()
+
+	var item entity.FollowUpItem
+	if err := db.First(&item, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Follow-up item not found"})
+		return
+	}
+
+	if item.Status == entity.FollowUpStatusClosed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Follow-up item is already closed"})
+		return
+	}
+
+	now := time.Now()
+	item.Status = entity.FollowUpStatusClosed
+	item.ClosedAt = &now
+	item.ClosedByID = &doctorID
+	item.CloseReason = input.Reason
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&item).Error; err != nil {
+			return err
+		}
+		// ปิดรายการติดตามของแผนการรักษา = ทำรายการในแผนแล้ว
+		if item.Kind == entity.FollowUpPlanItem && item.NotePlanItemID != nil {
+			return tx.Model(&entity.NotePlanItem{}).
+				Where("id = ? AND status = ?", *item.NotePlanItemID, entity.PlanItemOpen).
+				Updates(map[string]interface{}{"status": entity.PlanItemDone, "done_at": now}).Error
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Follow-up closed", "data": item})
+}
@@ -0,0 +1,242 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/config"
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// gdmStatus is the GDM workflow state of one pregnancy
+type gdmStatus struct {
+	PregnancyID      uint                     `json:"pregnancy_id"`
+	PregnantWoman    *entity.PregnantWoman    `json:"pregnant_woman,omitempty"`
+	GestationalWeeks int                      `json:"gestational_weeks"`
+	RiskFactors      []string                 `json:"risk_factors"`
+	HighRisk         bool                     `json:"high_risk"`
+	NextStep         service.GdmNextStep      `json:"next_step"`
+	Diagnosed        bool                     `json:"diagnosed"`
+	DiagnosedAt      *time.Time               `json:"diagnosed_at"`
+	Screenings       []entity.GdmScreening    `json:"screenings"`
+	Plan             *service.GdmFollowUpPlan `json:"plan,omitempty"`
+}
+
+// preloadGdm loads what the GDM risk assessment needs
+func preloadGdm(db *gorm.DB) *gorm.DB {
+	return db.Preload("PregnantWoman.MedicalHistories").
+		Preload("PregnantWoman.ObstetricHistories").
+		Preload("PregnantWoman.Pregnancies").
+		Preload("AntenatalVisits").
+		Preload("GdmScreenings", func(db *gorm.DB) *gorm.DB {
+			return db.Order("test_date ASC, id ASC")
+		})
+}
+
+// buildGdmStatus evaluates a pregnancy loaded with preloadGdm
+func buildGdmStatus(db *gorm.DB, pregnancy entity.Pregnancy, now time.Time) gdmStatus {
+	input := service.GdmRiskInput{Pregnancy: pregnancy, Visits: pregnancy.AntenatalVisits}
+	if pregnancy.PregnantWoman != nil {
+		input.Woman = *pregnancy.PregnantWoman
+		input.MedicalHistories = pregnancy.PregnantWoman.MedicalHistories
+		input.Obstetric = pregnancy.PregnantWoman.ObstetricHistories
+		input.OtherPregnancies = pregnancy.PregnantWoman.Pregnancies
+	}
+
+	status := gdmStatus{
+		PregnancyID:      pregnancy.ID,
+		GestationalWeeks: service.GestationalWeeks(service.PregnancyLMP(pregnancy), now),
+		RiskFactors:      service.GdmRiskFactors(input),
+		Diagnosed:        pregnancy.GdmDiagnosed,
+		DiagnosedAt:      pregnancy.GdmDiagnosedAt,
+		Screenings:       pregnancy.GdmScreenings,
+	}
+	if status.Screenings == nil {
+		status.Screenings = []entity.GdmScreening{}
+	}
+	status.HighRisk = len(status.RiskFactors) > 0
+	status.NextStep = service.NextGdmStep(status.HighRisk, status.GestationalWeeks, status.Diagnosed, status.Screenings)
+	if status.Diagnosed {
+		plan := service.BuildGdmPlan(loadMonitoringTarget(db, pregnancy.ID))
+		status.Plan = &plan
+	}
+	return status
+}
+
+// GET /pregnancies/:id/gdm - GDM risk, screening results, next step and plan
+func GetGdmStatus(c *gin.Context) {
+	id := c.Param("id")
+	db := config.DB()
+
+	found, ok := findPregnancyForActor(c, db, id)
+	if !ok {
+		return
+	}
+
+	var pregnancy entity.Pregnancy
+	if err := preloadGdm(db).First(&pregnancy, found.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": buildGdmStatus(db, pregnancy, time.Now())})
+}
+
+// applyGdmDiagnosis marks the pregnancy as GDM when a diagnostic test is positive
+func applyGdmDiagnosis(tx *gorm.DB, pregnancy *entity.Pregnancy, screening entity.GdmScreening, doctorID uint) error {
+	diagnosed := screening.Result == entity.GdmResultPositive && service.IsGdmDiagnosticTest(screening.TestType)
+	if !diagnosed || pregnancy.GdmDiagnosed {
+		return nil
+	}
+
+	diagnosedAt := screening.TestDate.UTC()
+	if err := tx.Model(pregnancy).Updates(map[string]interface{}{
+		"gdm_diagnosed":    true,
+		"gdm_diagnosed_at": diagnosedAt,
+	}).Error; err != nil {
+		return err
+	}
+
+	// เปิดการติดตามน้ำตาลที่บ้านด้วยเกณฑ์เริ่มต้น ถ้าแพทย์ยังไม่ได้กำหนด
+	var count int64
+	tx.Model(&entity.MonitoringTarget{}).Where("pregnancy_id = ?", pregnancy.ID).Count(&count)
+	if count == 0 {
+		target := service.DefaultMonitoringTarget()
+		target.PregnancyID = &pregnancy.ID
+		target.SetByID = &doctorID
+		return tx.Create(&target).Error
+	}
+	return nil
+}
+
+// recomputeGdmScreening interprets a corrected test again
+func recomputeGdmScreening(db *gorm.DB, record interface{}) error {
+	screening := record.(*entity.GdmScreening)
+	if err := service.InterpretGdmTest(screening); err != nil {
+		return err
+	}
+	if screening.TestDate.IsZero() || screening.TestDate.After(time.Now()) {
+		return errors.New("test_date must be set and cannot be in the future")
+	}
+	var pregnancy entity.Pregnancy
+	if screening.PregnancyID != nil {
+		db.First(&pregnancy, *screening.PregnancyID)
+	}
+	screening.GestationalWeeks = service.GestationalWeeks(service.PregnancyLMP(pregnancy), screening.TestDate)
+	screening.Early = screening.GestationalWeeks < service.GdmScreenFromWeeks
+	return nil
+}
+
+// afterGdmScreeningCorrection diagnoses GDM when a corrected OGTT turns positive
+func afterGdmScreeningCorrection(tx *gorm.DB, record interface{}, doctorID uint) error {
+	screening := record.(*entity.GdmScreening)
+	if screening.PregnancyID == nil {
+		return nil
+	}
+	var pregnancy entity.Pregnancy
+	if err := tx.First(&pregnancy, *screening.PregnancyID).Error; err != nil {
+		return err
+	}
+	return applyGdmDiagnosis(tx, &pregnancy, *screening, doctorID)
+}
+
+// POST /doctor/pregnancy/:id/gdm-screenings - Record a GCT or OGTT result
+func CreateGdmScreening(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+	var screening entity.GdmScreening
+
+	if err := c.ShouldBindJSON(&screening); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := service.InterpretGdmTest(&screening); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := config.DB()
+
+	pregnancy, err := requireActivePregnancy(db, id)
+	if err != nil {
+		respondPregnancyError(c, err)
+		return
+	}
+
+	now := time.Now()
+	if screening.TestDate.IsZero() {
+		screening.TestDate = now
+	}
+	if screening.TestDate.After(now) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "test_date cannot be in the future"})
+		return
+	}
+	screening.PregnancyID = &pregnancy.ID
+	screening.DoctorID = &doctorID
+	screening.GestationalWeeks = service.GestationalWeeks(service.PregnancyLMP(*pregnancy), screening.TestDate)
+	screening.Early = screening.GestationalWeeks < service.GdmScreenFromWeeks
+
+	diagnosed := screening.Result == entity.GdmResultPositive && service.IsGdmDiagnosticTest(screening.TestType)
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&screening).Error; err != nil {
+			return err
+		}
+		return applyGdmDiagnosis(tx, pregnancy, screening, doctorID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{"message": "GDM test recorded", "data": screening}
+	if diagnosed {
+		plan := service.BuildGdmPlan(loadMonitoringTarget(db, pregnancy.ID))
+		response["message"] = "Gestational diabetes diagnosed; home glucose monitoring enabled"
+		response["plan"] = plan
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// GET /doctor/gdm/due - Active pregnancies whose GDM screening or diagnostic test is due now
+func GetGdmScreeningDue(c *gin.Context) {
+	if _, ok := requireDoctor(c); !ok {
+		return
+	}
+
+	db := config.DB()
+
+	var pregnancies []entity.Pregnancy
+	if err := preloadGdm(db).Where("status = ?", entity.PregnancyStatusActive).Find(&pregnancies).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	rows := []gdmStatus{}
+	for _, p := range pregnancies {
+		status := buildGdmStatus(db, p, now)
+		if !status.NextStep.DueNow {
+			continue
+		}
+		if p.PregnantWoman != nil {
+			status.PregnantWoman = &entity.PregnantWoman{
+				Model:       gorm.Model{ID: p.PregnantWoman.ID},
+				FullName:    p.PregnantWoman.FullName,
+				HN:          p.PregnantWoman.HN,
+				PhoneNumber: p.PregnantWoman.PhoneNumber,
+			}
+		}
+		rows = append(rows, status)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": rows})
+}
@@ -0,0 +1,513 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/config"
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Hl7MaxMessageBytes caps one message received over MLLP or HTTP
+const Hl7MaxMessageBytes = 1 << 20
+
+const hl7ContentType = "x-application/hl7-v2+er7"
+
+var errHl7MessageResolved = errors.New("Message is not waiting for reconciliation")
+
+// hl7Unfiled is why a message waits in the reconciliation queue instead of being filed
+type hl7Unfiled struct {
+	reason string
+}
+
+func (e *hl7Unfiled) Error() string {
+	return e.reason
+}
+
+func unfiled(format string, args ...interface{}) error {
+	return &hl7Unfiled{reason: fmt.Sprintf(format, args...)}
+}
+
+// hl7FillerSystem scopes the LIS accession numbers (OBR-3) of one sender.
+// The link lets a corrected result update the lab result it corrects.
+func hl7FillerSystem(message *entity.Hl7InboundMessage) string {
+	return "urn:hl7v2:" + message.SendingApplication + "@" + message.SendingFacility + ":filler-order"
+}
+
+// IngestHl7Message stores a message received from the LIS, files its results
+// when the patient and tests can be matched and returns the ACK to send back.
+// Used by the MLLP listener and POST /hl7/oru.
+func IngestHl7Message(raw []byte, via, remote string) string {
+	return ingestHl7Message(config.DB(), raw, via, remote, time.Now())
+}
+
+func ingestHl7Message(db *gorm.DB, raw []byte, via, remote string, now time.Time) string {
+	message := entity.Hl7InboundMessage{
+		ReceivedVia: via,
+		RemoteAddr:  remote,
+		Raw:         string(raw),
+		Status:      entity.Hl7MessageUnmatched,
+		Reason:      "Not processed",
+	}
+	reject := func(header service.Hl7Header, reason string) string {
+		message.Status = entity.Hl7MessageRejected
+		message.Reason = reason
+		message.ProcessedAt = &now
+		db.Create(&message)
+		return service.Hl7Ack(header, service.Hl7AckReject, reason, now)
+	}
+
+	msg, err := service.ParseHl7(message.Raw)
+	if err != nil {
+		return reject(service.Hl7Header{}, err.Error())
+	}
+	header := msg.Header()
+	message.SendingApplication = header.SendingApplication
+	message.SendingFacility = header.SendingFacility
+	message.ControlID = header.ControlID
+	message.MessageType = header.MessageType
+	if header.ControlID == "" {
+		return reject(header, "MSH-10 message control ID is required")
+	}
+
+	// The sender retransmits when our ACK was lost; answer again without filing twice
+	var previous entity.Hl7InboundMessage
+	if err := db.Where("sending_application = ? AND sending_facility = ? AND control_id = ? AND status <> ?",
+		header.SendingApplication, header.SendingFacility, header.ControlID, entity.Hl7MessageRejected).
+		First(&previous).Error; err == nil {
+		return service.Hl7Ack(header, service.Hl7AckAccept, "Duplicate of message "+strconv.FormatUint(uint64(previous.ID), 10), now)
+	}
+
+	patients, err := service.ParseOruR01(msg)
+	if err != nil {
+		return reject(header, err.Error())
+	}
+	describeHl7Patients(&message, patients)
+
+	// Stored before filing so a message is never lost; it stays Unmatched if filing fails
+	if err := db.Create(&message).Error; err != nil {
+		return service.Hl7Ack(header, service.Hl7AckError, err.Error(), now)
+	}
+	if err := fileHl7Message(db, &message, patients, nil, nil); err != nil {
+		return service.Hl7Ack(header, service.Hl7AckError, err.Error(), now)
+	}
+	if message.Status == entity.Hl7MessageUnmatched {
+		return service.Hl7Ack(header, service.Hl7AckAccept, "Queued for reconciliation: "+message.Reason, now)
+	}
+	return service.Hl7Ack(header, service.Hl7AckAccept, "Filed as lab result "+message.LabResultIDs, now)
+}
+
+// describeHl7Patients copies the PID identifiers onto the message for the queue
+func describeHl7Patients(message *entity.Hl7InboundMessage, patients []service.Hl7OruPatient) {
+	var hns, cids, names []string
+	for _, p := range patients {
+		hns = append(hns, p.HNs...)
+		cids = append(cids, p.CIDs...)
+		names = append(names, p.Name)
+	}
+	message.PatientHN = strings.Join(hns, ",")
+	message.PatientCitizenID = strings.Join(cids, ",")
+	message.PatientName = strings.Join(names, ",")
+}
+
+// fileHl7Message files every order of the message as a lab result in one
+// transaction. A message that cannot be filed completely is left Unmatched with
+// the reason; only database errors are returned. pregnancy and doctorID are set
+// when a doctor reconciles the message.
+func fileHl7Message(db *gorm.DB, message *entity.Hl7InboundMessage, patients []service.Hl7OruPatient, pregnancy *entity.Pregnancy, doctorID *uint) error {
+	if pregnancy != nil && len(patients) != 1 {
+		return unfiled("The message has results for %d patients; it cannot be filed on one pregnancy", len(patients))
+	}
+
+	var resultIDs []string
+	var target *entity.Pregnancy
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var tests []entity.LabTest
+		if err := tx.Preload("ReferenceRanges").Where("active = ?", true).Find(&tests).Error; err != nil {
+			return err
+		}
+		for _, p := range patients {
+			target = pregnancy
+			if target == nil {
+				var err error
+				if target, err = matchHl7Pregnancy(tx, p); err != nil {
+					return err
+				}
+			}
+			for _, order := range p.Orders {
+				id, err := fileHl7Order(tx, message, target, order, tests, doctorID)
+				if err != nil {
+					return err
+				}
+				if id != 0 {
+					resultIDs = append(resultIDs, strconv.FormatUint(uint64(id), 10))
+				}
+			}
+		}
+		if len(resultIDs) == 0 {
+			return unfiled("The message has no results to file")
+		}
+		return nil
+	})
+
+	now := time.Now()
+	updates := map[string]interface{}{"processed_at": now}
+	var reason *hl7Unfiled
+	switch {
+	case errors.As(err, &reason):
+		updates["status"] = entity.Hl7MessageUnmatched
+		updates["reason"] = reason.reason
+	case err != nil:
+		return err
+	default:
+		updates["status"] = entity.Hl7MessageFiled
+		updates["reason"] = ""
+		updates["lab_result_ids"] = strings.Join(resultIDs, ",")
+		if len(patients) == 1 {
+			updates["pregnant_woman_id"] = target.PregnantWomanID
+			updates["pregnancy_id"] = target.ID
+		}
+		if doctorID != nil {
+			updates["status"] = entity.Hl7MessageReconciled
+			updates["resolved_by_id"] = *doctorID
+			updates["resolved_at"] = now
+		}
+	}
+	if err := db.Model(message).Updates(updates).Error; err != nil {
+		return err
+	}
+	return db.First(message, message.ID).Error
+}
+
+// matchHl7Pregnancy finds the patient by citizen ID or HN and her active pregnancy
+func matchHl7Pregnancy(tx *gorm.DB, p service.Hl7OruPatient) (*entity.Pregnancy, error) {
+	identifiers := strings.Join(append(append([]string{}, p.HNs...), p.CIDs...), ", ")
+
+	query := tx.Where("1 = 0")
+	if len(p.CIDs) > 0 {
+		query = query.Or("citizen_id IN ?", p.CIDs)
+	}
+	if len(p.HNs) > 0 {
+		query = query.Or("hn IN ?", p.HNs)
+	}
+	var women []entity.PregnantWoman
+	if err := query.Find(&women).Error; err != nil {
+		return nil, err
+	}
+	switch {
+	case len(women) == 0:
+		return nil, unfiled("No patient with HN / citizen ID %s", identifiers)
+	case len(women) > 1:
+		return nil, unfiled("HN / citizen ID %s match %d different patients", identifiers, len(women))
+	}
+
+	var pregnancy entity.Pregnancy
+	if err := tx.Where("p_id = ? AND status = ?", women[0].ID, entity.PregnancyStatusActive).First(&pregnancy).Error; err != nil {
+		return nil, unfiled("%s (%s) has no active pregnancy", women[0].FullName, identifiers)
+	}
+	return &pregnancy, nil
+}
+
+// fileHl7Order creates the lab result of one OBR, or updates the result an
+// earlier message filed under the same accession number. It returns 0 when
+// every OBX of the order was cancelled or empty.
+func fileHl7Order(tx *gorm.DB, message *entity.Hl7InboundMessage, pregnancy *entity.Pregnancy, order service.Hl7OruOrder, tests []entity.LabTest, doctorID *uint) (uint, error) {
+	var inputs []labObservationInput
+	var problems []string
+	for _, obs := range order.Observations {
+		if obs.Skipped() {
+			continue
+		}
+		test, ok := service.MatchLabTest(tests, obs)
+		if !ok {
+			problems = append(problems, "unknown test code "+strings.Trim(obs.Code+" / "+obs.AltCode, " /")+" ("+obs.Text+")")
+			continue
+		}
+		value, err := service.Hl7LabValue(test, obs)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		inputs = append(inputs, labObservationInput{Code: test.Code, Value: value})
+	}
+	if len(problems) > 0 {
+		return 0, unfiled("%s", strings.Join(problems, "; "))
+	}
+	if len(inputs) == 0 {
+		return 0, nil
+	}
+
+	observedAt := order.ObservedAt
+	if observedAt.IsZero() {
+		observedAt = message.CreatedAt
+	}
+	testDate := time.Date(observedAt.Year(), observedAt.Month(), observedAt.Day(), 0, 0, 0, 0, time.UTC)
+	observations, err := buildLabObservations(tx, pregnancy, testDate, inputs)
+	if err != nil {
+		return 0, unfiled("%s", err.Error())
+	}
+	remarks := strings.Join(order.Notes, "\n")
+
+	// A corrected result arrives under the accession number of the result it corrects
+	var link entity.FhirLink
+	if order.FillerNumber != "" {
+		err := tx.Where("resource_type = ? AND system = ? AND value = ? AND record_type = ?",
+			"DiagnosticReport", hl7FillerSystem(message), order.FillerNumber, "lab_result").First(&link).Error
+		if err == nil {
+			return updateHl7LabResult(tx, link.RecordID, pregnancy, testDate, remarks, observations)
+		}
+	}
+
+	result := entity.LabResult{
+		PregnancyID:  &pregnancy.ID,
+		TestDate:     testDate,
+		OtherRemarks: remarks,
+		Observations: observations,
+		DoctorID:     doctorID,
+		RecordStatus: entity.RecordStatusDraft,
+	}
+
+	// OBR-2 is our order ID when the LIS received the order from this system
+	var labOrder *entity.LabOrder
+	if id, err := strconv.ParseUint(order.PlacerNumber, 10, 0); err == nil {
+		var found entity.LabOrder
+		if tx.Where("id = ? AND pregnancy_id = ?", id, pregnancy.ID).First(&found).Error == nil &&
+			service.CheckLabOrderTransition(found.Status, entity.LabOrderResulted) == nil {
+			labOrder = &found
+			if result.DoctorID == nil {
+				result.DoctorID = found.DoctorID
+			}
+		}
+	}
+
+	if err := tx.Create(&result).Error; err != nil {
+		return 0, err
+	}
+	if labOrder != nil {
+		if err := attachLabResultToOrder(tx, labOrder, &result, time.Now()); err != nil {
+			return 0, err
+		}
+	}
+	if order.FillerNumber != "" {
+		link = entity.FhirLink{
+			ResourceType: "DiagnosticReport",
+			System:       hl7FillerSystem(message),
+			Value:        order.FillerNumber,
+			RecordType:   "lab_result",
+			RecordID:     result.ID,
+		}
+		if err := tx.Create(&link).Error; err != nil {
+			return 0, err
+		}
+	}
+	return result.ID, nil
+}
+
+// updateHl7LabResult replaces the observations a corrected result repeats; the others are kept
+func updateHl7LabResult(tx *gorm.DB, id uint, pregnancy *entity.Pregnancy, testDate time.Time, remarks string, observations []entity.LabObservation) (uint, error) {
+	var result entity.LabResult
+	if err := tx.First(&result, id).Error; err != nil {
+		return 0, unfiled("Lab result %d of the corrected accession no longer exists", id)
+	}
+	if result.PregnancyID == nil || *result.PregnancyID != pregnancy.ID {
+		return 0, unfiled("The corrected accession was filed on another pregnancy (lab result %d)", id)
+	}
+	if result.RecordStatus == entity.RecordStatusSigned {
+		return 0, unfiled("Lab result %d is signed; %s", id, errRecordSigned.Error())
+	}
+
+	codes := make([]string, len(observations))
+	for i := range observations {
+		codes[i] = observations[i].Code
+		observations[i].LabResultID = &result.ID
+	}
+	if err := tx.Unscoped().Where("lab_result_id = ? AND code IN ?", result.ID, codes).Delete(&entity.LabObservation{}).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Create(&observations).Error; err != nil {
+		return 0, err
+	}
+	updates := map[string]interface{}{"test_date": testDate}
+	if remarks != "" {
+		updates["other_remarks"] = remarks
+	}
+	return result.ID, tx.Model(&result).Updates(updates).Error
+}
+
+// POST /hl7/oru - Receive an HL7 v2 ORU^R01 from the LIS (Authorization: Bearer HL7_HTTP_TOKEN); replies with the ACK
+func ReceiveHl7Message(c *gin.Context) {
+	token := os.Getenv("HL7_HTTP_TOKEN")
+	if token == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "HL7 endpoint is not enabled"})
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), []byte("Bearer "+token)) != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid HL7 token"})
+		return
+	}
+
+	raw, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, Hl7MaxMessageBytes))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Message is larger than 1 MB"})
+		return
+	}
+
+	// The ACK reports the outcome; HTTP errors are kept for transport and authentication problems
+	c.Data(http.StatusOK, hl7ContentType, []byte(IngestHl7Message(raw, "http", c.ClientIP())))
+}
+
+// GET /doctor/hl7-messages?status=Unmatched - Received HL7 messages, the reconciliation queue by default
+func GetHl7Messages(c *gin.Context) {
+	if _, ok := requireDoctor(c); !ok {
+		return
+	}
+
+	db := config.DB()
+	query := db.Model(&entity.Hl7InboundMessage{}).Omit("raw")
+	status := c.DefaultQuery("status", entity.Hl7MessageUnmatched)
+	if status != "all" {
+		query = query.Where("status = ?", status)
+	}
+	if patientID := c.Query("patient_id"); patientID != "" {
+		query = query.Where("pregnant_woman_id = ?", patientID)
+	}
+
+	var messages []entity.Hl7InboundMessage
+	if err := query.Order("created_at DESC").Limit(500).Find(&messages).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": messages})
+}
+
+// findHl7Message loads a message and its parsed results for the doctor endpoints
+func findHl7Message(c *gin.Context, db *gorm.DB) (*entity.Hl7InboundMessage, []service.Hl7OruPatient, bool) {
+	var message entity.Hl7InboundMessage
+	if err := db.First(&message, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "HL7 message not found"})
+		return nil, nil, false
+	}
+	var patients []service.Hl7OruPatient
+	if msg, err := service.ParseHl7(message.Raw); err == nil {
+		patients, _ = service.ParseOruR01(msg)
+	}
+	return &message, patients, true
+}
+
+// GET /doctor/hl7-messages/:id - A received message with its parsed patients and results
+func GetHl7Message(c *gin.Context) {
+	if _, ok := requireDoctor(c); !ok {
+		return
+	}
+
+	message, patients, ok := findHl7Message(c, config.DB())
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": message, "patients": patients})
+}
+
+// POST /doctor/hl7-messages/:id/reconcile - File a queued message, on the given pregnancy or by matching again
+func ReconcileHl7Message(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		PregnancyID uint `json:"pregnancy_id"` // ว่าง = จับคู่ใหม่ด้วย HN / เลขบัตรประชาชน (หลังแก้ข้อมูลผู้ป่วยหรือแคตตาล็อก)
+	}
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := config.DB()
+
+	message, patients, ok := findHl7Message(c, db)
+	if !ok {
+		return
+	}
+	if message.Status != entity.Hl7MessageUnmatched {
+		c.JSON(http.StatusConflict, gin.H{"error": errHl7MessageResolved.Error()})
+		return
+	}
+
+	var pregnancy *entity.Pregnancy
+	if input.PregnancyID != 0 {
+		var err error
+		if pregnancy, err = requireActivePregnancy(db, input.PregnancyID); err != nil {
+			respondPregnancyError(c, err)
+			return
+		}
+	}
+
+	err := fileHl7Message(db, message, patients, pregnancy, &doctorID)
+	var reason *hl7Unfiled
+	switch {
+	case errors.As(err, &reason):
+		c.JSON(http.StatusConflict, gin.H{"error": reason.reason})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	case message.Status != entity.Hl7MessageReconciled:
+		c.JSON(http.StatusConflict, gin.H{"error": message.Reason})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Message filed", "data": message})
+}
+
+// POST /doctor/hl7-messages/:id/discard - Remove a message from the queue without filing it
+func DiscardHl7Message(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || strings.TrimSpace(input.Reason) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason is required"})
+		return
+	}
+
+	db := config.DB()
+
+	message, _, ok := findHl7Message(c, db)
+	if !ok {
+		return
+	}
+	if message.Status != entity.Hl7MessageUnmatched && message.Status != entity.Hl7MessageRejected {
+		c.JSON(http.StatusConflict, gin.H{"error": errHl7MessageResolved.Error()})
+		return
+	}
+
+	now := time.Now()
+	if err := db.Model(message).Updates(map[string]interface{}{
+		"status":         entity.Hl7MessageDiscarded,
+		"reason":         strings.TrimSpace(input.Reason),
+		"resolved_by_id": doctorID,
+		"resolved_at":    now,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	db.First(message, message.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "Message discarded", "data": message})
+}
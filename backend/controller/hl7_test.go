@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/service"
+	"gorm.io/gorm"
+)
+
+func hl7TestORU(controlID, cid string) []byte {
+	return []byte(strings.Join([]string{
+		`MSH|^~\&|LIS|LAB|PROJECTEIEI|ANC|20260701103000+0700||ORU^R01|` + controlID + `|P|2.5.1`,
+		`PID|1||` + cid + `^^^MOI^NI||ใจดี^สมหญิง`,
+		`OBR|1||ACC-` + controlID + `|CBC|||20260701083000+0700`,
+		`OBX|1|NM|HB^Hemoglobin||10.2|g/dL|||||F`,
+		`OBX|2|CE|ANTI_HIV^Anti-HIV||NEG^Negative|||||F`,
+	}, "\r") + "\r")
+}
+
+// ingestForTest ingests a message and returns MSA-1 and MSA-3 of the ACK
+func ingestForTest(t *testing.T, db *gorm.DB, raw []byte) (code, text string) {
+	t.Helper()
+	ack, err := service.ParseHl7(ingestHl7Message(db, raw, "mllp", "10.0.0.5", time.Now()))
+	if err != nil {
+		t.Fatalf("ACK is not HL7: %v", err)
+	}
+	msa, ok := ack.Segment("MSA")
+	if !ok {
+		t.Fatal("ACK has no MSA")
+	}
+	return msa.Value(1, 1), msa.Value(3, 1)
+}
+
+func seedHl7Patient(t *testing.T, db *gorm.DB, cid string) entity.Pregnancy {
+	t.Helper()
+	woman := entity.PregnantWoman{Username: "somying", FullName: "สมหญิง ใจดี", CitizenID: cid}
+	if err := db.Create(&woman).Error; err != nil {
+		t.Fatal(err)
+	}
+	pregnancy := entity.Pregnancy{PregnantWomanID: &woman.ID, Status: entity.PregnancyStatusActive,
+		LMP: time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)}
+	if err := db.Create(&pregnancy).Error; err != nil {
+		t.Fatal(err)
+	}
+	return pregnancy
+}
+
+func countRows(db *gorm.DB, model interface{}) int64 {
+	var n int64
+	db.Model(model).Count(&n)
+	return n
+}
+
+func TestIngestHl7FilesOnceAndAcksRetransmissions(t *testing.T) {
+	db := openTestDB(t, "lis")
+	pregnancy := seedHl7Patient(t, db, "1103700012346")
+	raw := hl7TestORU("MSG0001", "1103700012346")
+
+	code, text := ingestForTest(t, db, raw)
+	if code != service.Hl7AckAccept || !strings.HasPrefix(text, "Filed as lab result") {
+		t.Fatalf("first ACK = %s %q", code, text)
+	}
+	var result entity.LabResult
+	if err := db.Preload("Observations").Where("pregnancy_id = ?", pregnancy.ID).First(&result).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Observations) != 2 {
+		t.Fatalf("filed %d observations", len(result.Observations))
+	}
+
+	// the LIS did not get our ACK and sends the same message again
+	code, text = ingestForTest(t, db, raw)
+	if code != service.Hl7AckAccept || !strings.HasPrefix(text, "Duplicate of message") {
+		t.Fatalf("ACK of the retransmission = %s %q", code, text)
+	}
+	if n := countRows(db, &entity.LabResult{}); n != 1 {
+		t.Errorf("%d lab results after a retransmission", n)
+	}
+	if n := countRows(db, &entity.Hl7InboundMessage{}); n != 1 {
+		t.Errorf("%d stored messages after a retransmission", n)
+	}
+}
+
+func TestIngestHl7QueuesUnknownPatientOnce(t *testing.T) {
+	db := openTestDB(t, "lis")
+	raw := hl7TestORU("MSG0002", "1103700012346")
+
+	for i := 0; i < 2; i++ {
+		if code, _ := ingestForTest(t, db, raw); code != service.Hl7AckAccept {
+			t.Fatalf("send %d: ACK %s for a message waiting for reconciliation", i+1, code)
+		}
+	}
+	var messages []entity.Hl7InboundMessage
+	db.Find(&messages)
+	if len(messages) != 1 || messages[0].Status != entity.Hl7MessageUnmatched || messages[0].PatientCitizenID != "1103700012346" {
+		t.Fatalf("queued messages = %+v", messages)
+	}
+}
+
+func TestIngestHl7Rejects(t *testing.T) {
+	db := openTestDB(t, "lis")
+	seedHl7Patient(t, db, "1103700012346")
+
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"not HL7", "hello", "MSH"},
+		{"no control ID", string(hl7TestORU("", "1103700012346")), "MSH-10"},
+		{"not an ORU", strings.Replace(string(hl7TestORU("MSG0003", "1103700012346")), "ORU^R01", "ADT^A08", 1), "only ORU^R01"},
+	}
+	for _, tt := range tests {
+		code, text := ingestForTest(t, db, []byte(tt.raw))
+		if code != service.Hl7AckReject || !strings.Contains(text, tt.want) {
+			t.Errorf("%s: ACK %s %q, want AR with %q", tt.name, code, text, tt.want)
+		}
+	}
+	if n := countRows(db, &entity.Hl7InboundMessage{}); n != int64(len(tests)) {
+		t.Errorf("%d rejected messages stored, want %d", n, len(tests))
+	}
+
+	// a rejected control ID is not a duplicate: the corrected message is filed
+	code, text := ingestForTest(t, db, hl7TestORU("MSG0003", "1103700012346"))
+	if code != service.Hl7AckAccept || !strings.HasPrefix(text, "Filed as lab result") {
+		t.Fatalf("ACK of the corrected message = %s %q", code, text)
+	}
+}
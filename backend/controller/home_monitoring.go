@@ -0,0 +1,356 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/config"
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/scheduler"
+	"github.com/bestiesmile1845/Projecteiei/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// loadMonitoringTarget returns the doctor's targets for the pregnancy or the defaults
+func loadMonitoringTarget(db *gorm.DB, pregnancyID uint) entity.MonitoringTarget {
+	var target entity.MonitoringTarget
+	if err := db.Where("pregnancy_id = ?", pregnancyID).First(&target).Error; err != nil {
+		target = service.DefaultMonitoringTarget()
+		target.PregnancyID = &pregnancyID
+	}
+	return target
+}
+
+// recomputeHomeReading re-validates a corrected reading and assesses it again
+func recomputeHomeReading(db *gorm.DB, record interface{}) error {
+	reading := record.(*entity.HomeReading)
+	if reading.Kind == entity.HomeReadingGlucose && reading.GlucoseContext == "" {
+		reading.GlucoseContext = entity.GlucoseUnspecified
+	}
+	if err := service.ValidateHomeReading(*reading, time.Now()); err != nil {
+		return err
+	}
+	target := service.DefaultMonitoringTarget()
+	if reading.PregnancyID != nil {
+		target = loadMonitoringTarget(db, *reading.PregnancyID)
+	}
+	reading.Assessment, reading.AlertReason = service.AssessHomeReading(*reading, target)
+	return nil
+}
+
+// GET /pregnancies/:id/monitoring-targets
+func GetMonitoringTarget(c *gin.Context) {
+	id := c.Param("id")
+	db := config.DB()
+
+	pregnancy, ok := findPregnancyForActor(c, db, id)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": loadMonitoringTarget(db, pregnancy.ID)})
+}
+
+// PUT /doctor/pregnancy/:id/monitoring-targets - Set the BP and glucose targets of a pregnancy
+func SetMonitoringTarget(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+	db := config.DB()
+
+	pregnancy, ok := findPregnancyForActor(c, db, id)
+	if !ok {
+		return
+	}
+
+	// ค่าที่ไม่ได้ส่งมาคงค่าเดิม (หรือค่าเริ่มต้น)
+	target := loadMonitoringTarget(db, pregnancy.ID)
+	existing := target.Model
+	if err := c.ShouldBindJSON(&target); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	target.Model = existing
+	if err := service.ValidateMonitoringTarget(target); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	target.PregnancyID = &pregnancy.ID
+	target.SetByID = &doctorID
+	if err := db.Save(&target).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Monitoring targets saved", "data": target})
+}
+
+// POST /pregnancies/:id/home-readings - Record a home BP or glucose reading
+func CreateHomeReading(c *gin.Context) {
+	id := c.Param("id")
+	var reading entity.HomeReading
+
+	if err := c.ShouldBindJSON(&reading); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	if reading.MeasuredAt.IsZero() {
+		reading.MeasuredAt = now
+	}
+	if reading.Kind == entity.HomeReadingGlucose && reading.GlucoseContext == "" {
+		reading.GlucoseContext = entity.GlucoseUnspecified
+	}
+	if err := service.ValidateHomeReading(reading, now); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := config.DB()
+
+	pregnancy, ok := findPregnancyForActor(c, db, id)
+	if !ok {
+		return
+	}
+	if pregnancy.Status != entity.PregnancyStatusActive && !service.IsPostpartumPregnancy(pregnancy.Status) {
+		respondPregnancyError(c, errPregnancyNotActive)
+		return
+	}
+
+	target := loadMonitoringTarget(db, pregnancy.ID)
+	reading.Assessment, reading.AlertReason = service.AssessHomeReading(reading, target)
+
+	role, userID := currentActor(c)
+	reading.PregnancyID = &pregnancy.ID
+	reading.MeasuredAt = reading.MeasuredAt.UTC()
+	reading.SubmittedBy = role
+	reading.SubmittedByID = userID
+	reading.AcknowledgedByID = nil
+	reading.AcknowledgedAt = nil
+
+	alert := reading.Assessment != entity.HomeReadingNormal
+	s := scheduler.Current()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&reading).Error; err != nil {
+			return err
+		}
+		if !alert || s == nil || pregnancy.PregnantWomanID == nil {
+			return nil
+		}
+		var woman entity.PregnantWoman
+		if err := tx.First(&woman, *pregnancy.PregnantWomanID).Error; err != nil {
+			return err
+		}
+		subject, body := service.HomeReadingAlertText(woman, reading)
+		queued, err := s.AlertCareTeam(tx, scheduler.CareTeamAlert{
+			Key:         fmt.Sprintf("home-reading:%d", reading.ID),
+			PregnancyID: pregnancy.ID,
+			Subject:     subject,
+			Body:        body,
+		}, now)
+		if err != nil {
+			return err
+		}
+		reading.AlertsQueued = queued
+		return tx.Model(&reading).Update("alerts_queued", queued).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if reading.AlertsQueued > 0 {
+		s.DeliverSoon()
+	}
+
+	// Out-of-range readings are always on the doctors' alert list; say "notified" only when a message went out
+	message := "Reading recorded"
+	switch {
+	case reading.Assessment == entity.HomeReadingSevere && reading.AlertsQueued > 0:
+		message = "Reading is in the severe range; your care team has been alerted, but contact the clinic or go to hospital now"
+	case reading.Assessment == entity.HomeReadingSevere:
+		message = "Reading is in the severe range; contact the clinic or go to hospital now"
+	case reading.AlertsQueued > 0:
+		message = "Reading is outside your target; your care team has been notified"
+	case alert:
+		message = "Reading is outside your target; your care team will review it"
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": message, "data": reading})
+}
+
+// homeReadingQuery filters the readings of a pregnancy by ?kind=&from=&to= (YYYY-MM-DD)
+func homeReadingQuery(c *gin.Context, db *gorm.DB, pregnancyID uint) (*gorm.DB, bool) {
+	query := db.Where("pregnancy_id = ?", pregnancyID)
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	if from := c.Query("from"); from != "" {
+		day, err := time.ParseInLocation("2006-01-02", from, service.ClinicLocation)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be YYYY-MM-DD"})
+			return nil, false
+		}
+		query = query.Where("measured_at >= ?", day.UTC())
+	}
+	if to := c.Query("to"); to != "" {
+		day, err := time.ParseInLocation("2006-01-02", to, service.ClinicLocation)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be YYYY-MM-DD"})
+			return nil, false
+		}
+		query = query.Where("measured_at < ?", day.AddDate(0, 0, 1).UTC())
+	}
+	return query, true
+}
+
+// GET /pregnancies/:id/home-readings?kind=bp&from=&to=
+func GetHomeReadings(c *gin.Context) {
+	id := c.Param("id")
+	db := config.DB()
+
+	pregnancy, ok := findPregnancyForActor(c, db, id)
+	if !ok {
+		return
+	}
+	query, ok := homeReadingQuery(c, db, pregnancy.ID)
+	if !ok {
+		return
+	}
+
+	var readings []entity.HomeReading
+	if err := query.Order("measured_at DESC").Find(&readings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": readings})
+}
+
+// GET /pregnancies/:id/home-readings/series?kind=glucose&from=&to= - Chart-ready time series
+func GetHomeReadingSeries(c *gin.Context) {
+	id := c.Param("id")
+	db := config.DB()
+
+	pregnancy, ok := findPregnancyForActor(c, db, id)
+	if !ok {
+		return
+	}
+	query, ok := homeReadingQuery(c, db, pregnancy.ID)
+	if !ok {
+		return
+	}
+
+	var readings []entity.HomeReading
+	if err := query.Find(&readings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"series":  service.ReadingSeries(readings),
+		"targets": loadMonitoringTarget(db, pregnancy.ID),
+	}})
+}
+
+// GET /pregnancies/:id/home-readings/weekly?weeks=4 - Weekly summaries, newest first
+func GetHomeReadingWeekly(c *gin.Context) {
+	id := c.Param("id")
+	weeks, err := strconv.Atoi(c.DefaultQuery("weeks", "4"))
+	if err != nil || weeks < 1 || weeks > 52 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "weeks must be between 1 and 52"})
+		return
+	}
+
+	db := config.DB()
+
+	pregnancy, ok := findPregnancyForActor(c, db, id)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	from := service.WeekStart(now).AddDate(0, 0, -7*(weeks-1))
+	var readings []entity.HomeReading
+	if err := db.Where("pregnancy_id = ? AND measured_at >= ?", pregnancy.ID, from.UTC()).
+		Find(&readings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": service.WeeklySummaries(readings, weeks, now)})
+}
+
+// GET /doctor/home-readings/alerts?acknowledged=false - Out-of-range home readings
+func GetHomeReadingAlerts(c *gin.Context) {
+	if _, ok := requireDoctor(c); !ok {
+		return
+	}
+
+	db := config.DB()
+	query := db.Preload("Pregnancy.PregnantWoman", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id", "full_name", "hn", "phone_number")
+	}).Where("assessment IN ?", []string{entity.HomeReadingOutOfRange, entity.HomeReadingSevere})
+	if c.DefaultQuery("acknowledged", "false") == "false" {
+		query = query.Where("acknowledged_at IS NULL")
+	}
+
+	var readings []entity.HomeReading
+	if err := query.Order("CASE assessment WHEN 'Severe' THEN 0 ELSE 1 END, measured_at DESC").
+		Find(&readings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	type alertRow struct {
+		entity.HomeReading
+		PregnantWoman *entity.PregnantWoman `json:"pregnant_woman"`
+	}
+	rows := []alertRow{}
+	for _, r := range readings {
+		row := alertRow{HomeReading: r}
+		if r.Pregnancy != nil {
+			row.PregnantWoman = r.Pregnancy.PregnantWoman
+		}
+		rows = append(rows, row)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": rows})
+}
+
+// POST /doctor/home-readings/:id/acknowledge - Doctor has reviewed an out-of-range reading
+func AcknowledgeHomeReading(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+	db := config.DB()
+
+	var reading entity.HomeReading
+	if err := db.First(&reading, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reading not found"})
+		return
+	}
+	if reading.AcknowledgedAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reading has already been acknowledged"})
+		return
+	}
+
+	now := time.Now()
+	reading.AcknowledgedByID = &doctorID
+	reading.AcknowledgedAt = &now
+	if err := db.Save(&reading).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Reading acknowledged", "data": reading})
+}
@@ -0,0 +1,160 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/config"
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// labObservationInput is one test result keyed by catalog code; value may be a number or text
+type labObservationInput struct {
+	Code  string      `json:"code"`
+	Value interface{} `json:"value"`
+}
+
+// buildLabObservations turns submitted values into flagged observations using the catalog
+// and the trimester of the pregnancy on the test date
+func buildLabObservations(db *gorm.DB, pregnancy *entity.Pregnancy, testDate time.Time, inputs []labObservationInput) ([]entity.LabObservation, error) {
+	trimester := 0
+	if lmp := service.PregnancyLMP(*pregnancy); !lmp.IsZero() && !testDate.IsZero() {
+		trimester = service.Trimester(service.GestationalWeeks(lmp, testDate))
+	}
+
+	var observations []entity.LabObservation
+	var seen []string
+	for _, in := range inputs {
+		code := strings.ToUpper(strings.TrimSpace(in.Code))
+		for _, s := range seen {
+			if s == code {
+				return nil, errors.New(code + " is listed twice")
+			}
+		}
+		seen = append(seen, code)
+
+		var test entity.LabTest
+		if err := db.Preload("ReferenceRanges").Where("code = ? AND active = ?", code, true).First(&test).Error; err != nil {
+			return nil, errors.New("Unknown lab test " + in.Code)
+		}
+		value := ""
+		if in.Value != nil {
+			value = fmt.Sprint(in.Value)
+		}
+		obs, err := service.BuildObservation(test, value, trimester)
+		if err != nil {
+			return nil, err
+		}
+		observations = append(observations, obs)
+	}
+	return observations, nil
+}
+
+// GET /lab-tests?all=true - Lab test catalog with reference ranges (active tests unless all=true)
+func GetLabTests(c *gin.Context) {
+	db := config.DB()
+
+	query := db.Preload("ReferenceRanges", func(db *gorm.DB) *gorm.DB {
+		return db.Order("trimester ASC")
+	})
+	if c.Query("all") != "true" {
+		query = query.Where("active = ?", true)
+	}
+
+	var tests []entity.LabTest
+	if err := query.Order("code ASC").Find(&tests).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": tests})
+}
+
+// POST /doctor/lab-tests - Add a test to the catalog
+func CreateLabTest(c *gin.Context) {
+	if _, ok := requireDoctor(c); !ok {
+		return
+	}
+
+	var test entity.LabTest
+	if err := c.ShouldBindJSON(&test); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := service.ValidateLabTest(&test); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := config.DB()
+
+	var count int64
+	db.Model(&entity.LabTest{}).Where("code = ?", test.Code).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Lab test code already exists"})
+		return
+	}
+
+	test.ID = 0
+	test.Active = true
+	for i := range test.ReferenceRanges {
+		test.ReferenceRanges[i].ID = 0
+	}
+	if err := db.Create(&test).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Lab test created", "data": test})
+}
+
+// PUT /doctor/lab-tests/:id - Update a catalog test and replace its reference ranges.
+// The code is kept because observations are keyed by it.
+func UpdateLabTest(c *gin.Context) {
+	if _, ok := requireDoctor(c); !ok {
+		return
+	}
+
+	db := config.DB()
+
+	var test entity.LabTest
+	if err := db.First(&test, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Lab test not found"})
+		return
+	}
+
+	model, code := test.Model, test.Code
+	if err := c.ShouldBindJSON(&test); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	test.Model, test.Code = model, code
+	if err := service.ValidateLabTest(&test); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("lab_test_id = ?", test.ID).Delete(&entity.LabReferenceRange{}).Error; err != nil {
+			return err
+		}
+		for i := range test.ReferenceRanges {
+			test.ReferenceRanges[i].ID = 0
+			test.ReferenceRanges[i].LabTestID = &test.ID
+		}
+		return tx.Session(&gorm.Session{FullSaveAssociations: true}).Save(&test).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	db.Preload("ReferenceRanges").First(&test, test.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "Lab test updated", "data": test})
+}
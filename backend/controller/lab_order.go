@@ -0,0 +1,370 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/config"
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errLabResultMismatch = errors.New("Lab result belongs to another pregnancy")
+
+// labOrderConflictError is an order that can no longer take a result
+type labOrderConflictError struct{ error }
+
+// labOrderStatusFilter applies ?status=outstanding|all|<status> (default outstanding)
+func labOrderStatusFilter(c *gin.Context, query *gorm.DB) (*gorm.DB, bool) {
+	switch status := c.DefaultQuery("status", "outstanding"); status {
+	case "all":
+	case "outstanding":
+		query = query.Where("lab_orders.status IN ?", service.OutstandingLabOrderStatuses)
+	case entity.LabOrderOrdered, entity.LabOrderCollected, entity.LabOrderResulted, entity.LabOrderReviewed, entity.LabOrderCancelled:
+		query = query.Where("lab_orders.status = ?", status)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be outstanding, all or a lab order status"})
+		return nil, false
+	}
+	if priority := c.Query("priority"); priority != "" {
+		query = query.Where("lab_orders.priority = ?", priority)
+	}
+	return query, true
+}
+
+// labOrderOrder sorts STAT orders first, then by due date
+const labOrderOrder = "CASE lab_orders.priority WHEN 'STAT' THEN 0 WHEN 'Urgent' THEN 1 ELSE 2 END, " +
+	"lab_orders.due_date IS NULL, lab_orders.due_date ASC, lab_orders.ordered_at ASC"
+
+// markOverdueLabOrders sets Overdue on orders past their due date without a result
+func markOverdueLabOrders(orders []entity.LabOrder, now time.Time) {
+	for i := range orders {
+		orders[i].Overdue = service.IsLabOrderOverdue(orders[i], now)
+	}
+}
+
+// attachLabResultToOrder links a result to its order and marks the order resulted. The order
+// is read again inside tx and only moves from the status that was checked, so two results
+// racing for one order cannot both attach.
+func attachLabResultToOrder(tx *gorm.DB, order *entity.LabOrder, result *entity.LabResult, now time.Time) error {
+	if err := tx.First(order, order.ID).Error; err != nil {
+		return err
+	}
+	if result.PregnancyID == nil || order.PregnancyID == nil || *result.PregnancyID != *order.PregnancyID {
+		return labOrderConflictError{errLabResultMismatch}
+	}
+	if err := service.CheckLabOrderTransition(order.Status, entity.LabOrderResulted); err != nil {
+		return labOrderConflictError{err}
+	}
+	update := tx.Model(&entity.LabOrder{}).Where("id = ? AND status = ?", order.ID, order.Status).Updates(map[string]interface{}{
+		"status":        entity.LabOrderResulted,
+		"resulted_at":   now,
+		"lab_result_id": result.ID,
+	})
+	if update.Error != nil {
+		return update.Error
+	}
+	if update.RowsAffected == 0 {
+		return labOrderConflictError{errors.New("Lab order was changed by someone else; reload it and try again")}
+	}
+	order.Status = entity.LabOrderResulted
+	order.ResultedAt = &now
+	order.LabResultID = &result.ID
+	return nil
+}
+
+// findLabOrder loads an order for the doctor endpoints
+func findLabOrder(c *gin.Context, db *gorm.DB) (*entity.LabOrder, bool) {
+	var order entity.LabOrder
+	if err := db.First(&order, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Lab order not found"})
+		return nil, false
+	}
+	return &order, true
+}
+
+// POST /doctor/pregnancy/:id/lab-orders - Order a lab panel
+func CreateLabOrder(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+	var order entity.LabOrder
+
+	if err := c.ShouldBindJSON(&order); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := service.ValidateLabOrder(&order); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := config.DB()
+
+	pregnancy, err := requireActivePregnancy(db, id)
+	if err != nil {
+		respondPregnancyError(c, err)
+		return
+	}
+
+	order.ID = 0
+	order.PregnancyID = &pregnancy.ID
+	order.DoctorID = &doctorID
+	order.DueDate = service.LabOrderDueDate(service.PregnancyLMP(*pregnancy), order.DueWeek)
+	order.Status = entity.LabOrderOrdered
+	order.OrderedAt = time.Now()
+	order.CollectedAt, order.ResultedAt, order.ReviewedAt = nil, nil, nil
+	order.ReviewedByID, order.ReviewNote, order.CancelReason = nil, "", ""
+	order.LabResultID, order.LabResult = nil, nil
+
+	if err := db.Create(&order).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	order.Overdue = service.IsLabOrderOverdue(order, time.Now())
+	c.JSON(http.StatusCreated, gin.H{"message": "Lab order created", "data": order})
+}
+
+// POST /doctor/lab-orders/:id/collect - Record that the specimen was collected
+func CollectLabOrderSpecimen(c *gin.Context) {
+	if _, ok := requireDoctor(c); !ok {
+		return
+	}
+
+	var input struct {
+		CollectedAt *time.Time `json:"collected_at"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := config.DB()
+
+	order, ok := findLabOrder(c, db)
+	if !ok {
+		return
+	}
+	if err := service.CheckLabOrderTransition(order.Status, entity.LabOrderCollected); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	collectedAt := time.Now()
+	if input.CollectedAt != nil {
+		collectedAt = *input.CollectedAt
+	}
+	if err := db.Model(order).Updates(map[string]interface{}{
+		"status":       entity.LabOrderCollected,
+		"collected_at": collectedAt,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Specimen collected", "data": order})
+}
+
+// POST /doctor/lab-orders/:id/result - Attach a recorded lab result to the order
+func AttachLabOrderResult(c *gin.Context) {
+	if _, ok := requireDoctor(c); !ok {
+		return
+	}
+
+	var input struct {
+		LabResultID uint `json:"lab_result_id"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := config.DB()
+
+	order, ok := findLabOrder(c, db)
+	if !ok {
+		return
+	}
+
+	var result entity.LabResult
+	if err := db.First(&result, input.LabResultID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Lab result not found"})
+		return
+	}
+
+	// ผลหนึ่งรายการแนบกับคำสั่งตรวจได้คำสั่งเดียว
+	var used int64
+	db.Model(&entity.LabOrder{}).Where("lab_result_id = ? AND id <> ?", result.ID, order.ID).Count(&used)
+	if used > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Lab result is already attached to another order"})
+		return
+	}
+
+	if err := attachLabResultToOrder(db, order, &result, time.Now()); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	db.Preload("LabResult.Observations").First(order, order.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "Result attached", "data": order})
+}
+
+// POST /doctor/lab-orders/:id/review - Doctor acknowledges the result of an order
+func ReviewLabOrder(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Note string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := config.DB()
+
+	order, ok := findLabOrder(c, db)
+	if !ok {
+		return
+	}
+	if err := service.CheckLabOrderTransition(order.Status, entity.LabOrderReviewed); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := db.Model(order).Updates(map[string]interface{}{
+		"status":         entity.LabOrderReviewed,
+		"reviewed_at":    time.Now(),
+		"reviewed_by_id": doctorID,
+		"review_note":    strings.TrimSpace(input.Note),
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	db.Preload("LabResult.Observations").First(order, order.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "Result reviewed", "data": order})
+}
+
+// POST /doctor/lab-orders/:id/cancel - Cancel an order before it is resulted
+func CancelLabOrder(c *gin.Context) {
+	if _, ok := requireDoctor(c); !ok {
+		return
+	}
+
+	var input struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(input.Reason) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason is required"})
+		return
+	}
+
+	db := config.DB()
+
+	order, ok := findLabOrder(c, db)
+	if !ok {
+		return
+	}
+	if err := service.CheckLabOrderTransition(order.Status, entity.LabOrderCancelled); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := db.Model(order).Updates(map[string]interface{}{
+		"status":        entity.LabOrderCancelled,
+		"cancel_reason": strings.TrimSpace(input.Reason),
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Lab order cancelled", "data": order})
+}
+
+// GET /pregnancies/:id/lab-orders?status=outstanding|all|<status> - Lab orders of a pregnancy
+func GetPregnancyLabOrders(c *gin.Context) {
+	db := config.DB()
+
+	pregnancy, ok := findPregnancyForActor(c, db, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	query, ok := labOrderStatusFilter(c, db.Preload("LabResult.Observations").Where("lab_orders.pregnancy_id = ?", pregnancy.ID))
+	if !ok {
+		return
+	}
+
+	var orders []entity.LabOrder
+	if err := query.Order(labOrderOrder).Find(&orders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	markOverdueLabOrders(orders, time.Now())
+
+	c.JSON(http.StatusOK, gin.H{"data": orders})
+}
+
+// GET /doctor/lab-orders?status=&priority=&patient_id=&overdue=true - Clinic worklist of lab orders
+func GetLabOrderWorklist(c *gin.Context) {
+	if _, ok := requireDoctor(c); !ok {
+		return
+	}
+
+	db := config.DB()
+
+	query := db.Preload("LabResult.Observations").Preload("Pregnancy.PregnantWoman", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id", "full_name", "hn", "phone_number")
+	})
+	if patientID := c.Query("patient_id"); patientID != "" {
+		query = query.Joins("JOIN pregnancies ON pregnancies.id = lab_orders.pregnancy_id").
+			Where("pregnancies.p_id = ?", patientID)
+	}
+	query, ok := labOrderStatusFilter(c, query)
+	if !ok {
+		return
+	}
+
+	var orders []entity.LabOrder
+	if err := query.Order(labOrderOrder).Find(&orders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	now := time.Now()
+	markOverdueLabOrders(orders, now)
+
+	type orderRow struct {
+		entity.LabOrder
+		PregnantWoman *entity.PregnantWoman `json:"pregnant_woman"`
+	}
+	rows := []orderRow{}
+	for _, o := range orders {
+		if c.Query("overdue") == "true" && !o.Overdue {
+			continue
+		}
+		row := orderRow{LabOrder: o}
+		if o.Pregnancy != nil {
+			row.PregnantWoman = o.Pregnancy.PregnantWoman
+		}
+		rows = append(rows, row)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": rows})
+}
@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/config"
+	"github.com/bestiesmile1845/Projecteiei/moph43"
+	"github.com/bestiesmile1845/Projecteiei/service"
+	"github.com/gin-gonic/gin"
+)
+
+// GET /doctor/moph43?from=YYYY-MM-DD&to=YYYY-MM-DD - Row counts of the 43-file export and the records that cannot be exported
+func GetMoph43Report(c *gin.Context) {
+	export, ok := buildMoph43Export(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Success", "data": gin.H{
+		"hospcode":     export.HospCode,
+		"from":         export.From.Format("2006-01-02"),
+		"to":           export.To.Format("2006-01-02"),
+		"generated_at": export.GeneratedAt,
+		"file_name":    export.FileName(),
+		"counts":       export.Counts(),
+		"problems":     export.Problems,
+	}})
+}
+
+// GET /doctor/moph43/download?from=YYYY-MM-DD&to=YYYY-MM-DD - The 43-file export as a zip of pipe-delimited files
+func DownloadMoph43Export(c *gin.Context) {
+	export, ok := buildMoph43Export(c)
+	if !ok {
+		return
+	}
+	var buf bytes.Buffer
+	if err := export.WriteZip(&buf); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", "attachment; filename="+export.FileName())
+	c.Header("X-Moph43-Problems", strconv.Itoa(len(export.Problems)))
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+func buildMoph43Export(c *gin.Context) (*service.Moph43Export, bool) {
+	if _, ok := requireDoctor(c); !ok {
+		return nil, false
+	}
+	opts, err := moph43.OptionsFromEnv()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "43-file export is not configured: " + err.Error()})
+		return nil, false
+	}
+	from, to, err := moph43.ParseRange(c.Query("from"), c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	export, err := moph43.Build(config.DB(), opts, from, to, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return export, true
+}
@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/config"
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/scheduler"
+	"github.com/gin-gonic/gin"
+)
+
+// GET /notifications - Notifications sent (or queued) to the logged in mother
+func GetMyNotifications(c *gin.Context) {
+	// Doctor IDs overlap mother IDs, so the role decides whose notifications these are
+	role, userID := currentActor(c)
+	if role != "pregnant" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only a mother has notifications here; doctors use /doctor/notifications"})
+		return
+	}
+
+	db := config.DB()
+
+	var notifications []entity.Notification
+	if err := db.Where("pregnant_woman_id = ?", userID).Order("created_at DESC").Find(&notifications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": notifications})
+}
+
+// PUT /profile/notify-token - Connect (or with an empty token, disconnect) the mother's own webhook token
+func UpdateMyNotifyToken(c *gin.Context) {
+	role, userID := currentActor(c)
+	if role != "pregnant" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the mother can connect her notifications"})
+		return
+	}
+
+	var input struct {
+		NotifyToken string `json:"notify_token"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	token := strings.TrimSpace(input.NotifyToken)
+	if strings.ContainsAny(token, " \r\n") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "notify_token must not contain spaces or line breaks"})
+		return
+	}
+
+	db := config.DB()
+	if err := db.Model(&entity.PregnantWoman{}).Where("id = ?", userID).Update("notify_token", token).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification token updated", "data": gin.H{"connected": token != ""}})
+}
+
+// GET /doctor/notifications?status=&channel=&appointment_id= - Notification outbox
+func GetNotificationOutbox(c *gin.Context) {
+	if _, ok := requireDoctor(c); !ok {
+		return
+	}
+
+	db := config.DB()
+	query := db.Model(&entity.Notification{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if channel := c.Query("channel"); channel != "" {
+		query = query.Where("channel = ?", channel)
+	}
+	if appointmentID := c.Query("appointment_id"); appointmentID != "" {
+		query = query.Where("appointment_id = ?", appointmentID)
+	}
+
+	var notifications []entity.Notification
+	if err := query.Order("created_at DESC").Limit(500).Find(&notifications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": notifications})
+}
+
+// POST /doctor/notifications/:id/retry - Queue a failed message again
+func RetryNotification(c *gin.Context) {
+	if _, ok := requireDoctor(c); !ok {
+		return
+	}
+
+	id := c.Param("id")
+	db := config.DB()
+
+	var notification entity.Notification
+	if err := db.First(&notification, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+
+	if notification.Status != entity.NotificationStatusFailed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only failed notifications can be retried"})
+		return
+	}
+
+	notification.Status = entity.NotificationStatusPending
+	notification.Attempts = 0
+	notification.NextAttemptAt = time.Now().UTC()
+	if err := db.Save(&notification).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification queued", "data": notification})
+}
+
+// POST /doctor/notifications/run - Run the reminder scheduler now instead of waiting for the next tick
+func RunReminderScheduler(c *gin.Context) {
+	if _, ok := requireDoctor(c); !ok {
+		return
+	}
+
+	s := scheduler.Current()
+	if s == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Reminder scheduler is not running"})
+		return
+	}
+
+	enqueued, delivered := s.RunOnce(time.Now())
+	c.JSON(http.StatusOK, gin.H{"message": "Reminder scheduler ran", "data": gin.H{"enqueued": enqueued, "delivered": delivered}})
+}
@@ -0,0 +1,442 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bestiesmile1845/Projecteiei/config"
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxFilesPerUpload caps how many files one POST /documents may carry
+const maxFilesPerUpload = 20
+
+var (
+	errDocumentNotFound        = errors.New("Document not found")
+	errDocumentVersionConflict = errors.New("Document was changed by another upload; please retry")
+)
+
+// preloadDocumentVersions loads every version of a document, newest first, with its file and thumbnail
+func preloadDocumentVersions(db *gorm.DB) *gorm.DB {
+	return db.Preload("Versions", func(db *gorm.DB) *gorm.DB {
+		return db.Order("version DESC")
+	}).Preload("Versions.StoredFile").Preload("Versions.Thumbnail")
+}
+
+// findDocumentForActor loads a document the requester may see; mothers only see their own
+func findDocumentForActor(c *gin.Context, db *gorm.DB) (*entity.PatientDocument, bool) {
+	var doc entity.PatientDocument
+	if err := preloadDocumentVersions(db).First(&doc, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": errDocumentNotFound.Error()})
+		return nil, false
+	}
+	role, userID := currentActor(c)
+	if role != "doctor" && (role != "pregnant" || doc.PregnantWomanID == nil || *doc.PregnantWomanID != userID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": errDocumentNotFound.Error()})
+		return nil, false
+	}
+	return &doc, true
+}
+
+// canChangeDocument reports whether the requester may edit, re-upload or delete a document:
+// doctors, or the mother for documents she uploaded herself
+func canChangeDocument(c *gin.Context, doc entity.PatientDocument) bool {
+	role, userID := currentActor(c)
+	return role == "doctor" || (role == doc.UploadedByRole && userID == doc.UploadedByID)
+}
+
+// checkDocumentLinks makes sure the pregnancy and record a document is attached to belong to the patient
+func checkDocumentLinks(db *gorm.DB, doc *entity.PatientDocument) error {
+	patientID := *doc.PregnantWomanID
+
+	if doc.PregnancyID != nil {
+		var pregnancy entity.Pregnancy
+		if err := db.First(&pregnancy, *doc.PregnancyID).Error; err != nil ||
+			pregnancy.PregnantWomanID == nil || *pregnancy.PregnantWomanID != patientID {
+			return errors.New("pregnancy does not belong to the patient")
+		}
+	}
+
+	if doc.RecordType != "" {
+		// เอกสารแนบได้กับบันทึกชนิดเดียวกับที่แก้ไขผ่าน /doctor/records ได้
+		kind, ok := correctableRecords[doc.RecordType]
+		if !ok {
+			return errors.New("unknown record_type " + doc.RecordType)
+		}
+		query := db.Model(kind.newRecord()).Where("id = ?", *doc.RecordID)
+		if kind.womanColumn != "" {
+			query = query.Where(kind.womanColumn+" = ?", patientID)
+		} else {
+			query = query.Where("pregnancy_id IN (?)", db.Model(&entity.Pregnancy{}).Select("id").Where("p_id = ?", patientID))
+		}
+		var count int64
+		if err := query.Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return errors.New("record does not belong to the patient")
+		}
+	}
+	return nil
+}
+
+// formUint reads an optional unsigned id from a form field
+func formUint(c *gin.Context, field string) (*uint, error) {
+	value := strings.TrimSpace(c.PostForm(field))
+	if value == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseUint(value, 10, 0)
+	if err != nil {
+		return nil, errors.New(field + " must be a number")
+	}
+	id := uint(n)
+	return &id, nil
+}
+
+// newDocumentVersion stores one upload (and a thumbnail for images) as the next version of a document.
+// The stored files are returned so the caller can discard them if saving fails.
+func newDocumentVersion(c *gin.Context, data []byte, contentType, fileName string, patientID *uint, version int, note string) (entity.DocumentVersion, []*entity.StoredFile, error) {
+	role, userID := currentActor(c)
+	v := entity.DocumentVersion{
+		Version:        version,
+		Note:           strings.TrimSpace(note),
+		UploadedByRole: role,
+		UploadedByID:   userID,
+	}
+
+	file, err := storeUpload(c, data, contentType, fileName, "documents", patientID)
+	if err != nil {
+		return v, nil, err
+	}
+	v.StoredFile = file
+	stored := []*entity.StoredFile{file}
+
+	if service.IsImageType(contentType) {
+		// รูปที่ถอดรหัสไม่ได้ยังเก็บไว้ได้ เพียงแต่ไม่มีภาพย่อ
+		if thumb, err := service.ImageThumbnail(data); err == nil {
+			thumbnail, err := storeUpload(c, thumb, "image/jpeg", "thumb_"+service.DocumentTitle(file.FileName)+".jpg", "documents/thumbnails", patientID)
+			if err != nil {
+				return v, stored, err
+			}
+			v.Thumbnail = thumbnail
+			stored = append(stored, thumbnail)
+		}
+	}
+	return v, stored, nil
+}
+
+// POST /documents - Upload one or more files for a patient (multipart: File, PregnantWomanID, PregnancyID, RecordType, RecordID, Category, Title, Description, Tags)
+func CreatePatientDocuments(c *gin.Context) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid multipart form"})
+		return
+	}
+	headers := form.File["File"]
+	if len(headers) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one File is required"})
+		return
+	}
+	if len(headers) > maxFilesPerUpload {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at most " + strconv.Itoa(maxFilesPerUpload) + " files per upload"})
+		return
+	}
+
+	role, userID := currentActor(c)
+	template := entity.PatientDocument{
+		RecordType:     c.PostForm("RecordType"),
+		Category:       c.PostForm("Category"),
+		Title:          c.PostForm("Title"),
+		Description:    c.PostForm("Description"),
+		Tags:           c.PostForm("Tags"),
+		CurrentVersion: 1,
+		UploadedByRole: role,
+		UploadedByID:   userID,
+	}
+	for field, dst := range map[string]**uint{
+		"PregnantWomanID": &template.PregnantWomanID,
+		"PregnancyID":     &template.PregnancyID,
+		"RecordID":        &template.RecordID,
+	} {
+		if *dst, err = formUint(c, field); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// มารดาอัปโหลดได้เฉพาะเอกสารของตนเอง
+	switch role {
+	case "pregnant":
+		template.PregnantWomanID = &userID
+	case "doctor":
+		if template.PregnantWomanID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "PregnantWomanID is required"})
+			return
+		}
+	default:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed"})
+		return
+	}
+
+	db := config.DB()
+
+	var patient entity.PregnantWoman
+	if err := db.Select("id").First(&patient, *template.PregnantWomanID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Patient not found"})
+		return
+	}
+	if err := service.ValidatePatientDocument(&template, headers[0].Filename); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := checkDocumentLinks(db, &template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// ตรวจทุกไฟล์ก่อนเก็บ เพื่อไม่ให้เหลือไฟล์ค้างเมื่อไฟล์ใดไฟล์หนึ่งไม่ผ่าน
+	type upload struct {
+		data        []byte
+		contentType string
+		fileName    string
+	}
+	uploads := make([]upload, 0, len(headers))
+	for _, header := range headers {
+		data, contentType, err := readUpload(header)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": service.SanitizeFilename(header.Filename) + ": " + err.Error()})
+			return
+		}
+		uploads = append(uploads, upload{data, contentType, header.Filename})
+	}
+
+	var stored []*entity.StoredFile
+	docs := make([]entity.PatientDocument, 0, len(uploads))
+	for _, u := range uploads {
+		doc := template
+		if strings.TrimSpace(c.PostForm("Title")) == "" {
+			// ไม่ระบุชื่อ ใช้ชื่อไฟล์แต่ละไฟล์เป็นชื่อเอกสาร
+			doc.Title = service.DocumentTitle(u.fileName)
+		}
+		version, files, err := newDocumentVersion(c, u.data, u.contentType, u.fileName, doc.PregnantWomanID, 1, "")
+		stored = append(stored, files...)
+		if err != nil {
+			discardUpload(stored...)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		doc.Versions = []entity.DocumentVersion{version}
+		docs = append(docs, doc)
+	}
+
+	if err := db.Create(&docs).Error; err != nil {
+		discardUpload(stored...)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Documents uploaded", "data": docs})
+}
+
+// GET /documents?patient_id=&pregnancy_id=&record_type=&record_id=&category=&tag= - List documents with their latest version
+func GetPatientDocuments(c *gin.Context) {
+	db := config.DB()
+
+	query := db.Preload("Versions", func(db *gorm.DB) *gorm.DB {
+		return db.Where("version = (SELECT current_version FROM patient_documents WHERE patient_documents.id = document_versions.patient_document_id)")
+	}).Preload("Versions.StoredFile").Preload("Versions.Thumbnail")
+
+	role, userID := currentActor(c)
+	switch role {
+	case "pregnant":
+		query = query.Where("pregnant_woman_id = ?", userID)
+	case "doctor":
+		if patientID := c.Query("patient_id"); patientID != "" {
+			query = query.Where("pregnant_woman_id = ?", patientID)
+		}
+	default:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed"})
+		return
+	}
+	if pregnancyID := c.Query("pregnancy_id"); pregnancyID != "" {
+		query = query.Where("pregnancy_id = ?", pregnancyID)
+	}
+	if recordType := c.Query("record_type"); recordType != "" {
+		query = query.Where("record_type = ?", recordType)
+	}
+	if recordID := c.Query("record_id"); recordID != "" {
+		query = query.Where("record_id = ?", recordID)
+	}
+	if category := c.Query("category"); category != "" {
+		query = query.Where("category = ?", category)
+	}
+
+	var docs []entity.PatientDocument
+	if err := query.Order("created_at DESC").Find(&docs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if tag := c.Query("tag"); tag != "" {
+		tagged := []entity.PatientDocument{}
+		for _, doc := range docs {
+			if service.HasTag(doc.Tags, tag) {
+				tagged = append(tagged, doc)
+			}
+		}
+		docs = tagged
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": docs})
+}
+
+// GET /documents/:id - A document with all of its versions
+func GetPatientDocument(c *gin.Context) {
+	doc, ok := findDocumentForActor(c, config.DB())
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": doc})
+}
+
+// PUT /documents/:id - Update the category, title, description or tags of a document
+func UpdatePatientDocument(c *gin.Context) {
+	db := config.DB()
+
+	doc, ok := findDocumentForActor(c, db)
+	if !ok {
+		return
+	}
+	if !canChangeDocument(c, *doc) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the uploader or a doctor can change this document"})
+		return
+	}
+
+	var input struct {
+		Category    *string `json:"category"`
+		Title       *string `json:"title"`
+		Description *string `json:"description"`
+		Tags        *string `json:"tags"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Category != nil {
+		doc.Category = *input.Category
+	}
+	if input.Title != nil {
+		if strings.TrimSpace(*input.Title) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "title is required"})
+			return
+		}
+		doc.Title = *input.Title
+	}
+	if input.Description != nil {
+		doc.Description = *input.Description
+	}
+	if input.Tags != nil {
+		doc.Tags = *input.Tags
+	}
+	if err := service.ValidatePatientDocument(doc, ""); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := db.Model(doc).Updates(map[string]interface{}{
+		"category":    doc.Category,
+		"title":       doc.Title,
+		"description": doc.Description,
+		"tags":        doc.Tags,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Document updated", "data": doc})
+}
+
+// POST /documents/:id/versions - Re-upload a document as a new version (multipart: File, Note)
+func CreateDocumentVersion(c *gin.Context) {
+	db := config.DB()
+
+	doc, ok := findDocumentForActor(c, db)
+	if !ok {
+		return
+	}
+	if !canChangeDocument(c, *doc) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the uploader or a doctor can change this document"})
+		return
+	}
+
+	header, err := c.FormFile("File")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File is required"})
+		return
+	}
+	data, contentType, err := readUpload(header)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	version, stored, err := newDocumentVersion(c, data, contentType, header.Filename, doc.PregnantWomanID, doc.CurrentVersion+1, c.PostForm("Note"))
+	if err != nil {
+		discardUpload(stored...)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	version.PatientDocumentID = &doc.ID
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// ป้องกันการอัปโหลดพร้อมกันสองครั้งได้เลขเวอร์ชันเดียวกัน
+		result := tx.Model(&entity.PatientDocument{}).
+			Where("id = ? AND current_version = ?", doc.ID, doc.CurrentVersion).
+			Update("current_version", version.Version)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errDocumentVersionConflict
+		}
+		return tx.Create(&version).Error
+	})
+	if err != nil {
+		discardUpload(stored...)
+		status := http.StatusInternalServerError
+		if errors.Is(err, errDocumentVersionConflict) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	preloadDocumentVersions(db).First(doc, doc.ID)
+	c.JSON(http.StatusCreated, gin.H{"message": "New version uploaded", "data": doc})
+}
+
+// DELETE /documents/:id - Remove a document from the patient's list; the files are kept for the record
+func DeletePatientDocument(c *gin.Context) {
+	db := config.DB()
+
+	doc, ok := findDocumentForActor(c, db)
+	if !ok {
+		return
+	}
+	if !canChangeDocument(c, *doc) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the uploader or a doctor can delete this document"})
+		return
+	}
+
+	if err := db.Delete(&entity.PatientDocument{}, doc.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Document deleted"})
+}
@@ -0,0 +1,334 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/config"
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/scheduler"
+	"github.com/bestiesmile1845/Projecteiei/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errPregnancyNotEnded = errors.New("Postpartum care is only recorded for delivered or ended pregnancies")
+
+// findPregnancyForActor loads a pregnancy and makes sure a mother only reaches her own
+func findPregnancyForActor(c *gin.Context, db *gorm.DB, id string) (*entity.Pregnancy, bool) {
+	var pregnancy entity.Pregnancy
+	if err := db.First(&pregnancy, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": errPregnancyNotFound.Error()})
+		return nil, false
+	}
+
+	role, userID := currentActor(c)
+	if role == "pregnant" && (pregnancy.PregnantWomanID == nil || *pregnancy.PregnantWomanID != userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not your pregnancy"})
+		return nil, false
+	}
+	return &pregnancy, true
+}
+
+// POST /doctor/pregnancy/:id/postpartum-visits - Record a postpartum visit
+func CreatePostpartumVisit(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+	var visit entity.PostpartumVisit
+
+	if err := c.ShouldBindJSON(&visit); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := config.DB()
+
+	pregnancy, ok := findPregnancyForActor(c, db, id)
+	if !ok {
+		return
+	}
+
+	if !service.IsPostpartumPregnancy(pregnancy.Status) || pregnancy.OutcomeDate == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errPregnancyNotEnded.Error()})
+		return
+	}
+
+	if visit.VisitDate.IsZero() {
+		visit.VisitDate = time.Now()
+	}
+	day, err := service.DaysPostpartum(*pregnancy.OutcomeDate, visit.VisitDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	visit.PregnancyID = &pregnancy.ID
+	visit.DoctorID = &doctorID
+	visit.DayPostpartum = day
+	if visit.VisitType == "" {
+		visit.VisitType = service.PostpartumVisitType(day)
+	}
+	visit.EpdsScreenings = nil
+
+	if err := db.Create(&visit).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Postpartum visit recorded", "data": visit})
+}
+
+// GET /pregnancies/:id/postpartum-visits
+func GetPostpartumVisits(c *gin.Context) {
+	id := c.Param("id")
+	db := config.DB()
+
+	pregnancy, ok := findPregnancyForActor(c, db, id)
+	if !ok {
+		return
+	}
+
+	var visits []entity.PostpartumVisit
+	if err := db.Preload("EpdsScreenings").Where("pregnancy_id = ?", pregnancy.ID).
+		Order("visit_date ASC").Find(&visits).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": visits})
+}
+
+// openEpdsFollowUp puts a positive screen on the doctors' follow-up worklist
+func openEpdsFollowUp(tx *gorm.DB, screening entity.EpdsScreening, womanID *uint) error {
+	due := screening.ScreenedAt
+	return scheduler.OpenFollowUp(tx, entity.FollowUpItem{
+		ItemKey:         service.EpdsFollowUpKey(screening.ID),
+		Kind:            entity.FollowUpEpdsAlert,
+		Detail:          service.EpdsAlertDetail(screening),
+		DueDate:         &due,
+		PregnantWomanID: womanID,
+		PregnancyID:     screening.PregnancyID,
+	})
+}
+
+// recomputeEpdsScreening scores corrected answers again
+func recomputeEpdsScreening(db *gorm.DB, record interface{}) error {
+	screening := record.(*entity.EpdsScreening)
+	answers, err := service.SplitAnswers(screening.Answers)
+	if err != nil {
+		return err
+	}
+	result, err := service.ScoreEPDS(answers)
+	if err != nil {
+		return err
+	}
+	screening.Answers = service.JoinAnswers(answers)
+	screening.TotalScore = result.TotalScore
+	screening.SelfHarmScore = result.SelfHarmScore
+	screening.RiskLevel = result.RiskLevel
+	screening.Alert = result.Alert
+	return nil
+}
+
+// afterEpdsScreeningCorrection follows up a screen that a correction made positive
+func afterEpdsScreeningCorrection(tx *gorm.DB, record interface{}, doctorID uint) error {
+	screening := record.(*entity.EpdsScreening)
+	if !screening.Alert || screening.PregnancyID == nil {
+		return nil
+	}
+	var pregnancy entity.Pregnancy
+	if err := tx.First(&pregnancy, *screening.PregnancyID).Error; err != nil {
+		return err
+	}
+	return openEpdsFollowUp(tx, *screening, pregnancy.PregnantWomanID)
+}
+
+// POST /pregnancies/:id/epds - Submit an EPDS screening (by the mother herself or during a visit)
+func SubmitEpdsScreening(c *gin.Context) {
+	id := c.Param("id")
+	var input struct {
+		Answers           []int      `json:"answers"`
+		PostpartumVisitID *uint      `json:"postpartum_visit_id"`
+		ScreenedAt        *time.Time `json:"screened_at"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := service.ScoreEPDS(input.Answers)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := config.DB()
+
+	pregnancy, ok := findPregnancyForActor(c, db, id)
+	if !ok {
+		return
+	}
+
+	if !service.IsPostpartumPregnancy(pregnancy.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errPregnancyNotEnded.Error()})
+		return
+	}
+
+	if input.PostpartumVisitID != nil {
+		var visit entity.PostpartumVisit
+		if err := db.Where("id = ? AND pregnancy_id = ?", *input.PostpartumVisitID, pregnancy.ID).First(&visit).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Postpartum visit does not belong to this pregnancy"})
+			return
+		}
+	}
+
+	role, userID := currentActor(c)
+	screening := entity.EpdsScreening{
+		PregnancyID:       &pregnancy.ID,
+		PostpartumVisitID: input.PostpartumVisitID,
+		ScreenedAt:        time.Now(),
+		Answers:           service.JoinAnswers(input.Answers),
+		TotalScore:        result.TotalScore,
+		SelfHarmScore:     result.SelfHarmScore,
+		RiskLevel:         result.RiskLevel,
+		Alert:             result.Alert,
+		SubmittedBy:       role,
+		SubmittedByID:     userID,
+	}
+	if input.ScreenedAt != nil {
+		screening.ScreenedAt = *input.ScreenedAt
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&screening).Error; err != nil {
+			return err
+		}
+		if !screening.Alert {
+			return nil
+		}
+		// A positive screen goes straight onto the doctors' follow-up worklist
+		return openEpdsFollowUp(tx, screening, pregnancy.PregnantWomanID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	message := "EPDS screening recorded"
+	if screening.Alert {
+		message = "EPDS screening recorded; the care team has been alerted"
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": message, "data": screening})
+}
+
+// GET /pregnancies/:id/epds
+func GetEpdsScreenings(c *gin.Context) {
+	id := c.Param("id")
+	db := config.DB()
+
+	pregnancy, ok := findPregnancyForActor(c, db, id)
+	if !ok {
+		return
+	}
+
+	var screenings []entity.EpdsScreening
+	if err := db.Where("pregnancy_id = ?", pregnancy.ID).Order("screened_at DESC").Find(&screenings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": screenings})
+}
+
+// GET /doctor/postpartum/epds-alerts?reviewed=false - Positive EPDS screens
+func GetEpdsAlerts(c *gin.Context) {
+	if _, ok := requireDoctor(c); !ok {
+		return
+	}
+
+	db := config.DB()
+	query := db.Preload("Pregnancy.PregnantWoman", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id", "full_name", "hn", "phone_number")
+	}).Where("alert = ?", true)
+	if c.DefaultQuery("reviewed", "false") == "false" {
+		query = query.Where("reviewed_at IS NULL")
+	}
+
+	var screenings []entity.EpdsScreening
+	if err := query.Order("screened_at DESC").Find(&screenings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	type alertRow struct {
+		entity.EpdsScreening
+		PregnantWoman *entity.PregnantWoman `json:"pregnant_woman"`
+	}
+	rows := []alertRow{}
+	for _, s := range screenings {
+		row := alertRow{EpdsScreening: s}
+		if s.Pregnancy != nil {
+			row.PregnantWoman = s.Pregnancy.PregnantWoman
+		}
+		rows = append(rows, row)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": rows})
+}
+
+// POST /doctor/postpartum/epds/:id/review - Doctor acknowledges a positive screen
+func ReviewEpdsScreening(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+	var input struct {
+		Notes string `json:"notes"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := config.DB()
+
+	var screening entity.EpdsScreening
+	if err := db.First(&screening, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "EPDS screening not found"})
+		return
+	}
+
+	now := time.Now()
+	screening.ReviewedByID = &doctorID
+	screening.ReviewedAt = &now
+	screening.ReviewNotes = input.Notes
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&screening).Error; err != nil {
+			return err
+		}
+		// Reviewing the screen also closes its follow-up item
+		return tx.Model(&entity.FollowUpItem{}).
+			Where("item_key = ? AND status = ?", service.EpdsFollowUpKey(screening.ID), entity.FollowUpStatusOpen).
+			Updates(map[string]interface{}{
+				"status":       entity.FollowUpStatusClosed,
+				"closed_at":    now,
+				"closed_by_id": doctorID,
+				"close_reason": "EPDS reviewed",
+			}).Error
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "EPDS screening reviewed", "data": screening})
+}
@@ -1,16 +1,24 @@
 package controller
 
 import (
+	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/bestiesmile1845/Projecteiei/config"
 	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/service"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // POST /doctor/pregnancy - Create pregnancy record for patient
 func DoctorCreatePregnancy(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+
 	var pregnancy entity.Pregnancy
 
 	if err := c.ShouldBindJSON(&pregnancy); err != nil {
@@ -21,33 +29,73 @@ func DoctorCreatePregnancy(c *gin.Context) {
 	db := config.DB()
 
 	// Check if there is already an active pregnancy for this patient
-	var activePregnancy entity.Pregnancy
-	if err := db.Where("pregnant_woman_id = ? AND status = ?", pregnancy.PregnantWomanID, "Active").First(&activePregnancy).Error; err == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "Patient already has an active pregnancy. Please end the current pregnancy first."})
+	if err := ensureNoOtherActivePregnancy(db, &pregnancy); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 		return
 	}
 
 	// Set default status to Active
-	pregnancy.Status = "Active"
+	pregnancy.Status = entity.PregnancyStatusActive
+	pregnancy.OutcomeDate = nil
+	pregnancy.StatusTransitions = nil
 
 	// Calculate EDC if not provided (LMP + 280 days)
 	if pregnancy.EDC.IsZero() && !pregnancy.LMP.IsZero() {
 		pregnancy.EDC = pregnancy.LMP.AddDate(0, 0, 280)
 	}
 
-	if err := db.Create(&pregnancy).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	// Singleton unless told otherwise
+	if pregnancy.FetusCount == 0 {
+		pregnancy.FetusCount = 1
+	}
+	if pregnancy.FetusCount < 0 || pregnancy.FetusCount > maxFetusCount {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("FetusCount must be between 1 and %d", maxFetusCount)})
 		return
 	}
+	pregnancy.Fetuses = nil
+	pregnancy.AncScheduleTemplate = nil
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&pregnancy).Error; err != nil {
+			return err
+		}
+		if err := syncFetuses(tx, &pregnancy, pregnancy.FetusCount); err != nil {
+			return err
+		}
+		// Plan the ANC visits of the chosen (or default) schedule
+		return planAncAppointments(tx, &pregnancy, &doctorID)
+	}); err != nil {
+		respondAncPlanError(c, err)
+		return
+	}
+
+	db.Preload("Fetuses").First(&pregnancy, pregnancy.ID)
 
 	c.JSON(http.StatusCreated, gin.H{"message": "Pregnancy record created", "data": pregnancy})
 }
 
+// BabyOutcome is the delivery outcome of one baby (one entry per fetus)
+type BabyOutcome struct {
+	FetusID        *uint   `json:"fetus_id"`
+	Outcome        string  `json:"outcome"` // LiveBirth (default), Stillbirth
+	BirthWeight    float64 `json:"birth_weight"`
+	Sex            string  `json:"sex"`
+	DeliveryMethod string  `json:"delivery_method"`
+	Complications  string  `json:"complications"`
+	ChildStatus    string  `json:"child_status"`
+}
+
 // POST /doctor/pregnancy/:id/end - End a pregnancy
 func EndPregnancy(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+
 	id := c.Param("id")
 	var payload struct {
 		DeliveryDate   time.Time `json:"delivery_date"`
+		Reason         string    `json:"reason"`
 		DeliveryMethod string    `json:"delivery_method"`
 		BirthWeight    float64   `json:"birth_weight"`
 		Sex            string    `json:"sex"`
@@ -55,6 +103,13 @@ func EndPregnancy(c *gin.Context) {
 		Complications  string    `json:"complications"`
 		ChildStatus    string    `json:"child_status"`
 		GestationalAge int       `json:"gestational_age"`
+
+		// ผู้ทำคลอด: Doctor, Nurse, HealthWorker, TraditionalBirthAttendant, Self, Other
+		DeliveryAttendant string `json:"delivery_attendant"`
+
+		// Babies records an outcome per baby for multiple gestations.
+		// If empty, the single-baby fields above are used.
+		Babies []BabyOutcome `json:"babies"`
 	}
 
 	if err := c.ShouldBindJSON(&payload); err != nil {
@@ -66,47 +121,129 @@ func EndPregnancy(c *gin.Context) {
 
 	// Find the pregnancy
 	var pregnancy entity.Pregnancy
-	if err := db.First(&pregnancy, id).Error; err != nil {
+	if err := db.Preload("Fetuses", func(db *gorm.DB) *gorm.DB {
+		return db.Order("fetus_no ASC")
+	}).First(&pregnancy, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Pregnancy not found"})
 		return
 	}
 
-	if pregnancy.Status != "Active" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Pregnancy is not active"})
+	if err := service.ValidatePregnancyTransition(pregnancy.Status, entity.PregnancyStatusDelivered); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	if payload.DeliveryDate.IsZero() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "delivery_date is required"})
+		return
+	}
+	if payload.DeliveryAttendant != "" && !entity.IsDeliveryAttendant(payload.DeliveryAttendant) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery_attendant"})
+		return
+	}
+
+	babies := payload.Babies
+	if len(babies) == 0 {
+		babies = []BabyOutcome{{
+			BirthWeight:    payload.BirthWeight,
+			Sex:            payload.Sex,
+			DeliveryMethod: payload.DeliveryMethod,
+			Complications:  payload.Complications,
+			ChildStatus:    payload.ChildStatus,
+		}}
+	}
+
+	expected := pregnancy.FetusCount
+	if expected < 1 {
+		expected = 1
+	}
+	if len(babies) != expected {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Expected an outcome for %d baby(ies), got %d", expected, len(babies))})
+		return
+	}
+
+	// Match outcomes to fetuses by FetusID, or by order when not given
+	seenFetus := map[uint]bool{}
+	for i := range babies {
+		if babies[i].FetusID == nil && i < len(pregnancy.Fetuses) {
+			babies[i].FetusID = &pregnancy.Fetuses[i].ID
+		}
+		if babies[i].Outcome != "" && babies[i].Outcome != entity.ObstetricOutcomeLiveBirth && babies[i].Outcome != entity.ObstetricOutcomeStillbirth {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Baby outcome must be LiveBirth or Stillbirth"})
+			return
+		}
+		if err := validateFetusOfPregnancy(db, &babies[i].FetusID, &pregnancy.ID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if babies[i].FetusID != nil {
+			if seenFetus[*babies[i].FetusID] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("fetus %d has more than one outcome", *babies[i].FetusID)})
+				return
+			}
+			seenFetus[*babies[i].FetusID] = true
+		}
+	}
+
 	// Begin transaction
 	tx := db.Begin()
 
-	// 1. Update Pregnancy Status to Ended
-	if err := tx.Model(&pregnancy).Update("status", "Ended").Error; err != nil {
+	// 1. Update Pregnancy Status to Delivered
+	updates := map[string]interface{}{
+		"outcome_date":            payload.DeliveryDate,
+		"outcome_gestational_age": payload.GestationalAge,
+	}
+	if err := tx.Model(&pregnancy).Updates(updates).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update pregnancy status"})
 		return
 	}
-
-	// 2. Create PreviousPregnancy record
-	prevPregnancy := entity.PreviousPregnancy{
-		PregnantWomanID: pregnancy.PregnantWomanID,
-		PregnancyNo:     pregnancy.PregnancyNo,
-		DeliveryDate:    payload.DeliveryDate,
-		GestationalAge:  payload.GestationalAge,
-		DeliveryMethod:  payload.DeliveryMethod,
-		BirthWeight:     payload.BirthWeight,
-		Sex:             payload.Sex,
-		DeliveryPlace:   payload.DeliveryPlace,
-		Complications:   payload.Complications,
-		ChildStatus:     payload.ChildStatus,
-	}
-
-	if err := tx.Create(&prevPregnancy).Error; err != nil {
+	if err := transitionPregnancy(tx, &pregnancy, entity.PregnancyStatusDelivered, payload.Reason, doctorID); err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create previous pregnancy record"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update pregnancy status"})
 		return
 	}
 
+	// 2. Create one ObstetricHistory record per baby
+	var records []entity.ObstetricHistory
+	for i, baby := range babies {
+		method := baby.DeliveryMethod
+		if method == "" {
+			method = payload.DeliveryMethod
+		}
+
+		outcome := baby.Outcome
+		if outcome == "" {
+			outcome = entity.ObstetricOutcomeLiveBirth
+		}
+
+		history := entity.ObstetricHistory{
+			PregnantWomanID: pregnancy.PregnantWomanID,
+			PregnancyID:     &pregnancy.ID,
+			PregnancyNo:     pregnancy.PregnancyNo,
+			BabyNo:          i + 1,
+			Outcome:         outcome,
+			DeliveryDate:    payload.DeliveryDate,
+			GestationalAge:  payload.GestationalAge,
+			DeliveryMethod:  method,
+			BirthWeight:     baby.BirthWeight,
+			Sex:             baby.Sex,
+			DeliveryPlace:   payload.DeliveryPlace,
+			Complications:   baby.Complications,
+			ChildStatus:     baby.ChildStatus,
+
+			DeliveryAttendant: payload.DeliveryAttendant,
+		}
+
+		if err := tx.Create(&history).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create obstetric history record"})
+			return
+		}
+		records = append(records, history)
+	}
+
 	tx.Commit()
 
-	c.JSON(http.StatusOK, gin.H{"message": "Pregnancy ended successfully", "data": prevPregnancy})
+	c.JSON(http.StatusOK, gin.H{"message": "Pregnancy ended successfully", "data": records})
 }
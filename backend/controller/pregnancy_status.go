@@ -0,0 +1,246 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/config"
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var (
+	errPregnancyNotFound  = errors.New("Pregnancy not found")
+	errPregnancyNotActive = errors.New("Pregnancy is not active")
+)
+
+// requireActivePregnancy loads the pregnancy and rejects writes on closed pregnancies
+func requireActivePregnancy(db *gorm.DB, pregnancyID interface{}) (*entity.Pregnancy, error) {
+	if pregnancyID == nil {
+		return nil, errPregnancyNotFound
+	}
+	if id, ok := pregnancyID.(*uint); ok {
+		if id == nil {
+			return nil, errPregnancyNotFound
+		}
+		pregnancyID = *id
+	}
+
+	var pregnancy entity.Pregnancy
+	if err := db.First(&pregnancy, pregnancyID).Error; err != nil {
+		return nil, errPregnancyNotFound
+	}
+	if pregnancy.Status != entity.PregnancyStatusActive {
+		return nil, errPregnancyNotActive
+	}
+	return &pregnancy, nil
+}
+
+// respondPregnancyError writes the matching HTTP error for requireActivePregnancy
+func respondPregnancyError(c *gin.Context, err error) {
+	if errors.Is(err, errPregnancyNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
+
+// transitionPregnancy changes the status and keeps an audit row of which doctor did it and why
+func transitionPregnancy(tx *gorm.DB, pregnancy *entity.Pregnancy, to string, reason string, doctorID uint) error {
+	transition := entity.PregnancyStatusTransition{
+		PregnancyID:    &pregnancy.ID,
+		FromStatus:     pregnancy.Status,
+		ToStatus:       to,
+		Reason:         reason,
+		ActorRole:      "doctor",
+		ActorID:        doctorID,
+		TransitionedAt: time.Now(),
+	}
+	if err := tx.Create(&transition).Error; err != nil {
+		return err
+	}
+
+	pregnancy.Status = to
+	return tx.Model(pregnancy).Update("status", to).Error
+}
+
+// POST /doctor/pregnancy/:id/status - Close a pregnancy with a non-delivery outcome
+func ChangePregnancyStatus(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+	var input struct {
+		Status         string     `json:"status"`
+		Reason         string     `json:"reason"`
+		OutcomeDate    *time.Time `json:"outcome_date"`
+		GestationalAge int        `json:"gestational_age"`
+		TransferTo     string     `json:"transfer_to"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Deliveries need per-baby outcomes, so they go through the end endpoint
+	if input.Status == entity.PregnancyStatusDelivered {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Use POST /doctor/pregnancy/:id/end to record a delivery"})
+		return
+	}
+
+	db := config.DB()
+
+	var pregnancy entity.Pregnancy
+	if err := db.First(&pregnancy, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pregnancy not found"})
+		return
+	}
+
+	if err := service.ValidatePregnancyTransition(pregnancy.Status, input.Status); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	outcome := service.PregnancyOutcome{
+		Reason:         input.Reason,
+		OutcomeDate:    input.OutcomeDate,
+		GestationalAge: input.GestationalAge,
+		TransferTo:     input.TransferTo,
+	}
+	if err := service.ValidatePregnancyOutcome(input.Status, outcome); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Status == entity.PregnancyStatusActive {
+		if err := ensureNoOtherActivePregnancy(db, &pregnancy); err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{
+			"outcome_date":            input.OutcomeDate,
+			"outcome_gestational_age": input.GestationalAge,
+			"transfer_to":             input.TransferTo,
+		}
+		if err := tx.Model(&pregnancy).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		// Pregnancy losses go into the obstetric history so GTPAL picks them up
+		switch input.Status {
+		case entity.PregnancyStatusMiscarriage, entity.PregnancyStatusTermination, entity.PregnancyStatusEctopic:
+			history := entity.ObstetricHistory{
+				PregnantWomanID: pregnancy.PregnantWomanID,
+				PregnancyID:     &pregnancy.ID,
+				PregnancyNo:     pregnancy.PregnancyNo,
+				BabyNo:          1,
+				Outcome:         input.Status,
+				DeliveryDate:    *input.OutcomeDate,
+				GestationalAge:  input.GestationalAge,
+				Complications:   input.Reason,
+			}
+			if err := tx.Create(&history).Error; err != nil {
+				return err
+			}
+		}
+
+		return transitionPregnancy(tx, &pregnancy, input.Status, input.Reason, doctorID)
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Pregnancy status updated", "data": pregnancy})
+}
+
+// POST /doctor/pregnancy/:id/reopen - Undo a status change that was made by mistake
+func ReopenPregnancy(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+	var input struct {
+		Reason string `json:"reason"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := config.DB()
+
+	var pregnancy entity.Pregnancy
+	if err := db.First(&pregnancy, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pregnancy not found"})
+		return
+	}
+
+	if err := service.ValidatePregnancyReopen(pregnancy.Status, input.Reason); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := ensureNoOtherActivePregnancy(db, &pregnancy); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		// Remove the obstetric history rows that were produced when the pregnancy ended
+		if err := tx.Where("pregnancy_id = ?", pregnancy.ID).Delete(&entity.ObstetricHistory{}).Error; err != nil {
+			return err
+		}
+		updates := map[string]interface{}{
+			"outcome_date":            nil,
+			"outcome_gestational_age": 0,
+			"transfer_to":             "",
+		}
+		if err := tx.Model(&pregnancy).Updates(updates).Error; err != nil {
+			return err
+		}
+		return transitionPregnancy(tx, &pregnancy, entity.PregnancyStatusActive, input.Reason, doctorID)
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Pregnancy reopened", "data": pregnancy})
+}
+
+// GET /doctor/pregnancy/:pregnancyId/transitions - Status history of a pregnancy
+func GetPregnancyTransitions(c *gin.Context) {
+	id := c.Param("pregnancyId")
+	db := config.DB()
+
+	var transitions []entity.PregnancyStatusTransition
+	if err := db.Where("pregnancy_id = ?", id).Order("transitioned_at ASC").Find(&transitions).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{"data": []entity.PregnancyStatusTransition{}})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": transitions})
+}
+
+// ensureNoOtherActivePregnancy keeps the one-active-pregnancy-per-mother rule
+func ensureNoOtherActivePregnancy(db *gorm.DB, pregnancy *entity.Pregnancy) error {
+	var count int64
+	db.Model(&entity.Pregnancy{}).
+		Where("p_id = ? AND status = ? AND id <> ?", pregnancy.PregnantWomanID, entity.PregnancyStatusActive, pregnancy.ID).
+		Count(&count)
+	if count > 0 {
+		return errors.New("Patient already has an active pregnancy. Please end the current pregnancy first.")
+	}
+	return nil
+}
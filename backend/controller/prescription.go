@@ -0,0 +1,387 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/config"
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// prescriptionRow is a prescription together with its adherence summary
+type prescriptionRow struct {
+	entity.Prescription
+	Adherence entity.PrescriptionAdherence `json:"adherence"`
+}
+
+// findPrescriptionForActor loads a prescription and makes sure a mother only reaches her own
+func findPrescriptionForActor(c *gin.Context, db *gorm.DB, id string) (*entity.Prescription, bool) {
+	var prescription entity.Prescription
+	if err := db.Preload("Pregnancy").First(&prescription, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Prescription not found"})
+		return nil, false
+	}
+
+	// Only doctors and the mother the prescription belongs to
+	role, userID := currentActor(c)
+	owner := prescription.Pregnancy != nil && prescription.Pregnancy.PregnantWomanID != nil &&
+		*prescription.Pregnancy.PregnantWomanID == userID
+	if role != "doctor" && !(role == "pregnant" && owner) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not your prescription"})
+		return nil, false
+	}
+	return &prescription, true
+}
+
+// loadAdherence computes the adherence of each prescription from its logs
+func loadAdherence(db *gorm.DB, prescriptions []entity.Prescription, now time.Time) ([]entity.PrescriptionAdherence, error) {
+	result := []entity.PrescriptionAdherence{}
+	if len(prescriptions) == 0 {
+		return result, nil
+	}
+
+	ids := make([]uint, len(prescriptions))
+	for i, p := range prescriptions {
+		ids[i] = p.ID
+	}
+	var logs []entity.AdherenceLog
+	if err := db.Where("prescription_id IN ?", ids).Find(&logs).Error; err != nil {
+		return nil, err
+	}
+	byPrescription := map[uint][]entity.AdherenceLog{}
+	for _, l := range logs {
+		byPrescription[*l.PrescriptionID] = append(byPrescription[*l.PrescriptionID], l)
+	}
+
+	for _, p := range prescriptions {
+		result = append(result, service.ComputeAdherence(p, byPrescription[p.ID], now))
+	}
+	return result, nil
+}
+
+// attachAdherence sets patient.Adherence for the prescriptions of her active pregnancy
+func attachAdherence(db *gorm.DB, patient *entity.PregnantWoman) {
+	var pregnancyIDs []uint
+	for _, p := range patient.Pregnancies {
+		if p.Status == entity.PregnancyStatusActive {
+			pregnancyIDs = append(pregnancyIDs, p.ID)
+		}
+	}
+	if len(pregnancyIDs) == 0 {
+		return
+	}
+
+	var prescriptions []entity.Prescription
+	if err := db.Where("pregnancy_id IN ?", pregnancyIDs).Order("start_date ASC").Find(&prescriptions).Error; err != nil {
+		return
+	}
+	if adherence, err := loadAdherence(db, prescriptions, time.Now()); err == nil && len(adherence) > 0 {
+		patient.Adherence = adherence
+	}
+}
+
+// prescriptionAllergy checks a drug against the allergies in the mother's medical history
+func prescriptionAllergy(db *gorm.DB, womanID *uint, drugName string) (string, bool) {
+	var allergies []string
+	if womanID != nil {
+		db.Model(&entity.MedicalHistory{}).Where("pregnant_woman_id = ?", *womanID).
+			Pluck("drug_allergies", &allergies)
+	}
+	return service.CheckDrugAllergy(allergies, drugName)
+}
+
+// recomputePrescription re-checks a corrected prescription like a new one
+func recomputePrescription(db *gorm.DB, record interface{}) error {
+	p := record.(*entity.Prescription)
+	p.DrugName = strings.TrimSpace(p.DrugName)
+	if err := service.ValidatePrescription(*p); err != nil {
+		return err
+	}
+
+	var pregnancy entity.Pregnancy
+	if p.PregnancyID != nil {
+		db.First(&pregnancy, *p.PregnancyID)
+	}
+	if _, found := prescriptionAllergy(db, pregnancy.PregnantWomanID, p.DrugName); !found {
+		p.AllergyOverride = false
+		p.AllergyOverrideReason = ""
+	} else if !p.AllergyOverride || strings.TrimSpace(p.AllergyOverrideReason) == "" {
+		return errors.New("Drug matches a recorded allergy; set allergy_override with a reason to prescribe anyway")
+	}
+	return nil
+}
+
+// recomputeAdherenceLog keeps corrected doses within the prescription's daily doses
+func recomputeAdherenceLog(db *gorm.DB, record interface{}) error {
+	log := record.(*entity.AdherenceLog)
+	var prescription entity.Prescription
+	if log.PrescriptionID == nil || db.First(&prescription, *log.PrescriptionID).Error != nil {
+		return errors.New("Prescription not found")
+	}
+	if log.DosesTaken < 0 || log.DosesTaken > prescription.DosesPerDay {
+		return errors.New("doses_taken must be between 0 and doses_per_day")
+	}
+	return nil
+}
+
+// POST /doctor/pregnancy/:id/prescriptions - Prescribe a medication or supplement
+func CreatePrescription(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+	var prescription entity.Prescription
+
+	if err := c.ShouldBindJSON(&prescription); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := config.DB()
+
+	pregnancy, err := requireActivePregnancy(db, id)
+	if err != nil {
+		respondPregnancyError(c, err)
+		return
+	}
+
+	if prescription.StartDate.IsZero() {
+		prescription.StartDate = time.Now()
+	}
+	if prescription.DosesPerDay == 0 {
+		prescription.DosesPerDay = 1
+	}
+	if prescription.Category == "" {
+		prescription.Category = "other"
+	}
+	prescription.DrugName = strings.TrimSpace(prescription.DrugName)
+	if err := service.ValidatePrescription(prescription); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if allergen, found := prescriptionAllergy(db, pregnancy.PregnantWomanID, prescription.DrugName); found {
+		if !prescription.AllergyOverride {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Drug matches a recorded allergy; set allergy_override with a reason to prescribe anyway",
+				"allergy": allergen,
+			})
+			return
+		}
+		if strings.TrimSpace(prescription.AllergyOverrideReason) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "allergy_override_reason is required"})
+			return
+		}
+	} else {
+		prescription.AllergyOverride = false
+		prescription.AllergyOverrideReason = ""
+	}
+
+	prescription.PregnancyID = &pregnancy.ID
+	prescription.DoctorID = &doctorID
+	prescription.Status = entity.PrescriptionStatusActive
+	prescription.StopReason = ""
+	prescription.AdherenceLogs = nil
+
+	if err := db.Create(&prescription).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Prescription created", "data": prescription})
+}
+
+// POST /doctor/prescriptions/:id/stop - Stop a prescription from today
+func StopPrescription(c *gin.Context) {
+	if _, ok := requireDoctor(c); !ok {
+		return
+	}
+
+	id := c.Param("id")
+	var input struct {
+		Reason string `json:"reason"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := config.DB()
+
+	prescription, ok := findPrescriptionForActor(c, db, id)
+	if !ok {
+		return
+	}
+	if prescription.Status == entity.PrescriptionStatusStopped {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Prescription is already stopped"})
+		return
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":      entity.PrescriptionStatusStopped,
+		"stop_reason": input.Reason,
+	}
+	if prescription.EndDate == nil || prescription.EndDate.After(now) {
+		updates["end_date"] = now.UTC()
+	}
+	if err := db.Model(prescription).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	db.First(prescription, prescription.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "Prescription stopped", "data": prescription})
+}
+
+// GET /pregnancies/:id/prescriptions - Prescriptions with adherence percentages
+func GetPrescriptions(c *gin.Context) {
+	id := c.Param("id")
+	db := config.DB()
+
+	pregnancy, ok := findPregnancyForActor(c, db, id)
+	if !ok {
+		return
+	}
+
+	var prescriptions []entity.Prescription
+	if err := db.Where("pregnancy_id = ?", pregnancy.ID).Order("start_date ASC").Find(&prescriptions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	adherence, err := loadAdherence(db, prescriptions, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	rows := []prescriptionRow{}
+	for i, p := range prescriptions {
+		rows = append(rows, prescriptionRow{Prescription: p, Adherence: adherence[i]})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": rows})
+}
+
+// GET /prescriptions/today - The mother's medications to take today and what she has logged
+func GetTodayMedications(c *gin.Context) {
+	role, userID := currentActor(c)
+	if role != "pregnant" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the mother can view her daily medications"})
+		return
+	}
+
+	db := config.DB()
+	now := time.Now()
+	today := service.ClinicDay(now)
+
+	var prescriptions []entity.Prescription
+	if err := db.Joins("JOIN pregnancies ON pregnancies.id = prescriptions.pregnancy_id AND pregnancies.deleted_at IS NULL").
+		Where("pregnancies.p_id = ? AND pregnancies.status = ? AND prescriptions.status = ?",
+			userID, entity.PregnancyStatusActive, entity.PrescriptionStatusActive).
+		Order("prescriptions.start_date ASC").
+		Find(&prescriptions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	type todayRow struct {
+		entity.Prescription
+		Today *entity.AdherenceLog `json:"today"`
+	}
+	rows := []todayRow{}
+	for _, p := range prescriptions {
+		if service.CheckAdherenceDay(p, today, now) != nil {
+			continue
+		}
+		row := todayRow{Prescription: p}
+		var log entity.AdherenceLog
+		if err := db.Where("prescription_id = ? AND day = ?", p.ID, today).First(&log).Error; err == nil {
+			row.Today = &log
+		}
+		rows = append(rows, row)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": rows})
+}
+
+// PUT /prescriptions/:id/adherence - Record the doses taken on a day (one entry per day)
+func LogAdherence(c *gin.Context) {
+	id := c.Param("id")
+	var input struct {
+		Date       string `json:"date"` // YYYY-MM-DD, defaults to today
+		DosesTaken *int   `json:"doses_taken"`
+		Notes      string `json:"notes"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.DosesTaken == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "doses_taken is required"})
+		return
+	}
+
+	db := config.DB()
+
+	prescription, ok := findPrescriptionForActor(c, db, id)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	if input.Date == "" {
+		input.Date = service.ClinicDay(now)
+	}
+	if err := service.CheckAdherenceDay(*prescription, input.Date, now); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if *input.DosesTaken < 0 || *input.DosesTaken > prescription.DosesPerDay {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "doses_taken must be between 0 and doses_per_day"})
+		return
+	}
+
+	role, userID := currentActor(c)
+	var log entity.AdherenceLog
+	if err := db.Where(entity.AdherenceLog{PrescriptionID: &prescription.ID, Day: input.Date}).
+		Assign(map[string]interface{}{"doses_taken": *input.DosesTaken, "notes": input.Notes, "logged_by": role, "logged_by_id": userID}).
+		FirstOrCreate(&log).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Adherence recorded", "data": log})
+}
+
+// GET /prescriptions/:id/adherence - Daily log and adherence summary of a prescription
+func GetAdherenceLogs(c *gin.Context) {
+	id := c.Param("id")
+	db := config.DB()
+
+	prescription, ok := findPrescriptionForActor(c, db, id)
+	if !ok {
+		return
+	}
+
+	var logs []entity.AdherenceLog
+	if err := db.Where("prescription_id = ?", prescription.ID).Order("day DESC").Find(&logs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":      logs,
+		"adherence": service.ComputeAdherence(*prescription, logs, time.Now()),
+	})
+}
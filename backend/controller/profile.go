@@ -2,6 +2,7 @@ package controller
 
 import (
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/bestiesmile1845/Projecteiei/config"
@@ -99,12 +100,20 @@ func UpdatePersonalProfile(c *gin.Context) {
 		CitizenID   string `json:"citizen_id"`
 		PhoneNumber string `json:"phone_number"`
 		Email       string `json:"email"`
+		Race        string `json:"race"`        // รหัส 3 หลักตาม 43 แฟ้ม เช่น 099 = ไทย
+		Nationality string `json:"nationality"` // รหัส 3 หลักตาม 43 แฟ้ม เช่น 099 = ไทย
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	for _, code := range []string{input.Race, input.Nationality} {
+		if code != "" && (len(code) != 3 || strings.Trim(code, "0123456789") != "") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "race and nationality must be 3-digit codes (099 = Thai)"})
+			return
+		}
+	}
 
 	db := config.DB()
 	var user entity.PregnantWoman
@@ -117,6 +126,8 @@ func UpdatePersonalProfile(c *gin.Context) {
 	user.CitizenID = input.CitizenID
 	user.PhoneNumber = input.PhoneNumber
 	user.Email = input.Email
+	user.Race = input.Race
+	user.Nationality = input.Nationality
 
 	// Parse BirthDate
 	if input.BirthDate != "" {
@@ -0,0 +1,372 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/config"
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxQueueAttempts bounds the retries when two check-ins race for the same queue number
+const maxQueueAttempts = 5
+
+var (
+	errVisitAppointmentNotFound = errors.New("appointment not found")
+	errVisitAppointmentPatient  = errors.New("appointment belongs to another patient")
+	errVisitAppointmentClosed   = errors.New("appointment is already closed")
+)
+
+// checkInAppointment marks the appointment checked-in and gives it the next queue number of its session
+func checkInAppointment(db *gorm.DB, appt *entity.Appointment, now time.Time) error {
+	if err := service.ValidateAppointmentTransition(appt.Status, entity.AppointmentStatusCheckedIn); err != nil {
+		return err
+	}
+	if err := service.CheckCheckInDay(appt.AppointmentDate, now); err != nil {
+		return err
+	}
+
+	session := service.QueueSessionKey(service.ClinicDay(appt.AppointmentDate), appt.DoctorID)
+
+	var err error
+	for attempt := 0; attempt < maxQueueAttempts; attempt++ {
+		err = db.Transaction(func(tx *gorm.DB) error {
+			var last int
+			if err := tx.Model(&entity.QueueTicket{}).Where("session_key = ?", session).
+				Select("COALESCE(MAX(queue_no), 0)").Scan(&last).Error; err != nil {
+				return err
+			}
+			ticket := entity.QueueTicket{SessionKey: session, QueueNo: last + 1, AppointmentID: &appt.ID}
+			if err := tx.Create(&ticket).Error; err != nil {
+				return err
+			}
+
+			// Guard against a concurrent check-in of the same appointment
+			result := tx.Model(&entity.Appointment{}).
+				Where("id = ? AND status = ?", appt.ID, entity.AppointmentStatusScheduled).
+				Updates(map[string]interface{}{"status": entity.AppointmentStatusCheckedIn, "checked_in_at": now, "sequence": gorm.Expr("sequence + 1")})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errors.New("appointment is already checked in")
+			}
+			appt.Status = entity.AppointmentStatusCheckedIn
+			appt.CheckedInAt = &now
+			appt.Sequence++
+			appt.QueueTicket = &ticket
+			return nil
+		})
+		if err == nil || !(isUniqueViolation(err) || isDatabaseLocked(err)) {
+			return err
+		}
+		time.Sleep(time.Duration(attempt+1) * 20 * time.Millisecond)
+	}
+	return err
+}
+
+// isDatabaseLocked reports SQLite lock contention between concurrent writers
+func isDatabaseLocked(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "database is locked")
+}
+
+// respondCheckIn writes the result of a check-in
+func respondCheckIn(c *gin.Context, appt *entity.Appointment, err error) {
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Checked in", "data": appt})
+}
+
+// GET /appointments/:id/check-in-qr - Payload of the QR code staff scan to check the mother in
+func GetCheckInQR(c *gin.Context) {
+	id := c.Param("id")
+	db := config.DB()
+
+	appt, ok := findAppointmentForActor(c, db, id)
+	if !ok {
+		return
+	}
+
+	if appt.CheckInToken == "" {
+		token, err := service.NewFeedToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err := db.Model(appt).Update("check_in_token", token).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		appt.CheckInToken = token
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"qr": service.CheckInQRPrefix + appt.CheckInToken}})
+}
+
+// POST /appointments/:id/check-in - Mother checks herself in by scanning the clinic kiosk QR (doctors need no code)
+func CheckInAppointment(c *gin.Context) {
+	id := c.Param("id")
+	var input struct {
+		KioskCode string `json:"kiosk_code"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := config.DB()
+
+	appt, ok := findAppointmentForActor(c, db, id)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	if role, _ := currentActor(c); role == "pregnant" {
+		if err := service.CheckKioskCode(input.KioskCode, now); errors.Is(err, service.ErrKioskDisabled) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Self check-in is not available; please check in at the counter"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	respondCheckIn(c, appt, checkInAppointment(db, appt, now))
+}
+
+// POST /doctor/check-in - Staff scan the QR on the mother's phone
+func ScanCheckIn(c *gin.Context) {
+	if _, ok := requireDoctor(c); !ok {
+		return
+	}
+
+	var input struct {
+		QR string `json:"qr"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := service.ParseCheckInQR(input.QR)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := config.DB()
+
+	var appt entity.Appointment
+	if err := db.Where("check_in_token = ?", token).First(&appt).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Appointment not found"})
+		return
+	}
+
+	respondCheckIn(c, &appt, checkInAppointment(db, &appt, time.Now()))
+}
+
+// GET /doctor/queue/kiosk-code - Today's code to show as a QR at the clinic entrance
+func GetKioskCode(c *gin.Context) {
+	if _, ok := requireDoctor(c); !ok {
+		return
+	}
+
+	day := service.ClinicDay(time.Now())
+	code, err := service.KioskCode(day)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"date": day, "kiosk_code": code}})
+}
+
+// POST /doctor/queue/:id/call - Call the checked-in appointment into the examination room
+func CallQueue(c *gin.Context) {
+	if _, ok := requireDoctor(c); !ok {
+		return
+	}
+
+	id := c.Param("id")
+	db := config.DB()
+
+	var ticket entity.QueueTicket
+	if err := db.Where("appointment_id = ?", id).First(&ticket).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Appointment is not in the queue"})
+		return
+	}
+
+	now := time.Now()
+	ticket.CalledAt = &now
+	if err := db.Save(&ticket).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Queue called", "data": ticket})
+}
+
+// GET /queue?date=YYYY-MM-DD&doctor_id= - Live queue for the waiting-room display (queue numbers only, no names)
+func GetQueueStatus(c *gin.Context) {
+	day := c.DefaultQuery("date", service.ClinicDay(time.Now()))
+	if _, err := time.Parse("2006-01-02", day); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date must be YYYY-MM-DD"})
+		return
+	}
+
+	db := config.DB()
+	query := db.Preload("Appointment").Where("session_key LIKE ?", day+"/%")
+	if doctorID := c.Query("doctor_id"); doctorID != "" {
+		n, err := strconv.ParseUint(doctorID, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid doctor_id"})
+			return
+		}
+		id := uint(n)
+		query = db.Preload("Appointment").Where("session_key = ?", service.QueueSessionKey(day, &id))
+	}
+
+	var tickets []entity.QueueTicket
+	if err := query.Order("queue_no ASC").Find(&tickets).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	type queueEntry struct {
+		Session  string     `json:"session"`
+		QueueNo  int        `json:"queue_no"`
+		State    string     `json:"state"`
+		CalledAt *time.Time `json:"called_at"`
+	}
+
+	entries := []queueEntry{}
+	nowServing := map[string]queueEntry{}
+	waiting := 0
+	for _, t := range tickets {
+		e := queueEntry{Session: t.SessionKey, QueueNo: t.QueueNo, State: service.QueueWaiting, CalledAt: t.CalledAt}
+		switch {
+		case t.Appointment != nil && t.Appointment.Status == entity.AppointmentStatusCompleted:
+			e.State = service.QueueDone
+		case t.CalledAt != nil:
+			e.State = service.QueueCalled
+		default:
+			waiting++
+		}
+		if t.CalledAt != nil {
+			if current, ok := nowServing[t.SessionKey]; !ok || current.CalledAt.Before(*t.CalledAt) {
+				nowServing[t.SessionKey] = e
+			}
+		}
+		entries = append(entries, e)
+	}
+
+	serving := []queueEntry{}
+	for _, e := range nowServing {
+		serving = append(serving, e)
+	}
+	sort.Slice(serving, func(i, j int) bool { return serving[i].Session < serving[j].Session })
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"date":        day,
+		"now_serving": serving,
+		"waiting":     waiting,
+		"queue":       entries,
+	}})
+}
+
+// linkVisitToAppointment attaches a new visit to the appointment it came from and completes it,
+// whether or not she was checked in. Without an explicit AppointmentID the patient's checked-in appointment of the visit day is used.
+func linkVisitToAppointment(tx *gorm.DB, visit *entity.AntenatalVisit) error {
+	var pregnancy entity.Pregnancy
+	if err := tx.First(&pregnancy, visit.PregnancyID).Error; err != nil {
+		return err
+	}
+
+	var appt entity.Appointment
+	if visit.AppointmentID != nil {
+		if err := tx.First(&appt, *visit.AppointmentID).Error; err != nil {
+			return errVisitAppointmentNotFound
+		}
+		if appt.PregnantWomanID == nil || pregnancy.PregnantWomanID == nil || *appt.PregnantWomanID != *pregnancy.PregnantWomanID {
+			return errVisitAppointmentPatient
+		}
+	} else {
+		visitDay := visit.VisitDate
+		if visitDay.IsZero() {
+			visitDay = time.Now()
+		}
+		var candidates []entity.Appointment
+		if err := tx.Where("pregnant_woman_id = ? AND status = ?", pregnancy.PregnantWomanID, entity.AppointmentStatusCheckedIn).
+			Order("checked_in_at ASC").Find(&candidates).Error; err != nil {
+			return err
+		}
+		found := false
+		for _, a := range candidates {
+			if service.ClinicDay(a.AppointmentDate) == service.ClinicDay(visitDay) {
+				appt, found = a, true
+				break
+			}
+		}
+		if !found {
+			return nil
+		}
+		visit.AppointmentID = &appt.ID
+	}
+
+	// The visit shows she came: an appointment nobody checked in is completed
+	// too, or MarkNoShows would later flag it. Closed appointments take no visit.
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":       entity.AppointmentStatusCompleted,
+		"completed_at": now,
+		"sequence":     gorm.Expr("sequence + 1"),
+	}
+	if appt.CheckedInAt == nil {
+		updates["checked_in_at"] = now
+	}
+	result := tx.Model(&entity.Appointment{}).
+		Where("id = ? AND status IN ?", appt.ID, []string{entity.AppointmentStatusScheduled, entity.AppointmentStatusCheckedIn}).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errVisitAppointmentClosed
+	}
+	return nil
+}
+
+// createVisitWithAppointment saves a visit and links it to its appointment in one transaction
+func createVisitWithAppointment(c *gin.Context, db *gorm.DB, visit *entity.AntenatalVisit) bool {
+	visit.Appointment = nil
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := linkVisitToAppointment(tx, visit); err != nil {
+			return err
+		}
+		return tx.Create(visit).Error
+	})
+	if errors.Is(err, errVisitAppointmentNotFound) || errors.Is(err, errVisitAppointmentPatient) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if errors.Is(err, errVisitAppointmentClosed) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"gorm.io/gorm"
+)
+
+func TestLinkVisitToAppointmentCompletesIt(t *testing.T) {
+	db := openTestDB(t, "visits")
+	mother := entity.PregnantWoman{Username: "mother"}
+	if err := db.Create(&mother).Error; err != nil {
+		t.Fatal(err)
+	}
+	pregnancy := entity.Pregnancy{PregnantWomanID: &mother.ID, Status: entity.PregnancyStatusActive}
+	if err := db.Create(&pregnancy).Error; err != nil {
+		t.Fatal(err)
+	}
+	arrived := clinicTime(8, 30)
+
+	tests := []struct {
+		name        string
+		status      string
+		checkedInAt *time.Time
+		err         error
+	}{
+		{"not checked in", entity.AppointmentStatusScheduled, nil, nil},
+		{"checked in", entity.AppointmentStatusCheckedIn, &arrived, nil},
+		{"cancelled", entity.AppointmentStatusCancelled, nil, errVisitAppointmentClosed},
+		{"no-show", entity.AppointmentStatusNoShow, nil, errVisitAppointmentClosed},
+		{"already completed", entity.AppointmentStatusCompleted, &arrived, errVisitAppointmentClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appt := entity.Appointment{PregnantWomanID: &mother.ID, AppointmentDate: clinicTime(9, 0), Status: tt.status, CheckedInAt: tt.checkedInAt, Sequence: 1}
+			if err := db.Create(&appt).Error; err != nil {
+				t.Fatal(err)
+			}
+			visit := entity.AntenatalVisit{PregnancyID: &pregnancy.ID, AppointmentID: &appt.ID, VisitDate: clinicTime(9, 10)}
+			err := db.Transaction(func(tx *gorm.DB) error { return linkVisitToAppointment(tx, &visit) })
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+
+			var got entity.Appointment
+			db.First(&got, appt.ID)
+			if tt.err != nil {
+				if got.Status != tt.status || got.Sequence != 1 {
+					t.Fatalf("closed appointment changed to %s, sequence %d", got.Status, got.Sequence)
+				}
+				return
+			}
+			// completed, so MarkNoShows leaves it alone and calendars see the change
+			if got.Status != entity.AppointmentStatusCompleted || got.CompletedAt == nil || got.Sequence != 2 {
+				t.Fatalf("appointment = %s, completed at %v, sequence %d", got.Status, got.CompletedAt, got.Sequence)
+			}
+			if got.CheckedInAt == nil || (tt.checkedInAt != nil && !got.CheckedInAt.Equal(arrived)) {
+				t.Fatalf("checked in at %v", got.CheckedInAt)
+			}
+		})
+	}
+}
@@ -0,0 +1,566 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/config"
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// correctableRecord describes a clinical record type that doctors can correct
+type correctableRecord struct {
+	newRecord func() interface{}
+	newSlice  func() interface{}
+	// fields that tie the record to its patient and never change through a correction
+	protected []string
+	// column linking the record to the mother, or "" when it hangs off a pregnancy
+	womanColumn string
+	// signed records are immutable and only take addenda
+	signable bool
+	// records that hang off another record (a prescription or a visit) rather than a
+	// pregnancy: the parent's table and the record's column pointing at it
+	parentTable  string
+	parentColumn string
+	// parentID returns the parent of a record whose signed parent locks it too
+	parentID func(record interface{}) *uint
+	// recompute re-validates a corrected record and re-derives its computed fields;
+	// an error is the doctor's to fix
+	recompute func(db *gorm.DB, record interface{}) error
+	// afterCorrect applies a corrected record's side effects inside the correction
+	afterCorrect func(tx *gorm.DB, record interface{}, doctorID uint) error
+}
+
+var correctableRecords = map[string]correctableRecord{
+	"antenatal_visit": {
+		newRecord: func() interface{} { return &entity.AntenatalVisit{} },
+		newSlice:  func() interface{} { return &[]entity.AntenatalVisit{} },
+		protected: []string{"PregnancyID", "AppointmentID", "DoctorID", "RecordStatus", "SignedAt", "Addenda"},
+		signable:  true,
+	},
+	// observations are corrected through CorrectLabObservations so they are flagged again
+	"lab_result": {
+		newRecord: func() interface{} { return &entity.LabResult{} },
+		newSlice:  func() interface{} { return &[]entity.LabResult{} },
+		protected: []string{"PregnancyID", "DoctorID", "RecordStatus", "SignedAt", "Addenda", "Observations", "StoredFileID", "StoredFile"},
+		signable:  true,
+	},
+	"postpartum_visit": {
+		newRecord: func() interface{} { return &entity.PostpartumVisit{} },
+		newSlice:  func() interface{} { return &[]entity.PostpartumVisit{} },
+		protected: []string{"PregnancyID", "DoctorID"},
+	},
+	"vaccination": {
+		newRecord:   func() interface{} { return &entity.Vaccination{} },
+		newSlice:    func() interface{} { return &[]entity.Vaccination{} },
+		protected:   []string{"PregnantWomanID"},
+		womanColumn: "p_id",
+	},
+	"medical_history": {
+		newRecord:   func() interface{} { return &entity.MedicalHistory{} },
+		newSlice:    func() interface{} { return &[]entity.MedicalHistory{} },
+		protected:   []string{"PregnantWomanID"},
+		womanColumn: "pregnant_woman_id",
+	},
+	"obstetric_history": {
+		newRecord:   func() interface{} { return &entity.ObstetricHistory{} },
+		newSlice:    func() interface{} { return &[]entity.ObstetricHistory{} },
+		protected:   []string{"PregnantWomanID", "PregnancyID"},
+		womanColumn: "pregnant_woman_id",
+	},
+	"prescription": {
+		newRecord: func() interface{} { return &entity.Prescription{} },
+		newSlice:  func() interface{} { return &[]entity.Prescription{} },
+		protected: []string{"PregnancyID", "DoctorID", "Status", "StopReason", "AdherenceLogs"},
+		recompute: recomputePrescription,
+	},
+	"adherence_log": {
+		newRecord:    func() interface{} { return &entity.AdherenceLog{} },
+		newSlice:     func() interface{} { return &[]entity.AdherenceLog{} },
+		protected:    []string{"PrescriptionID", "Day", "LoggedBy", "LoggedByID"},
+		parentTable:  "prescriptions",
+		parentColumn: "prescription_id",
+		recompute:    recomputeAdherenceLog,
+	},
+	"danger_sign_report": {
+		newRecord: func() interface{} { return &entity.DangerSignReport{} },
+		newSlice:  func() interface{} { return &[]entity.DangerSignReport{} },
+		protected: []string{"PregnancyID", "AlertsQueued", "SubmittedBy", "SubmittedByID", "AcknowledgedByID", "AcknowledgedAt", "ResponseNotes"},
+		recompute: recomputeDangerSignReport,
+	},
+	"home_reading": {
+		newRecord: func() interface{} { return &entity.HomeReading{} },
+		newSlice:  func() interface{} { return &[]entity.HomeReading{} },
+		protected: []string{"PregnancyID", "AlertsQueued", "SubmittedBy", "SubmittedByID", "AcknowledgedByID", "AcknowledgedAt"},
+		recompute: recomputeHomeReading,
+	},
+	"gdm_screening": {
+		newRecord:    func() interface{} { return &entity.GdmScreening{} },
+		newSlice:     func() interface{} { return &[]entity.GdmScreening{} },
+		protected:    []string{"PregnancyID", "DoctorID"},
+		recompute:    recomputeGdmScreening,
+		afterCorrect: afterGdmScreeningCorrection,
+	},
+	"epds_screening": {
+		newRecord:    func() interface{} { return &entity.EpdsScreening{} },
+		newSlice:     func() interface{} { return &[]entity.EpdsScreening{} },
+		protected:    []string{"PregnancyID", "PostpartumVisitID", "SubmittedBy", "SubmittedByID", "ReviewedByID", "ReviewedAt", "ReviewNotes"},
+		recompute:    recomputeEpdsScreening,
+		afterCorrect: afterEpdsScreeningCorrection,
+	},
+	"fetal_kick_count": {
+		newRecord: func() interface{} { return &entity.FetalKickCount{} },
+		newSlice:  func() interface{} { return &[]entity.FetalKickCount{} },
+		protected: []string{"PregnancyID", "FetusID"},
+		recompute: recomputeFetalKickCount,
+	},
+	"fetal_observation": {
+		newRecord:    func() interface{} { return &entity.FetalObservation{} },
+		newSlice:     func() interface{} { return &[]entity.FetalObservation{} },
+		protected:    []string{"AntenatalVisitID", "FetusID"},
+		parentTable:  "antenatal_visits",
+		parentColumn: "antenatal_visit_id",
+		parentID:     func(record interface{}) *uint { return record.(*entity.FetalObservation).AntenatalVisitID },
+	},
+}
+
+// isLockedRecord reports whether a record is signed or belongs to a signed record
+func isLockedRecord(db *gorm.DB, kind correctableRecord, record interface{}) bool {
+	if isSignedRecord(record) {
+		return true
+	}
+	if kind.parentID == nil || kind.parentID(record) == nil {
+		return false
+	}
+	var count int64
+	db.Table(kind.parentTable).Where("id = ? AND record_status = ?", *kind.parentID(record), entity.RecordStatusSigned).Count(&count)
+	return count > 0
+}
+
+// correctableRecordType resolves :type or writes a 404
+func correctableRecordType(c *gin.Context) (string, correctableRecord, bool) {
+	recordType := c.Param("type")
+	record, ok := correctableRecords[recordType]
+	if !ok {
+		names := make([]string, 0, len(correctableRecords))
+		for name := range correctableRecords {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown record type; must be one of " + strings.Join(names, ", ")})
+		return "", correctableRecord{}, false
+	}
+	return recordType, record, true
+}
+
+// bindCorrectionReason reads the mandatory reason from the JSON body (or ?reason= for DELETE)
+func bindCorrectionReason(c *gin.Context, changes *json.RawMessage) (string, bool) {
+	var input struct {
+		Reason  string          `json:"reason"`
+		Changes json.RawMessage `json:"changes"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return "", false
+		}
+	}
+	if input.Reason == "" {
+		input.Reason = c.Query("reason")
+	}
+	input.Reason = strings.TrimSpace(input.Reason)
+	if input.Reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A correction reason is required"})
+		return "", false
+	}
+	if changes != nil {
+		*changes = input.Changes
+	}
+	return input.Reason, true
+}
+
+// recordSnapshot serialises a record for the correction log
+func recordSnapshot(record interface{}) string {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// restoreProtectedFields copies gorm.Model and the protected fields back from the original
+func restoreProtectedFields(record, original interface{}, protected []string) {
+	dst := reflect.ValueOf(record).Elem()
+	src := reflect.ValueOf(original).Elem()
+	for _, name := range append([]string{"Model"}, protected...) {
+		if f := dst.FieldByName(name); f.IsValid() && f.CanSet() {
+			f.Set(src.FieldByName(name))
+		}
+	}
+}
+
+// logCorrection writes the correction row
+func logCorrection(tx *gorm.DB, recordType string, recordID uint, action, reason, original, updated string, doctorID uint) error {
+	return tx.Create(&entity.RecordCorrection{
+		RecordType:     recordType,
+		RecordID:       recordID,
+		Action:         action,
+		Reason:         reason,
+		OriginalValues: original,
+		NewValues:      updated,
+		DoctorID:       &doctorID,
+	}).Error
+}
+
+// PUT /doctor/records/:type/:id - Correct a clinical record; body {"reason": "...", "changes": {...}}
+func UpdateClinicalRecord(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+	recordType, kind, ok := correctableRecordType(c)
+	if !ok {
+		return
+	}
+
+	var changes json.RawMessage
+	reason, ok := bindCorrectionReason(c, &changes)
+	if !ok {
+		return
+	}
+	if len(changes) == 0 || string(changes) == "null" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "changes are required"})
+		return
+	}
+
+	db := config.DB()
+
+	record := kind.newRecord()
+	if err := db.First(record, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Record not found"})
+		return
+	}
+	if isLockedRecord(db, kind, record) {
+		c.JSON(http.StatusConflict, gin.H{"error": errRecordSigned.Error()})
+		return
+	}
+	// a separate copy: unmarshalling writes through the record's pointer fields
+	original := kind.newRecord()
+	db.First(original, c.Param("id"))
+	before := recordSnapshot(record)
+
+	if err := json.Unmarshal(changes, record); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	restoreProtectedFields(record, original, kind.protected)
+	if kind.recompute != nil {
+		if err := kind.recompute(db, record); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	after := recordSnapshot(record)
+	if after == before {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No changes to apply"})
+		return
+	}
+
+	recordID := reflect.ValueOf(record).Elem().FieldByName("ID").Uint()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(record).Error; err != nil {
+			return err
+		}
+		if kind.afterCorrect != nil {
+			if err := kind.afterCorrect(tx, record, doctorID); err != nil {
+				return err
+			}
+		}
+		return logCorrection(tx, recordType, uint(recordID), entity.CorrectionUpdate, reason, before, recordSnapshot(record), doctorID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Record corrected", "data": record})
+}
+
+// PUT /doctor/lab-results/:id/observations - Correct the observations of a draft lab result;
+// body {"reason": "...", "changes": [{"code": "HB", "value": 10.8}]}. Each listed code replaces
+// that observation and a null value removes it; the values are flagged again from the catalog.
+func CorrectLabObservations(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+
+	var changes json.RawMessage
+	reason, ok := bindCorrectionReason(c, &changes)
+	if !ok {
+		return
+	}
+	var inputs []labObservationInput
+	if err := json.Unmarshal(changes, &inputs); err != nil || len(inputs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "changes must be a list of {code, value}"})
+		return
+	}
+
+	db := config.DB()
+
+	var result entity.LabResult
+	if err := db.Preload("Observations").First(&result, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Record not found"})
+		return
+	}
+	if isSignedRecord(&result) {
+		c.JSON(http.StatusConflict, gin.H{"error": errRecordSigned.Error()})
+		return
+	}
+	var pregnancy entity.Pregnancy
+	if result.PregnancyID != nil {
+		db.Unscoped().First(&pregnancy, *result.PregnancyID)
+	}
+
+	recorded := map[string]bool{}
+	for _, obs := range result.Observations {
+		recorded[obs.Code] = true
+	}
+	var replaced, values []labObservationInput
+	for _, in := range inputs {
+		in.Code = strings.ToUpper(strings.TrimSpace(in.Code))
+		replaced = append(replaced, in)
+		if in.Value != nil {
+			values = append(values, in)
+		} else if !recorded[in.Code] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The result has no " + in.Code + " observation to remove"})
+			return
+		}
+	}
+	observations, err := buildLabObservations(db, &pregnancy, result.TestDate, values)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before := recordSnapshot(&result)
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, in := range replaced {
+			if err := tx.Where("lab_result_id = ? AND code = ?", result.ID, in.Code).Delete(&entity.LabObservation{}).Error; err != nil {
+				return err
+			}
+		}
+		for i := range observations {
+			observations[i].LabResultID = &result.ID
+		}
+		if len(observations) > 0 {
+			if err := tx.Create(&observations).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Preload("Observations").First(&result, result.ID).Error; err != nil {
+			return err
+		}
+		return logCorrection(tx, "lab_result", result.ID, entity.CorrectionUpdate, reason, before, recordSnapshot(&result), doctorID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Lab observations corrected", "data": result})
+}
+
+// DELETE /doctor/records/:type/:id - Soft-delete a clinical record with a reason
+func DeleteClinicalRecord(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+	recordType, kind, ok := correctableRecordType(c)
+	if !ok {
+		return
+	}
+	reason, ok := bindCorrectionReason(c, nil)
+	if !ok {
+		return
+	}
+
+	db := config.DB()
+
+	record := kind.newRecord()
+	if err := db.First(record, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Record not found"})
+		return
+	}
+	if isLockedRecord(db, kind, record) {
+		c.JSON(http.StatusConflict, gin.H{"error": errRecordSigned.Error()})
+		return
+	}
+
+	recordID := reflect.ValueOf(record).Elem().FieldByName("ID").Uint()
+	before := recordSnapshot(record)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(record).Error; err != nil {
+			return err
+		}
+		return logCorrection(tx, recordType, uint(recordID), entity.CorrectionDelete, reason, before, "", doctorID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Record deleted"})
+}
+
+// POST /doctor/records/:type/:id/restore - Bring back a soft-deleted record
+func RestoreClinicalRecord(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+	recordType, kind, ok := correctableRecordType(c)
+	if !ok {
+		return
+	}
+	reason, ok := bindCorrectionReason(c, nil)
+	if !ok {
+		return
+	}
+
+	db := config.DB()
+
+	record := kind.newRecord()
+	if err := db.Unscoped().Where("deleted_at IS NOT NULL").First(record, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deleted record not found"})
+		return
+	}
+	if isLockedRecord(db, kind, record) {
+		c.JSON(http.StatusConflict, gin.H{"error": errRecordSigned.Error()})
+		return
+	}
+
+	recordID := reflect.ValueOf(record).Elem().FieldByName("ID").Uint()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(record).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		return logCorrection(tx, recordType, uint(recordID), entity.CorrectionRestore, reason, "", recordSnapshot(record), doctorID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	db.First(record, recordID)
+	c.JSON(http.StatusOK, gin.H{"message": "Record restored", "data": record})
+}
+
+// GET /doctor/records/:type/:id/corrections - Correction history of a record, oldest first
+func GetRecordCorrections(c *gin.Context) {
+	if _, ok := requireDoctor(c); !ok {
+		return
+	}
+	recordType, _, ok := correctableRecordType(c)
+	if !ok {
+		return
+	}
+
+	db := config.DB()
+
+	var corrections []entity.RecordCorrection
+	if err := db.Where("record_type = ? AND record_id = ?", recordType, c.Param("id")).
+		Order("created_at ASC").Find(&corrections).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": corrections})
+}
+
+// GET /doctor/records/deleted?type=lab_result&patient_id=1 - Soft-deleted clinical records
+func GetDeletedRecords(c *gin.Context) {
+	if _, ok := requireDoctor(c); !ok {
+		return
+	}
+
+	types := []string{}
+	if recordType := c.Query("type"); recordType != "" {
+		if _, ok := correctableRecords[recordType]; !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Unknown record type"})
+			return
+		}
+		types = append(types, recordType)
+	} else {
+		for name := range correctableRecords {
+			types = append(types, name)
+		}
+		sort.Strings(types)
+	}
+	patientID := c.Query("patient_id")
+
+	db := config.DB()
+
+	type deletedRow struct {
+		RecordType string      `json:"record_type"`
+		RecordID   uint        `json:"record_id"`
+		DeletedAt  time.Time   `json:"deleted_at"`
+		Reason     string      `json:"reason"`
+		DeletedBy  *uint       `json:"deleted_by"`
+		Record     interface{} `json:"record"`
+	}
+	rows := []deletedRow{}
+
+	for _, recordType := range types {
+		kind := correctableRecords[recordType]
+		query := db.Unscoped().Where("deleted_at IS NOT NULL")
+		if patientID != "" {
+			pregnancies := db.Model(&entity.Pregnancy{}).Select("id").Where("p_id = ?", patientID)
+			switch {
+			case kind.womanColumn != "":
+				query = query.Where(kind.womanColumn+" = ?", patientID)
+			case kind.parentTable != "":
+				query = query.Where(kind.parentColumn+" IN (?)", db.Table(kind.parentTable).Select("id").Where("pregnancy_id IN (?)", pregnancies))
+			default:
+				query = query.Where("pregnancy_id IN (?)", pregnancies)
+			}
+		}
+
+		records := kind.newSlice()
+		if err := query.Order("deleted_at DESC").Find(records).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		list := reflect.ValueOf(records).Elem()
+		for i := 0; i < list.Len(); i++ {
+			item := list.Index(i)
+			model := item.FieldByName("Model").Interface().(gorm.Model)
+			row := deletedRow{
+				RecordType: recordType,
+				RecordID:   model.ID,
+				DeletedAt:  model.DeletedAt.Time,
+				Record:     item.Addr().Interface(),
+			}
+			var correction entity.RecordCorrection
+			if err := db.Where("record_type = ? AND record_id = ? AND action = ?", recordType, model.ID, entity.CorrectionDelete).
+				Order("created_at DESC").First(&correction).Error; err == nil {
+				row.Reason = correction.Reason
+				row.DeletedBy = correction.DoctorID
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].DeletedAt.After(rows[j].DeletedAt) })
+	c.JSON(http.StatusOK, gin.H{"data": rows})
+}
@@ -0,0 +1,175 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/config"
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/gin-gonic/gin"
+)
+
+var errRecordSigned = errors.New("Signed records cannot be changed; add an addendum instead")
+
+// isSignedRecord reports whether a visit or lab result has been signed
+func isSignedRecord(record interface{}) bool {
+	f := reflect.ValueOf(record).Elem().FieldByName("RecordStatus")
+	return f.IsValid() && f.String() == entity.RecordStatusSigned
+}
+
+// prepareDraftVisit makes a new visit a draft owned by the doctor who records it
+func prepareDraftVisit(visit *entity.AntenatalVisit, doctorID uint) {
+	visit.DoctorID = &doctorID
+	visit.Doctor = nil
+	visit.RecordStatus = entity.RecordStatusDraft
+	visit.SignedAt = nil
+	visit.Addenda = nil
+}
+
+// signableRecordType resolves :type for the signing endpoints
+func signableRecordType(c *gin.Context) (string, correctableRecord, bool) {
+	recordType, kind, ok := correctableRecordType(c)
+	if !ok {
+		return "", kind, false
+	}
+	if !kind.signable {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only antenatal visits and lab results are signed"})
+		return "", kind, false
+	}
+	return recordType, kind, true
+}
+
+// POST /doctor/records/:type/:id/sign - The responsible doctor signs a draft visit or lab result
+func SignClinicalRecord(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+	_, kind, ok := signableRecordType(c)
+	if !ok {
+		return
+	}
+
+	db := config.DB()
+
+	record := kind.newRecord()
+	if err := db.First(record, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Record not found"})
+		return
+	}
+	if isSignedRecord(record) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Record is already signed"})
+		return
+	}
+
+	// บันทึกเก่าที่ไม่มีแพทย์ผู้รับผิดชอบ ผู้ลงนามจะเป็นผู้รับผิดชอบ
+	owner := reflect.ValueOf(record).Elem().FieldByName("DoctorID").Interface().(*uint)
+	if owner != nil && *owner != doctorID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the responsible doctor can sign this record"})
+		return
+	}
+
+	now := time.Now()
+	if err := db.Model(record).Updates(map[string]interface{}{
+		"doctor_id":     doctorID,
+		"record_status": entity.RecordStatusSigned,
+		"signed_at":     now,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	db.First(record, c.Param("id"))
+	c.JSON(http.StatusOK, gin.H{"message": "Record signed", "data": record})
+}
+
+// POST /doctor/records/:type/:id/addenda - Amend a signed record with a linked addendum
+func CreateRecordAddendum(c *gin.Context) {
+	doctorID, ok := requireDoctor(c)
+	if !ok {
+		return
+	}
+	recordType, kind, ok := signableRecordType(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Reason  string          `json:"reason"`
+		Text    string          `json:"text"`
+		Changes json.RawMessage `json:"changes"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	input.Reason = strings.TrimSpace(input.Reason)
+	input.Text = strings.TrimSpace(input.Text)
+	if input.Reason == "" || (input.Text == "" && len(input.Changes) == 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason and text or changes are required"})
+		return
+	}
+
+	db := config.DB()
+
+	record := kind.newRecord()
+	if err := db.First(record, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Record not found"})
+		return
+	}
+	if !isSignedRecord(record) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Draft records are edited directly; sign the record before adding addenda"})
+		return
+	}
+
+	// The corrected values must fit the record, but the signed record itself is left untouched
+	changes := ""
+	if len(input.Changes) > 0 && string(input.Changes) != "null" {
+		if err := json.Unmarshal(input.Changes, kind.newRecord()); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "changes do not match the record: " + err.Error()})
+			return
+		}
+		changes = string(input.Changes)
+	}
+
+	addendum := entity.RecordAddendum{
+		RecordType: recordType,
+		RecordID:   uint(reflect.ValueOf(record).Elem().FieldByName("ID").Uint()),
+		DoctorID:   &doctorID,
+		Reason:     input.Reason,
+		Text:       input.Text,
+		Changes:    changes,
+	}
+	if err := db.Create(&addendum).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Addendum added", "data": addendum})
+}
+
+// GET /doctor/records/:type/:id/addenda - Addenda of a signed record, oldest first
+func GetRecordAddenda(c *gin.Context) {
+	if _, ok := requireDoctor(c); !ok {
+		return
+	}
+	recordType, _, ok := signableRecordType(c)
+	if !ok {
+		return
+	}
+
+	db := config.DB()
+
+	var addenda []entity.RecordAddendum
+	if err := db.Where("record_type = ? AND record_id = ?", recordType, c.Param("id")).
+		Order("created_at ASC").Find(&addenda).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": addenda})
+}
@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/config"
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/service"
+	"github.com/gin-gonic/gin"
+)
+
+// receiveUpload validates the multipart file in field and writes it to the object store under prefix.
+// It returns nil without error when the form has no file. The caller records the StoredFile row
+// and calls discardUpload if that fails.
+func receiveUpload(c *gin.Context, field, prefix string, patientID *uint) (*entity.StoredFile, error) {
+	header, err := c.FormFile(field)
+	if errors.Is(err, http.ErrMissingFile) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data, contentType, err := readUpload(header)
+	if err != nil {
+		return nil, err
+	}
+	return storeUpload(c, data, contentType, header.Filename, prefix, patientID)
+}
+
+// readUpload reads one uploaded file and checks its size and type
+func readUpload(header *multipart.FileHeader) ([]byte, string, error) {
+	maxBytes := service.UploadMaxBytes()
+	if header.Size > maxBytes {
+		return nil, "", errors.New("file is larger than " + strconv.FormatInt(maxBytes>>20, 10) + " MB")
+	}
+	f, err := header.Open()
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxBytes+1))
+	if err != nil {
+		return nil, "", err
+	}
+
+	contentType, err := service.DetectUploadType(data, maxBytes)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, contentType, nil
+}
+
+// storeUpload writes validated bytes under a random key and returns the unsaved StoredFile row
+func storeUpload(c *gin.Context, data []byte, contentType, fileName, prefix string, patientID *uint) (*entity.StoredFile, error) {
+	key, err := service.NewObjectKey(prefix, contentType, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := config.Storage().Put(key, data, contentType); err != nil {
+		return nil, err
+	}
+
+	role, userID := currentActor(c)
+	sum := sha256.Sum256(data)
+	return &entity.StoredFile{
+		StorageKey:      key,
+		FileName:        service.SanitizeFilename(fileName),
+		ContentType:     contentType,
+		Size:            int64(len(data)),
+		Checksum:        hex.EncodeToString(sum[:]),
+		PregnantWomanID: patientID,
+		UploadedByRole:  role,
+		UploadedByID:    userID,
+	}, nil
+}
+
+// discardUpload removes objects whose database records could not be saved
+func discardUpload(uploads ...*entity.StoredFile) {
+	for _, upload := range uploads {
+		if upload != nil {
+			config.Storage().Delete(upload.StorageKey)
+		}
+	}
+}
+
+// canAccessFile reports whether the requester may see a patient's file: doctors, or the mother it belongs to
+func canAccessFile(c *gin.Context, file entity.StoredFile) bool {
+	role, userID := currentActor(c)
+	switch role {
+	case "doctor":
+		return true
+	case "pregnant":
+		return file.PregnantWomanID != nil && *file.PregnantWomanID == userID
+	}
+	return false
+}
+
+// GET /files/:id?download=true - Download an uploaded file after checking the requester can see the patient
+func DownloadStoredFile(c *gin.Context) {
+	db := config.DB()
+
+	var file entity.StoredFile
+	if err := db.First(&file, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	if !canAccessFile(c, file) {
+		// ไม่บอกว่ามีไฟล์อยู่จริงถ้าไม่มีสิทธิ์
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+
+	serveStoredFile(c, file)
+}
+
+// serveStoredFile streams a file from the object store with headers that keep browsers from sniffing it
+func serveStoredFile(c *gin.Context, file entity.StoredFile) {
+	reader, err := config.Storage().Get(file.StorageKey)
+	if errors.Is(err, service.ErrObjectNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	defer reader.Close()
+
+	disposition := "inline"
+	if c.Query("download") == "true" {
+		disposition = "attachment"
+	}
+	c.Header("Content-Disposition", disposition+"; filename*=UTF-8''"+url.PathEscape(file.FileName))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Cache-Control", "private, no-store")
+	c.DataFromReader(http.StatusOK, file.Size, file.ContentType, reader, nil)
+}
@@ -0,0 +1,30 @@
+package entity
+
+import "gorm.io/gorm"
+
+// AncScheduleTemplate แม่แบบตารางนัดฝากครรภ์ (เช่น ANC คุณภาพ 5 ครั้งของกระทรวงสาธารณสุข หรือ WHO 8 contacts)
+type AncScheduleTemplate struct {
+	gorm.Model
+	Code      string `gorm:"uniqueIndex" json:"code"` // MOPH5, WHO8
+	Name      string `json:"name"`
+	IsDefault bool   `json:"is_default"`
+
+	Contacts []AncContact `gorm:"foreignKey:AncScheduleTemplateID" json:"contacts"`
+}
+
+// AncContact คือการฝากครรภ์แต่ละครั้งในแม่แบบ พร้อมช่วงอายุครรภ์และรายการตรวจที่ต้องทำ
+type AncContact struct {
+	gorm.Model
+
+	// FK -> AncScheduleTemplate
+	AncScheduleTemplateID *uint                `json:"anc_schedule_template_id"`
+	AncScheduleTemplate   *AncScheduleTemplate `gorm:"references:ID" json:"-"`
+
+	ContactNo   int    `json:"contact_no"`
+	WeekFrom    int    `json:"week_from"`   // อายุครรภ์ต่ำสุดของช่วงนัด (สัปดาห์)
+	WeekTo      int    `json:"week_to"`     // อายุครรภ์สูงสุดของช่วงนัด
+	TargetWeek  int    `json:"target_week"` // อายุครรภ์ที่ใช้วางนัด
+	Checks      string `json:"checks"`      // รายการตรวจ คั่นด้วย comma
+	Labs        string `json:"labs"`        // รายการแลป คั่นด้วย comma
+	Description string `json:"description"`
+}
@@ -13,6 +13,14 @@ type AntenatalVisit struct {
 	PregnancyID *uint      `valid:"required~กรุณาเลือกครรภ์"`
 	Pregnancy   *Pregnancy `gorm:"references:ID" valid:"-"`
 
+	// FK -> Appointment (นัดที่มาตรวจครั้งนี้ ถ้ามี)
+	AppointmentID *uint        `valid:"-"`
+	Appointment   *Appointment `gorm:"references:ID" valid:"-"`
+
+	// FK -> Doctor (แพทย์ผู้รับผิดชอบและผู้ลงนาม)
+	DoctorID *uint   `valid:"-"`
+	Doctor   *Doctor `gorm:"references:ID" valid:"-"`
+
 	VisitDate        time.Time
 	GestationalAge   int
 	Weight           float64
@@ -24,4 +32,12 @@ type AntenatalVisit struct {
 	UrineSugar       string
 	Swelling         string
 	MedicalDiagnosis string
+
+	// ผลตรวจทารกรายคน (ครรภ์แฝด)
+	FetalObservations []FetalObservation `gorm:"foreignKey:AntenatalVisitID"`
+
+	// การลงนามรับรอง: หลังลงนามแก้ไขไม่ได้ เพิ่มได้เฉพาะบันทึกแนบท้าย
+	RecordStatus string `gorm:"default:Draft"` // Draft, Signed
+	SignedAt     *time.Time
+	Addenda      []RecordAddendum `gorm:"polymorphic:Record;polymorphicValue:antenatal_visit"`
 }
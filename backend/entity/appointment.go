@@ -6,14 +6,53 @@ import (
 	"gorm.io/gorm"
 )
 
+// สถานะของนัดหมาย
+const (
+	AppointmentStatusScheduled = "Scheduled"
+	AppointmentStatusCheckedIn = "CheckedIn"
+	AppointmentStatusCompleted = "Completed"
+	AppointmentStatusCancelled = "Cancelled"
+	AppointmentStatusNoShow    = "NoShow"
+)
+
 type Appointment struct {
 	gorm.Model
 	AppointmentDate time.Time `json:"appointment_date"`
 	Title           string    `json:"title"`
 	Location        string    `json:"location"`
+	Status          string    `json:"status"` // Scheduled, CheckedIn, Completed, Cancelled, NoShow
+	Notes           string    `json:"notes"`
+
+	// FK -> PregnantWoman
+	PregnantWomanID *uint          `json:"pregnant_woman_id"`
+	PregnantWoman   *PregnantWoman `gorm:"references:ID" json:"pregnant_woman,omitempty"`
+
+	// FK -> Doctor
+	DoctorID *uint   `json:"doctor_id"`
+	Doctor   *Doctor `gorm:"references:ID" json:"doctor,omitempty"`
+
+	// FK -> Pregnancy (ว่างได้ถ้ายังไม่ได้เปิดครรภ์)
+	PregnancyID *uint      `json:"pregnancy_id"`
+	Pregnancy   *Pregnancy `gorm:"references:ID" json:"-"`
+
+	// FK -> AncContact (นัดที่ระบบวางให้ตามตารางฝากครรภ์)
+	AncContactID *uint       `json:"anc_contact_id"`
+	AncContact   *AncContact `gorm:"references:ID" json:"anc_contact,omitempty"`
+
+	RescheduleCount int        `json:"reschedule_count"`
+	Sequence        int        `json:"sequence"` // iCalendar SEQUENCE เพิ่มทุกครั้งที่นัดเปลี่ยน
+	CancelReason    string     `json:"cancel_reason"`
+	CancelledAt     *time.Time `json:"cancelled_at"`
+	CheckedInAt     *time.Time `json:"checked_in_at"`
+	CompletedAt     *time.Time `json:"completed_at"`
+
+	// เช็คอินด้วย QR: token ที่อยู่ใน QR ของนัด และบัตรคิวที่ได้
+	CheckInToken string       `gorm:"index" json:"-"`
+	QueueTicket  *QueueTicket `gorm:"foreignKey:AppointmentID" json:"queue_ticket,omitempty"`
+
+	// เวลาของแพทย์ที่จองไว้ให้นัดนี้ (ใช้เป็นเวลาสิ้นสุดในปฏิทิน)
+	Slot *AppointmentSlot `gorm:"foreignKey:AppointmentID" json:"-"`
 
 	VisitDoctorID *uint
 	VisitDoctor   *VisitDoctor `gorm:"references:ID"`
-
-	PregnantWomen []PregnantWoman `gorm:"foreignKey:AppointmentID"`
 }
@@ -0,0 +1,20 @@
+package entity
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AppointmentSlot จองช่วงเวลาของแพทย์ไว้ให้นัดหมาย ป้องกันการจองซ้อน
+type AppointmentSlot struct {
+	gorm.Model
+
+	DoctorID  *uint     `gorm:"uniqueIndex:idx_doctor_slot_start" json:"doctor_id"`
+	SlotStart time.Time `gorm:"uniqueIndex:idx_doctor_slot_start" json:"slot_start"`
+	SlotEnd   time.Time `gorm:"index" json:"slot_end"` // ตรวจการจองซ้อนตามช่วงเวลา ไม่ใช่แค่เวลาเริ่ม
+
+	// FK -> Appointment
+	AppointmentID *uint        `gorm:"uniqueIndex" json:"appointment_id"`
+	Appointment   *Appointment `gorm:"references:ID" json:"-"`
+}
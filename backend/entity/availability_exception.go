@@ -0,0 +1,24 @@
+package entity
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AvailabilityException ใช้แทนตารางประจำสัปดาห์ในวันที่ระบุ (ลา/เพิ่มเวลาออกตรวจ)
+type AvailabilityException struct {
+	gorm.Model
+
+	// FK -> Doctor
+	DoctorID *uint   `json:"doctor_id" valid:"required~กรุณาเลือกแพทย์"`
+	Doctor   *Doctor `gorm:"references:ID" json:"-" valid:"-"`
+
+	Date        time.Time `json:"date"`        // วันที่ (เวลาคลินิก 00:00)
+	Unavailable bool      `json:"unavailable"` // true = ไม่ออกตรวจทั้งวัน
+	StartTime   string    `json:"start_time"`
+	EndTime     string    `json:"end_time"`
+	SlotMinutes int       `json:"slot_minutes"`
+	Location    string    `json:"location"`
+	Reason      string    `json:"reason"`
+}
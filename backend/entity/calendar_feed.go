@@ -0,0 +1,16 @@
+package entity
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CalendarFeed เก็บ token ลับสำหรับ subscribe ปฏิทินนัดหมาย (.ics) ของผู้ใช้แต่ละคน
+type CalendarFeed struct {
+	gorm.Model
+	Role      string     `gorm:"uniqueIndex:idx_calendar_feed_owner" json:"role"` // doctor, pregnant
+	UserID    uint       `gorm:"uniqueIndex:idx_calendar_feed_owner" json:"user_id"`
+	Token     string     `gorm:"uniqueIndex" json:"token"`
+	RotatedAt *time.Time `json:"rotated_at"`
+}
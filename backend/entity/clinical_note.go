@@ -0,0 +1,75 @@
+package entity
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// สถานะรายการในแผนการรักษา
+const (
+	PlanItemOpen = "Open"
+	PlanItemDone = "Done"
+)
+
+// ClinicalNote บันทึกทางคลินิกแบบ SOAP ผูกกับครรภ์และการตรวจครรภ์ (ถ้ามี)
+type ClinicalNote struct {
+	gorm.Model
+
+	// FK -> Pregnancy
+	PregnancyID *uint      `json:"pregnancy_id"`
+	Pregnancy   *Pregnancy `gorm:"references:ID" json:"-"`
+
+	// FK -> AntenatalVisit (การตรวจที่บันทึกนี้อ้างถึง)
+	AntenatalVisitID *uint           `json:"antenatal_visit_id"`
+	AntenatalVisit   *AntenatalVisit `gorm:"references:ID" json:"-"`
+
+	// FK -> Doctor (ผู้เขียน)
+	DoctorID *uint   `json:"doctor_id"`
+	Doctor   *Doctor `gorm:"references:ID" json:"-"`
+
+	// FK -> NoteTemplate (แม่แบบที่ใช้ ถ้ามี)
+	NoteTemplateID *uint         `json:"note_template_id"`
+	NoteTemplate   *NoteTemplate `gorm:"references:ID" json:"-"`
+
+	NoteDate   time.Time `json:"note_date"`
+	Subjective string    `json:"subjective"` // อาการที่มารดาเล่า
+	Objective  string    `json:"objective"`  // ผลตรวจร่างกาย/แล็บ
+	Assessment string    `json:"assessment"` // การประเมิน
+	Plan       string    `json:"plan"`       // แผนการรักษา
+
+	Diagnoses []NoteDiagnosis `gorm:"foreignKey:ClinicalNoteID" json:"diagnoses"`
+	PlanItems []NotePlanItem  `gorm:"foreignKey:ClinicalNoteID" json:"plan_items"`
+}
+
+// NoteDiagnosis การวินิจฉัยที่ลงรหัส ICD-10 ในบันทึก
+type NoteDiagnosis struct {
+	gorm.Model
+
+	// FK -> ClinicalNote
+	ClinicalNoteID *uint         `json:"clinical_note_id"`
+	ClinicalNote   *ClinicalNote `gorm:"references:ID" json:"-"`
+
+	// FK -> Icd10Code
+	Icd10CodeID *uint      `json:"icd10_code_id"`
+	Icd10Code   *Icd10Code `gorm:"references:ID" json:"-"`
+
+	Code        string `json:"code"` // คัดลอกรหัสไว้ให้อ่านได้แม้ตารางรหัสเปลี่ยน
+	Description string `json:"description"`
+	IsPrimary   bool   `json:"is_primary"`
+}
+
+// NotePlanItem รายการในแผนการรักษา สร้างรายการติดตามในงานติดตามได้
+type NotePlanItem struct {
+	gorm.Model
+
+	// FK -> ClinicalNote
+	ClinicalNoteID *uint         `json:"clinical_note_id"`
+	ClinicalNote   *ClinicalNote `gorm:"references:ID" json:"-"`
+
+	Description string     `json:"description"`
+	DueDate     *time.Time `json:"due_date"`
+	FollowUp    bool       `json:"follow_up"` // แสดงในรายการติดตามเมื่อถึงกำหนด
+	Status      string     `json:"status"`    // Open, Done
+	DoneAt      *time.Time `json:"done_at"`
+}
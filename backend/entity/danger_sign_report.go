@@ -0,0 +1,53 @@
+package entity
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// อาการอันตรายระหว่างตั้งครรภ์ที่มารดารายงานได้
+const (
+	DangerSignBleeding         = "bleeding"
+	DangerSignSevereHeadache   = "severe_headache"
+	DangerSignBlurredVision    = "blurred_vision"
+	DangerSignLeakingFluid     = "leaking_fluid"
+	DangerSignAbdominalPain    = "abdominal_pain"
+	DangerSignFever            = "fever"
+	DangerSignReducedMovements = "reduced_movements"
+)
+
+// ระดับการคัดกรอง
+const (
+	TriageRoutine   = "Routine"
+	TriageUrgent    = "Urgent"
+	TriageEmergency = "Emergency"
+)
+
+// DangerSignReport อาการอันตรายที่มารดารายงานเอง พร้อมผลคัดกรองและคำแนะนำที่ส่งกลับ
+type DangerSignReport struct {
+	gorm.Model
+
+	// FK -> Pregnancy
+	PregnancyID *uint      `json:"pregnancy_id"`
+	Pregnancy   *Pregnancy `gorm:"references:ID" json:"-"`
+
+	ReportedAt       time.Time `json:"reported_at"`
+	Symptoms         string    `json:"symptoms"` // รหัสอาการคั่นด้วย comma
+	HeavyBleeding    bool      `json:"heavy_bleeding"`
+	Temperature      *float64  `json:"temperature"` // °C ถ้าวัดได้
+	Notes            string    `json:"notes"`
+	GestationalWeeks int       `json:"gestational_weeks"`
+
+	Triage        string `json:"triage"`         // Routine, Urgent, Emergency
+	TriageReasons string `json:"triage_reasons"` // เหตุผลของผลคัดกรอง คั่นด้วย ;
+	Instructions  string `json:"instructions"`   // คำแนะนำที่ส่งกลับให้มารดา
+	AlertsQueued  int    `json:"alerts_queued"`  // จำนวนข้อความแจ้งเตือนทีมดูแลที่เข้าคิวส่ง
+
+	SubmittedBy   string `json:"submitted_by"` // doctor, pregnant
+	SubmittedByID uint   `json:"submitted_by_id"`
+
+	AcknowledgedByID *uint      `json:"acknowledged_by_id"`
+	AcknowledgedAt   *time.Time `json:"acknowledged_at"`
+	ResponseNotes    string     `json:"response_notes"`
+}
@@ -13,4 +13,5 @@ type Doctor struct {
 	Password 	string
 
 	VisitDoctors []VisitDoctor `gorm:"foreignKey:DoctorID"`
+	Appointments []Appointment `gorm:"foreignKey:DoctorID"`
 }
\ No newline at end of file
@@ -0,0 +1,18 @@
+package entity
+
+import "gorm.io/gorm"
+
+// DoctorAvailability คือช่วงเวลาออกตรวจประจำสัปดาห์ของแพทย์
+type DoctorAvailability struct {
+	gorm.Model
+
+	// FK -> Doctor
+	DoctorID *uint   `json:"doctor_id" valid:"required~กรุณาเลือกแพทย์"`
+	Doctor   *Doctor `gorm:"references:ID" json:"-" valid:"-"`
+
+	Weekday     int    `json:"weekday"`      // 0 = Sunday ... 6 = Saturday
+	StartTime   string `json:"start_time"`   // "09:00" (เวลาคลินิก)
+	EndTime     string `json:"end_time"`     // "12:00"
+	SlotMinutes int    `json:"slot_minutes"` // ความยาวต่อนัด
+	Location    string `json:"location"`
+}
@@ -0,0 +1,39 @@
+package entity
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ระดับความเสี่ยงจากแบบคัดกรอง EPDS
+const (
+	EpdsRiskLow      = "Low"
+	EpdsRiskPossible = "Possible"
+	EpdsRiskProbable = "Probable"
+)
+
+// EpdsScreening แบบคัดกรองภาวะซึมเศร้าหลังคลอด Edinburgh Postnatal Depression Scale (10 ข้อ)
+type EpdsScreening struct {
+	gorm.Model
+
+	// FK -> Pregnancy
+	PregnancyID *uint      `json:"pregnancy_id"`
+	Pregnancy   *Pregnancy `gorm:"references:ID" json:"-"`
+
+	// FK -> PostpartumVisit (ถ้าทำระหว่างการตรวจ)
+	PostpartumVisitID *uint            `json:"postpartum_visit_id"`
+	PostpartumVisit   *PostpartumVisit `gorm:"references:ID" json:"-"`
+
+	ScreenedAt    time.Time  `json:"screened_at"`
+	Answers       string     `json:"answers"` // ตัวเลือกที่ตอบข้อ 1-10 (0-3) คั่นด้วย comma
+	TotalScore    int        `json:"total_score"`
+	SelfHarmScore int        `json:"self_harm_score"` // คะแนนข้อ 10
+	RiskLevel     string     `json:"risk_level"`      // Low, Possible, Probable
+	Alert         bool       `json:"alert"`           // ต้องให้แพทย์ประเมินต่อ
+	SubmittedBy   string     `json:"submitted_by"`    // doctor, pregnant
+	SubmittedByID uint       `json:"submitted_by_id"`
+	ReviewedByID  *uint      `json:"reviewed_by_id"`
+	ReviewedAt    *time.Time `json:"reviewed_at"`
+	ReviewNotes   string     `json:"review_notes"`
+}
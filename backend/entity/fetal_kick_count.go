@@ -13,6 +13,10 @@ type FetalKickCount struct {
 	PregnancyID *uint      `valid:"required~กรุณาเลือกครรภ์"`
 	Pregnancy   *Pregnancy `gorm:"references:ID" valid:"-"`
 
+	// FK -> Fetus (ว่างได้สำหรับครรภ์เดี่ยว)
+	FetusID *uint  `valid:"-"`
+	Fetus   *Fetus `gorm:"references:ID" valid:"-"`
+
 	CountDate        time.Time
 	KickCountMorning int
 	KickCountLunch   int
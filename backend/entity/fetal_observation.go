@@ -0,0 +1,20 @@
+package entity
+
+import "gorm.io/gorm"
+
+// FetalObservation เก็บผลตรวจทารกแต่ละคนในการฝากครรภ์หนึ่งครั้ง (รองรับครรภ์แฝด)
+type FetalObservation struct {
+	gorm.Model
+
+	// FK -> AntenatalVisit
+	AntenatalVisitID *uint           `valid:"-"`
+	AntenatalVisit   *AntenatalVisit `gorm:"references:ID" valid:"-"`
+
+	// FK -> Fetus
+	FetusID *uint  `valid:"required~กรุณาเลือกทารก"`
+	Fetus   *Fetus `gorm:"references:ID" valid:"-"`
+
+	FetalHeartSound string
+	Presentation    string // Cephalic, Breech, Transverse
+	FetalMovement   string
+}
@@ -0,0 +1,17 @@
+package entity
+
+import "gorm.io/gorm"
+
+type Fetus struct {
+	gorm.Model
+
+	// FK -> Pregnancy
+	PregnancyID *uint      `valid:"required~กรุณาเลือกครรภ์"`
+	Pregnancy   *Pregnancy `gorm:"references:ID" valid:"-"`
+
+	FetusNo int    // 1, 2, 3 ...
+	Label   string // A, B, C (ใช้แสดงผลแฝด)
+
+	FetalObservations []FetalObservation `gorm:"foreignKey:FetusID"`
+	FetalKickCounts   []FetalKickCount   `gorm:"foreignKey:FetusID"`
+}
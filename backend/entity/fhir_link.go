@@ -0,0 +1,14 @@
+package entity
+
+import "gorm.io/gorm"
+
+// FhirLink จับคู่ identifier ของระบบภายนอก (HIS) กับระเบียนในระบบ เพื่อให้นำเข้าซ้ำแล้วอัปเดตระเบียนเดิม
+type FhirLink struct {
+	gorm.Model
+	ResourceType string `gorm:"uniqueIndex:idx_fhir_links_identifier" json:"resource_type"` // Patient, Encounter, ...
+	System       string `gorm:"uniqueIndex:idx_fhir_links_identifier" json:"system"`
+	Value        string `gorm:"uniqueIndex:idx_fhir_links_identifier" json:"value"`
+
+	RecordType string `json:"record_type"` // pregnant_woman, pregnancy, antenatal_visit, lab_result, vaccination
+	RecordID   uint   `json:"record_id"`
+}
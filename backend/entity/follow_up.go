@@ -0,0 +1,72 @@
+package entity
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ประเภทรายการที่ต้องติดตาม
+const (
+	FollowUpMissedAppointment  = "MissedAppointment"
+	FollowUpOverdueVisit       = "OverdueVisit"
+	FollowUpOverdueVaccination = "OverdueVaccination"
+	FollowUpMissingLab         = "MissingLab"
+	FollowUpPostpartumVisit    = "PostpartumVisit"
+	FollowUpEpdsAlert          = "EpdsAlert"
+	FollowUpPlanItem           = "PlanItem"
+)
+
+// สถานะรายการติดตาม
+const (
+	FollowUpStatusOpen   = "Open"
+	FollowUpStatusClosed = "Closed"
+)
+
+// FollowUpItem คือรายการที่แพทย์ต้องติดตามมารดา (ขาดนัด, ไม่มาฝากครรภ์ตามกำหนด, วัคซีน/แลปค้าง)
+type FollowUpItem struct {
+	gorm.Model
+	// ItemKey ระบุเหตุการณ์เดียวกันเพื่อไม่ให้สร้างซ้ำ เช่น missed-appt:12
+	ItemKey string     `gorm:"uniqueIndex" json:"item_key"`
+	Kind    string     `gorm:"index" json:"kind"`
+	Detail  string     `json:"detail"`
+	DueDate *time.Time `json:"due_date"`
+
+	// FK -> PregnantWoman
+	PregnantWomanID *uint          `json:"pregnant_woman_id"`
+	PregnantWoman   *PregnantWoman `gorm:"references:ID" json:"pregnant_woman,omitempty"`
+
+	// FK -> Pregnancy
+	PregnancyID *uint      `json:"pregnancy_id"`
+	Pregnancy   *Pregnancy `gorm:"references:ID" json:"-"`
+
+	// FK -> Appointment (กรณีขาดนัด)
+	AppointmentID *uint        `json:"appointment_id"`
+	Appointment   *Appointment `gorm:"references:ID" json:"-"`
+
+	// FK -> NotePlanItem (กรณีแผนการรักษาในบันทึกทางคลินิก)
+	NotePlanItemID *uint         `json:"note_plan_item_id"`
+	NotePlanItem   *NotePlanItem `gorm:"references:ID" json:"-"`
+
+	Status      string     `gorm:"index" json:"status"` // Open, Closed
+	ClosedAt    *time.Time `json:"closed_at"`
+	ClosedByID  *uint      `json:"closed_by_id"` // Doctor ID (ว่าง = ระบบปิดเองเมื่อแก้ไขแล้ว)
+	CloseReason string     `json:"close_reason"`
+
+	OutreachAttempts []FollowUpOutreach `gorm:"foreignKey:FollowUpItemID" json:"outreach_attempts"`
+}
+
+// FollowUpOutreach บันทึกการติดต่อมารดาแต่ละครั้ง
+type FollowUpOutreach struct {
+	gorm.Model
+
+	// FK -> FollowUpItem
+	FollowUpItemID *uint         `json:"follow_up_item_id"`
+	FollowUpItem   *FollowUpItem `gorm:"references:ID" json:"-"`
+
+	Method      string    `json:"method"`  // call, message, home_visit
+	Outcome     string    `json:"outcome"` // reached, no_answer, wrong_number, rescheduled
+	Notes       string    `json:"notes"`
+	DoctorID    *uint     `json:"doctor_id"`
+	AttemptedAt time.Time `json:"attempted_at"`
+}
@@ -0,0 +1,59 @@
+package entity
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ชนิดการตรวจคัดกรอง/วินิจฉัยเบาหวานขณะตั้งครรภ์
+const (
+	GdmTestGCT50   = "GCT50"   // 50 g glucose challenge test (คัดกรอง)
+	GdmTestOGTT75  = "OGTT75"  // 75 g OGTT (วินิจฉัย)
+	GdmTestOGTT100 = "OGTT100" // 100 g OGTT (วินิจฉัย)
+)
+
+// เกณฑ์การวินิจฉัย
+const (
+	GdmCriteriaGCT140           = "GCT140"           // GCT ≥ 140 mg/dL
+	GdmCriteriaGCT130           = "GCT130"           // GCT ≥ 130 mg/dL
+	GdmCriteriaIADPSG           = "IADPSG"           // 75 g: 92/180/153 ค่าเดียวผิดปกติ
+	GdmCriteriaCarpenterCoustan = "CarpenterCoustan" // 100 g: 95/180/155/140 ผิดปกติตั้งแต่ 2 ค่า
+	GdmCriteriaNDDG             = "NDDG"             // 100 g: 105/190/165/145 ผิดปกติตั้งแต่ 2 ค่า
+)
+
+// ผลการตรวจ
+const (
+	GdmResultNegative = "Negative"
+	GdmResultPositive = "Positive"
+)
+
+// GdmScreening ผลการตรวจคัดกรอง (GCT) หรือวินิจฉัย (OGTT) เบาหวานขณะตั้งครรภ์
+type GdmScreening struct {
+	gorm.Model
+
+	// FK -> Pregnancy
+	PregnancyID *uint      `json:"pregnancy_id"`
+	Pregnancy   *Pregnancy `gorm:"references:ID" json:"-"`
+
+	// FK -> Doctor
+	DoctorID *uint   `json:"doctor_id"`
+	Doctor   *Doctor `gorm:"references:ID" json:"-"`
+
+	TestType         string    `json:"test_type"` // GCT50, OGTT75, OGTT100
+	Criteria         string    `json:"criteria"`
+	TestDate         time.Time `json:"test_date"`
+	GestationalWeeks int       `json:"gestational_weeks"`
+	Early            bool      `json:"early"` // ตรวจก่อน 24 สัปดาห์เพราะมีความเสี่ยงสูง
+
+	// ระดับน้ำตาลในพลาสมา (mg/dL)
+	Fasting   *float64 `json:"fasting"`
+	OneHour   *float64 `json:"one_hour"`
+	TwoHour   *float64 `json:"two_hour"`
+	ThreeHour *float64 `json:"three_hour"`
+
+	Result         string `json:"result"` // Positive, Negative
+	AbnormalValues int    `json:"abnormal_values"`
+	Interpretation string `json:"interpretation"`
+	Notes          string `json:"notes"`
+}
@@ -0,0 +1,50 @@
+package entity
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// สถานะของข้อความ HL7 ที่รับเข้า
+const (
+	Hl7MessageFiled      = "Filed"      // บันทึกเป็นผลแลปอัตโนมัติแล้ว
+	Hl7MessageUnmatched  = "Unmatched"  // รอเจ้าหน้าที่จับคู่ผู้ป่วย/รายการตรวจ
+	Hl7MessageReconciled = "Reconciled" // เจ้าหน้าที่จับคู่และบันทึกแล้ว
+	Hl7MessageDiscarded  = "Discarded"
+	Hl7MessageRejected   = "Rejected" // อ่านข้อความไม่ได้หรือไม่ใช่ ORU^R01 (ตอบ AR)
+)
+
+// Hl7InboundMessage เก็บข้อความ HL7 v2 ทุกฉบับที่รับจาก LIS พร้อมผลการประมวลผล
+// ข้อความที่บันทึกอัตโนมัติไม่ได้จะรออยู่ในคิวให้แพทย์จับคู่เอง
+type Hl7InboundMessage struct {
+	gorm.Model
+	ReceivedVia        string `json:"received_via"` // mllp, http
+	RemoteAddr         string `json:"remote_addr"`
+	SendingApplication string `json:"sending_application"`
+	SendingFacility    string `gorm:"index:idx_hl7_messages_control" json:"sending_facility"`
+	ControlID          string `gorm:"index:idx_hl7_messages_control" json:"control_id"` // MSH-10 ใช้ตรวจข้อความที่ส่งซ้ำ
+	MessageType        string `json:"message_type"`
+	Raw                string `json:"raw"`
+
+	// ตัวระบุผู้ป่วยจาก PID (ข้อความหนึ่งอาจมีหลายคน คั่นด้วย comma)
+	PatientHN        string `json:"patient_hn"`
+	PatientCitizenID string `json:"patient_citizen_id"`
+	PatientName      string `json:"patient_name"`
+
+	Status string `gorm:"index" json:"status"` // Filed, Unmatched, Reconciled, Discarded, Rejected
+	Reason string `json:"reason"`              // เหตุที่บันทึกอัตโนมัติไม่ได้ / เหตุที่ทิ้ง
+
+	// FK -> PregnantWoman / Pregnancy ที่บันทึกผลให้ (เมื่อมีผู้ป่วยคนเดียว)
+	PregnantWomanID *uint          `json:"pregnant_woman_id"`
+	PregnantWoman   *PregnantWoman `gorm:"references:ID" json:"-"`
+	PregnancyID     *uint          `json:"pregnancy_id"`
+	Pregnancy       *Pregnancy     `gorm:"references:ID" json:"-"`
+
+	// ผลแลปที่สร้าง/แก้ไขจากข้อความนี้ (ID คั่นด้วย comma)
+	LabResultIDs string `json:"lab_result_ids"`
+
+	ProcessedAt  *time.Time `json:"processed_at"`
+	ResolvedByID *uint      `json:"resolved_by_id"` // Doctor ID ผู้จับคู่หรือทิ้งข้อความ
+	ResolvedAt   *time.Time `json:"resolved_at"`
+}
@@ -0,0 +1,64 @@
+package entity
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ชนิดค่าที่มารดาวัดเองที่บ้าน
+const (
+	HomeReadingBloodPressure = "bp"
+	HomeReadingGlucose       = "glucose"
+)
+
+// ช่วงเวลาที่เจาะน้ำตาลปลายนิ้ว
+const (
+	GlucoseFasting     = "fasting"
+	GlucosePostMeal1h  = "post_meal_1h"
+	GlucosePostMeal2h  = "post_meal_2h"
+	GlucosePreMeal     = "pre_meal"
+	GlucoseBedtime     = "bedtime"
+	GlucoseUnspecified = "random"
+)
+
+// ผลการประเมินค่าที่วัด
+const (
+	HomeReadingNormal     = "Normal"
+	HomeReadingOutOfRange = "OutOfRange"
+	HomeReadingSevere     = "Severe"
+)
+
+// HomeReading ค่าความดันโลหิตหรือน้ำตาลปลายนิ้วที่มารดาวัดเองที่บ้าน
+type HomeReading struct {
+	gorm.Model
+
+	// FK -> Pregnancy
+	PregnancyID *uint      `json:"pregnancy_id"`
+	Pregnancy   *Pregnancy `gorm:"references:ID" json:"-"`
+
+	Kind       string    `json:"kind"` // bp, glucose
+	MeasuredAt time.Time `json:"measured_at"`
+
+	// ความดันโลหิต (mmHg)
+	Systolic  int  `json:"systolic"`
+	Diastolic int  `json:"diastolic"`
+	Pulse     *int `json:"pulse"`
+
+	// น้ำตาลปลายนิ้ว (mg/dL)
+	Glucose        float64 `json:"glucose"`
+	GlucoseContext string  `json:"glucose_context"` // fasting, post_meal_1h, post_meal_2h, pre_meal, bedtime, random
+
+	Notes string `json:"notes"`
+
+	Assessment  string `json:"assessment"` // Normal, OutOfRange, Severe
+	AlertReason string `json:"alert_reason"`
+	// จำนวนข้อความแจ้งเตือนทีมดูแลที่เข้าคิวส่ง
+	AlertsQueued int `json:"alerts_queued"`
+
+	SubmittedBy   string `json:"submitted_by"` // doctor, pregnant
+	SubmittedByID uint   `json:"submitted_by_id"`
+
+	AcknowledgedByID *uint      `json:"acknowledged_by_id"`
+	AcknowledgedAt   *time.Time `json:"acknowledged_at"`
+}
@@ -0,0 +1,11 @@
+package entity
+
+import "gorm.io/gorm"
+
+// Icd10Code ตารางรหัสโรค ICD-10 ที่ใช้ในคลินิก (ค้นหาได้จากรหัสหรือชื่อ)
+type Icd10Code struct {
+	gorm.Model
+	Code    string `gorm:"uniqueIndex" json:"code"` // เช่น O24.4
+	Title   string `json:"title"`
+	TitleTh string `json:"title_th"`
+}
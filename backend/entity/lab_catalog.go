@@ -0,0 +1,40 @@
+package entity
+
+import "gorm.io/gorm"
+
+// ชนิดค่าผลตรวจ
+const (
+	LabValueNumeric = "numeric"
+	LabValueChoice  = "choice"
+	LabValueText    = "text"
+)
+
+// LabTest รายการตรวจในแคตตาล็อกแลป (เพิ่มรายการตรวจได้โดยไม่ต้องแก้ schema)
+type LabTest struct {
+	gorm.Model
+	Code      string `gorm:"uniqueIndex" json:"code"` // เช่น HB, VDRL, HBSAG
+	Name      string `json:"name"`
+	Unit      string `json:"unit"`
+	ValueType string `json:"value_type"` // numeric, choice, text
+	Choices   string `json:"choices"`    // ค่าที่เลือกได้ของ choice คั่นด้วย comma
+	Active    bool   `gorm:"default:true" json:"active"`
+
+	// รหัสเดียวกันในระบบอื่น (LOINC / รหัสของ LIS) คั่นด้วย comma ใช้จับคู่ผลที่รับจาก HL7
+	ExternalCodes string `json:"external_codes"`
+
+	ReferenceRanges []LabReferenceRange `gorm:"foreignKey:LabTestID" json:"reference_ranges"`
+}
+
+// LabReferenceRange ค่าปกติของรายการตรวจตามไตรมาส (Trimester 0 = ใช้ได้ทุกไตรมาส)
+type LabReferenceRange struct {
+	gorm.Model
+
+	// FK -> LabTest
+	LabTestID *uint    `json:"lab_test_id"`
+	LabTest   *LabTest `gorm:"references:ID" json:"-"`
+
+	Trimester    int      `json:"trimester"`
+	Low          *float64 `json:"low"`
+	High         *float64 `json:"high"`
+	NormalValues string   `json:"normal_values"` // ค่าปกติของ choice คั่นด้วย comma เช่น Negative
+}
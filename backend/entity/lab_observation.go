@@ -0,0 +1,31 @@
+package entity
+
+import "gorm.io/gorm"
+
+// การแปลผลเทียบค่าปกติ
+const (
+	LabFlagNormal   = "Normal"
+	LabFlagLow      = "Low"
+	LabFlagHigh     = "High"
+	LabFlagAbnormal = "Abnormal"
+)
+
+// LabObservation ผลตรวจหนึ่งรายการของผลแลป อ้างอิงรายการตรวจด้วยรหัส
+type LabObservation struct {
+	gorm.Model
+
+	// FK -> LabResult
+	LabResultID *uint      `gorm:"index" json:"lab_result_id"`
+	LabResult   *LabResult `gorm:"references:ID" json:"-"`
+
+	// FK -> LabTest
+	LabTestID *uint    `json:"lab_test_id"`
+	LabTest   *LabTest `gorm:"references:ID" json:"-"`
+
+	Code          string   `gorm:"index" json:"code"`
+	ValueNumber   *float64 `json:"value_number"`
+	ValueText     string   `json:"value_text"`
+	Unit          string   `json:"unit"`
+	Flag          string   `json:"flag"`           // Normal, Low, High, Abnormal (ว่าง = ไม่มีค่าปกติ)
+	ReferenceText string   `json:"reference_text"` // ค่าปกติที่ใช้แปลผล เช่น 11-14 g/dL
+}
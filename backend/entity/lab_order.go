@@ -0,0 +1,58 @@
+package entity
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// สถานะคำสั่งตรวจทางห้องปฏิบัติการ
+const (
+	LabOrderOrdered   = "Ordered"
+	LabOrderCollected = "SpecimenCollected"
+	LabOrderResulted  = "Resulted"
+	LabOrderReviewed  = "Reviewed"
+	LabOrderCancelled = "Cancelled"
+)
+
+// ความเร่งด่วนของคำสั่งตรวจ
+const (
+	LabPriorityRoutine = "Routine"
+	LabPriorityUrgent  = "Urgent"
+	LabPriorityStat    = "STAT"
+)
+
+// LabOrder คำสั่งตรวจแลปของแพทย์ ติดตามตั้งแต่สั่งตรวจจนแพทย์รับทราบผล
+type LabOrder struct {
+	gorm.Model
+
+	// FK -> Pregnancy
+	PregnancyID *uint      `json:"pregnancy_id"`
+	Pregnancy   *Pregnancy `gorm:"references:ID" json:"-"`
+
+	// FK -> Doctor (ผู้สั่งตรวจ)
+	DoctorID *uint   `json:"doctor_id"`
+	Doctor   *Doctor `gorm:"references:ID" json:"-"`
+
+	Panel    string     `json:"panel"`    // booking, third_trimester, cbc, anti_hiv, thalassemia
+	Priority string     `json:"priority"` // Routine, Urgent, STAT
+	DueWeek  int        `json:"due_week"` // ต้องมีผลภายในอายุครรภ์นี้
+	DueDate  *time.Time `json:"due_date"` // คำนวณจาก LMP และ DueWeek
+	Notes    string     `json:"notes"`
+
+	Status       string     `gorm:"index" json:"status"`
+	OrderedAt    time.Time  `json:"ordered_at"`
+	CollectedAt  *time.Time `json:"collected_at"`
+	ResultedAt   *time.Time `json:"resulted_at"`
+	ReviewedAt   *time.Time `json:"reviewed_at"`
+	ReviewedByID *uint      `json:"reviewed_by_id"` // Doctor ID ผู้รับทราบผล
+	ReviewNote   string     `json:"review_note"`
+	CancelReason string     `json:"cancel_reason"`
+
+	// FK -> LabResult (ผลที่แนบกับคำสั่งตรวจ)
+	LabResultID *uint      `json:"lab_result_id"`
+	LabResult   *LabResult `gorm:"references:ID" json:"lab_result,omitempty"`
+
+	// คำนวณ ไม่ได้บันทึกลงฐานข้อมูล
+	Overdue bool `gorm:"-" json:"overdue"`
+}
@@ -14,15 +14,21 @@ type LabResult struct {
 	Pregnancy   *Pregnancy `gorm:"references:ID" valid:"-"`
 
 	TestDate     time.Time
-	Hct          float64 // Hematocrit
-	Hb           float64 // Hemoglobin
-	HbTyping     string
 	OtherRemarks string
-	FilePath     string // Path to the uploaded PDF file
 
-	// FK -> ผลตรวจ
-	DCPResultID     *uint        `valid:"-"`
-	DCPResult       *CheckResult `gorm:"foreignKey:DCPResultID" valid:"-"`
-	AntiHIVResultID *uint        `valid:"-"`
-	AntiHIVResult   *CheckResult `gorm:"foreignKey:AntiHIVResultID" valid:"-"`
+	// FK -> StoredFile (ไฟล์ผลแลปที่แนบ ดาวน์โหลดผ่าน /files/:id)
+	StoredFileID *uint       `valid:"-"`
+	StoredFile   *StoredFile `gorm:"references:ID" valid:"-"`
+
+	// ผลตรวจแต่ละรายการ อ้างอิงแคตตาล็อกรายการตรวจ
+	Observations []LabObservation `gorm:"foreignKey:LabResultID" valid:"-"`
+
+	// FK -> Doctor (แพทย์ผู้รับผิดชอบและผู้ลงนาม)
+	DoctorID *uint   `valid:"-"`
+	Doctor   *Doctor `gorm:"references:ID" valid:"-"`
+
+	// การลงนามรับรอง: หลังลงนามแก้ไขไม่ได้ เพิ่มได้เฉพาะบันทึกแนบท้าย
+	RecordStatus string `gorm:"default:Draft"` // Draft, Signed
+	SignedAt     *time.Time
+	Addenda      []RecordAddendum `gorm:"polymorphic:Record;polymorphicValue:lab_result"`
 }
@@ -0,0 +1,28 @@
+package entity
+
+import "gorm.io/gorm"
+
+// MonitoringTarget เกณฑ์ความดันและน้ำตาลของมารดาแต่ละครรภ์ที่แพทย์กำหนด
+type MonitoringTarget struct {
+	gorm.Model
+
+	// FK -> Pregnancy (หนึ่งชุดต่อครรภ์)
+	PregnancyID *uint      `gorm:"uniqueIndex" json:"pregnancy_id"`
+	Pregnancy   *Pregnancy `gorm:"references:ID" json:"-"`
+
+	// ความดันโลหิต (mmHg)
+	SystolicMax     int `json:"systolic_max"`
+	DiastolicMax    int `json:"diastolic_max"`
+	SystolicSevere  int `json:"systolic_severe"`
+	DiastolicSevere int `json:"diastolic_severe"`
+
+	// น้ำตาลปลายนิ้ว (mg/dL)
+	FastingMax    float64 `json:"fasting_max"`
+	PostMeal1hMax float64 `json:"post_meal_1h_max"`
+	PostMeal2hMax float64 `json:"post_meal_2h_max"`
+	GlucoseMin    float64 `json:"glucose_min"` // ต่ำกว่านี้ถือว่าน้ำตาลต่ำ
+
+	// FK -> Doctor (ผู้กำหนดเกณฑ์ ว่างแปลว่าใช้ค่าเริ่มต้น)
+	SetByID *uint   `json:"set_by_id"`
+	SetBy   *Doctor `gorm:"references:ID" json:"-"`
+}
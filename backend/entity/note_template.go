@@ -0,0 +1,19 @@
+package entity
+
+import "gorm.io/gorm"
+
+// NoteTemplate แม่แบบบันทึก SOAP ที่แพทย์ใช้ซ้ำได้
+type NoteTemplate struct {
+	gorm.Model
+
+	// FK -> Doctor (เจ้าของแม่แบบ)
+	DoctorID *uint   `json:"doctor_id"`
+	Doctor   *Doctor `gorm:"references:ID" json:"-"`
+
+	Name       string `json:"name"`
+	Shared     bool   `json:"shared"` // แพทย์คนอื่นใช้ได้
+	Subjective string `json:"subjective"`
+	Objective  string `json:"objective"`
+	Assessment string `json:"assessment"`
+	Plan       string `json:"plan"`
+}
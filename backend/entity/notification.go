@@ -0,0 +1,60 @@
+package entity
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// สถานะของข้อความใน outbox
+const (
+	NotificationStatusPending = "Pending"
+	NotificationStatusSending = "Sending"
+	NotificationStatusSent    = "Sent"
+	NotificationStatusFailed  = "Failed"
+	NotificationStatusSkipped = "Skipped" // นัดถูกยกเลิก/เลื่อนก่อนส่ง
+)
+
+// ช่องทางการส่ง
+const (
+	NotificationChannelEmail   = "email"
+	NotificationChannelSMS     = "sms"
+	NotificationChannelWebhook = "webhook"
+)
+
+// Notification คือข้อความแจ้งเตือนใน outbox รอส่งผ่านช่องทางต่างๆ
+type Notification struct {
+	gorm.Model
+	// DedupKey กันไม่ให้สร้างข้อความซ้ำสำหรับนัดเดียวกัน/รอบเดียวกัน/ช่องทางเดียวกัน
+	DedupKey    string `gorm:"uniqueIndex" json:"dedup_key"`
+	Kind        string `json:"kind"` // appointment_reminder, care_team_alert
+	Channel     string `json:"channel"`
+	Destination string `json:"destination"` // email / phone number / ผู้รับของ webhook (pregnant_woman:<id>)
+	Subject     string `json:"subject"`
+	Body        string `json:"body"`
+	// token ของผู้รับ (webhook) ใส่ตอนส่งเท่านั้น ไม่บันทึกลง outbox
+	Token string `gorm:"-" json:"-"`
+
+	// FK -> PregnantWoman (ผู้รับ)
+	PregnantWomanID *uint          `json:"pregnant_woman_id"`
+	PregnantWoman   *PregnantWoman `gorm:"references:ID" json:"-"`
+
+	// FK -> Doctor (ผู้รับ กรณีแจ้งเตือนทีมดูแล)
+	DoctorID *uint   `json:"doctor_id"`
+	Doctor   *Doctor `gorm:"references:ID" json:"-"`
+
+	// FK -> Appointment
+	AppointmentID *uint        `json:"appointment_id"`
+	Appointment   *Appointment `gorm:"references:ID" json:"-"`
+	// วันนัดตอนที่สร้างข้อความ ใช้ตรวจว่านัดถูกเลื่อนไปแล้วหรือยัง
+	AppointmentDate *time.Time `json:"appointment_date"`
+
+	Status        string     `gorm:"index" json:"status"` // Pending, Sending, Sent, Failed, Skipped
+	Attempts      int        `json:"attempts"`
+	MaxAttempts   int        `json:"max_attempts"`
+	NextAttemptAt time.Time  `gorm:"index" json:"next_attempt_at"`
+	LastError     string     `json:"last_error"`
+	SentAt        *time.Time `json:"sent_at"`
+	// เวลาที่ถูก claim ไปส่ง ถ้าค้างเป็น Sending นานเกินกำหนดจะถูกคืนเข้าคิว
+	ClaimedAt *time.Time `json:"claimed_at"`
+}
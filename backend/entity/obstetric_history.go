@@ -0,0 +1,101 @@
+package entity
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ผลของการตั้งครรภ์ในประวัติทางสูติกรรม
+const (
+	ObstetricOutcomeLiveBirth   = "LiveBirth"
+	ObstetricOutcomeStillbirth  = "Stillbirth"
+	ObstetricOutcomeMiscarriage = "Miscarriage"
+	ObstetricOutcomeTermination = "Termination"
+	ObstetricOutcomeEctopic     = "Ectopic"
+)
+
+var obstetricOutcomes = []string{
+	ObstetricOutcomeLiveBirth,
+	ObstetricOutcomeStillbirth,
+	ObstetricOutcomeMiscarriage,
+	ObstetricOutcomeTermination,
+	ObstetricOutcomeEctopic,
+}
+
+// IsObstetricOutcome reports whether s is a known obstetric outcome
+func IsObstetricOutcome(s string) bool {
+	for _, outcome := range obstetricOutcomes {
+		if outcome == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ผู้ทำคลอด ส่งออกเป็น BDOCTOR ของ 43 แฟ้ม
+const (
+	DeliveryAttendantDoctor       = "Doctor"
+	DeliveryAttendantNurse        = "Nurse"
+	DeliveryAttendantHealthWorker = "HealthWorker"              // เจ้าหน้าที่สาธารณสุขอื่นที่ไม่ใช่แพทย์หรือพยาบาล
+	DeliveryAttendantTraditional  = "TraditionalBirthAttendant" // ผดุงครรภ์โบราณ
+	DeliveryAttendantSelf         = "Self"                      // คลอดเอง
+	DeliveryAttendantOther        = "Other"
+)
+
+var deliveryAttendants = []string{
+	DeliveryAttendantDoctor,
+	DeliveryAttendantNurse,
+	DeliveryAttendantHealthWorker,
+	DeliveryAttendantTraditional,
+	DeliveryAttendantSelf,
+	DeliveryAttendantOther,
+}
+
+// IsDeliveryAttendant reports whether s is a known delivery attendant
+func IsDeliveryAttendant(s string) bool {
+	for _, attendant := range deliveryAttendants {
+		if attendant == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ObstetricHistory is one baby (or one pregnancy loss) in the mother's obstetric history.
+// Twins are stored as two rows sharing the same PregnancyNo.
+type ObstetricHistory struct {
+	gorm.Model
+
+	// FK -> PregnantWoman
+	PregnantWomanID *uint
+	PregnantWoman   *PregnantWoman `gorm:"references:ID"`
+
+	// FK -> Pregnancy ที่สิ้นสุดแล้ว (ว่างได้สำหรับประวัติที่บันทึกย้อนหลัง)
+	PregnancyID *uint      `json:"pregnancy_id"`
+	Pregnancy   *Pregnancy `gorm:"references:ID" json:"-"`
+
+	PregnancyNo    int       `json:"pregnancy_no"` // ครรภ์ที่ (Gravida)
+	BabyNo         int       `json:"baby_no"`      // ลำดับทารกในครรภ์แฝด (1 สำหรับครรภ์เดี่ยว)
+	Outcome        string    `json:"outcome"`      // LiveBirth, Stillbirth, Miscarriage, Termination, Ectopic
+	DeliveryDate   time.Time `json:"delivery_date"`
+	GestationalAge int       `json:"gestational_age"` // Weeks
+	DeliveryMethod string    `json:"delivery_method"` // Normal, C-Section, Vacuum, etc.
+	BirthWeight    float64   `json:"birth_weight"`    // kg
+	Sex            string    `json:"sex"`             // Male, Female
+	DeliveryPlace  string    `json:"delivery_place"`
+	Complications  string    `json:"complications"`
+	ChildStatus    string    `json:"child_status"` // Healthy, Deceased, etc.
+
+	// ผู้ทำคลอด: Doctor, Nurse, HealthWorker, TraditionalBirthAttendant, Self, Other (ว่าง = ไม่ได้บันทึก)
+	DeliveryAttendant string `json:"delivery_attendant"`
+}
+
+// GTPAL สรุปประวัติการตั้งครรภ์ (Gravida, Term, Preterm, Abortion, Living)
+type GTPAL struct {
+	Gravida  int `json:"gravida"`
+	Term     int `json:"term"`
+	Preterm  int `json:"preterm"`
+	Abortion int `json:"abortion"`
+	Living   int `json:"living"`
+}
@@ -0,0 +1,65 @@
+package entity
+
+import "gorm.io/gorm"
+
+// หมวดเอกสาร
+const (
+	DocumentUltrasound = "Ultrasound" // ภาพอัลตราซาวด์
+	DocumentReferral   = "Referral"   // ใบส่งตัว
+	DocumentOutsideLab = "OutsideLab" // ผลแล็บจากที่อื่น
+	DocumentPinkBook   = "PinkBook"   // ภาพสมุดสีชมพู
+	DocumentPhoto      = "Photo"
+	DocumentOther      = "Other"
+)
+
+// PatientDocument เอกสารแนบของมารดา ผูกกับครรภ์หรือบันทึกทางคลินิกได้ การอัปโหลดซ้ำจะเพิ่มเวอร์ชันใหม่
+type PatientDocument struct {
+	gorm.Model
+
+	// FK -> PregnantWoman (เจ้าของเอกสาร)
+	PregnantWomanID *uint          `json:"pregnant_woman_id"`
+	PregnantWoman   *PregnantWoman `gorm:"references:ID" json:"-"`
+
+	// FK -> Pregnancy (ถ้าเอกสารเป็นของครรภ์ใดครรภ์หนึ่ง)
+	PregnancyID *uint      `json:"pregnancy_id"`
+	Pregnancy   *Pregnancy `gorm:"references:ID" json:"-"`
+
+	// บันทึกที่แนบ เช่น lab_result, antenatal_visit (ว่างถ้าไม่ได้ผูกกับบันทึก)
+	RecordType string `gorm:"index:idx_patient_documents_record" json:"record_type"`
+	RecordID   *uint  `gorm:"index:idx_patient_documents_record" json:"record_id"`
+
+	Category    string `json:"category"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Tags        string `json:"tags"` // คั่นด้วยจุลภาค
+
+	CurrentVersion int `json:"current_version"`
+
+	UploadedByRole string `json:"uploaded_by_role"` // doctor, pregnant
+	UploadedByID   uint   `json:"uploaded_by_id"`
+
+	Versions []DocumentVersion `gorm:"foreignKey:PatientDocumentID" json:"versions"`
+}
+
+// DocumentVersion ไฟล์แต่ละเวอร์ชันของเอกสาร เวอร์ชันเก่ายังดาวน์โหลดได้
+type DocumentVersion struct {
+	gorm.Model
+
+	// FK -> PatientDocument
+	PatientDocumentID *uint            `json:"patient_document_id"`
+	PatientDocument   *PatientDocument `gorm:"references:ID" json:"-"`
+
+	Version int    `json:"version"`
+	Note    string `json:"note"` // เหตุผลที่อัปโหลดใหม่
+
+	// FK -> StoredFile (ไฟล์จริง)
+	StoredFileID *uint       `json:"stored_file_id"`
+	StoredFile   *StoredFile `gorm:"references:ID" json:"file"`
+
+	// FK -> StoredFile (ภาพย่อ มีเฉพาะไฟล์ภาพ)
+	ThumbnailID *uint       `json:"thumbnail_id"`
+	Thumbnail   *StoredFile `gorm:"references:ID" json:"thumbnail"`
+
+	UploadedByRole string `json:"uploaded_by_role"`
+	UploadedByID   uint   `json:"uploaded_by_id"`
+}
@@ -0,0 +1,49 @@
+package entity
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ประเภทการตรวจหลังคลอด
+const (
+	PostpartumVisitEarly   = "Early"   // วันที่ 1-7 หลังคลอด
+	PostpartumVisitSixWeek = "SixWeek" // 6 สัปดาห์หลังคลอด
+	PostpartumVisitOther   = "Other"
+)
+
+// PostpartumVisit การตรวจมารดาหลังคลอด ผูกกับครรภ์ที่สิ้นสุดแล้ว
+type PostpartumVisit struct {
+	gorm.Model
+
+	// FK -> Pregnancy
+	PregnancyID *uint      `json:"pregnancy_id"`
+	Pregnancy   *Pregnancy `gorm:"references:ID" json:"-"`
+
+	// FK -> Doctor
+	DoctorID *uint   `json:"doctor_id"`
+	Doctor   *Doctor `gorm:"references:ID" json:"-"`
+
+	VisitDate     time.Time `json:"visit_date"`
+	DayPostpartum int       `json:"day_postpartum"`
+	VisitType     string    `json:"visit_type"` // Early, SixWeek, Other
+
+	// ผลตรวจ
+	BloodPressure         string  `json:"blood_pressure"`
+	PulseRate             int     `json:"pulse_rate"`
+	Temperature           float64 `json:"temperature"`
+	Bleeding              string  `json:"bleeding"`           // ลักษณะน้ำคาวปลา: normal, heavy, foul_smelling
+	UterineInvolution     string  `json:"uterine_involution"` // ระดับยอดมดลูก / การหดรัดตัว
+	Breastfeeding         string  `json:"breastfeeding"`      // exclusive, partial, none
+	BreastfeedingProblems string  `json:"breastfeeding_problems"`
+	WoundCondition        string  `json:"wound_condition"` // แผลฝีเย็บ/แผลผ่าตัด: healing, infected, dehiscence
+	Notes                 string  `json:"notes"`
+
+	// การให้คำปรึกษาเรื่องการคุมกำเนิด
+	ContraceptionCounseled bool   `json:"contraception_counseled"`
+	ContraceptionMethod    string `json:"contraception_method"` // pill, injection, implant, iud, condom, sterilization, lam, none
+	ContraceptionAccepted  bool   `json:"contraception_accepted"`
+
+	EpdsScreenings []EpdsScreening `gorm:"foreignKey:PostpartumVisitID" json:"epds_screenings,omitempty"`
+}
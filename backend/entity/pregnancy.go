@@ -6,6 +6,37 @@ import (
 	"gorm.io/gorm"
 )
 
+// สถานะของการตั้งครรภ์
+const (
+	PregnancyStatusActive         = "Active"
+	PregnancyStatusDelivered      = "Delivered"
+	PregnancyStatusMiscarriage    = "Miscarriage"
+	PregnancyStatusTermination    = "Termination"
+	PregnancyStatusEctopic        = "Ectopic"
+	PregnancyStatusTransferredOut = "TransferredOut"
+	PregnancyStatusLostToFollowUp = "LostToFollowUp"
+)
+
+var pregnancyStatuses = []string{
+	PregnancyStatusActive,
+	PregnancyStatusDelivered,
+	PregnancyStatusMiscarriage,
+	PregnancyStatusTermination,
+	PregnancyStatusEctopic,
+	PregnancyStatusTransferredOut,
+	PregnancyStatusLostToFollowUp,
+}
+
+// IsPregnancyStatus reports whether s is a known pregnancy status
+func IsPregnancyStatus(s string) bool {
+	for _, status := range pregnancyStatuses {
+		if status == s {
+			return true
+		}
+	}
+	return false
+}
+
 type Pregnancy struct {
 	gorm.Model
 
@@ -14,14 +45,37 @@ type Pregnancy struct {
 	PregnantWoman   *PregnantWoman `gorm:"references:ID" valid:"-"`
 
 	PregnancyNo        int
-	Status             string    `json:"status"` // Active, Ended
+	Status             string    `json:"status"` // Active, Delivered, Miscarriage, Termination, Ectopic, TransferredOut, LostToFollowUp
 	LMP                time.Time // Last Menstrual Period
 	EDC                time.Time // Expected Date of Confinement
 	PrePregnancyWeight float64
 	Height             float64
 	PrePregnancyBMI    float64
+	FetusCount         int `gorm:"default:1"` // 1 = ครรภ์เดี่ยว, 2 = แฝดสอง, 3 = แฝดสาม
+
+	// FK -> AncScheduleTemplate (ตารางนัดฝากครรภ์ที่ใช้)
+	AncScheduleTemplateID *uint                `valid:"-"`
+	AncScheduleTemplate   *AncScheduleTemplate `gorm:"references:ID" valid:"-"`
+
+	// ผลสิ้นสุดการตั้งครรภ์
+	OutcomeDate           *time.Time
+	OutcomeGestationalAge int
+	TransferTo            string // สถานพยาบาลที่ส่งต่อ
+
+	// เบาหวานขณะตั้งครรภ์ (ตั้งค่าอัตโนมัติจากผล OGTT)
+	GdmDiagnosed   bool       `json:"gdm_diagnosed"`
+	GdmDiagnosedAt *time.Time `json:"gdm_diagnosed_at"`
 
 	AntenatalVisits []AntenatalVisit `gorm:"foreignKey:PregnancyID"`
 	LabResults      []LabResult      `gorm:"foreignKey:PregnancyID"`
 	FetalKickCounts []FetalKickCount `gorm:"foreignKey:PregnancyID"`
+	Fetuses         []Fetus          `gorm:"foreignKey:PregnancyID"`
+
+	StatusTransitions []PregnancyStatusTransition `gorm:"foreignKey:PregnancyID"`
+
+	// หลังคลอด
+	PostpartumVisits []PostpartumVisit `gorm:"foreignKey:PregnancyID"`
+	EpdsScreenings   []EpdsScreening   `gorm:"foreignKey:PregnancyID"`
+
+	GdmScreenings []GdmScreening `gorm:"foreignKey:PregnancyID"`
 }
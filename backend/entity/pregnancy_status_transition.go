@@ -0,0 +1,23 @@
+package entity
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PregnancyStatusTransition บันทึกการเปลี่ยนสถานะของการตั้งครรภ์แต่ละครั้ง
+type PregnancyStatusTransition struct {
+	gorm.Model
+
+	// FK -> Pregnancy
+	PregnancyID *uint      `valid:"required~กรุณาเลือกครรภ์"`
+	Pregnancy   *Pregnancy `gorm:"references:ID" valid:"-"`
+
+	FromStatus     string
+	ToStatus       string
+	Reason         string
+	ActorRole      string // doctor, pregnant
+	ActorID        uint
+	TransitionedAt time.Time
+}
@@ -15,15 +15,16 @@ type PregnantWoman struct {
 	CitizenID   string `json:"citizen_id"`
 	PhoneNumber string `json:"phone_number"`
 	Email       string `json:"email"`
+	// รหัสเชื้อชาติและสัญชาติตามมาตรฐาน 43 แฟ้ม (099 = ไทย) ว่าง = ไม่ได้บันทึก
+	Race        string `json:"race"`
+	Nationality string `json:"nationality"`
+	// token แจ้งเตือนผ่าน webhook (LINE Notify) ของแม่เอง ไม่ส่งออกทาง API
+	NotifyToken string `json:"-"`
 
 	// **ฟิลด์ที่เพิ่มเข้ามาสำหรับการล็อกอิน**
 	Username string `gorm:"uniqueIndex" json:"username"`
 	Password string `json:"password"`
 
-	// FK -> Appointment (A_ID)
-	AppointmentID *uint        `gorm:"column:a_id" valid:"-"`
-	Appointment   *Appointment `gorm:"references:ID" valid:"-"`
-
 	// FK -> Husband
 	HusbandID *uint    `valid:"-"`
 	Husband   *Husband `gorm:"references:ID" valid:"-"`
@@ -31,5 +32,18 @@ type PregnantWoman struct {
 	MedicalHistories   []MedicalHistory   `gorm:"foreignKey:PregnantWomanID"`
 	Vaccinations       []Vaccination      `gorm:"foreignKey:PregnantWomanID"`
 	Pregnancies        []Pregnancy        `gorm:"foreignKey:PregnantWomanID"`
-	PregnancyHistories []PregnancyHistory `gorm:"foreignKey:PregnantWomanID"`
+	ObstetricHistories []ObstetricHistory `gorm:"foreignKey:PregnantWomanID"`
+	Appointments       []Appointment      `gorm:"foreignKey:PregnantWomanID" json:"-"`
+
+	// นัดหมายครั้งถัดไป คำนวณจาก Appointments ไม่ได้บันทึกลงฐานข้อมูล
+	Appointment *Appointment `gorm:"-" valid:"-"`
+
+	// คำนวณจาก ObstetricHistories ไม่ได้บันทึกลงฐานข้อมูล
+	GTPAL *GTPAL `gorm:"-" json:"gtpal,omitempty"`
+
+	// คำนวณเปอร์เซ็นต์การกินยาของครรภ์ปัจจุบัน ไม่ได้บันทึกลงฐานข้อมูล
+	Adherence []PrescriptionAdherence `gorm:"-" json:"adherence,omitempty"`
+
+	// บันทึกทางคลินิกของทุกครรภ์ เรียงตามเวลา ไม่ได้บันทึกลงฐานข้อมูล
+	ClinicalNotes []ClinicalNote `gorm:"-" json:"clinical_notes,omitempty"`
 }
@@ -0,0 +1,73 @@
+package entity
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// สถานะของใบสั่งยา
+const (
+	PrescriptionStatusActive  = "Active"
+	PrescriptionStatusStopped = "Stopped"
+)
+
+// Prescription ยา/วิตามินเสริมที่สั่งให้มารดาระหว่างตั้งครรภ์ (ธาตุเหล็ก, โฟลิก, ไอโอดีน, แคลเซียม ฯลฯ)
+type Prescription struct {
+	gorm.Model
+
+	// FK -> Pregnancy
+	PregnancyID *uint      `json:"pregnancy_id"`
+	Pregnancy   *Pregnancy `gorm:"references:ID" json:"-"`
+
+	// FK -> Doctor (ผู้สั่งยา)
+	DoctorID *uint   `json:"doctor_id"`
+	Doctor   *Doctor `gorm:"references:ID" json:"-"`
+
+	DrugName     string     `json:"drug_name"`
+	Category     string     `json:"category"`      // iron, folic_acid, iodine, calcium, other
+	Dose         string     `json:"dose"`          // เช่น 60 mg
+	Frequency    string     `json:"frequency"`     // เช่น วันละ 1 ครั้ง หลังอาหารเช้า
+	DosesPerDay  int        `json:"doses_per_day"` // ใช้คำนวณการกินยาครบ
+	StartDate    time.Time  `json:"start_date"`
+	EndDate      *time.Time `json:"end_date"`
+	Instructions string     `json:"instructions"`
+
+	Status     string `json:"status"` // Active, Stopped
+	StopReason string `json:"stop_reason"`
+
+	// สั่งทั้งที่ชื่อยาตรงกับประวัติแพ้ยา ต้องระบุเหตุผล
+	AllergyOverride       bool   `json:"allergy_override"`
+	AllergyOverrideReason string `json:"allergy_override_reason"`
+
+	AdherenceLogs []AdherenceLog `gorm:"foreignKey:PrescriptionID" json:"adherence_logs,omitempty"`
+}
+
+// AdherenceLog บันทึกการกินยาประจำวันของมารดา (หนึ่งแถวต่อวันต่อใบสั่งยา)
+type AdherenceLog struct {
+	gorm.Model
+
+	// FK -> Prescription
+	PrescriptionID *uint         `gorm:"uniqueIndex:idx_adherence_day" json:"prescription_id"`
+	Prescription   *Prescription `gorm:"references:ID" json:"-"`
+
+	Day        string `gorm:"uniqueIndex:idx_adherence_day" json:"day"` // YYYY-MM-DD ตามเวลาคลินิก
+	DosesTaken int    `json:"doses_taken"`
+	Notes      string `json:"notes"` // เหตุผลที่ไม่ได้กิน/อาการข้างเคียง
+
+	// ผู้บันทึกล่าสุด: pregnant (แม่บันทึกเอง) หรือ doctor
+	LoggedBy   string `json:"logged_by"`
+	LoggedByID uint   `json:"logged_by_id"`
+}
+
+// PrescriptionAdherence สรุปเปอร์เซ็นต์การกินยาของใบสั่งยา (คำนวณ ไม่ได้บันทึก)
+type PrescriptionAdherence struct {
+	PrescriptionID uint    `json:"prescription_id"`
+	DrugName       string  `json:"drug_name"`
+	Category       string  `json:"category"`
+	Status         string  `json:"status"`
+	ExpectedDoses  int     `json:"expected_doses"`
+	TakenDoses     int     `json:"taken_doses"`
+	DaysLogged     int     `json:"days_logged"`
+	Percent        float64 `json:"percent"`
+}
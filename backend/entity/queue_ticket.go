@@ -0,0 +1,20 @@
+package entity
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// QueueTicket คือบัตรคิวที่ได้ตอนเช็คอิน เลขคิวไม่ซ้ำกันภายในรอบตรวจ (วัน + แพทย์)
+type QueueTicket struct {
+	gorm.Model
+	SessionKey string `gorm:"uniqueIndex:idx_queue_session_no" json:"session_key"` // 2026-10-19/d1
+	QueueNo    int    `gorm:"uniqueIndex:idx_queue_session_no" json:"queue_no"`
+
+	// FK -> Appointment (หนึ่งนัดได้หนึ่งคิว)
+	AppointmentID *uint        `gorm:"uniqueIndex" json:"appointment_id"`
+	Appointment   *Appointment `gorm:"references:ID" json:"-"`
+
+	CalledAt *time.Time `json:"called_at"` // เวลาที่เรียกเข้าห้องตรวจ
+}
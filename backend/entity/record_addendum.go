@@ -0,0 +1,26 @@
+package entity
+
+import "gorm.io/gorm"
+
+// สถานะการลงนามของบันทึกการตรวจและผลแล็บ
+const (
+	RecordStatusDraft  = "Draft"
+	RecordStatusSigned = "Signed"
+)
+
+// RecordAddendum บันทึกแนบท้ายเพื่อแก้ไข/เพิ่มเติมบันทึกที่ลงนามแล้ว (ไม่แก้ไขบันทึกเดิม)
+type RecordAddendum struct {
+	gorm.Model
+
+	// บันทึกที่แนบท้าย (antenatal_visit, lab_result)
+	RecordType string `gorm:"index:idx_addendum_record" json:"record_type"`
+	RecordID   uint   `gorm:"index:idx_addendum_record" json:"record_id"`
+
+	// FK -> Doctor (ผู้เขียน)
+	DoctorID *uint   `json:"doctor_id"`
+	Doctor   *Doctor `gorm:"references:ID" json:"-"`
+
+	Reason  string `json:"reason"`
+	Text    string `json:"text"`
+	Changes string `json:"changes"` // ค่าที่แก้ไข (JSON) ถ้ามี
+}
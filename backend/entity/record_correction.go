@@ -0,0 +1,28 @@
+package entity
+
+import "gorm.io/gorm"
+
+// การแก้ไขข้อมูลทางคลินิก
+const (
+	CorrectionUpdate  = "Update"
+	CorrectionDelete  = "Delete"
+	CorrectionRestore = "Restore"
+)
+
+// RecordCorrection บันทึกการแก้ไข ลบ หรือกู้คืนข้อมูลทางคลินิก พร้อมเหตุผลและค่าเดิม
+type RecordCorrection struct {
+	gorm.Model
+
+	RecordType string `gorm:"index:idx_correction_record" json:"record_type"` // antenatal_visit, lab_result, vaccination, ...
+	RecordID   uint   `gorm:"index:idx_correction_record" json:"record_id"`
+	Action     string `json:"action"` // Update, Delete, Restore
+	Reason     string `json:"reason"`
+
+	// ค่าของข้อมูลก่อนและหลังการแก้ไข (JSON)
+	OriginalValues string `json:"original_values"`
+	NewValues      string `json:"new_values"`
+
+	// FK -> Doctor (ผู้แก้ไข)
+	DoctorID *uint   `json:"doctor_id"`
+	Doctor   *Doctor `gorm:"references:ID" json:"-"`
+}
@@ -24,7 +24,6 @@ func SetupDatabase() {
 		// -------- ระบบสมุดแม่และเด็กอิเล็กทรอนิกส์ --------
 		// Master / Lookup
 		&BloodType{},
-		&CheckResult{},
 
 		// บุคคล
 		&Doctor{},
@@ -35,6 +34,40 @@ func SetupDatabase() {
 		// การพบแพทย์ / นัดหมาย
 		&VisitDoctor{},
 		&Appointment{},
+		&DoctorAvailability{},
+		&AvailabilityException{},
+		&AppointmentSlot{},
+		&AncScheduleTemplate{},
+		&AncContact{},
+		&Notification{},
+		&CalendarFeed{},
+		&QueueTicket{},
+		&FollowUpItem{},
+		&FollowUpOutreach{},
+		&PostpartumVisit{},
+		&EpdsScreening{},
+		&Prescription{},
+		&AdherenceLog{},
+		&DangerSignReport{},
+		&MonitoringTarget{},
+		&HomeReading{},
+		&GdmScreening{},
+		&RecordCorrection{},
+		&RecordAddendum{},
+		&Icd10Code{},
+		&NoteTemplate{},
+		&ClinicalNote{},
+		&NoteDiagnosis{},
+		&NotePlanItem{},
+		&LabOrder{},
+		&LabTest{},
+		&LabReferenceRange{},
+		&LabObservation{},
+		&StoredFile{},
+		&PatientDocument{},
+		&DocumentVersion{},
+		&FhirLink{},
+		&Hl7InboundMessage{},
 
 		// ประวัติการเจ็บป่วย
 		&MedicalHistory{},
@@ -48,9 +81,12 @@ func SetupDatabase() {
 		&Pregnancy{},
 		&AntenatalVisit{},
 		&FetalKickCount{},
+		&Fetus{},
+		&FetalObservation{},
+		&PregnancyStatusTransition{},
 
 		// ประวัติการตั้งครรภ์ที่ผ่านมา
-		&PregnancyHistory{},
+		&ObstetricHistory{},
 
 		// ผลแลป
 		&LabResult{},
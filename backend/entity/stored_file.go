@@ -0,0 +1,20 @@
+package entity
+
+import "gorm.io/gorm"
+
+// StoredFile ไฟล์ที่อัปโหลดไว้ในที่เก็บไฟล์ (local หรือ S3) ดาวน์โหลดได้ผ่าน /files/:id เมื่อมีสิทธิ์เท่านั้น
+type StoredFile struct {
+	gorm.Model
+	StorageKey  string `gorm:"uniqueIndex" json:"-"` // คีย์สุ่มในที่เก็บไฟล์ ไม่เปิดเผยให้ผู้ใช้
+	FileName    string `json:"file_name"`            // ชื่อไฟล์เดิมที่ผ่านการทำความสะอาดแล้ว
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	Checksum    string `json:"checksum"` // SHA-256
+
+	// FK -> PregnantWoman (เจ้าของข้อมูล ใช้ตรวจสิทธิ์ดาวน์โหลด)
+	PregnantWomanID *uint          `json:"pregnant_woman_id"`
+	PregnantWoman   *PregnantWoman `gorm:"references:ID" json:"-"`
+
+	UploadedByRole string `json:"uploaded_by_role"` // doctor, pregnant
+	UploadedByID   uint   `json:"uploaded_by_id"`
+}
@@ -2,8 +2,16 @@ package entity
 
 import "gorm.io/gorm"
 
+// รหัสชนิดวัคซีนที่ระบบใช้ตัดสินใจ (เช่น ตารางฉีด dT)
+const (
+	VaccineCodeDT        = "dT"
+	VaccineCodeInfluenza = "FLU"
+	VaccineCodeCovid19   = "COVID19"
+)
+
 type VaccineType struct {
 	gorm.Model
 	Name         string
+	Code         string        `gorm:"index"`
 	Vaccinations []Vaccination `gorm:"foreignKey:VaccineTypeID"`
 }
@@ -0,0 +1,152 @@
+package hl7
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/service"
+)
+
+// idleTimeout closes connections the LIS keeps open without sending
+const idleTimeout = 10 * time.Minute
+
+// Handler stores and processes one message and returns the ACK to send back
+type Handler func(raw []byte, via, remote string) string
+
+// Listener accepts HL7 v2 messages over MLLP (TCP). Each frame is answered with the ACK of the handler.
+type Listener struct {
+	Addr     string
+	Allowed  []*net.IPNet // senders that may connect; empty = nobody
+	MaxBytes int
+	Handle   Handler
+
+	ln net.Listener
+}
+
+// Start listens on HL7_MLLP_ADDR (e.g. ":2575"). The listener is off unless the
+// address is set; HL7_MLLP_ALLOW, a comma separated list of IPs / CIDRs, must then name
+// the senders, because MLLP has no authentication of its own.
+func Start(handle Handler, maxBytes int) *Listener {
+	addr := os.Getenv("HL7_MLLP_ADDR")
+	if addr == "" {
+		log.Println("HL7 MLLP listener disabled")
+		return nil
+	}
+
+	allowed, err := parseAllowList(os.Getenv("HL7_MLLP_ALLOW"))
+	if err != nil {
+		panic("invalid HL7_MLLP_ALLOW: " + err.Error())
+	}
+	if len(allowed) == 0 {
+		panic("HL7_MLLP_ADDR is set but HL7_MLLP_ALLOW is empty; list the LIS addresses (e.g. 10.0.0.5 or 10.0.0.0/24)")
+	}
+	l := &Listener{Addr: addr, Allowed: allowed, MaxBytes: maxBytes, Handle: handle}
+	if err := l.Listen(); err != nil {
+		panic("failed to start HL7 MLLP listener: " + err.Error())
+	}
+	log.Println("HL7 MLLP listener on", l.ln.Addr())
+	go l.Serve()
+	return l
+}
+
+// Listen opens the TCP socket
+func (l *Listener) Listen() error {
+	ln, err := net.Listen("tcp", l.Addr)
+	if err != nil {
+		return err
+	}
+	l.ln = ln
+	return nil
+}
+
+// Serve accepts connections until the listener is closed
+func (l *Listener) Serve() {
+	for {
+		conn, err := l.ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Println("HL7 MLLP accept:", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		if !l.allows(conn.RemoteAddr()) {
+			log.Println("HL7 MLLP: refused connection from", conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+		go l.serveConn(conn)
+	}
+}
+
+// Close stops accepting connections
+func (l *Listener) Close() error {
+	return l.ln.Close()
+}
+
+// serveConn answers every frame on the connection in order; the LIS waits for each ACK before sending the next message
+func (l *Listener) serveConn(conn net.Conn) {
+	defer conn.Close()
+	remote := conn.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+
+	r := bufio.NewReader(conn)
+	for {
+		conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		frame, err := service.ReadMllpFrame(r, l.MaxBytes)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Println("HL7 MLLP", remote+":", err)
+			}
+			return
+		}
+		ack := l.Handle(frame, "mllp", remote)
+		conn.SetWriteDeadline(time.Now().Add(time.Minute))
+		if _, err := conn.Write(service.MllpFrame(ack)); err != nil {
+			log.Println("HL7 MLLP", remote+":", err)
+			return
+		}
+	}
+}
+
+func (l *Listener) allows(addr net.Addr) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, network := range l.Allowed {
+		if network.Contains(tcp.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAllowList reads "10.0.0.5, 192.168.1.0/24"
+func parseAllowList(value string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, entry := range service.SplitList(value) {
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
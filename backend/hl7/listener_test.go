@@ -0,0 +1,82 @@
+package hl7
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/service"
+)
+
+func TestParseAllowList(t *testing.T) {
+	networks, err := parseAllowList("10.0.0.5, 192.168.1.0/24, ::1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &Listener{Allowed: networks}
+	for addr, want := range map[string]bool{
+		"10.0.0.5": true, "10.0.0.6": false, "192.168.1.200": true, "::1": true, "127.0.0.1": false,
+	} {
+		if got := l.allows(&net.TCPAddr{IP: net.ParseIP(addr)}); got != want {
+			t.Errorf("allows(%s) = %v", addr, got)
+		}
+	}
+	if _, err := parseAllowList("10.0.0.300"); err == nil {
+		t.Error("an invalid address was accepted")
+	}
+}
+
+// serveOnce starts a listener on a free port and sends it one message
+func serveOnce(t *testing.T, allow string) (ack string, handled bool) {
+	t.Helper()
+	networks, err := parseAllowList(allow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &Listener{Addr: "127.0.0.1:0", Allowed: networks, MaxBytes: 1 << 10, Handle: func(raw []byte, via, remote string) string {
+		handled = true
+		return "MSA|AA|" + string(raw)
+	}}
+	if err := l.Listen(); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go l.Serve()
+
+	conn, err := net.Dial("tcp", l.ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	conn.Write(service.MllpFrame("MSH|1"))
+	frame, err := service.ReadMllpFrame(bufio.NewReader(conn), 1<<10)
+	if err != nil {
+		return "", handled
+	}
+	return string(frame), handled
+}
+
+func TestListenerAllowsListedSender(t *testing.T) {
+	if ack, _ := serveOnce(t, "127.0.0.1"); ack != "MSA|AA|MSH|1" {
+		t.Fatalf("ACK = %q", ack)
+	}
+}
+
+func TestListenerRefusesEveryoneWithoutAllowList(t *testing.T) {
+	if ack, handled := serveOnce(t, ""); ack != "" || handled {
+		t.Fatalf("a sender was served without HL7_MLLP_ALLOW: ACK %q", ack)
+	}
+}
+
+func TestStartRequiresAllowList(t *testing.T) {
+	t.Setenv("HL7_MLLP_ADDR", "127.0.0.1:0")
+	t.Setenv("HL7_MLLP_ALLOW", "")
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Start opened an MLLP port that accepts any sender")
+		}
+	}()
+	Start(func(raw []byte, via, remote string) string { return "" }, 1<<10)
+}
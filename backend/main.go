@@ -3,7 +3,9 @@ package main
 import (
 	"github.com/bestiesmile1845/Projecteiei/config"
 	"github.com/bestiesmile1845/Projecteiei/controller"
+	"github.com/bestiesmile1845/Projecteiei/hl7"
 	"github.com/bestiesmile1845/Projecteiei/middlewares"
+	"github.com/bestiesmile1845/Projecteiei/scheduler"
 
 	"github.com/gin-gonic/gin"
 )
@@ -12,8 +14,18 @@ func main() {
 
 	// Initialize database connection
 	config.ConnectionDB()
+	// Storage first: migrating old lab result files copies them into the store
+	config.SetupStorage()
 	config.SetupDatabase()
 
+	// Appointment reminders (notification outbox)
+	scheduler.Start(config.DB())
+	scheduler.StartNoShow(config.DB())
+	scheduler.StartFollowUps(config.DB())
+
+	// HL7 v2 lab results from the LIS over MLLP (HL7_MLLP_ADDR)
+	hl7.Start(controller.IngestHl7Message, controller.Hl7MaxMessageBytes)
+
 	r := gin.Default()
 
 	r.Use(CORSMiddleware())
@@ -21,6 +33,15 @@ func main() {
 	r.POST("/login", controller.Login)
 	r.POST("/register", controller.CreatePregnantWoman)
 
+	// Calendar subscriptions authenticate with the secret token in the URL
+	r.GET("/calendar/feeds/:token", controller.GetCalendarFeed)
+
+	// Waiting-room display shows queue numbers only
+	r.GET("/queue", controller.GetQueueStatus)
+
+	// The LIS authenticates with HL7_HTTP_TOKEN
+	r.POST("/hl7/oru", controller.ReceiveHl7Message)
+
 	// Protected Routes
 	protected := r.Group("/")
 	protected.Use(middlewares.Authorizes())
@@ -38,7 +59,7 @@ func main() {
 		// Medical History Routes
 		protected.GET("/medical-histories/pregnant-woman/:id", controller.GetMedicalHistoryByPregnantWomanID)
 		protected.PUT("/medical-histories/:id", controller.UpdateMedicalHistory)
-		protected.GET("/previous-pregnancies/pregnant-woman/:id", controller.GetPreviousPregnanciesByPregnantWomanID)
+		protected.GET("/previous-pregnancies/pregnant-woman/:id", controller.GetObstetricHistoriesByPregnantWomanID)
 
 		// Antenatal Visit Routes
 		protected.GET("/antenatal-visits/pregnancy/:id", controller.GetAntenatalVisitsByPregnancyID)
@@ -51,7 +72,106 @@ func main() {
 		protected.POST("/doctor/antenatal-visit", controller.DoctorCreateAntenatalVisit)
 		protected.POST("/doctor/pregnancy", controller.DoctorCreatePregnancy)
 		protected.POST("/doctor/pregnancy/:id/end", controller.EndPregnancy)
+		protected.POST("/doctor/pregnancy/:id/status", controller.ChangePregnancyStatus)
+		protected.POST("/doctor/pregnancy/:id/reopen", controller.ReopenPregnancy)
+		protected.GET("/doctor/pregnancy/:pregnancyId/transitions", controller.GetPregnancyTransitions)
+		protected.PUT("/doctor/pregnancy/:id/fetuses", controller.UpdateFetusCount)
+		protected.GET("/pregnancies/:id/fetuses", controller.GetFetusesByPregnancyID)
+		protected.PUT("/doctor/pregnancy/:id/edc", controller.RedatePregnancy)
+		protected.GET("/pregnancies/:id/anc-schedule", controller.GetAncSchedule)
+		protected.GET("/doctor/anc-schedule/missed", controller.GetMissedAncContacts)
+		protected.GET("/anc-schedule-templates", controller.ListAncScheduleTemplates)
+		protected.POST("/doctor/anc-schedule-templates", controller.CreateAncScheduleTemplate)
+		protected.PUT("/doctor/anc-schedule-templates/:id", controller.UpdateAncScheduleTemplate)
+		protected.DELETE("/doctor/anc-schedule-templates/:id", controller.DeleteAncScheduleTemplate)
+		protected.GET("/notifications", controller.GetMyNotifications)
+		protected.GET("/doctor/notifications", controller.GetNotificationOutbox)
+		protected.POST("/doctor/notifications/:id/retry", controller.RetryNotification)
+		protected.POST("/doctor/notifications/run", controller.RunReminderScheduler)
 		protected.POST("/doctor/patient/:id/appointment", controller.DoctorCreateAppointment)
+		protected.GET("/doctor/patient/:patientId/appointments", controller.GetPatientAppointments)
+		protected.PUT("/doctor/appointments/:id/status", controller.UpdateAppointmentStatus)
+
+		// Availability & Booking Routes
+		protected.GET("/doctor/availability", controller.GetMyAvailability)
+		protected.POST("/doctor/availability", controller.CreateAvailability)
+		protected.DELETE("/doctor/availability/:id", controller.DeleteAvailability)
+		protected.POST("/doctor/availability/exceptions", controller.CreateAvailabilityException)
+		protected.DELETE("/doctor/availability/exceptions/:id", controller.DeleteAvailabilityException)
+		protected.GET("/doctors", controller.ListDoctors)
+		protected.GET("/doctors/:id/slots", controller.GetDoctorSlots)
+
+		// Appointment Routes
+		protected.GET("/appointments", controller.GetMyAppointments)
+		protected.POST("/appointments/book", controller.BookAppointment)
+		protected.PUT("/appointments/:id/reschedule", controller.RescheduleAppointment)
+		protected.POST("/appointments/:id/cancel", controller.CancelAppointment)
+		protected.GET("/appointments/:id/ics", controller.GetAppointmentICS)
+		protected.GET("/appointments/:id/check-in-qr", controller.GetCheckInQR)
+		protected.POST("/appointments/:id/check-in", controller.CheckInAppointment)
+		protected.POST("/doctor/check-in", controller.ScanCheckIn)
+		protected.GET("/doctor/queue/kiosk-code", controller.GetKioskCode)
+		protected.POST("/doctor/queue/:id/call", controller.CallQueue)
+		protected.GET("/doctor/follow-ups", controller.GetFollowUpWorklist)
+		protected.POST("/doctor/follow-ups/refresh", controller.RefreshFollowUpWorklist)
+		protected.POST("/doctor/follow-ups/:id/outreach", controller.RecordFollowUpOutreach)
+		protected.POST("/doctor/follow-ups/:id/close", controller.CloseFollowUp)
+		protected.POST("/doctor/pregnancy/:id/postpartum-visits", controller.CreatePostpartumVisit)
+		protected.GET("/pregnancies/:id/postpartum-visits", controller.GetPostpartumVisits)
+		protected.POST("/pregnancies/:id/epds", controller.SubmitEpdsScreening)
+		protected.GET("/pregnancies/:id/epds", controller.GetEpdsScreenings)
+		protected.GET("/doctor/postpartum/epds-alerts", controller.GetEpdsAlerts)
+		protected.POST("/doctor/postpartum/epds/:id/review", controller.ReviewEpdsScreening)
+		protected.POST("/doctor/pregnancy/:id/prescriptions", controller.CreatePrescription)
+		protected.POST("/doctor/prescriptions/:id/stop", controller.StopPrescription)
+		protected.GET("/pregnancies/:id/prescriptions", controller.GetPrescriptions)
+		protected.GET("/prescriptions/today", controller.GetTodayMedications)
+		protected.PUT("/prescriptions/:id/adherence", controller.LogAdherence)
+		protected.GET("/prescriptions/:id/adherence", controller.GetAdherenceLogs)
+		protected.POST("/pregnancies/:id/danger-signs", controller.ReportDangerSigns)
+		protected.GET("/pregnancies/:id/danger-signs", controller.GetDangerSignReports)
+		protected.GET("/doctor/danger-signs", controller.GetUrgentDangerSigns)
+		protected.POST("/doctor/danger-signs/:id/acknowledge", controller.AcknowledgeDangerSign)
+		protected.GET("/pregnancies/:id/monitoring-targets", controller.GetMonitoringTarget)
+		protected.PUT("/doctor/pregnancy/:id/monitoring-targets", controller.SetMonitoringTarget)
+		protected.POST("/pregnancies/:id/home-readings", controller.CreateHomeReading)
+		protected.GET("/pregnancies/:id/home-readings", controller.GetHomeReadings)
+		protected.GET("/pregnancies/:id/home-readings/series", controller.GetHomeReadingSeries)
+		protected.GET("/pregnancies/:id/home-readings/weekly", controller.GetHomeReadingWeekly)
+		protected.GET("/doctor/home-readings/alerts", controller.GetHomeReadingAlerts)
+		protected.POST("/doctor/home-readings/:id/acknowledge", controller.AcknowledgeHomeReading)
+		protected.GET("/pregnancies/:id/gdm", controller.GetGdmStatus)
+		protected.POST("/doctor/pregnancy/:id/gdm-screenings", controller.CreateGdmScreening)
+		protected.GET("/doctor/gdm/due", controller.GetGdmScreeningDue)
+		protected.GET("/doctor/records/deleted", controller.GetDeletedRecords)
+		protected.PUT("/doctor/records/:type/:id", controller.UpdateClinicalRecord)
+		protected.DELETE("/doctor/records/:type/:id", controller.DeleteClinicalRecord)
+		protected.POST("/doctor/records/:type/:id/restore", controller.RestoreClinicalRecord)
+		protected.GET("/doctor/records/:type/:id/corrections", controller.GetRecordCorrections)
+		protected.POST("/doctor/records/:type/:id/sign", controller.SignClinicalRecord)
+		protected.POST("/doctor/records/:type/:id/addenda", controller.CreateRecordAddendum)
+		protected.GET("/doctor/records/:type/:id/addenda", controller.GetRecordAddenda)
+		protected.GET("/icd10-codes", controller.SearchIcd10Codes)
+		protected.GET("/doctor/note-templates", controller.GetNoteTemplates)
+		protected.POST("/doctor/note-templates", controller.CreateNoteTemplate)
+		protected.PUT("/doctor/note-templates/:id", controller.UpdateNoteTemplate)
+		protected.DELETE("/doctor/note-templates/:id", controller.DeleteNoteTemplate)
+		protected.POST("/doctor/pregnancy/:id/notes", controller.CreateClinicalNote)
+		protected.GET("/pregnancies/:id/notes", controller.GetPregnancyClinicalNotes)
+		protected.GET("/doctor/patients/:id/notes", controller.GetPatientNotesTimeline)
+		protected.POST("/doctor/notes/plan-items/:id/complete", controller.CompleteNotePlanItem)
+		protected.POST("/doctor/pregnancy/:id/lab-orders", controller.CreateLabOrder)
+		protected.GET("/pregnancies/:id/lab-orders", controller.GetPregnancyLabOrders)
+		protected.GET("/doctor/lab-orders", controller.GetLabOrderWorklist)
+		protected.POST("/doctor/lab-orders/:id/collect", controller.CollectLabOrderSpecimen)
+		protected.POST("/doctor/lab-orders/:id/result", controller.AttachLabOrderResult)
+		protected.POST("/doctor/lab-orders/:id/review", controller.ReviewLabOrder)
+		protected.POST("/doctor/lab-orders/:id/cancel", controller.CancelLabOrder)
+		protected.GET("/lab-tests", controller.GetLabTests)
+		protected.POST("/doctor/lab-tests", controller.CreateLabTest)
+		protected.PUT("/doctor/lab-tests/:id", controller.UpdateLabTest)
+		protected.GET("/calendar/feed", controller.GetMyCalendarFeed)
+		protected.POST("/calendar/feed/rotate", controller.RotateCalendarFeed)
 
 		// Doctor Health Data Routes
 		protected.POST("/doctor/medical-history", controller.DoctorCreateMedicalHistory)
@@ -60,16 +180,30 @@ func main() {
 		protected.POST("/doctor/vaccination", controller.DoctorCreateVaccination)
 		protected.GET("/vaccine-types", controller.ListVaccineTypes)
 		
-		protected.POST("/doctor/previous-pregnancy", controller.DoctorCreatePreviousPregnancy)
-		protected.GET("/doctor/patient/:patientId/previous-pregnancies", controller.GetPreviousPregnancies)
+		protected.POST("/doctor/previous-pregnancy", controller.DoctorCreateObstetricHistory)
+		protected.GET("/doctor/patient/:patientId/previous-pregnancies", controller.GetObstetricHistories)
 		protected.GET("/doctor/pregnancy/:pregnancyId/lab-results", controller.GetLabResultsByPregnancyID)
-		
-		// Static file serving for uploads
-		r.Static("/uploads", "./uploads")
+		protected.PUT("/doctor/lab-results/:id/observations", controller.CorrectLabObservations)
+		protected.GET("/files/:id", controller.DownloadStoredFile)
+		protected.POST("/documents", controller.CreatePatientDocuments)
+		protected.GET("/documents", controller.GetPatientDocuments)
+		protected.GET("/documents/:id", controller.GetPatientDocument)
+		protected.PUT("/documents/:id", controller.UpdatePatientDocument)
+		protected.POST("/documents/:id/versions", controller.CreateDocumentVersion)
+		protected.DELETE("/documents/:id", controller.DeletePatientDocument)
+		protected.GET("/fhir/Patient/:id/$everything", controller.FhirPatientEverything)
+		protected.POST("/fhir", controller.FhirImportBundle)
+		protected.GET("/doctor/hl7-messages", controller.GetHl7Messages)
+		protected.GET("/doctor/hl7-messages/:id", controller.GetHl7Message)
+		protected.POST("/doctor/hl7-messages/:id/reconcile", controller.ReconcileHl7Message)
+		protected.POST("/doctor/hl7-messages/:id/discard", controller.DiscardHl7Message)
+		protected.GET("/doctor/moph43", controller.GetMoph43Report)
+		protected.GET("/doctor/moph43/download", controller.DownloadMoph43Export)
 
 		// Profile Routes
 		protected.PUT("/profile/husband", controller.UpdateHusband)
 		protected.PUT("/profile/personal", controller.UpdatePersonalProfile)
+		protected.PUT("/profile/notify-token", controller.UpdateMyNotifyToken)
 		protected.PUT("/profile/medical-history", controller.UpdateMyMedicalHistory)
 	}
 
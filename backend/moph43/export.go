@@ -0,0 +1,259 @@
+package moph43
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/service"
+	"gorm.io/gorm"
+)
+
+// OptionsFromEnv reads MOPH_HOSPCODE (required) and MOPH_TYPEAREA (default 4)
+func OptionsFromEnv() (service.Moph43Options, error) {
+	opts := service.Moph43Options{HospCode: os.Getenv("MOPH_HOSPCODE"), TypeArea: os.Getenv("MOPH_TYPEAREA")}
+	if opts.HospCode == "" {
+		return opts, errors.New("MOPH_HOSPCODE is not set")
+	}
+	return opts, service.ValidateMoph43Options(&opts)
+}
+
+// ParseRange reads the from / to dates (YYYY-MM-DD, both inclusive) in the clinic's time zone
+func ParseRange(from, to string) (time.Time, time.Time, error) {
+	start, err := time.ParseInLocation("2006-01-02", from, service.ClinicLocation)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("from must be a date (YYYY-MM-DD)")
+	}
+	end, err := time.ParseInLocation("2006-01-02", to, service.ClinicLocation)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("to must be a date (YYYY-MM-DD)")
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, errors.New("to must not be before from")
+	}
+	return start, end, nil
+}
+
+// Build exports the services given between from and to (inclusive days):
+// ANC and POSTNATAL by visit date, PRENATAL for pregnancies with a visit or a
+// lab result in the period, LABOR and NEWBORN by the date the pregnancy ended,
+// and PERSON for every mother with at least one of those records
+func Build(db *gorm.DB, opts service.Moph43Options, from, to, now time.Time) (*service.Moph43Export, error) {
+	export := service.NewMoph43Export(opts, from, to, now)
+	end := to.AddDate(0, 0, 1)
+
+	var visits []entity.AntenatalVisit
+	if err := db.Where("visit_date >= ? AND visit_date < ?", from, end).Order("visit_date, id").Find(&visits).Error; err != nil {
+		return nil, err
+	}
+	var postpartum []entity.PostpartumVisit
+	if err := db.Where("visit_date >= ? AND visit_date < ?", from, end).Order("visit_date, id").Find(&postpartum).Error; err != nil {
+		return nil, err
+	}
+	var tested []uint
+	if err := db.Model(&entity.LabResult{}).Where("test_date >= ? AND test_date < ?", from, end).
+		Distinct().Pluck("pregnancy_id", &tested).Error; err != nil {
+		return nil, err
+	}
+	var ended []entity.Pregnancy
+	if err := db.Where("outcome_date >= ? AND outcome_date < ? AND status IN ?", from, end, []string{
+		entity.PregnancyStatusDelivered, entity.PregnancyStatusMiscarriage,
+		entity.PregnancyStatusTermination, entity.PregnancyStatusEctopic,
+	}).Order("outcome_date, id").Find(&ended).Error; err != nil {
+		return nil, err
+	}
+
+	// ครรภ์ที่ต้องส่ง PRENATAL: มาฝากครรภ์หรือมีผลแลปในช่วงนี้
+	prenatal := map[uint]bool{}
+	pregnancyIDs := append([]uint{}, tested...)
+	for _, id := range tested {
+		prenatal[id] = true
+	}
+	for _, v := range visits {
+		if v.PregnancyID != nil {
+			prenatal[*v.PregnancyID] = true
+			pregnancyIDs = append(pregnancyIDs, *v.PregnancyID)
+		}
+	}
+	for _, v := range postpartum {
+		if v.PregnancyID != nil {
+			pregnancyIDs = append(pregnancyIDs, *v.PregnancyID)
+		}
+	}
+	for _, p := range ended {
+		pregnancyIDs = append(pregnancyIDs, p.ID)
+	}
+
+	pregnancies := map[uint]entity.Pregnancy{}
+	if len(pregnancyIDs) > 0 {
+		var rows []entity.Pregnancy
+		if err := db.Where("id IN ?", pregnancyIDs).Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, p := range rows {
+			pregnancies[p.ID] = p
+		}
+	}
+
+	womanIDs := []uint{}
+	for _, p := range pregnancies {
+		if p.PregnantWomanID != nil {
+			womanIDs = append(womanIDs, *p.PregnantWomanID)
+		}
+	}
+	var women []entity.PregnantWoman
+	if len(womanIDs) > 0 {
+		if err := db.Preload("Husband").Where("id IN ?", womanIDs).Order("id").Find(&women).Error; err != nil {
+			return nil, err
+		}
+	}
+	bloodGroups, err := latestBloodGroups(db, womanIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// PERSON ก่อน แฟ้มอื่นของมารดาที่ส่ง PERSON ไม่ได้จะถูกข้ามทั้งหมด
+	exported := map[uint]entity.PregnantWoman{}
+	for _, w := range women {
+		groups := bloodGroups[w.ID]
+		row := service.Moph43Person(opts, w, groups["BLOOD_GROUP"], groups["RH"])
+		if export.Add("PERSON", row, record("pregnant_woman", w.ID), w) {
+			exported[w.ID] = w
+		}
+	}
+	// mother returns the woman of a pregnancy, or reports the record as skipped
+	mother := func(file, rec string, pregnancyID *uint) (entity.PregnantWoman, entity.Pregnancy, bool) {
+		if pregnancyID == nil {
+			return entity.PregnantWoman{}, entity.Pregnancy{}, false
+		}
+		p, loaded := pregnancies[*pregnancyID]
+		if !loaded {
+			// the pregnancy was deleted but the record still points at it
+			export.Skip(file, rec, entity.PregnantWoman{}, "PID", fmt.Sprintf("pregnancy %d has been deleted", *pregnancyID))
+			return entity.PregnantWoman{}, p, false
+		}
+		if p.PregnantWomanID == nil {
+			export.Skip(file, rec, entity.PregnantWoman{}, "PID", "pregnancy has no mother")
+			return entity.PregnantWoman{}, p, false
+		}
+		w, ok := exported[*p.PregnantWomanID]
+		if !ok {
+			for _, candidate := range women {
+				if candidate.ID == *p.PregnantWomanID {
+					w = candidate
+				}
+			}
+			export.Skip(file, rec, w, "PID", "the mother's PERSON record could not be exported")
+			return w, p, false
+		}
+		return w, p, true
+	}
+
+	for _, v := range visits {
+		rec := record("antenatal_visit", v.ID)
+		if w, p, ok := mother("ANC", rec, v.PregnancyID); ok {
+			export.Add("ANC", service.Moph43Anc(opts, w, p, v), rec, w)
+		}
+	}
+
+	ids := make([]uint, 0, len(prenatal))
+	for id := range prenatal {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		id := id
+		rec := record("pregnancy", id)
+		w, p, ok := mother("PRENATAL", rec, &id)
+		if !ok {
+			continue
+		}
+		var results []entity.LabResult
+		if err := db.Preload("Observations").Where("pregnancy_id = ?", id).Order("test_date, id").Find(&results).Error; err != nil {
+			return nil, err
+		}
+		var orders []entity.LabOrder
+		if err := db.Where("pregnancy_id = ?", id).Find(&orders).Error; err != nil {
+			return nil, err
+		}
+		var provider *uint
+		for _, v := range visits {
+			if v.PregnancyID != nil && *v.PregnancyID == id && v.DoctorID != nil {
+				provider = v.DoctorID
+				break
+			}
+		}
+		export.Add("PRENATAL", service.Moph43Prenatal(opts, w, p, results, orders, provider), rec, w)
+	}
+
+	for _, p := range ended {
+		id := p.ID
+		rec := record("pregnancy", id)
+		var babies []entity.ObstetricHistory
+		if err := db.Where("pregnancy_id = ?", id).Order("baby_no, id").Find(&babies).Error; err != nil {
+			return nil, err
+		}
+		w, _, ok := mother("LABOR", rec, &id)
+		if ok {
+			export.Add("LABOR", service.Moph43Labor(opts, w, p, babies), rec, w)
+		}
+		for _, baby := range babies {
+			if baby.Outcome != entity.ObstetricOutcomeLiveBirth {
+				continue
+			}
+			brec := record("obstetric_history", baby.ID)
+			if !ok {
+				mother("NEWBORN", brec, &id)
+				continue
+			}
+			export.Add("NEWBORN", service.Moph43Newborn(opts, w, p, baby), brec, w)
+		}
+	}
+
+	for _, v := range postpartum {
+		rec := record("postpartum_visit", v.ID)
+		if w, p, ok := mother("POSTNATAL", rec, v.PregnancyID); ok {
+			export.Add("POSTNATAL", service.Moph43Postnatal(opts, w, p, v), rec, w)
+		}
+	}
+
+	service.SortMoph43Problems(export.Problems)
+	return export, nil
+}
+
+func record(table string, id uint) string {
+	return fmt.Sprintf("%s %d", table, id)
+}
+
+// latestBloodGroups returns the latest BLOOD_GROUP and RH results of each woman from any of her pregnancies
+func latestBloodGroups(db *gorm.DB, womanIDs []uint) (map[uint]map[string]string, error) {
+	groups := map[uint]map[string]string{}
+	if len(womanIDs) == 0 {
+		return groups, nil
+	}
+	var rows []struct {
+		WomanID   uint
+		Code      string
+		ValueText string
+	}
+	err := db.Model(&entity.LabObservation{}).
+		Select("pregnancies.p_id AS woman_id, lab_observations.code, lab_observations.value_text").
+		Joins("JOIN lab_results ON lab_results.id = lab_observations.lab_result_id AND lab_results.deleted_at IS NULL").
+		Joins("JOIN pregnancies ON pregnancies.id = lab_results.pregnancy_id AND pregnancies.deleted_at IS NULL").
+		Where("pregnancies.p_id IN ? AND lab_observations.code IN ?", womanIDs, []string{"BLOOD_GROUP", "RH"}).
+		Order("lab_results.test_date, lab_observations.id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		if groups[r.WomanID] == nil {
+			groups[r.WomanID] = map[string]string{}
+		}
+		groups[r.WomanID][r.Code] = r.ValueText
+	}
+	return groups, nil
+}
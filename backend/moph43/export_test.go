@@ -0,0 +1,65 @@
+package moph43
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/service"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&entity.Husband{}, &entity.PregnantWoman{}, &entity.Pregnancy{}, &entity.AntenatalVisit{},
+		&entity.PostpartumVisit{}, &entity.LabResult{}, &entity.LabObservation{}, &entity.LabOrder{}, &entity.ObstetricHistory{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestBuildNamesPregnanciesWithoutMother(t *testing.T) {
+	db := openTestDB(t)
+	day := time.Date(2026, 10, 5, 9, 0, 0, 0, time.UTC)
+
+	deleted := entity.Pregnancy{Status: entity.PregnancyStatusActive, LMP: day.AddDate(0, -3, 0)}
+	orphan := entity.Pregnancy{Status: entity.PregnancyStatusActive, LMP: day.AddDate(0, -3, 0)}
+	for _, p := range []*entity.Pregnancy{&deleted, &orphan} {
+		if err := db.Create(p).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Create(&entity.AntenatalVisit{PregnancyID: &p.ID, VisitDate: day}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Delete(&deleted).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	export, err := Build(db, service.Moph43Options{HospCode: "10669"}, day, day, day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gone := fmt.Sprintf("pregnancy %d has been deleted", deleted.ID)
+	want := map[string]string{
+		"ANC antenatal_visit 1":                       gone,
+		"PRENATAL " + record("pregnancy", deleted.ID): gone,
+		"ANC antenatal_visit 2":                       "pregnancy has no mother",
+		"PRENATAL " + record("pregnancy", orphan.ID):  "pregnancy has no mother",
+	}
+	if len(export.Problems) != len(want) {
+		t.Fatalf("problems = %+v", export.Problems)
+	}
+	for _, p := range export.Problems {
+		if problem, ok := want[p.File+" "+p.Record]; !ok || p.Problem != problem || p.Field != "PID" {
+			t.Errorf("unexpected problem %+v", p)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package scheduler
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/service"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CareTeamAlert is one message for the doctors caring for a pregnancy
+type CareTeamAlert struct {
+	Key         string // unique per event, e.g. danger-sign:12
+	PregnancyID uint
+	Subject     string
+	Body        string
+}
+
+// AlertCareTeam writes the alert to the outbox once per doctor and channel (e-mail and
+// SMS; the webhook channel only reaches mothers) and returns how many messages were
+// queued. The care team is every doctor who saw the pregnancy at a visit or has an
+// appointment with it; a pregnancy nobody has seen yet alerts all doctors.
+func (s *ReminderScheduler) AlertCareTeam(tx *gorm.DB, alert CareTeamAlert, now time.Time) (int, error) {
+	if len(s.Channels) == 0 {
+		return 0, nil
+	}
+
+	var doctorIDs []uint
+	if err := tx.Model(&entity.AntenatalVisit{}).Where("pregnancy_id = ? AND doctor_id IS NOT NULL", alert.PregnancyID).
+		Distinct().Pluck("doctor_id", &doctorIDs).Error; err != nil {
+		return 0, err
+	}
+	var booked []uint
+	if err := tx.Model(&entity.Appointment{}).Where("pregnancy_id = ? AND doctor_id IS NOT NULL", alert.PregnancyID).
+		Distinct().Pluck("doctor_id", &booked).Error; err != nil {
+		return 0, err
+	}
+	doctorIDs = append(doctorIDs, booked...)
+
+	query := tx.Model(&entity.Doctor{})
+	if len(doctorIDs) > 0 {
+		query = query.Where("id IN ?", doctorIDs)
+	}
+	var doctors []entity.Doctor
+	if err := query.Find(&doctors).Error; err != nil {
+		return 0, err
+	}
+
+	queued := 0
+	for _, doctor := range doctors {
+		doctorID := doctor.ID
+		for channel := range s.Channels {
+			destination := ""
+			switch channel {
+			case entity.NotificationChannelEmail:
+				destination = doctor.Email
+			case entity.NotificationChannelSMS:
+				destination = doctor.PhoneNumber
+			}
+			if destination == "" {
+				continue
+			}
+			n := entity.Notification{
+				DedupKey:      alert.Key + ":doctor:" + strconv.FormatUint(uint64(doctorID), 10) + ":" + channel,
+				Kind:          "care_team_alert",
+				Channel:       channel,
+				Destination:   destination,
+				Subject:       alert.Subject,
+				Body:          alert.Body,
+				DoctorID:      &doctorID,
+				Status:        entity.NotificationStatusPending,
+				MaxAttempts:   service.NotificationMaxAttempts,
+				NextAttemptAt: now.UTC(),
+			}
+			result := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "dedup_key"}}, DoNothing: true}).Create(&n)
+			if result.Error != nil {
+				return queued, result.Error
+			}
+			queued += int(result.RowsAffected)
+		}
+	}
+	if queued == 0 {
+		log.Printf("care team alert %s for pregnancy %d: no doctor has an e-mail or phone for the configured channels", alert.Key, alert.PregnancyID)
+	}
+	return queued, nil
+}
+
+// DeliverSoon sends pending messages now rather than on the next tick, so an
+// alert written in a request goes out as soon as that request has committed
+func (s *ReminderScheduler) DeliverSoon() {
+	go func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, err := s.DeliverPending(time.Now()); err != nil {
+			log.Println("reminder scheduler: deliver:", err)
+		}
+	}()
+}
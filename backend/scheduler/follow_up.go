@@ -0,0 +1,297 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/service"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const defaultFollowUpInterval = time.Hour
+
+// StartFollowUps refreshes the follow-up worklist in the background. Configure with
+// FOLLOW_UP_INTERVAL ("1h") and FOLLOW_UP_VISIT_GAP_WEEKS; FOLLOW_UP_SCHEDULER=off disables it.
+func StartFollowUps(db *gorm.DB) {
+	if os.Getenv("FOLLOW_UP_SCHEDULER") == "off" {
+		log.Println("follow-up scheduler disabled")
+		return
+	}
+	interval := defaultFollowUpInterval
+	if value := os.Getenv("FOLLOW_UP_INTERVAL"); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			interval = d
+		}
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := RefreshFollowUps(db, time.Now(), FollowUpGapWeeks()); err != nil {
+				log.Println("follow-up scheduler:", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// FollowUpGapWeeks reads FOLLOW_UP_VISIT_GAP_WEEKS, the fixed number of weeks a mother may go
+// without a visit. Unset (or invalid) follows the gestational-age schedule.
+func FollowUpGapWeeks() int {
+	if n, err := strconv.Atoi(os.Getenv("FOLLOW_UP_VISIT_GAP_WEEKS")); err == nil && n > 0 {
+		return n
+	}
+	return 0
+}
+
+// OpenFollowUp adds an open item unless one with the same key already exists, open or closed
+func OpenFollowUp(db *gorm.DB, item entity.FollowUpItem) error {
+	item.Status = entity.FollowUpStatusOpen
+	return db.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "item_key"}}, DoNothing: true}).Create(&item).Error
+}
+
+// PlanItemFollowUp is the worklist item of a clinical note plan item, due when the item is
+func PlanItemFollowUp(pi entity.NotePlanItem, pregnancy entity.Pregnancy) entity.FollowUpItem {
+	planItemID := pi.ID
+	pregnancyID := pregnancy.ID
+	due := pi.CreatedAt
+	if pi.DueDate != nil {
+		due = *pi.DueDate
+	}
+	return entity.FollowUpItem{
+		ItemKey:         service.PlanItemFollowUpKey(pi.ID),
+		Kind:            entity.FollowUpPlanItem,
+		Detail:          pi.Description,
+		DueDate:         &due,
+		PregnantWomanID: pregnancy.PregnantWomanID,
+		PregnancyID:     &pregnancyID,
+		NotePlanItemID:  &planItemID,
+	}
+}
+
+// RefreshFollowUps opens an item for every current finding and closes open items that were resolved.
+// Items closed by a doctor stay closed because their key already exists. gapWeeks > 0 replaces the
+// gestational-age visit schedule.
+func RefreshFollowUps(db *gorm.DB, now time.Time, gapWeeks int) error {
+	candidates := map[string]entity.FollowUpItem{}
+	add := func(item entity.FollowUpItem) {
+		item.Status = entity.FollowUpStatusOpen
+		candidates[item.ItemKey] = item
+	}
+
+	var pregnancies []entity.Pregnancy
+	if err := db.Where("status = ?", entity.PregnancyStatusActive).Find(&pregnancies).Error; err != nil {
+		return err
+	}
+
+	for _, p := range pregnancies {
+		pregnancyID := p.ID
+		lmp := service.PregnancyLMP(p)
+
+		// Visits against the gestational-age schedule
+		var last entity.AntenatalVisit
+		baseline := p.CreatedAt
+		var lastVisitID uint
+		if err := db.Where("pregnancy_id = ?", p.ID).Order("visit_date DESC").First(&last).Error; err == nil {
+			baseline = last.VisitDate
+			lastVisitID = last.ID
+		}
+		if due := service.VisitDueDate(lmp, baseline, gapWeeks); service.IsOverdue(due, now) {
+			add(entity.FollowUpItem{
+				ItemKey:         fmt.Sprintf("overdue-visit:%d:%d", p.ID, lastVisitID),
+				Kind:            entity.FollowUpOverdueVisit,
+				Detail:          fmt.Sprintf("ไม่ได้มาฝากครรภ์ตั้งแต่ %s (อายุครรภ์ปัจจุบัน %d สัปดาห์)", baseline.In(service.ClinicLocation).Format("02/01/2006"), service.GestationalWeeks(lmp, now)),
+				DueDate:         &due,
+				PregnantWomanID: p.PregnantWomanID,
+				PregnancyID:     &pregnancyID,
+			})
+		}
+
+		if lmp.IsZero() {
+			continue
+		}
+
+		// dT vaccination of this pregnancy
+		var vaccinations []entity.Vaccination
+		if err := db.Preload("VaccineType").Where("p_id = ?", p.PregnantWomanID).Order("id").Find(&vaccinations).Error; err != nil {
+			return err
+		}
+		dT := service.CurrentDTVaccination(vaccinations, lmp)
+		if dose, due, ok := service.NextDTDose(dT, lmp, now); ok && service.IsOverdue(due, now) {
+			add(entity.FollowUpItem{
+				ItemKey:         fmt.Sprintf("vaccination:%d:dT:%d", p.ID, dose),
+				Kind:            entity.FollowUpOverdueVaccination,
+				Detail:          fmt.Sprintf("วัคซีน dT เข็มที่ %d เลยกำหนด", dose),
+				DueDate:         &due,
+				PregnantWomanID: p.PregnantWomanID,
+				PregnancyID:     &pregnancyID,
+			})
+		}
+
+		// Lab panels due by this gestational age
+		var labs []entity.LabResult
+		if err := db.Preload("Observations").Where("pregnancy_id = ?", p.ID).Find(&labs).Error; err != nil {
+			return err
+		}
+		for _, panel := range service.ExpectedLabPanels {
+			due := lmp.AddDate(0, 0, panel.DueWeek*7)
+			if !now.After(due) {
+				continue
+			}
+			if missing := service.PanelMissingTests(panel, lmp, labs); len(missing) > 0 {
+				add(entity.FollowUpItem{
+					ItemKey:         fmt.Sprintf("lab:%d:%s", p.ID, panel.Code),
+					Kind:            entity.FollowUpMissingLab,
+					Detail:          panel.Name + " ยังไม่มีผล: " + strings.Join(missing, ", "),
+					DueDate:         &due,
+					PregnantWomanID: p.PregnantWomanID,
+					PregnancyID:     &pregnancyID,
+				})
+			}
+		}
+	}
+
+	// Postpartum visits of recent deliveries
+	var ended []entity.Pregnancy
+	if err := db.Where("status = ? AND outcome_date >= ?", entity.PregnancyStatusDelivered,
+		now.AddDate(0, 0, -service.PostpartumTrackDays).UTC()).Find(&ended).Error; err != nil {
+		return err
+	}
+	for _, p := range ended {
+		pregnancyID := p.ID
+		var visits []entity.PostpartumVisit
+		if err := db.Where("pregnancy_id = ?", p.ID).Find(&visits).Error; err != nil {
+			return err
+		}
+		done := map[string]bool{}
+		for _, v := range visits {
+			done[v.VisitType] = true
+		}
+		expected := []struct {
+			visitType string
+			days      int
+			label     string
+		}{
+			{entity.PostpartumVisitEarly, service.PostpartumEarlyDays, "ตรวจหลังคลอดภายใน 7 วัน"},
+			{entity.PostpartumVisitSixWeek, service.PostpartumSixWeekDue, "ตรวจหลังคลอด 6 สัปดาห์"},
+		}
+		for _, e := range expected {
+			due := p.OutcomeDate.AddDate(0, 0, e.days)
+			if done[e.visitType] || !service.IsOverdue(due, now) {
+				continue
+			}
+			add(entity.FollowUpItem{
+				ItemKey:         fmt.Sprintf("postpartum:%d:%s", p.ID, e.visitType),
+				Kind:            entity.FollowUpPostpartumVisit,
+				Detail:          "ยังไม่ได้" + e.label,
+				DueDate:         &due,
+				PregnantWomanID: p.PregnantWomanID,
+				PregnancyID:     &pregnancyID,
+			})
+		}
+	}
+
+	// Positive EPDS screens stay on the list until a doctor reviews them
+	var screenings []entity.EpdsScreening
+	if err := db.Preload("Pregnancy").Where("alert = ? AND reviewed_at IS NULL", true).Find(&screenings).Error; err != nil {
+		return err
+	}
+	for _, s := range screenings {
+		due := s.ScreenedAt
+		item := entity.FollowUpItem{
+			ItemKey:     service.EpdsFollowUpKey(s.ID),
+			Kind:        entity.FollowUpEpdsAlert,
+			Detail:      service.EpdsAlertDetail(s),
+			DueDate:     &due,
+			PregnancyID: s.PregnancyID,
+		}
+		if s.Pregnancy != nil {
+			item.PregnantWomanID = s.Pregnancy.PregnantWomanID
+		}
+		add(item)
+	}
+
+	// Open plan items from clinical notes. The note's write path already put them on the
+	// worklist with their due date; keeping them here stops the close pass below from
+	// resolving one before it is due.
+	var planItems []entity.NotePlanItem
+	if err := db.Preload("ClinicalNote.Pregnancy").
+		Where("follow_up = ? AND status = ?", true, entity.PlanItemOpen).
+		Find(&planItems).Error; err != nil {
+		return err
+	}
+	for _, pi := range planItems {
+		if pi.ClinicalNote == nil || pi.ClinicalNote.Pregnancy == nil {
+			continue
+		}
+		add(PlanItemFollowUp(pi, *pi.ClinicalNote.Pregnancy))
+	}
+
+	// Missed appointments without a later visit or a new booking
+	var missed []entity.Appointment
+	if err := db.Where("status = ? AND pregnant_woman_id IS NOT NULL AND appointment_date >= ?",
+		entity.AppointmentStatusNoShow, now.AddDate(0, 0, -service.MissedAppointmentDays).UTC()).
+		Find(&missed).Error; err != nil {
+		return err
+	}
+	for _, a := range missed {
+		var later int64
+		db.Model(&entity.Appointment{}).
+			Where("pregnant_woman_id = ? AND appointment_date > ? AND status IN ?", a.PregnantWomanID, a.AppointmentDate.UTC(),
+				[]string{entity.AppointmentStatusScheduled, entity.AppointmentStatusCheckedIn, entity.AppointmentStatusCompleted}).
+			Count(&later)
+		if later == 0 {
+			db.Model(&entity.AntenatalVisit{}).
+				Joins("JOIN pregnancies ON pregnancies.id = antenatal_visits.pregnancy_id").
+				Where("pregnancies.p_id = ? AND antenatal_visits.visit_date > ?", a.PregnantWomanID, a.AppointmentDate.UTC()).
+				Count(&later)
+		}
+		if later > 0 {
+			continue
+		}
+		apptID := a.ID
+		due := a.AppointmentDate
+		add(entity.FollowUpItem{
+			ItemKey:         fmt.Sprintf("missed-appt:%d", a.ID),
+			Kind:            entity.FollowUpMissedAppointment,
+			Detail:          fmt.Sprintf("ขาดนัด \"%s\" วันที่ %s", a.Title, a.AppointmentDate.In(service.ClinicLocation).Format("02/01/2006 15:04")),
+			DueDate:         &due,
+			PregnantWomanID: a.PregnantWomanID,
+			PregnancyID:     a.PregnancyID,
+			AppointmentID:   &apptID,
+		})
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, item := range candidates {
+			if err := OpenFollowUp(tx, item); err != nil {
+				return err
+			}
+		}
+
+		var open []entity.FollowUpItem
+		if err := tx.Where("status = ?", entity.FollowUpStatusOpen).Find(&open).Error; err != nil {
+			return err
+		}
+		for _, item := range open {
+			if _, still := candidates[item.ItemKey]; still {
+				continue
+			}
+			if err := tx.Model(&item).Updates(map[string]interface{}{
+				"status":       entity.FollowUpStatusClosed,
+				"closed_at":    now,
+				"close_reason": "Resolved",
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,121 @@
+package scheduler
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/service"
+)
+
+func TestRefreshKeepsPlanItemOpenUntilDone(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.AutoMigrate(&entity.Pregnancy{}, &entity.AntenatalVisit{}, &entity.LabResult{}, &entity.Vaccination{},
+		&entity.PostpartumVisit{}, &entity.EpdsScreening{}, &entity.ClinicalNote{}, &entity.NotePlanItem{}, &entity.FollowUpItem{}); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2026, 10, 19, 2, 0, 0, 0, time.UTC)
+	pregnancy := entity.Pregnancy{Status: entity.PregnancyStatusDelivered}
+	if err := db.Create(&pregnancy).Error; err != nil {
+		t.Fatal(err)
+	}
+	due := now.AddDate(0, 0, 14)
+	note := entity.ClinicalNote{PregnancyID: &pregnancy.ID, NoteDate: now, PlanItems: []entity.NotePlanItem{
+		{Description: "Repeat CBC", DueDate: &due, FollowUp: true, Status: entity.PlanItemOpen},
+	}}
+	if err := db.Create(&note).Error; err != nil {
+		t.Fatal(err)
+	}
+	planItem := note.PlanItems[0]
+	// What the note's write path does
+	if err := OpenFollowUp(db, PlanItemFollowUp(planItem, pregnancy)); err != nil {
+		t.Fatal(err)
+	}
+
+	status := func() string {
+		var item entity.FollowUpItem
+		if err := db.Where("item_key = ?", service.PlanItemFollowUpKey(planItem.ID)).First(&item).Error; err != nil {
+			t.Fatal(err)
+		}
+		return item.Status
+	}
+
+	if err := RefreshFollowUps(db, now, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := status(); got != entity.FollowUpStatusOpen {
+		t.Fatalf("plan item due in two weeks: follow-up %s after a refresh", got)
+	}
+
+	db.Model(&planItem).Update("status", entity.PlanItemDone)
+	if err := RefreshFollowUps(db, now, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := status(); got != entity.FollowUpStatusClosed {
+		t.Fatalf("done plan item: follow-up %s after a refresh", got)
+	}
+}
+
+func TestRefreshCountsOnlyThisPregnancysDTDoses(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.AutoMigrate(&entity.Pregnancy{}, &entity.AntenatalVisit{}, &entity.LabResult{}, &entity.VaccineType{}, &entity.Vaccination{},
+		&entity.PostpartumVisit{}, &entity.EpdsScreening{}, &entity.ClinicalNote{}, &entity.NotePlanItem{}, &entity.FollowUpItem{}); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2026, 10, 19, 2, 0, 0, 0, time.UTC)
+	mother := entity.PregnantWoman{Username: "mother"}
+	dT := entity.VaccineType{Name: "dT", Code: entity.VaccineCodeDT}
+	if err := db.Create(&mother).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&dT).Error; err != nil {
+		t.Fatal(err)
+	}
+	// 20 weeks, so dose 1 was due at 12 weeks
+	pregnancy := entity.Pregnancy{PregnantWomanID: &mother.ID, Status: entity.PregnancyStatusActive, LMP: time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)}
+	if err := db.Create(&pregnancy).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// the full course of her previous pregnancy
+	day := func(y int, m time.Month, d int) *time.Time { v := time.Date(y, m, d, 0, 0, 0, 0, time.UTC); return &v }
+	previous := entity.Vaccination{PregnantWomanID: &mother.ID, VaccineTypeID: &dT.ID,
+		Dose1DateDuringPreg: day(2024, 1, 10), Dose2DateDuringPreg: day(2024, 2, 7), Dose3DateDuringPreg: day(2024, 8, 7)}
+	previous.CreatedAt = *day(2024, 1, 10)
+	if err := db.Create(&previous).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	status := func(dose int) string {
+		var item entity.FollowUpItem
+		if err := db.Where("item_key = ?", fmt.Sprintf("vaccination:%d:dT:%d", pregnancy.ID, dose)).First(&item).Error; err != nil {
+			return ""
+		}
+		return item.Status
+	}
+
+	if err := RefreshFollowUps(db, now, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := status(1); got != entity.FollowUpStatusOpen {
+		t.Fatalf("dose 1 with only the previous pregnancy's doses: follow-up %q", got)
+	}
+
+	current := entity.Vaccination{PregnantWomanID: &mother.ID, VaccineTypeID: &dT.ID, Dose1DateDuringPreg: day(2026, 9, 1)}
+	if err := db.Create(&current).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := RefreshFollowUps(db, now, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := status(1); got != entity.FollowUpStatusClosed {
+		t.Fatalf("dose 1 given in this pregnancy: follow-up %q", got)
+	}
+	// dose 2 was due four weeks after dose 1
+	if got := status(2); got != entity.FollowUpStatusOpen {
+		t.Fatalf("dose 2: follow-up %q", got)
+	}
+}
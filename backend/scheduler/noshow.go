@@ -0,0 +1,50 @@
+package scheduler
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/service"
+	"gorm.io/gorm"
+)
+
+const defaultNoShowInterval = 5 * time.Minute
+
+// StartNoShow marks no-shows in the background on its own tick, so it runs
+// whether or not reminders are enabled. Configure with NO_SHOW_INTERVAL ("5m");
+// NO_SHOW_SCHEDULER=off disables it.
+func StartNoShow(db *gorm.DB) {
+	if os.Getenv("NO_SHOW_SCHEDULER") == "off" {
+		log.Println("no-show scheduler disabled")
+		return
+	}
+	interval := defaultNoShowInterval
+	if value := os.Getenv("NO_SHOW_INTERVAL"); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			interval = d
+		}
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if n, err := MarkNoShows(db, time.Now()); err != nil {
+				log.Println("no-show scheduler:", err)
+			} else if n > 0 {
+				log.Printf("no-show scheduler: %d appointments marked no-show", n)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// MarkNoShows closes appointments nobody checked in for once their clinic session is over
+func MarkNoShows(db *gorm.DB, now time.Time) (int64, error) {
+	result := db.Model(&entity.Appointment{}).
+		Where("status = ? AND appointment_date < ?", entity.AppointmentStatusScheduled, service.NoShowCutoff(now)).
+		Updates(map[string]interface{}{"status": entity.AppointmentStatusNoShow, "sequence": gorm.Expr("sequence + 1")})
+	return result.RowsAffected, result.Error
+}
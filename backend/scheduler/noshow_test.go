@@ -0,0 +1,36 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/service"
+)
+
+func TestMarkNoShowsBumpsSequence(t *testing.T) {
+	db := openTestDB(t)
+	yesterday := time.Date(2026, 11, 2, 9, 0, 0, 0, service.ClinicLocation)
+	missed := entity.Appointment{AppointmentDate: yesterday, Status: entity.AppointmentStatusScheduled, Sequence: 1}
+	seen := entity.Appointment{AppointmentDate: yesterday, Status: entity.AppointmentStatusCheckedIn, Sequence: 2}
+	if err := db.Create(&missed).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&seen).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := MarkNoShows(db, yesterday.AddDate(0, 0, 1))
+	if err != nil || n != 1 {
+		t.Fatalf("marked %d, %v", n, err)
+	}
+	// calendar clients only replace an event whose SEQUENCE went up
+	db.First(&missed, missed.ID)
+	if missed.Status != entity.AppointmentStatusNoShow || missed.Sequence != 2 {
+		t.Errorf("missed appointment = %s, sequence %d", missed.Status, missed.Sequence)
+	}
+	db.First(&seen, seen.ID)
+	if seen.Status != entity.AppointmentStatusCheckedIn || seen.Sequence != 2 {
+		t.Errorf("checked-in appointment = %s, sequence %d", seen.Status, seen.Sequence)
+	}
+}
@@ -0,0 +1,289 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/service"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultInterval  = time.Minute
+	deliverBatchSize = 50
+	// claimLease is how long a message may stay Sending before it is assumed
+	// that the run sending it died and the message is queued again
+	claimLease = 10 * time.Minute
+)
+
+// ReminderScheduler finds upcoming appointments, writes reminders to the outbox
+// and delivers pending outbox messages through the configured channels
+type ReminderScheduler struct {
+	DB       *gorm.DB
+	Channels map[string]service.Channel
+	Offsets  []time.Duration
+	Interval time.Duration
+
+	mu sync.Mutex // one run at a time
+}
+
+var current *ReminderScheduler
+
+// Start runs the scheduler in the background. Configure with REMINDER_INTERVAL ("1m"),
+// REMINDER_OFFSETS ("72h,24h") and the channel variables; REMINDER_SCHEDULER=off disables it.
+func Start(db *gorm.DB) *ReminderScheduler {
+	interval := defaultInterval
+	if value := os.Getenv("REMINDER_INTERVAL"); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			interval = d
+		}
+	}
+
+	s := &ReminderScheduler{
+		DB:       db,
+		Channels: service.ChannelsFromEnv(),
+		Offsets:  service.ReminderOffsetsFromEnv(),
+		Interval: interval,
+	}
+	current = s
+
+	if os.Getenv("REMINDER_SCHEDULER") == "off" {
+		log.Println("reminder scheduler disabled")
+		return s
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.Interval)
+		defer ticker.Stop()
+		for {
+			s.RunOnce(time.Now())
+			<-ticker.C
+		}
+	}()
+	return s
+}
+
+// Current returns the scheduler started by Start (nil before that)
+func Current() *ReminderScheduler {
+	return current
+}
+
+// RunOnce enqueues due reminders and delivers pending messages
+func (s *ReminderScheduler) RunOnce(now time.Time) (enqueued, delivered int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	enqueued, err := s.EnqueueReminders(now)
+	if err != nil {
+		log.Println("reminder scheduler: enqueue:", err)
+	}
+	delivered, err = s.DeliverPending(now)
+	if err != nil {
+		log.Println("reminder scheduler: deliver:", err)
+	}
+	return enqueued, delivered
+}
+
+// EnqueueReminders writes one outbox message per channel for appointments whose reminder is due
+func (s *ReminderScheduler) EnqueueReminders(now time.Time) (int, error) {
+	if len(s.Offsets) == 0 || len(s.Channels) == 0 {
+		return 0, nil
+	}
+	maxOffset := s.Offsets[0]
+	for _, o := range s.Offsets {
+		if o > maxOffset {
+			maxOffset = o
+		}
+	}
+
+	var appointments []entity.Appointment
+	if err := s.DB.Preload("PregnantWoman").
+		Where("status = ? AND pregnant_woman_id IS NOT NULL AND appointment_date > ? AND appointment_date <= ?",
+			entity.AppointmentStatusScheduled, now.UTC(), now.Add(maxOffset).UTC()).
+		Find(&appointments).Error; err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, appt := range appointments {
+		if appt.PregnantWoman == nil {
+			continue
+		}
+		offset, ok := service.DueReminderOffset(appt.AppointmentDate, now, s.Offsets)
+		if !ok {
+			continue
+		}
+		subject, body := service.AppointmentReminderText(appt)
+		date := appt.AppointmentDate
+		apptID := appt.ID
+
+		for channel := range s.Channels {
+			destination := reminderDestination(channel, appt.PregnantWoman)
+			if destination == "" {
+				continue
+			}
+			n := entity.Notification{
+				DedupKey:        service.ReminderDedupKey(appt.ID, appt.AppointmentDate, offset, channel),
+				Kind:            "appointment_reminder",
+				Channel:         channel,
+				Destination:     destination,
+				Subject:         subject,
+				Body:            body,
+				PregnantWomanID: appt.PregnantWomanID,
+				AppointmentID:   &apptID,
+				AppointmentDate: &date,
+				Status:          entity.NotificationStatusPending,
+				MaxAttempts:     service.NotificationMaxAttempts,
+				NextAttemptAt:   now.UTC(),
+			}
+			// The unique dedup key makes this a no-op when the reminder already exists
+			result := s.DB.Where(entity.Notification{DedupKey: n.DedupKey}).FirstOrCreate(&n)
+			if result.Error != nil {
+				return count, result.Error
+			}
+			count += int(result.RowsAffected)
+		}
+	}
+	return count, nil
+}
+
+func reminderDestination(channel string, woman *entity.PregnantWoman) string {
+	switch channel {
+	case entity.NotificationChannelEmail:
+		return woman.Email
+	case entity.NotificationChannelSMS:
+		return woman.PhoneNumber
+	case entity.NotificationChannelWebhook:
+		if woman.NotifyToken != "" {
+			return service.WebhookDestination(woman.ID)
+		}
+	}
+	return ""
+}
+
+// DeliverPending sends messages whose next attempt is due
+func (s *ReminderScheduler) DeliverPending(now time.Time) (int, error) {
+	if _, err := s.RequeueStaleClaims(now); err != nil {
+		return 0, err
+	}
+
+	var pending []entity.Notification
+	if err := s.DB.Where("status = ? AND next_attempt_at <= ?", entity.NotificationStatusPending, now.UTC()).
+		Order("next_attempt_at ASC").Limit(deliverBatchSize).Find(&pending).Error; err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for i := range pending {
+		n := &pending[i]
+
+		// Claim the message so that another run does not send it twice
+		claimedAt := now.UTC()
+		claim := s.DB.Model(&entity.Notification{}).
+			Where("id = ? AND status = ?", n.ID, entity.NotificationStatusPending).
+			Updates(map[string]interface{}{"status": entity.NotificationStatusSending, "claimed_at": &claimedAt})
+		if claim.Error != nil || claim.RowsAffected == 0 {
+			continue
+		}
+
+		if reason := s.staleReason(n); reason != "" {
+			s.DB.Model(n).Updates(map[string]interface{}{"status": entity.NotificationStatusSkipped, "last_error": reason})
+			continue
+		}
+
+		if err := s.send(n); err != nil {
+			n.Attempts++
+			updates := map[string]interface{}{"attempts": n.Attempts, "last_error": err.Error()}
+			if n.Attempts >= n.MaxAttempts {
+				updates["status"] = entity.NotificationStatusFailed
+			} else {
+				updates["status"] = entity.NotificationStatusPending
+				updates["next_attempt_at"] = now.Add(service.NotificationBackoff(n.Attempts)).UTC()
+			}
+			s.DB.Model(n).Updates(updates)
+			continue
+		}
+
+		sentAt := now.UTC()
+		s.DB.Model(n).Updates(map[string]interface{}{
+			"status":     entity.NotificationStatusSent,
+			"attempts":   n.Attempts + 1,
+			"sent_at":    &sentAt,
+			"last_error": "",
+		})
+		sent++
+	}
+	return sent, nil
+}
+
+// RequeueStaleClaims puts messages whose claim lease ran out back in the queue.
+// The interrupted run may or may not have sent them, so it counts as an attempt.
+func (s *ReminderScheduler) RequeueStaleClaims(now time.Time) (int64, error) {
+	stale := s.DB.Model(&entity.Notification{}).
+		Where("status = ? AND (claimed_at IS NULL OR claimed_at < ?)", entity.NotificationStatusSending, now.Add(-claimLease).UTC())
+
+	failed := stale.Session(&gorm.Session{}).Where("attempts + 1 >= max_attempts").Updates(map[string]interface{}{
+		"status":     entity.NotificationStatusFailed,
+		"attempts":   gorm.Expr("attempts + 1"),
+		"last_error": "delivery was interrupted",
+	})
+	if failed.Error != nil {
+		return 0, failed.Error
+	}
+	requeued := stale.Session(&gorm.Session{}).Updates(map[string]interface{}{
+		"status":          entity.NotificationStatusPending,
+		"attempts":        gorm.Expr("attempts + 1"),
+		"last_error":      "delivery was interrupted",
+		"next_attempt_at": now.UTC(),
+	})
+	if requeued.Error != nil {
+		return failed.RowsAffected, requeued.Error
+	}
+	if total := failed.RowsAffected + requeued.RowsAffected; total > 0 {
+		log.Printf("reminder scheduler: %d stale claims re-queued, %d failed", requeued.RowsAffected, failed.RowsAffected)
+	}
+	return failed.RowsAffected + requeued.RowsAffected, nil
+}
+
+func (s *ReminderScheduler) send(n *entity.Notification) (err error) {
+	channel, ok := s.Channels[n.Channel]
+	if !ok {
+		return service.ErrNoChannel
+	}
+	// The webhook token is looked up at send time so the outbox never holds it
+	if n.Channel == entity.NotificationChannelWebhook && n.PregnantWomanID != nil {
+		var woman entity.PregnantWoman
+		if err := s.DB.Select("id", "notify_token").First(&woman, *n.PregnantWomanID).Error; err != nil {
+			return err
+		}
+		n.Token = woman.NotifyToken
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("channel panicked: %v", r)
+		}
+	}()
+	return channel.Send(*n)
+}
+
+// staleReason tells why a reminder should no longer be sent (cancelled or moved appointment)
+func (s *ReminderScheduler) staleReason(n *entity.Notification) string {
+	if n.AppointmentID == nil {
+		return ""
+	}
+	var appt entity.Appointment
+	if err := s.DB.First(&appt, *n.AppointmentID).Error; err != nil {
+		return "appointment no longer exists"
+	}
+	if appt.Status != entity.AppointmentStatusScheduled {
+		return "appointment is " + appt.Status
+	}
+	if n.AppointmentDate != nil && !appt.AppointmentDate.Equal(*n.AppointmentDate) {
+		return "appointment was rescheduled"
+	}
+	return ""
+}
@@ -0,0 +1,207 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+	"github.com/bestiesmile1845/Projecteiei/service"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fakeChannel records what it was asked to send and fails while err is set
+type fakeChannel struct {
+	err  error
+	sent []entity.Notification
+}
+
+func (f *fakeChannel) Name() string { return entity.NotificationChannelSMS }
+
+func (f *fakeChannel) Send(n entity.Notification) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.sent = append(f.sent, n)
+	return nil
+}
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&entity.PregnantWoman{}, &entity.Appointment{}, &entity.Notification{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func queue(t *testing.T, db *gorm.DB, n entity.Notification) entity.Notification {
+	t.Helper()
+	if n.Status == "" {
+		n.Status = entity.NotificationStatusPending
+	}
+	if n.MaxAttempts == 0 {
+		n.MaxAttempts = service.NotificationMaxAttempts
+	}
+	if n.Channel == "" {
+		n.Channel = entity.NotificationChannelSMS
+	}
+	if err := db.Create(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func reload(t *testing.T, db *gorm.DB, id uint) entity.Notification {
+	t.Helper()
+	var n entity.Notification
+	if err := db.First(&n, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestDeliverPendingBacksOffThenFails(t *testing.T) {
+	db := openTestDB(t)
+	channel := &fakeChannel{err: errors.New("gateway down")}
+	s := &ReminderScheduler{DB: db, Channels: map[string]service.Channel{entity.NotificationChannelSMS: channel}}
+
+	now := time.Date(2026, 10, 19, 2, 0, 0, 0, time.UTC)
+	n := queue(t, db, entity.Notification{DedupKey: "a", Destination: "0812345678", NextAttemptAt: now})
+
+	for attempt := 1; attempt < service.NotificationMaxAttempts; attempt++ {
+		if sent, err := s.DeliverPending(now); err != nil || sent != 0 {
+			t.Fatalf("attempt %d: DeliverPending = (%d, %v)", attempt, sent, err)
+		}
+		got := reload(t, db, n.ID)
+		if got.Status != entity.NotificationStatusPending || got.Attempts != attempt {
+			t.Fatalf("attempt %d: status %s attempts %d", attempt, got.Status, got.Attempts)
+		}
+		want := now.Add(service.NotificationBackoff(attempt))
+		if !got.NextAttemptAt.Equal(want) {
+			t.Fatalf("attempt %d: next attempt %v, want %v", attempt, got.NextAttemptAt, want)
+		}
+		if got.LastError != "gateway down" {
+			t.Fatalf("attempt %d: last error %q", attempt, got.LastError)
+		}
+
+		// Not due yet: nothing is attempted
+		if _, err := s.DeliverPending(want.Add(-time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		if reload(t, db, n.ID).Attempts != attempt {
+			t.Fatalf("attempt %d: retried before the backoff ran out", attempt)
+		}
+		now = want
+	}
+
+	if _, err := s.DeliverPending(now); err != nil {
+		t.Fatal(err)
+	}
+	got := reload(t, db, n.ID)
+	if got.Status != entity.NotificationStatusFailed || got.Attempts != service.NotificationMaxAttempts {
+		t.Fatalf("after the last attempt: status %s attempts %d", got.Status, got.Attempts)
+	}
+}
+
+func TestDeliverPendingRecoversAfterFailure(t *testing.T) {
+	db := openTestDB(t)
+	channel := &fakeChannel{err: errors.New("timeout")}
+	s := &ReminderScheduler{DB: db, Channels: map[string]service.Channel{entity.NotificationChannelSMS: channel}}
+
+	now := time.Date(2026, 10, 19, 2, 0, 0, 0, time.UTC)
+	n := queue(t, db, entity.Notification{DedupKey: "b", Destination: "0812345678", NextAttemptAt: now})
+	s.DeliverPending(now)
+
+	channel.err = nil
+	sent, err := s.DeliverPending(now.Add(service.NotificationBackoff(1)))
+	if err != nil || sent != 1 {
+		t.Fatalf("DeliverPending = (%d, %v)", sent, err)
+	}
+	got := reload(t, db, n.ID)
+	if got.Status != entity.NotificationStatusSent || got.Attempts != 2 || got.LastError != "" || got.SentAt == nil {
+		t.Fatalf("status %s attempts %d last error %q sent at %v", got.Status, got.Attempts, got.LastError, got.SentAt)
+	}
+	if len(channel.sent) != 1 {
+		t.Fatalf("sent %d messages", len(channel.sent))
+	}
+}
+
+func TestRequeueStaleClaims(t *testing.T) {
+	db := openTestDB(t)
+	channel := &fakeChannel{}
+	s := &ReminderScheduler{DB: db, Channels: map[string]service.Channel{entity.NotificationChannelSMS: channel}}
+
+	now := time.Date(2026, 10, 19, 2, 0, 0, 0, time.UTC)
+	old := now.Add(-claimLease - time.Minute)
+	recent := now.Add(-time.Minute)
+	stale := queue(t, db, entity.Notification{DedupKey: "stale", Status: entity.NotificationStatusSending, ClaimedAt: &old, NextAttemptAt: old})
+	legacy := queue(t, db, entity.Notification{DedupKey: "legacy", Status: entity.NotificationStatusSending, NextAttemptAt: old})
+	inFlight := queue(t, db, entity.Notification{DedupKey: "in-flight", Status: entity.NotificationStatusSending, ClaimedAt: &recent, NextAttemptAt: recent})
+	exhausted := queue(t, db, entity.Notification{DedupKey: "exhausted", Status: entity.NotificationStatusSending, ClaimedAt: &old,
+		Attempts: service.NotificationMaxAttempts - 1, NextAttemptAt: old})
+
+	sent, err := s.DeliverPending(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent != 2 {
+		t.Fatalf("sent %d, want the stale and the legacy claim", sent)
+	}
+	for _, id := range []uint{stale.ID, legacy.ID} {
+		got := reload(t, db, id)
+		if got.Status != entity.NotificationStatusSent || got.Attempts != 2 {
+			t.Errorf("%s: status %s attempts %d", got.DedupKey, got.Status, got.Attempts)
+		}
+	}
+	if got := reload(t, db, inFlight.ID); got.Status != entity.NotificationStatusSending || got.Attempts != 0 {
+		t.Errorf("in-flight claim was touched: status %s attempts %d", got.Status, got.Attempts)
+	}
+	if got := reload(t, db, exhausted.ID); got.Status != entity.NotificationStatusFailed || got.LastError != "delivery was interrupted" {
+		t.Errorf("exhausted claim: status %s last error %q", got.Status, got.LastError)
+	}
+}
+
+// webhookRecorder captures the token a webhook message is sent with
+type webhookRecorder struct{ tokens []string }
+
+func (w *webhookRecorder) Name() string { return entity.NotificationChannelWebhook }
+
+func (w *webhookRecorder) Send(n entity.Notification) error {
+	if n.Token == "" {
+		return service.ErrNoRecipientToken
+	}
+	w.tokens = append(w.tokens, n.Token)
+	return nil
+}
+
+func TestWebhookSendsWithRecipientToken(t *testing.T) {
+	db := openTestDB(t)
+	hook := &webhookRecorder{}
+	s := &ReminderScheduler{DB: db, Channels: map[string]service.Channel{entity.NotificationChannelWebhook: hook}}
+
+	woman := entity.PregnantWoman{Username: "mom", NotifyToken: "secret-token"}
+	if err := db.Create(&woman).Error; err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 10, 19, 2, 0, 0, 0, time.UTC)
+	n := queue(t, db, entity.Notification{DedupKey: "hook", Channel: entity.NotificationChannelWebhook,
+		Destination: service.WebhookDestination(woman.ID), PregnantWomanID: &woman.ID, NextAttemptAt: now})
+
+	if sent, err := s.DeliverPending(now); err != nil || sent != 1 {
+		t.Fatalf("DeliverPending = (%d, %v)", sent, err)
+	}
+	if len(hook.tokens) != 1 || hook.tokens[0] != "secret-token" {
+		t.Fatalf("tokens = %v", hook.tokens)
+	}
+	var stored struct{ Destination string }
+	db.Model(&entity.Notification{}).Select("destination").Where("id = ?", n.ID).Scan(&stored)
+	if stored.Destination != "pregnant_woman:1" {
+		t.Errorf("outbox destination = %q", stored.Destination)
+	}
+}
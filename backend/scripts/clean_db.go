@@ -38,12 +38,31 @@ func main() {
 
 	// List of tables to clean (Order matters for foreign keys if enforced, but we'll disable them temporarily)
 	tables := []string{
+		"notifications",
+		"adherence_logs",
+		"prescriptions",
+		"danger_sign_reports",
+		"home_readings",
+		"monitoring_targets",
+		"gdm_screenings",
+		"record_corrections",
+		"record_addendums",
+		"note_plan_items",
+		"note_diagnoses",
+		"clinical_notes",
+		"lab_orders",
+		"lab_observations",
 		"fetal_kick_counts",
 		"lab_results",
+		"fhir_links",
+		"hl7_inbound_messages",
+		"document_versions",
+		"patient_documents",
+		"stored_files",
 		"antenatal_visits",
 		"vaccinations",
 		"medical_histories",
-		"previous_pregnancies",
+		"obstetric_histories",
 		"pregnancies",
 		"pregnant_women",
 	}
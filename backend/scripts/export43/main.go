@@ -0,0 +1,77 @@
+// Command export43 writes the MOPH 43-file zip (PERSON, ANC, PRENATAL, LABOR,
+// NEWBORN, POSTNATAL) for a period and prints the records that could not be exported.
+//
+//	MOPH_HOSPCODE=12345 DB_PATH=Mother.db go run ./scripts/export43 -from 2026-10-01 -to 2026-10-31 -out exports
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/config"
+	"github.com/bestiesmile1845/Projecteiei/moph43"
+	"github.com/bestiesmile1845/Projecteiei/service"
+)
+
+func main() {
+	from := flag.String("from", "", "first service date (YYYY-MM-DD)")
+	to := flag.String("to", "", "last service date (YYYY-MM-DD), defaults to -from")
+	out := flag.String("out", ".", "directory to write the zip to")
+	flag.Parse()
+
+	if *to == "" {
+		*to = *from
+	}
+	start, end, err := moph43.ParseRange(*from, *to)
+	if err != nil {
+		fail(err)
+	}
+	opts, err := moph43.OptionsFromEnv()
+	if err != nil {
+		fail(err)
+	}
+
+	config.ConnectionDB()
+	export, err := moph43.Build(config.DB(), opts, start, end, time.Now())
+	if err != nil {
+		fail(err)
+	}
+
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		fail(err)
+	}
+	path := filepath.Join(*out, export.FileName())
+	f, err := os.Create(path)
+	if err != nil {
+		fail(err)
+	}
+	if err := export.WriteZip(f); err != nil {
+		f.Close()
+		fail(err)
+	}
+	if err := f.Close(); err != nil {
+		fail(err)
+	}
+
+	fmt.Println("wrote", path)
+	counts := export.Counts()
+	for _, file := range service.Moph43Files {
+		fmt.Printf("  %-10s %d\n", file, counts[file])
+	}
+	if len(export.Problems) == 0 {
+		return
+	}
+	fmt.Printf("%d records not exported:\n", len(export.Problems))
+	for _, p := range export.Problems {
+		fmt.Printf("  %-10s %-22s %-30s %s %s\n", p.File, p.Record, p.Patient, p.Field, p.Problem)
+	}
+	os.Exit(2)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "export43:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+)
+
+// Status of an ANC contact in a pregnancy's schedule
+const (
+	AncContactUpcoming  = "Upcoming"
+	AncContactDue       = "Due"
+	AncContactCompleted = "Completed"
+	AncContactMissed    = "Missed"
+)
+
+// AncPlannedHour is the clinic time at which generated ANC appointments are placed
+const AncPlannedHour = 9
+
+// PregnancyLMP returns the LMP of the pregnancy, deriving it from the EDC when missing
+func PregnancyLMP(p entity.Pregnancy) time.Time {
+	if !p.LMP.IsZero() {
+		return p.LMP
+	}
+	if !p.EDC.IsZero() {
+		return p.EDC.AddDate(0, 0, -280)
+	}
+	return time.Time{}
+}
+
+// GestationalWeeks returns completed weeks of gestation at the given time
+func GestationalWeeks(lmp, at time.Time) int {
+	if lmp.IsZero() || at.Before(lmp) {
+		return 0
+	}
+	return int(at.Sub(lmp).Hours() / 24 / 7)
+}
+
+// AncContactWindow returns the first day, the day after the last day and the target day of a contact
+func AncContactWindow(lmp time.Time, contact entity.AncContact) (from, to, target time.Time) {
+	day := time.Date(lmp.Year(), lmp.Month(), lmp.Day(), 0, 0, 0, 0, ClinicLocation)
+	from = day.AddDate(0, 0, contact.WeekFrom*7)
+	to = day.AddDate(0, 0, (contact.WeekTo+1)*7)
+	target = day.AddDate(0, 0, contact.TargetWeek*7)
+	return from, to, target
+}
+
+// PlanAncContactDate picks the appointment date for a contact. Contacts whose
+// target has passed but whose window is still open are planned for the next day;
+// contacts whose window has closed are not planned (ok is false).
+func PlanAncContactDate(lmp time.Time, contact entity.AncContact, now time.Time) (date time.Time, ok bool) {
+	_, to, target := AncContactWindow(lmp, contact)
+	if !now.Before(to) {
+		return time.Time{}, false
+	}
+	if target.Before(now) {
+		today := now.In(ClinicLocation)
+		target = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, ClinicLocation).AddDate(0, 0, 1)
+	}
+	return target.Add(AncPlannedHour * time.Hour).UTC(), true
+}
+
+// AncLastPlannedHour is the clinic time by which generated ANC appointments must
+// start when the doctor has no published hours and 09:00 is already taken
+const AncLastPlannedHour = 16
+
+// maxAncWeek is the last gestational week a contact may be planned for
+const maxAncWeek = 42
+
+// ValidateAncTemplate normalises a schedule template and checks its contacts
+func ValidateAncTemplate(t *entity.AncScheduleTemplate) error {
+	t.Code = strings.ToUpper(strings.TrimSpace(t.Code))
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Code == "" || t.Name == "" {
+		return errors.New("code and name are required")
+	}
+	if len(t.Contacts) == 0 {
+		return errors.New("a template needs at least one contact")
+	}
+	seen := map[int]bool{}
+	for i := range t.Contacts {
+		c := &t.Contacts[i]
+		if c.ContactNo < 1 {
+			return errors.New("contact_no must be 1 or more")
+		}
+		if seen[c.ContactNo] {
+			return fmt.Errorf("contact %d is listed more than once", c.ContactNo)
+		}
+		seen[c.ContactNo] = true
+		if c.WeekFrom < 0 || c.WeekFrom > c.TargetWeek || c.TargetWeek > c.WeekTo || c.WeekTo > maxAncWeek {
+			return fmt.Errorf("contact %d: weeks must satisfy 0 <= week_from <= target_week <= week_to <= %d", c.ContactNo, maxAncWeek)
+		}
+		c.Checks = strings.Join(SplitList(c.Checks), ",")
+		c.Labs = strings.Join(SplitList(c.Labs), ",")
+		c.Description = strings.TrimSpace(c.Description)
+	}
+	sort.Slice(t.Contacts, func(i, j int) bool { return t.Contacts[i].ContactNo < t.Contacts[j].ContactNo })
+	return nil
+}
+
+// AncContactStatus tells whether a contact was attended, missed, is due now or still ahead
+func AncContactStatus(lmp time.Time, contact entity.AncContact, visitDates []time.Time, now time.Time) string {
+	from, to, _ := AncContactWindow(lmp, contact)
+	for _, d := range visitDates {
+		if !d.Before(from) && d.Before(to) {
+			return AncContactCompleted
+		}
+	}
+	switch {
+	case !now.Before(to):
+		return AncContactMissed
+	case !now.Before(from):
+		return AncContactDue
+	default:
+		return AncContactUpcoming
+	}
+}
+
+// SplitList turns a comma separated list into trimmed items
+func SplitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
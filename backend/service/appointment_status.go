@@ -0,0 +1,35 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+)
+
+// appointmentTransitions lists the statuses an appointment may move to.
+// Completed, Cancelled and NoShow are final.
+var appointmentTransitions = map[string][]string{
+	entity.AppointmentStatusScheduled: {
+		entity.AppointmentStatusCheckedIn,
+		entity.AppointmentStatusCancelled,
+		entity.AppointmentStatusNoShow,
+	},
+	entity.AppointmentStatusCheckedIn: {
+		entity.AppointmentStatusCompleted,
+	},
+}
+
+// ValidateAppointmentTransition checks that moving an appointment from one status to another is allowed
+func ValidateAppointmentTransition(from, to string) error {
+	for _, allowed := range appointmentTransitions[from] {
+		if allowed == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot change appointment status from %s to %s", from, to)
+}
+
+// IsAppointmentOpen reports whether the appointment can still be rescheduled or cancelled
+func IsAppointmentOpen(status string) bool {
+	return status == entity.AppointmentStatusScheduled
+}
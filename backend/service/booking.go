@@ -0,0 +1,151 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+)
+
+// Booking policy for mothers booking their own appointments
+const (
+	BookingMinLeadTime   = 2 * time.Hour       // ต้องจองล่วงหน้าอย่างน้อย
+	BookingMaxAhead      = 90 * 24 * time.Hour // จองล่วงหน้าได้ไม่เกิน
+	CancelMinLeadTime    = 24 * time.Hour      // ยกเลิกได้ก่อนเวลานัดอย่างน้อย
+	DefaultSlotMinutes   = 15
+	MaxSlotSearchDays    = 31
+	clinicTimeZoneName   = "Asia/Bangkok"
+	clinicTimeZoneOffset = 7 * 60 * 60
+)
+
+// ClinicLocation is the time zone in which availability times ("09:00") are read
+var ClinicLocation = loadClinicLocation()
+
+func loadClinicLocation() *time.Location {
+	if loc, err := time.LoadLocation(clinicTimeZoneName); err == nil {
+		return loc
+	}
+	return time.FixedZone("ICT", clinicTimeZoneOffset)
+}
+
+// Slot is one bookable period of a doctor
+type Slot struct {
+	DoctorID uint      `json:"doctor_id"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Location string    `json:"location"`
+}
+
+// ParseClock parses "HH:MM" into minutes after midnight
+func ParseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// ValidateAvailabilityHours checks start/end/slot length of a template or exception
+func ValidateAvailabilityHours(start, end string, slotMinutes int) error {
+	startMin, err := ParseClock(start)
+	if err != nil {
+		return err
+	}
+	endMin, err := ParseClock(end)
+	if err != nil {
+		return err
+	}
+	if endMin <= startMin {
+		return errors.New("end_time must be after start_time")
+	}
+	if slotMinutes < 0 || slotMinutes > endMin-startMin {
+		return errors.New("slot_minutes does not fit between start_time and end_time")
+	}
+	return nil
+}
+
+// GenerateSlots expands weekly templates and date exceptions into slots between from and to.
+// An exception on a date replaces all weekly templates of that date.
+func GenerateSlots(doctorID uint, templates []entity.DoctorAvailability, exceptions []entity.AvailabilityException, from, to time.Time) []Slot {
+	exceptionsByDate := map[string][]entity.AvailabilityException{}
+	for _, e := range exceptions {
+		key := e.Date.In(ClinicLocation).Format("2006-01-02")
+		exceptionsByDate[key] = append(exceptionsByDate[key], e)
+	}
+
+	var slots []Slot
+	day := time.Date(from.In(ClinicLocation).Year(), from.In(ClinicLocation).Month(), from.In(ClinicLocation).Day(), 0, 0, 0, 0, ClinicLocation)
+	for ; day.Before(to); day = day.AddDate(0, 0, 1) {
+		if dayExceptions, ok := exceptionsByDate[day.Format("2006-01-02")]; ok {
+			for _, e := range dayExceptions {
+				if e.Unavailable {
+					continue
+				}
+				slots = append(slots, expandHours(doctorID, day, e.StartTime, e.EndTime, e.SlotMinutes, e.Location)...)
+			}
+			continue
+		}
+		for _, t := range templates {
+			if t.Weekday != int(day.Weekday()) {
+				continue
+			}
+			slots = append(slots, expandHours(doctorID, day, t.StartTime, t.EndTime, t.SlotMinutes, t.Location)...)
+		}
+	}
+
+	var inRange []Slot
+	for _, s := range slots {
+		if !s.Start.Before(from) && s.Start.Before(to) {
+			inRange = append(inRange, s)
+		}
+	}
+	sort.Slice(inRange, func(i, j int) bool { return inRange[i].Start.Before(inRange[j].Start) })
+	return inRange
+}
+
+func expandHours(doctorID uint, day time.Time, start, end string, slotMinutes int, location string) []Slot {
+	startMin, err := ParseClock(start)
+	if err != nil {
+		return nil
+	}
+	endMin, err := ParseClock(end)
+	if err != nil {
+		return nil
+	}
+	if slotMinutes <= 0 {
+		slotMinutes = DefaultSlotMinutes
+	}
+
+	var slots []Slot
+	for m := startMin; m+slotMinutes <= endMin; m += slotMinutes {
+		slotStart := day.Add(time.Duration(m) * time.Minute)
+		slots = append(slots, Slot{
+			DoctorID: doctorID,
+			Start:    slotStart.UTC(),
+			End:      slotStart.Add(time.Duration(slotMinutes) * time.Minute).UTC(),
+			Location: location,
+		})
+	}
+	return slots
+}
+
+// CheckBookingWindow applies the self-booking policy to a requested slot start
+func CheckBookingWindow(start, now time.Time) error {
+	if start.Before(now.Add(BookingMinLeadTime)) {
+		return fmt.Errorf("appointments must be booked at least %d hours ahead", int(BookingMinLeadTime.Hours()))
+	}
+	if start.After(now.Add(BookingMaxAhead)) {
+		return fmt.Errorf("appointments can be booked at most %d days ahead", int(BookingMaxAhead.Hours()/24))
+	}
+	return nil
+}
+
+// CheckCancelWindow applies the self-cancellation policy to an appointment
+func CheckCancelWindow(appointmentDate, now time.Time) error {
+	if appointmentDate.Before(now.Add(CancelMinLeadTime)) {
+		return fmt.Errorf("appointments can only be cancelled or moved at least %d hours ahead; please contact the clinic", int(CancelMinLeadTime.Hours()))
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+)
+
+// NormalizeIcd10Code uppercases a code and adds the dot after the category (O244 -> O24.4)
+func NormalizeIcd10Code(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) > 3 && !strings.Contains(code, ".") {
+		code = code[:3] + "." + code[3:]
+	}
+	return code
+}
+
+// ApplyNoteTemplate fills the SOAP sections the doctor left empty from a template
+func ApplyNoteTemplate(note *entity.ClinicalNote, template entity.NoteTemplate) {
+	if strings.TrimSpace(note.Subjective) == "" {
+		note.Subjective = template.Subjective
+	}
+	if strings.TrimSpace(note.Objective) == "" {
+		note.Objective = template.Objective
+	}
+	if strings.TrimSpace(note.Assessment) == "" {
+		note.Assessment = template.Assessment
+	}
+	if strings.TrimSpace(note.Plan) == "" {
+		note.Plan = template.Plan
+	}
+}
+
+// ValidateClinicalNote checks that a note has content and its coded parts are consistent
+func ValidateClinicalNote(note entity.ClinicalNote) error {
+	if strings.TrimSpace(note.Subjective+note.Objective+note.Assessment+note.Plan) == "" && len(note.Diagnoses) == 0 {
+		return errors.New("at least one SOAP section or diagnosis is required")
+	}
+
+	primary := 0
+	seen := []string{}
+	for _, d := range note.Diagnoses {
+		if contains(seen, d.Code) {
+			return errors.New("diagnosis " + d.Code + " is listed twice")
+		}
+		seen = append(seen, d.Code)
+		if d.IsPrimary {
+			primary++
+		}
+	}
+	if primary > 1 {
+		return errors.New("only one diagnosis can be primary")
+	}
+
+	for _, item := range note.PlanItems {
+		if strings.TrimSpace(item.Description) == "" {
+			return errors.New("plan item description is required")
+		}
+		if item.FollowUp && item.DueDate != nil && item.DueDate.Before(note.NoteDate.AddDate(0, 0, -1)) {
+			return errors.New("plan item due_date cannot be before the note date")
+		}
+	}
+	return nil
+}
+
+// ValidateNoteTemplate checks a template before it is saved
+func ValidateNoteTemplate(template entity.NoteTemplate) error {
+	if strings.TrimSpace(template.Name) == "" {
+		return errors.New("name is required")
+	}
+	if strings.TrimSpace(template.Subjective+template.Objective+template.Assessment+template.Plan) == "" {
+		return errors.New("template must contain at least one SOAP section")
+	}
+	return nil
+}
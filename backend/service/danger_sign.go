@@ -0,0 +1,185 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+)
+
+// DangerSigns are the symptom codes a mother can report
+var DangerSigns = []string{
+	entity.DangerSignBleeding,
+	entity.DangerSignSevereHeadache,
+	entity.DangerSignBlurredVision,
+	entity.DangerSignLeakingFluid,
+	entity.DangerSignAbdominalPain,
+	entity.DangerSignFever,
+	entity.DangerSignReducedMovements,
+}
+
+// Triage thresholds
+const (
+	TriageViabilityWeeks = 20   // เลือดออก/ความดันสูงหลัง 20 สัปดาห์ถือว่ารุนแรง
+	TriageTermWeeks      = 37   // น้ำเดินก่อน 37 สัปดาห์ = ถุงน้ำคร่ำแตกก่อนกำหนด
+	TriageMovementWeeks  = 28   // ลูกดิ้นน้อยหลัง 28 สัปดาห์ต้องมาโรงพยาบาลทันที
+	TriageFeverC         = 38.0 // ไข้
+	TriageHighFeverC     = 39.5
+)
+
+// triageInstructions are the instructions returned to the mother for each level
+var triageInstructions = map[string]string{
+	entity.TriageEmergency: "ไปห้องคลอดหรือห้องฉุกเฉินของโรงพยาบาลที่ใกล้ที่สุดทันที หรือโทร 1669 อย่าขับรถเอง และนำสมุดฝากครรภ์ไปด้วย",
+	entity.TriageUrgent:    "ติดต่อคลินิกฝากครรภ์หรือมาโรงพยาบาลภายในวันนี้ หากอาการแย่ลงให้ไปห้องฉุกเฉินหรือโทร 1669 ทันที",
+	entity.TriageRoutine:   "สังเกตอาการต่อที่บ้าน พักผ่อนและดื่มน้ำให้เพียงพอ แจ้งแพทย์ในการนัดครั้งถัดไป หากอาการมากขึ้นหรือมีอาการอันตรายอื่นให้รายงานใหม่",
+}
+
+// DangerSignInput is what the mother reports
+type DangerSignInput struct {
+	Symptoms         []string
+	HeavyBleeding    bool
+	Temperature      *float64
+	GestationalWeeks int
+}
+
+// TriageResult is the classification of one report
+type TriageResult struct {
+	Level        string
+	Reasons      []string
+	Instructions string
+}
+
+// NormalizeDangerSigns validates the symptom codes and removes duplicates
+func NormalizeDangerSigns(symptoms []string) ([]string, error) {
+	var result []string
+	for _, s := range symptoms {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if !contains(DangerSigns, s) {
+			return nil, errors.New("unknown symptom " + s + "; must be one of " + strings.Join(DangerSigns, ", "))
+		}
+		if !contains(result, s) {
+			result = append(result, s)
+		}
+	}
+	if len(result) == 0 {
+		return nil, errors.New("at least one symptom is required")
+	}
+	return result, nil
+}
+
+// TriageDangerSigns classifies a report as Routine, Urgent or Emergency.
+// Every rule that fires adds a reason; the highest level wins. An unknown
+// gestational age (0, no LMP or EDC) is triaged as the later, riskier stage.
+func TriageDangerSigns(in DangerSignInput) TriageResult {
+	result := TriageResult{Level: entity.TriageRoutine}
+	raise := func(level, reason string) {
+		if triageRank(level) > triageRank(result.Level) {
+			result.Level = level
+		}
+		result.Reasons = append(result.Reasons, reason)
+	}
+
+	has := func(s string) bool { return contains(in.Symptoms, s) }
+	ga := in.GestationalWeeks
+	unknownGA := ga <= 0
+	// from reports whether the pregnancy may be at or past the given week
+	from := func(weeks int) bool { return unknownGA || ga >= weeks }
+	unknown := func(reason string) string {
+		if unknownGA {
+			return reason + "; gestational age unknown"
+		}
+		return reason
+	}
+
+	if has(entity.DangerSignBleeding) {
+		switch {
+		case in.HeavyBleeding:
+			raise(entity.TriageEmergency, "heavy vaginal bleeding")
+		case from(TriageViabilityWeeks):
+			raise(entity.TriageEmergency, unknown("bleeding after 20 weeks (possible placenta praevia or abruption)"))
+		case has(entity.DangerSignAbdominalPain):
+			raise(entity.TriageEmergency, "bleeding with abdominal pain in early pregnancy (possible ectopic or miscarriage)")
+		default:
+			raise(entity.TriageUrgent, "light bleeding in early pregnancy")
+		}
+	}
+
+	if has(entity.DangerSignSevereHeadache) && has(entity.DangerSignBlurredVision) {
+		raise(entity.TriageEmergency, "severe headache with visual disturbance (possible pre-eclampsia)")
+	} else if has(entity.DangerSignBlurredVision) {
+		raise(entity.TriageUrgent, "blurred vision")
+	} else if has(entity.DangerSignSevereHeadache) {
+		if from(TriageViabilityWeeks) {
+			raise(entity.TriageUrgent, unknown("severe headache after 20 weeks (check blood pressure)"))
+		} else {
+			raise(entity.TriageRoutine, "headache in early pregnancy")
+		}
+	}
+
+	if has(entity.DangerSignLeakingFluid) {
+		if unknownGA || ga < TriageTermWeeks {
+			raise(entity.TriageEmergency, unknown("leaking fluid before 37 weeks (possible preterm rupture of membranes)"))
+		} else {
+			raise(entity.TriageUrgent, "leaking fluid at term")
+		}
+	}
+
+	if has(entity.DangerSignAbdominalPain) && !has(entity.DangerSignBleeding) {
+		raise(entity.TriageUrgent, "abdominal pain")
+	}
+
+	if has(entity.DangerSignFever) {
+		switch {
+		case in.Temperature != nil && *in.Temperature >= TriageHighFeverC:
+			raise(entity.TriageEmergency, "high fever")
+		case has(entity.DangerSignLeakingFluid):
+			raise(entity.TriageEmergency, "fever with leaking fluid (possible chorioamnionitis)")
+		case in.Temperature != nil && *in.Temperature < TriageFeverC:
+			raise(entity.TriageRoutine, "feeling feverish without a raised temperature")
+		default:
+			raise(entity.TriageUrgent, "fever")
+		}
+	}
+
+	if has(entity.DangerSignReducedMovements) {
+		switch {
+		case from(TriageMovementWeeks):
+			raise(entity.TriageEmergency, unknown("reduced fetal movements after 28 weeks"))
+		case from(TriageViabilityWeeks):
+			raise(entity.TriageUrgent, "reduced fetal movements")
+		default:
+			raise(entity.TriageRoutine, "fetal movements are not yet regular before 20 weeks")
+		}
+	}
+
+	result.Instructions = triageInstructions[result.Level]
+	return result
+}
+
+// DangerSignAlertText builds the care team alert of an urgent report
+func DangerSignAlertText(woman entity.PregnantWoman, report entity.DangerSignReport) (subject, body string) {
+	subject = "[" + report.Triage + "] อาการผิดปกติ: " + woman.FullName
+	body = fmt.Sprintf("%s (HN %s, โทร %s) รายงานอาการ %s เมื่อ %s น.\nผลคัดกรอง: %s - %s",
+		woman.FullName, woman.HN, woman.PhoneNumber, report.Symptoms,
+		report.ReportedAt.In(ClinicLocation).Format("02/01/2006 15:04"), report.Triage, report.TriageReasons)
+	if report.GestationalWeeks > 0 {
+		body += fmt.Sprintf("\nอายุครรภ์ %d สัปดาห์", report.GestationalWeeks)
+	}
+	return subject, body
+}
+
+// IsUrgentTriage reports whether a level belongs on the care team's urgent feed
+func IsUrgentTriage(level string) bool {
+	return triageRank(level) >= triageRank(entity.TriageUrgent)
+}
+
+func triageRank(level string) int {
+	switch level {
+	case entity.TriageEmergency:
+		return 2
+	case entity.TriageUrgent:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+)
+
+func TestTriageDangerSigns(t *testing.T) {
+	tests := []struct {
+		name     string
+		symptoms []string
+		heavy    bool
+		ga       int
+		want     string
+	}{
+		{"light bleeding early", []string{entity.DangerSignBleeding}, false, 10, entity.TriageUrgent},
+		{"bleeding after 20 weeks", []string{entity.DangerSignBleeding}, false, 24, entity.TriageEmergency},
+		{"bleeding, GA unknown", []string{entity.DangerSignBleeding}, false, 0, entity.TriageEmergency},
+		{"heavy bleeding", []string{entity.DangerSignBleeding}, true, 8, entity.TriageEmergency},
+		{"headache early", []string{entity.DangerSignSevereHeadache}, false, 12, entity.TriageRoutine},
+		{"headache, GA unknown", []string{entity.DangerSignSevereHeadache}, false, 0, entity.TriageUrgent},
+		{"leaking fluid preterm", []string{entity.DangerSignLeakingFluid}, false, 30, entity.TriageEmergency},
+		{"leaking fluid at term", []string{entity.DangerSignLeakingFluid}, false, 38, entity.TriageUrgent},
+		{"leaking fluid, GA unknown", []string{entity.DangerSignLeakingFluid}, false, 0, entity.TriageEmergency},
+		{"reduced movements at 18 weeks", []string{entity.DangerSignReducedMovements}, false, 18, entity.TriageRoutine},
+		{"reduced movements at 24 weeks", []string{entity.DangerSignReducedMovements}, false, 24, entity.TriageUrgent},
+		{"reduced movements, GA unknown", []string{entity.DangerSignReducedMovements}, false, 0, entity.TriageEmergency},
+		{"headache with blurred vision", []string{entity.DangerSignSevereHeadache, entity.DangerSignBlurredVision}, false, 30, entity.TriageEmergency},
+	}
+	for _, tt := range tests {
+		got := TriageDangerSigns(DangerSignInput{Symptoms: tt.symptoms, HeavyBleeding: tt.heavy, GestationalWeeks: tt.ga})
+		if got.Level != tt.want {
+			t.Errorf("%s: level %s, want %s (%v)", tt.name, got.Level, tt.want, got.Reasons)
+		}
+	}
+}
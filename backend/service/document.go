@@ -0,0 +1,75 @@
+package service
+
+import (
+	"errors"
+	"path"
+	"strings"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+)
+
+// DocumentCategories are the categories a patient document can be filed under
+var DocumentCategories = []string{
+	entity.DocumentUltrasound,
+	entity.DocumentReferral,
+	entity.DocumentOutsideLab,
+	entity.DocumentPinkBook,
+	entity.DocumentPhoto,
+	entity.DocumentOther,
+}
+
+const maxDocumentTitleLength = 200
+
+// NormalizeTags lowercases, trims and de-duplicates a comma separated tag list
+func NormalizeTags(tags string) string {
+	items := []string{}
+	for _, tag := range SplitList(tags) {
+		tag = strings.ToLower(tag)
+		if !contains(items, tag) {
+			items = append(items, tag)
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+// HasTag reports whether a normalized tag list contains tag
+func HasTag(tags, tag string) bool {
+	return contains(SplitList(tags), strings.ToLower(strings.TrimSpace(tag)))
+}
+
+// DocumentTitle derives a default title from an uploaded file name
+func DocumentTitle(fileName string) string {
+	name := SanitizeFilename(fileName)
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+// ValidatePatientDocument fills defaults and checks the document metadata.
+// fileName is used as the title when none is given.
+func ValidatePatientDocument(doc *entity.PatientDocument, fileName string) error {
+	doc.Category = strings.TrimSpace(doc.Category)
+	if doc.Category == "" {
+		doc.Category = entity.DocumentOther
+	}
+	if !contains(DocumentCategories, doc.Category) {
+		return errors.New("category must be one of " + strings.Join(DocumentCategories, ", "))
+	}
+
+	doc.Title = strings.TrimSpace(doc.Title)
+	if doc.Title == "" {
+		doc.Title = DocumentTitle(fileName)
+	}
+	if doc.Title == "" {
+		return errors.New("title is required")
+	}
+	if len([]rune(doc.Title)) > maxDocumentTitleLength {
+		return errors.New("title is too long")
+	}
+
+	doc.Description = strings.TrimSpace(doc.Description)
+	doc.Tags = NormalizeTags(doc.Tags)
+	doc.RecordType = strings.TrimSpace(doc.RecordType)
+	if (doc.RecordType == "") != (doc.RecordID == nil) {
+		return errors.New("record_type and record_id must be given together")
+	}
+	return nil
+}
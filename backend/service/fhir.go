@@ -0,0 +1,304 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+// FHIR R4 identifier and code systems used by the export and import
+const (
+	FhirIdentifierBase = "urn:projecteiei:id:"        // + patient, pregnancy, antenatal-visit, lab-result, vaccination, hn
+	FhirExtensionBase  = "urn:projecteiei:extension:" // + pregnancy-status, pregnancy-no, edc, fetus-count, medical-diagnosis, pregnancy
+	FhirLocalCodes     = "urn:projecteiei:code"       // visit findings without a LOINC code
+	FhirLabTestCodes   = "urn:projecteiei:lab-test"   // codes of the lab test catalog
+	FhirVaccineCodes   = "urn:projecteiei:vaccine"    // codes of the vaccine types
+
+	FhirSystemThaiCID  = "https://terms.sil-th.org/id/th-cid"
+	FhirSystemLoinc    = "http://loinc.org"
+	FhirSystemSnomed   = "http://snomed.info/sct"
+	FhirSystemUcum     = "http://unitsofmeasure.org"
+	FhirSystemV2IDType = "http://terminology.hl7.org/CodeSystem/v2-0203"
+	FhirSystemActCode  = "http://terminology.hl7.org/CodeSystem/v3-ActCode"
+
+	fhirSystemInterpretation  = "http://terminology.hl7.org/CodeSystem/v3-ObservationInterpretation"
+	fhirSystemObsCategory     = "http://terminology.hl7.org/CodeSystem/observation-category"
+	fhirSystemConditionStatus = "http://terminology.hl7.org/CodeSystem/condition-clinical"
+)
+
+// FhirBundle is a FHIR Bundle (searchset, collection, transaction or transaction-response)
+type FhirBundle struct {
+	ResourceType string            `json:"resourceType"`
+	ID           string            `json:"id,omitempty"`
+	Type         string            `json:"type"`
+	Timestamp    string            `json:"timestamp,omitempty"`
+	Total        *int              `json:"total,omitempty"`
+	Entry        []FhirBundleEntry `json:"entry,omitempty"`
+}
+
+type FhirBundleEntry struct {
+	FullURL  string             `json:"fullUrl,omitempty"`
+	Resource *FhirResource      `json:"resource,omitempty"`
+	Request  *FhirEntryRequest  `json:"request,omitempty"`
+	Response *FhirEntryResponse `json:"response,omitempty"`
+}
+
+type FhirEntryRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+}
+
+type FhirEntryResponse struct {
+	Status   string        `json:"status"`
+	Location string        `json:"location,omitempty"`
+	Outcome  *FhirResource `json:"outcome,omitempty"`
+}
+
+// FhirResource holds the elements of every resource type this system maps.
+// Only the elements of its resourceType are filled; the rest are omitted from JSON.
+type FhirResource struct {
+	ResourceType string           `json:"resourceType"`
+	ID           string           `json:"id,omitempty"`
+	Extension    []FhirExtension  `json:"extension,omitempty"`
+	Identifier   []FhirIdentifier `json:"identifier,omitempty"`
+	Status       string           `json:"status,omitempty"`
+
+	// Patient
+	Name      []FhirHumanName    `json:"name,omitempty"`
+	Telecom   []FhirContactPoint `json:"telecom,omitempty"`
+	Gender    string             `json:"gender,omitempty"`
+	BirthDate string             `json:"birthDate,omitempty"`
+
+	// EpisodeOfCare, Encounter, Condition, Observation, DiagnosticReport
+	Class          *FhirCoding           `json:"class,omitempty"`
+	Type           []FhirCodeableConcept `json:"type,omitempty"`
+	Category       []FhirCodeableConcept `json:"category,omitempty"`
+	ClinicalStatus *FhirCodeableConcept  `json:"clinicalStatus,omitempty"`
+	Code           *FhirCodeableConcept  `json:"code,omitempty"`
+	Patient        *FhirReference        `json:"patient,omitempty"`
+	Subject        *FhirReference        `json:"subject,omitempty"`
+	Encounter      *FhirReference        `json:"encounter,omitempty"`
+	EpisodeOfCare  []FhirReference       `json:"episodeOfCare,omitempty"`
+	Diagnosis      []FhirDiagnosis       `json:"diagnosis,omitempty"`
+	Period         *FhirPeriod           `json:"period,omitempty"`
+
+	OnsetDateTime     string `json:"onsetDateTime,omitempty"`
+	AbatementDateTime string `json:"abatementDateTime,omitempty"`
+	EffectiveDateTime string `json:"effectiveDateTime,omitempty"`
+
+	ValueQuantity  *FhirQuantity         `json:"valueQuantity,omitempty"`
+	ValueString    string                `json:"valueString,omitempty"`
+	Component      []FhirComponent       `json:"component,omitempty"`
+	Interpretation []FhirCodeableConcept `json:"interpretation,omitempty"`
+	ReferenceRange []FhirReferenceRange  `json:"referenceRange,omitempty"`
+	Result         []FhirReference       `json:"result,omitempty"`
+	Conclusion     string                `json:"conclusion,omitempty"`
+
+	// Immunization
+	VaccineCode        *FhirCodeableConcept  `json:"vaccineCode,omitempty"`
+	StatusReason       *FhirCodeableConcept  `json:"statusReason,omitempty"`
+	OccurrenceDateTime string                `json:"occurrenceDateTime,omitempty"`
+	ProtocolApplied    []FhirProtocolApplied `json:"protocolApplied,omitempty"`
+	Note               []FhirAnnotation      `json:"note,omitempty"`
+
+	// OperationOutcome
+	Issue []FhirIssue `json:"issue,omitempty"`
+}
+
+type FhirExtension struct {
+	URL          string `json:"url"`
+	ValueString  string `json:"valueString,omitempty"`
+	ValueCode    string `json:"valueCode,omitempty"`
+	ValueDate    string `json:"valueDate,omitempty"`
+	ValueInteger *int   `json:"valueInteger,omitempty"`
+
+	ValueReference *FhirReference `json:"valueReference,omitempty"`
+}
+
+type FhirIdentifier struct {
+	Type   *FhirCodeableConcept `json:"type,omitempty"`
+	System string               `json:"system,omitempty"`
+	Value  string               `json:"value,omitempty"`
+}
+
+type FhirHumanName struct {
+	Text   string   `json:"text,omitempty"`
+	Family string   `json:"family,omitempty"`
+	Given  []string `json:"given,omitempty"`
+}
+
+type FhirContactPoint struct {
+	System string `json:"system,omitempty"` // phone, email
+	Value  string `json:"value,omitempty"`
+}
+
+type FhirCoding struct {
+	System  string `json:"system,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Display string `json:"display,omitempty"`
+}
+
+type FhirCodeableConcept struct {
+	Coding []FhirCoding `json:"coding,omitempty"`
+	Text   string       `json:"text,omitempty"`
+}
+
+type FhirReference struct {
+	Reference string `json:"reference,omitempty"`
+	Display   string `json:"display,omitempty"`
+}
+
+type FhirDiagnosis struct {
+	Condition FhirReference `json:"condition"`
+}
+
+type FhirPeriod struct {
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+}
+
+type FhirQuantity struct {
+	Value  float64 `json:"value"`
+	Unit   string  `json:"unit,omitempty"`
+	System string  `json:"system,omitempty"`
+	Code   string  `json:"code,omitempty"`
+}
+
+type FhirComponent struct {
+	Code          FhirCodeableConcept `json:"code"`
+	ValueQuantity *FhirQuantity       `json:"valueQuantity,omitempty"`
+}
+
+type FhirReferenceRange struct {
+	Text string `json:"text,omitempty"`
+}
+
+type FhirProtocolApplied struct {
+	DoseNumberPositiveInt int `json:"doseNumberPositiveInt,omitempty"`
+}
+
+type FhirAnnotation struct {
+	Text string `json:"text"`
+}
+
+type FhirIssue struct {
+	Severity    string `json:"severity"` // error, warning, information
+	Code        string `json:"code"`     // invalid, not-found, conflict, not-supported, exception
+	Diagnostics string `json:"diagnostics,omitempty"`
+}
+
+// FhirOperationOutcome builds the OperationOutcome returned for errors and skipped entries
+func FhirOperationOutcome(severity, code, diagnostics string) *FhirResource {
+	return &FhirResource{
+		ResourceType: "OperationOutcome",
+		Issue:        []FhirIssue{{Severity: severity, Code: code, Diagnostics: diagnostics}},
+	}
+}
+
+// FhirLocalIdentifier is the identifier this system gives a record of the given kind
+func FhirLocalIdentifier(kind string, id uint) FhirIdentifier {
+	return FhirIdentifier{System: FhirIdentifierBase + kind, Value: strconv.FormatUint(uint64(id), 10)}
+}
+
+// FhirLocalID returns the local id carried in a resource's identifier of the given kind
+func FhirLocalID(res FhirResource, kind string) (uint, bool) {
+	for _, identifier := range res.Identifier {
+		if identifier.System == FhirIdentifierBase+kind {
+			if id, err := strconv.ParseUint(identifier.Value, 10, 0); err == nil && id > 0 {
+				return uint(id), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// FhirReferenceTo builds a relative reference such as Patient/1
+func FhirReferenceTo(resourceType, id string) *FhirReference {
+	return &FhirReference{Reference: resourceType + "/" + id}
+}
+
+// FhirDate formats a FHIR date (YYYY-MM-DD); zero times are omitted
+func FhirDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+// FhirDateTime formats a FHIR dateTime; zero times are omitted
+func FhirDateTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// ParseFhirDateTime reads a FHIR date or dateTime. An empty value is the zero time.
+func ParseFhirDateTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02", "2006-01", "2006"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("invalid FHIR date " + value)
+}
+
+// FhirExtensionValue returns the value of the extension with the given name below FhirExtensionBase
+func FhirExtensionValue(res FhirResource, name string) (FhirExtension, bool) {
+	for _, ext := range res.Extension {
+		if ext.URL == FhirExtensionBase+name {
+			return ext, true
+		}
+	}
+	return FhirExtension{}, false
+}
+
+// HasFhirCoding reports whether a concept carries the code in the given system
+func HasFhirCoding(concept *FhirCodeableConcept, system, code string) bool {
+	if concept == nil {
+		return false
+	}
+	for _, coding := range concept.Coding {
+		if coding.System == system && coding.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// FhirCodeIn returns the first code of a concept in the given system
+func FhirCodeIn(concept *FhirCodeableConcept, system string) string {
+	if concept == nil {
+		return ""
+	}
+	for _, coding := range concept.Coding {
+		if coding.System == system {
+			return coding.Code
+		}
+	}
+	return ""
+}
+
+// FhirConceptText returns the text of a concept, falling back to the first display
+func FhirConceptText(concept *FhirCodeableConcept) string {
+	if concept == nil {
+		return ""
+	}
+	if concept.Text != "" {
+		return concept.Text
+	}
+	for _, coding := range concept.Coding {
+		if coding.Display != "" {
+			return coding.Display
+		}
+	}
+	return ""
+}
+
+// fhirConcept builds a concept with one coding
+func fhirConcept(system, code, display string) *FhirCodeableConcept {
+	return &FhirCodeableConcept{Coding: []FhirCoding{{System: system, Code: code, Display: display}}, Text: display}
+}
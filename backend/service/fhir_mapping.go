@@ -0,0 +1,619 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+)
+
+// Findings of an antenatal visit, exported as Observations of the visit's Encounter
+const (
+	VisitFindingWeight         = "weight"
+	VisitFindingBloodPressure  = "blood-pressure"
+	VisitFindingFundalHeight   = "fundal-height"
+	VisitFindingGestationalAge = "gestational-age"
+	VisitFindingFetalHeart     = "fetal-heart"
+	VisitFindingFetalMovement  = "fetal-movement"
+	VisitFindingUrineProtein   = "urine-protein"
+	VisitFindingUrineSugar     = "urine-sugar"
+	VisitFindingSwelling       = "edema"
+)
+
+type fhirVisitFinding struct {
+	key     string
+	system  string
+	code    string
+	display string
+	unit    string // UCUM unit for numeric findings
+}
+
+var fhirVisitFindings = []fhirVisitFinding{
+	{VisitFindingWeight, FhirSystemLoinc, "29463-7", "Body weight", "kg"},
+	{VisitFindingBloodPressure, FhirSystemLoinc, "85354-9", "Blood pressure panel", ""},
+	{VisitFindingFundalHeight, FhirSystemLoinc, "11881-0", "Uterus Fundal height by Tape measure", "cm"},
+	{VisitFindingGestationalAge, FhirSystemLoinc, "49051-6", "Gestational age in weeks", "wk"},
+	{VisitFindingFetalHeart, FhirSystemLoinc, "55283-6", "Fetal Heart rate", ""},
+	{VisitFindingFetalMovement, FhirLocalCodes, VisitFindingFetalMovement, "Fetal movement", ""},
+	{VisitFindingUrineProtein, FhirSystemLoinc, "20454-5", "Protein [Presence] in Urine by Test strip", ""},
+	{VisitFindingUrineSugar, FhirSystemLoinc, "25428-4", "Glucose [Presence] in Urine by Test strip", ""},
+	{VisitFindingSwelling, FhirLocalCodes, VisitFindingSwelling, "Edema", ""},
+}
+
+const (
+	loincSystolic       = "8480-6"
+	loincDiastolic      = "8462-4"
+	snomedPregnancy     = "77386006"
+	snomedPrenatal      = "424525001" // Antenatal care
+	snomedPrenatalVisit = "424619006"
+)
+
+var bloodPressurePattern = regexp.MustCompile(`^\s*(\d{2,3})\s*/\s*(\d{2,3})\s*$`)
+
+// fhirInterpretation maps lab flags to v3 ObservationInterpretation codes
+var fhirInterpretation = map[string]string{
+	entity.LabFlagNormal:   "N",
+	entity.LabFlagLow:      "L",
+	entity.LabFlagHigh:     "H",
+	entity.LabFlagAbnormal: "A",
+}
+
+func fhirID(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+func fhirInt(n int) *int {
+	return &n
+}
+
+// FhirPatient maps a mother to a Patient
+func FhirPatient(w entity.PregnantWoman) FhirResource {
+	res := FhirResource{
+		ResourceType: "Patient",
+		ID:           fhirID(w.ID),
+		Identifier:   []FhirIdentifier{FhirLocalIdentifier("patient", w.ID)},
+		Gender:       "female",
+		BirthDate:    FhirDate(w.BirthDate),
+	}
+	if w.CitizenID != "" {
+		res.Identifier = append(res.Identifier, FhirIdentifier{System: FhirSystemThaiCID, Value: w.CitizenID})
+	}
+	if w.HN != "" {
+		res.Identifier = append(res.Identifier, FhirIdentifier{
+			Type:   fhirConcept(FhirSystemV2IDType, "MR", "Medical record number"),
+			System: FhirIdentifierBase + "hn",
+			Value:  w.HN,
+		})
+	}
+	if w.FullName != "" {
+		res.Name = []FhirHumanName{{Text: w.FullName}}
+	}
+	if w.PhoneNumber != "" {
+		res.Telecom = append(res.Telecom, FhirContactPoint{System: "phone", Value: w.PhoneNumber})
+	}
+	if w.Email != "" {
+		res.Telecom = append(res.Telecom, FhirContactPoint{System: "email", Value: w.Email})
+	}
+	return res
+}
+
+// FhirPatientKeys returns the citizen id and hospital number of a Patient.
+// Any identifier typed MR is taken as the hospital number.
+func FhirPatientKeys(res FhirResource) (cid, hn string) {
+	for _, identifier := range res.Identifier {
+		switch {
+		case identifier.System == FhirSystemThaiCID:
+			cid = identifier.Value
+		case identifier.System == FhirIdentifierBase+"hn" || HasFhirCoding(identifier.Type, FhirSystemV2IDType, "MR"):
+			if hn == "" {
+				hn = identifier.Value
+			}
+		}
+	}
+	return cid, hn
+}
+
+// ApplyFhirPatient copies the demographics of a Patient onto a mother
+func ApplyFhirPatient(res FhirResource, w *entity.PregnantWoman) error {
+	if res.Gender != "" && res.Gender != "female" {
+		return errors.New("Patient must be female")
+	}
+	cid, hn := FhirPatientKeys(res)
+	if cid != "" {
+		w.CitizenID = cid
+	}
+	if hn != "" {
+		w.HN = hn
+	}
+	for _, name := range res.Name {
+		full := name.Text
+		if full == "" {
+			full = strings.TrimSpace(strings.Join(name.Given, " ") + " " + name.Family)
+		}
+		if full != "" {
+			w.FullName = full
+			break
+		}
+	}
+	for _, contact := range res.Telecom {
+		switch contact.System {
+		case "phone":
+			w.PhoneNumber = contact.Value
+		case "email":
+			w.Email = contact.Value
+		}
+	}
+	if res.BirthDate != "" {
+		birth, err := ParseFhirDateTime(res.BirthDate)
+		if err != nil {
+			return err
+		}
+		w.BirthDate = birth
+	}
+	return nil
+}
+
+// FhirPregnancy maps a pregnancy to an EpisodeOfCare of antenatal care and the Condition of being pregnant
+func FhirPregnancy(p entity.Pregnancy, patient *FhirReference) (episode, condition FhirResource) {
+	conditionID := "pregnancy-" + fhirID(p.ID)
+	active := p.Status == entity.PregnancyStatusActive
+
+	episode = FhirResource{
+		ResourceType: "EpisodeOfCare",
+		ID:           fhirID(p.ID),
+		Identifier:   []FhirIdentifier{FhirLocalIdentifier("pregnancy", p.ID)},
+		Status:       "finished",
+		Extension: []FhirExtension{
+			{URL: FhirExtensionBase + "pregnancy-status", ValueCode: p.Status},
+			{URL: FhirExtensionBase + "fetus-count", ValueInteger: fhirInt(max(p.FetusCount, 1))},
+		},
+		Type:      []FhirCodeableConcept{*fhirConcept(FhirSystemSnomed, snomedPrenatal, "Antenatal care")},
+		Patient:   patient,
+		Diagnosis: []FhirDiagnosis{{Condition: *FhirReferenceTo("Condition", conditionID)}},
+		Period:    &FhirPeriod{Start: FhirDate(PregnancyLMP(p))},
+	}
+	if active {
+		episode.Status = "active"
+	}
+	if p.PregnancyNo > 0 {
+		episode.Extension = append(episode.Extension, FhirExtension{URL: FhirExtensionBase + "pregnancy-no", ValueInteger: fhirInt(p.PregnancyNo)})
+	}
+	if !p.EDC.IsZero() {
+		episode.Extension = append(episode.Extension, FhirExtension{URL: FhirExtensionBase + "edc", ValueDate: FhirDate(p.EDC)})
+	}
+	if p.OutcomeDate != nil {
+		episode.Period.End = FhirDate(*p.OutcomeDate)
+	}
+
+	clinical := "resolved"
+	if active {
+		clinical = "active"
+	}
+	condition = FhirResource{
+		ResourceType:      "Condition",
+		ID:                conditionID,
+		Identifier:        []FhirIdentifier{FhirLocalIdentifier("pregnancy", p.ID)},
+		ClinicalStatus:    fhirConcept(fhirSystemConditionStatus, clinical, ""),
+		Code:              fhirConcept(FhirSystemSnomed, snomedPregnancy, "Pregnancy"),
+		Subject:           patient,
+		OnsetDateTime:     FhirDate(PregnancyLMP(p)),
+		AbatementDateTime: episode.Period.End,
+	}
+	return episode, condition
+}
+
+// ApplyFhirPregnancy copies an EpisodeOfCare (and its pregnancy Condition, if any) onto a pregnancy.
+// The status is only set on new pregnancies; existing ones change status through their lifecycle.
+func ApplyFhirPregnancy(episode FhirResource, condition *FhirResource, p *entity.Pregnancy) error {
+	start := ""
+	end := ""
+	if episode.Period != nil {
+		start, end = episode.Period.Start, episode.Period.End
+	}
+	if condition != nil {
+		if start == "" {
+			start = condition.OnsetDateTime
+		}
+		if end == "" {
+			end = condition.AbatementDateTime
+		}
+	}
+
+	lmp, err := ParseFhirDateTime(start)
+	if err != nil {
+		return err
+	}
+	if !lmp.IsZero() {
+		p.LMP = lmp
+	}
+	if ext, ok := FhirExtensionValue(episode, "edc"); ok {
+		edc, err := ParseFhirDateTime(ext.ValueDate)
+		if err != nil {
+			return err
+		}
+		p.EDC = edc
+	}
+	if p.EDC.IsZero() && !p.LMP.IsZero() {
+		p.EDC = p.LMP.AddDate(0, 0, 280)
+	}
+	if p.LMP.IsZero() && p.EDC.IsZero() {
+		return errors.New("EpisodeOfCare needs a period start (LMP) or an EDC extension")
+	}
+	if ext, ok := FhirExtensionValue(episode, "pregnancy-no"); ok && ext.ValueInteger != nil {
+		p.PregnancyNo = *ext.ValueInteger
+	}
+	if ext, ok := FhirExtensionValue(episode, "fetus-count"); ok && ext.ValueInteger != nil {
+		p.FetusCount = *ext.ValueInteger
+	}
+	if end != "" {
+		outcome, err := ParseFhirDateTime(end)
+		if err != nil {
+			return err
+		}
+		p.OutcomeDate = &outcome
+	}
+
+	if p.ID == 0 {
+		status := entity.PregnancyStatusActive
+		if ext, ok := FhirExtensionValue(episode, "pregnancy-status"); ok && entity.IsPregnancyStatus(ext.ValueCode) {
+			status = ext.ValueCode
+		} else if episode.Status == "finished" {
+			status = entity.PregnancyStatusDelivered
+		}
+		p.Status = status
+	}
+	return nil
+}
+
+// FhirAntenatalVisit maps a visit to an Encounter and one Observation per recorded finding
+func FhirAntenatalVisit(v entity.AntenatalVisit, patient *FhirReference) (encounter FhirResource, observations []FhirResource) {
+	encounterID := fhirID(v.ID)
+	encounter = FhirResource{
+		ResourceType: "Encounter",
+		ID:           encounterID,
+		Identifier:   []FhirIdentifier{FhirLocalIdentifier("antenatal-visit", v.ID)},
+		Status:       "finished",
+		Class:        &FhirCoding{System: FhirSystemActCode, Code: "AMB", Display: "ambulatory"},
+		Type:         []FhirCodeableConcept{*fhirConcept(FhirSystemSnomed, snomedPrenatalVisit, "Prenatal visit")},
+		Subject:      patient,
+		Period:       &FhirPeriod{Start: FhirDateTime(v.VisitDate)},
+	}
+	if v.PregnancyID != nil {
+		encounter.EpisodeOfCare = []FhirReference{*FhirReferenceTo("EpisodeOfCare", fhirID(*v.PregnancyID))}
+	}
+	if v.MedicalDiagnosis != "" {
+		encounter.Extension = []FhirExtension{{URL: FhirExtensionBase + "medical-diagnosis", ValueString: v.MedicalDiagnosis}}
+	}
+
+	status := "preliminary"
+	if v.RecordStatus == entity.RecordStatusSigned {
+		status = "final"
+	}
+	for _, finding := range fhirVisitFindings {
+		obs := FhirResource{
+			ResourceType:      "Observation",
+			ID:                "visit-" + encounterID + "-" + finding.key,
+			Status:            status,
+			Category:          []FhirCodeableConcept{*fhirConcept(fhirSystemObsCategory, "exam", "Exam")},
+			Code:              fhirConcept(finding.system, finding.code, finding.display),
+			Subject:           patient,
+			Encounter:         FhirReferenceTo("Encounter", encounterID),
+			EffectiveDateTime: FhirDateTime(v.VisitDate),
+		}
+		switch finding.key {
+		case VisitFindingWeight:
+			if v.Weight <= 0 {
+				continue
+			}
+			obs.Category = []FhirCodeableConcept{*fhirConcept(fhirSystemObsCategory, "vital-signs", "Vital Signs")}
+			obs.ValueQuantity = fhirQuantity(v.Weight, finding.unit)
+		case VisitFindingBloodPressure:
+			if v.BloodPressure == "" {
+				continue
+			}
+			obs.Category = []FhirCodeableConcept{*fhirConcept(fhirSystemObsCategory, "vital-signs", "Vital Signs")}
+			if m := bloodPressurePattern.FindStringSubmatch(v.BloodPressure); m != nil {
+				systolic, _ := strconv.ParseFloat(m[1], 64)
+				diastolic, _ := strconv.ParseFloat(m[2], 64)
+				obs.Component = []FhirComponent{
+					{Code: *fhirConcept(FhirSystemLoinc, loincSystolic, "Systolic blood pressure"), ValueQuantity: fhirQuantity(systolic, "mm[Hg]")},
+					{Code: *fhirConcept(FhirSystemLoinc, loincDiastolic, "Diastolic blood pressure"), ValueQuantity: fhirQuantity(diastolic, "mm[Hg]")},
+				}
+			} else {
+				obs.ValueString = v.BloodPressure
+			}
+		case VisitFindingFundalHeight:
+			if v.HeightFundus <= 0 {
+				continue
+			}
+			obs.ValueQuantity = fhirQuantity(v.HeightFundus, finding.unit)
+		case VisitFindingGestationalAge:
+			if v.GestationalAge <= 0 {
+				continue
+			}
+			obs.ValueQuantity = fhirQuantity(float64(v.GestationalAge), finding.unit)
+		default:
+			text := visitFindingText(v, finding.key)
+			if text == "" {
+				continue
+			}
+			obs.ValueString = text
+		}
+		observations = append(observations, obs)
+	}
+	return encounter, observations
+}
+
+func fhirQuantity(value float64, unit string) *FhirQuantity {
+	return &FhirQuantity{Value: value, Unit: unit, System: FhirSystemUcum, Code: unit}
+}
+
+// visitFindingText returns the free-text findings of a visit by key
+func visitFindingText(v entity.AntenatalVisit, key string) string {
+	switch key {
+	case VisitFindingFetalHeart:
+		return v.FetalHeartSound
+	case VisitFindingFetalMovement:
+		return v.FetalMovement
+	case VisitFindingUrineProtein:
+		return v.UrineProtein
+	case VisitFindingUrineSugar:
+		return v.UrineSugar
+	case VisitFindingSwelling:
+		return v.Swelling
+	}
+	return ""
+}
+
+// visitFindingKey finds which visit finding an Observation code stands for
+func visitFindingKey(code *FhirCodeableConcept) string {
+	for _, finding := range fhirVisitFindings {
+		if HasFhirCoding(code, finding.system, finding.code) {
+			return finding.key
+		}
+	}
+	return ""
+}
+
+// ApplyFhirEncounter copies an Encounter and the Observations that reference it onto a visit
+func ApplyFhirEncounter(encounter FhirResource, observations []FhirResource, v *entity.AntenatalVisit) error {
+	if encounter.Period == nil || encounter.Period.Start == "" {
+		return errors.New("Encounter needs period.start")
+	}
+	visitDate, err := ParseFhirDateTime(encounter.Period.Start)
+	if err != nil {
+		return err
+	}
+	v.VisitDate = visitDate
+	if ext, ok := FhirExtensionValue(encounter, "medical-diagnosis"); ok {
+		v.MedicalDiagnosis = ext.ValueString
+	}
+
+	for _, obs := range observations {
+		key := visitFindingKey(obs.Code)
+		number := 0.0
+		if obs.ValueQuantity != nil {
+			number = obs.ValueQuantity.Value
+		}
+		text := obs.ValueString
+		if text == "" && obs.ValueQuantity != nil {
+			text = strconv.FormatFloat(number, 'f', -1, 64)
+		}
+
+		switch key {
+		case VisitFindingWeight:
+			v.Weight = number
+		case VisitFindingFundalHeight:
+			v.HeightFundus = number
+		case VisitFindingGestationalAge:
+			v.GestationalAge = int(number)
+		case VisitFindingBloodPressure:
+			var systolic, diastolic float64
+			for _, component := range obs.Component {
+				if component.ValueQuantity == nil {
+					continue
+				}
+				if HasFhirCoding(&component.Code, FhirSystemLoinc, loincSystolic) {
+					systolic = component.ValueQuantity.Value
+				}
+				if HasFhirCoding(&component.Code, FhirSystemLoinc, loincDiastolic) {
+					diastolic = component.ValueQuantity.Value
+				}
+			}
+			if systolic > 0 && diastolic > 0 {
+				v.BloodPressure = fmt.Sprintf("%.0f/%.0f", systolic, diastolic)
+			} else if obs.ValueString != "" {
+				v.BloodPressure = obs.ValueString
+			}
+		case VisitFindingFetalHeart:
+			v.FetalHeartSound = text
+		case VisitFindingFetalMovement:
+			v.FetalMovement = text
+		case VisitFindingUrineProtein:
+			v.UrineProtein = text
+		case VisitFindingUrineSugar:
+			v.UrineSugar = text
+		case VisitFindingSwelling:
+			v.Swelling = text
+		}
+	}
+	return nil
+}
+
+// FhirLabResult maps a lab result to a DiagnosticReport with one Observation per catalog test
+func FhirLabResult(r entity.LabResult, patient *FhirReference) (report FhirResource, observations []FhirResource) {
+	status := "preliminary"
+	if r.RecordStatus == entity.RecordStatusSigned {
+		status = "final"
+	}
+	report = FhirResource{
+		ResourceType:      "DiagnosticReport",
+		ID:                fhirID(r.ID),
+		Identifier:        []FhirIdentifier{FhirLocalIdentifier("lab-result", r.ID)},
+		Status:            status,
+		Category:          []FhirCodeableConcept{*fhirConcept("http://terminology.hl7.org/CodeSystem/v2-0074", "LAB", "Laboratory")},
+		Code:              &FhirCodeableConcept{Text: "Antenatal laboratory results"},
+		Subject:           patient,
+		EffectiveDateTime: FhirDate(r.TestDate),
+		Conclusion:        r.OtherRemarks,
+	}
+	if r.PregnancyID != nil {
+		// DiagnosticReport has no episode element, so the pregnancy is carried in an extension
+		report.Extension = []FhirExtension{{URL: FhirExtensionBase + "pregnancy", ValueReference: FhirReferenceTo("EpisodeOfCare", fhirID(*r.PregnancyID))}}
+	}
+
+	for _, o := range r.Observations {
+		display := o.Code
+		if o.LabTest != nil && o.LabTest.Name != "" {
+			display = o.LabTest.Name
+		}
+		obs := FhirResource{
+			ResourceType:      "Observation",
+			ID:                "lab-" + fhirID(o.ID),
+			Status:            status,
+			Category:          []FhirCodeableConcept{*fhirConcept(fhirSystemObsCategory, "laboratory", "Laboratory")},
+			Code:              fhirConcept(FhirLabTestCodes, o.Code, display),
+			Subject:           patient,
+			EffectiveDateTime: report.EffectiveDateTime,
+		}
+		if o.ValueNumber != nil {
+			obs.ValueQuantity = &FhirQuantity{Value: *o.ValueNumber, Unit: o.Unit}
+		} else {
+			obs.ValueString = o.ValueText
+		}
+		if code, ok := fhirInterpretation[o.Flag]; ok {
+			obs.Interpretation = []FhirCodeableConcept{*fhirConcept(fhirSystemInterpretation, code, o.Flag)}
+		}
+		if o.ReferenceText != "" {
+			obs.ReferenceRange = []FhirReferenceRange{{Text: o.ReferenceText}}
+		}
+		report.Result = append(report.Result, *FhirReferenceTo("Observation", obs.ID))
+		observations = append(observations, obs)
+	}
+	return report, observations
+}
+
+// FhirLabValue is one catalog test value read from an imported Observation
+type FhirLabValue struct {
+	Code  string
+	Value interface{}
+}
+
+// FhirLabValues reads the test date and the catalog values of a DiagnosticReport and its result Observations.
+// Flags are not imported; they are evaluated again against the local reference ranges.
+func FhirLabValues(report FhirResource, observations []FhirResource) (time.Time, []FhirLabValue, error) {
+	testDate, err := ParseFhirDateTime(report.EffectiveDateTime)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	if testDate.IsZero() {
+		return time.Time{}, nil, errors.New("DiagnosticReport needs effectiveDateTime")
+	}
+
+	var values []FhirLabValue
+	for _, obs := range observations {
+		code := FhirCodeIn(obs.Code, FhirLabTestCodes)
+		if code == "" {
+			return time.Time{}, nil, errors.New("Observation " + obs.ID + " has no " + FhirLabTestCodes + " code")
+		}
+		var value interface{} = obs.ValueString
+		if obs.ValueQuantity != nil {
+			value = obs.ValueQuantity.Value
+		}
+		values = append(values, FhirLabValue{Code: code, Value: value})
+	}
+	return testDate, values, nil
+}
+
+// FhirVaccination maps a vaccination record to one Immunization per dose given during pregnancy,
+// or a not-done Immunization when the mother was not vaccinated
+func FhirVaccination(v entity.Vaccination, patient *FhirReference) []FhirResource {
+	vaccine := &FhirCodeableConcept{Text: "Vaccine"}
+	if v.VaccineType != nil {
+		vaccine = &FhirCodeableConcept{Text: v.VaccineType.Name}
+		if v.VaccineType.Code != "" {
+			vaccine.Coding = []FhirCoding{{System: FhirVaccineCodes, Code: v.VaccineType.Code, Display: v.VaccineType.Name}}
+		}
+	}
+
+	base := FhirResource{
+		ResourceType: "Immunization",
+		Identifier:   []FhirIdentifier{FhirLocalIdentifier("vaccination", v.ID)},
+		VaccineCode:  vaccine,
+		Patient:      patient,
+	}
+	if v.Remarks != "" {
+		base.Note = []FhirAnnotation{{Text: v.Remarks}}
+	}
+
+	var immunizations []FhirResource
+	for i, date := range []*time.Time{v.Dose1DateDuringPreg, v.Dose2DateDuringPreg, v.Dose3DateDuringPreg} {
+		if date == nil || date.IsZero() {
+			continue
+		}
+		res := base
+		res.ID = fhirID(v.ID) + "-" + strconv.Itoa(i+1)
+		res.Status = "completed"
+		res.OccurrenceDateTime = FhirDate(*date)
+		res.ProtocolApplied = []FhirProtocolApplied{{DoseNumberPositiveInt: i + 1}}
+		immunizations = append(immunizations, res)
+	}
+	if len(immunizations) == 0 && v.ReasonForNotVaccinating != "" {
+		res := base
+		res.ID = fhirID(v.ID) + "-not-done"
+		res.Status = "not-done"
+		res.StatusReason = &FhirCodeableConcept{Text: v.ReasonForNotVaccinating}
+		res.OccurrenceDateTime = FhirDateTime(v.CreatedAt)
+		immunizations = append(immunizations, res)
+	}
+	return immunizations
+}
+
+// ApplyFhirImmunization records an Immunization on a vaccination record: a dose date by its dose
+// number (or the next free dose), or the reason it was not given
+func ApplyFhirImmunization(res FhirResource, v *entity.Vaccination) error {
+	if res.Status == "not-done" {
+		v.ReasonForNotVaccinating = FhirConceptText(res.StatusReason)
+		if v.ReasonForNotVaccinating == "" {
+			v.ReasonForNotVaccinating = "not done"
+		}
+		return nil
+	}
+
+	date, err := ParseFhirDateTime(res.OccurrenceDateTime)
+	if err != nil {
+		return err
+	}
+	if date.IsZero() {
+		return errors.New("Immunization needs occurrenceDateTime")
+	}
+
+	doses := []**time.Time{&v.Dose1DateDuringPreg, &v.Dose2DateDuringPreg, &v.Dose3DateDuringPreg}
+	dose := 0
+	if len(res.ProtocolApplied) > 0 {
+		dose = res.ProtocolApplied[0].DoseNumberPositiveInt
+	}
+	if dose == 0 {
+		// ไม่ระบุเข็มที่ ใช้ช่องแรกที่ว่างหรือที่เป็นวันเดียวกัน
+		for i, slot := range doses {
+			if *slot == nil || (*slot).Equal(date) {
+				dose = i + 1
+				break
+			}
+		}
+	}
+	if dose < 1 || dose > len(doses) {
+		return errors.New("Immunization dose number must be between 1 and 3")
+	}
+	*doses[dose-1] = &date
+	for _, note := range res.Note {
+		if note.Text != "" {
+			v.Remarks = note.Text
+		}
+	}
+	return nil
+}
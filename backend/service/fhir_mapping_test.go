@@ -0,0 +1,187 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+)
+
+func fhirTestDate(value string) time.Time {
+	t, _ := time.Parse("2006-01-02", value)
+	return t
+}
+
+func TestFhirPatientRoundTrip(t *testing.T) {
+	w := entity.PregnantWoman{FullName: "สมหญิง ใจดี", CitizenID: "1103700012345", HN: "HN-0042",
+		PhoneNumber: "0812345678", Email: "somying@example.com", BirthDate: fhirTestDate("1995-03-14")}
+	w.ID = 42
+
+	var got entity.PregnantWoman
+	if err := ApplyFhirPatient(FhirPatient(w), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.FullName != w.FullName || got.CitizenID != w.CitizenID || got.HN != w.HN ||
+		got.PhoneNumber != w.PhoneNumber || got.Email != w.Email || !got.BirthDate.Equal(w.BirthDate) {
+		t.Fatalf("round trip = %+v", got)
+	}
+
+	male := FhirPatient(w)
+	male.Gender = "male"
+	if err := ApplyFhirPatient(male, &got); err == nil {
+		t.Fatal("a male Patient was accepted")
+	}
+}
+
+func TestFhirPregnancyRoundTrip(t *testing.T) {
+	outcome := fhirTestDate("2025-01-20")
+	tests := []struct {
+		name string
+		p    entity.Pregnancy
+	}{
+		{"active twins", entity.Pregnancy{PregnancyNo: 2, Status: entity.PregnancyStatusActive, FetusCount: 2,
+			LMP: fhirTestDate("2026-05-01"), EDC: fhirTestDate("2027-02-05")}},
+		{"delivered", entity.Pregnancy{PregnancyNo: 1, Status: entity.PregnancyStatusDelivered, FetusCount: 1,
+			LMP: fhirTestDate("2024-04-15"), EDC: fhirTestDate("2025-01-20"), OutcomeDate: &outcome}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.p.ID = 7
+			episode, condition := FhirPregnancy(tt.p, FhirReferenceTo("Patient", "42"))
+
+			var got entity.Pregnancy
+			if err := ApplyFhirPregnancy(episode, &condition, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != tt.p.Status || got.PregnancyNo != tt.p.PregnancyNo || got.FetusCount != tt.p.FetusCount ||
+				!got.LMP.Equal(tt.p.LMP) || !got.EDC.Equal(tt.p.EDC) {
+				t.Fatalf("round trip = %+v", got)
+			}
+			if (got.OutcomeDate == nil) != (tt.p.OutcomeDate == nil) || (got.OutcomeDate != nil && !got.OutcomeDate.Equal(*tt.p.OutcomeDate)) {
+				t.Fatalf("outcome date = %v, want %v", got.OutcomeDate, tt.p.OutcomeDate)
+			}
+		})
+	}
+}
+
+func TestFhirPregnancyKeepsStatusOfExistingRecord(t *testing.T) {
+	delivered := entity.Pregnancy{Status: entity.PregnancyStatusDelivered, LMP: fhirTestDate("2024-04-15")}
+	episode, _ := FhirPregnancy(delivered, nil)
+
+	existing := entity.Pregnancy{Status: entity.PregnancyStatusActive}
+	existing.ID = 3
+	if err := ApplyFhirPregnancy(episode, nil, &existing); err != nil {
+		t.Fatal(err)
+	}
+	if existing.Status != entity.PregnancyStatusActive {
+		t.Fatalf("status of an existing pregnancy changed to %s", existing.Status)
+	}
+}
+
+func TestFhirAntenatalVisitRoundTrip(t *testing.T) {
+	pregnancyID := uint(7)
+	v := entity.AntenatalVisit{PregnancyID: &pregnancyID, VisitDate: time.Date(2026, 8, 3, 9, 30, 0, 0, time.UTC),
+		GestationalAge: 13, Weight: 58.5, BloodPressure: "118/76", HeightFundus: 12, FetalHeartSound: "148",
+		FetalMovement: "Present", UrineProtein: "Negative", UrineSugar: "Trace", Swelling: "None",
+		MedicalDiagnosis: "Normal pregnancy"}
+	v.ID = 11
+
+	encounter, observations := FhirAntenatalVisit(v, FhirReferenceTo("Patient", "42"))
+	if len(observations) != len(fhirVisitFindings) {
+		t.Fatalf("%d observations, want one per finding", len(observations))
+	}
+
+	got := entity.AntenatalVisit{}
+	if err := ApplyFhirEncounter(encounter, observations, &got); err != nil {
+		t.Fatal(err)
+	}
+	got.Model, got.PregnancyID = v.Model, v.PregnancyID
+	if got.VisitDate.Equal(v.VisitDate) {
+		got.VisitDate = v.VisitDate
+	}
+	if !reflect.DeepEqual(got, v) {
+		t.Fatalf("round trip = %+v\nwant %+v", got, v)
+	}
+}
+
+func TestFhirAntenatalVisitSkipsEmptyFindings(t *testing.T) {
+	v := entity.AntenatalVisit{VisitDate: fhirTestDate("2026-08-03"), BloodPressure: "not measured"}
+	_, observations := FhirAntenatalVisit(v, nil)
+	if len(observations) != 1 || observations[0].ValueString != "not measured" {
+		t.Fatalf("observations = %+v", observations)
+	}
+
+	var got entity.AntenatalVisit
+	if err := ApplyFhirEncounter(FhirResource{ResourceType: "Encounter"}, nil, &got); err == nil {
+		t.Fatal("an Encounter without period.start was accepted")
+	}
+}
+
+func TestFhirLabResultRoundTrip(t *testing.T) {
+	hb := 10.2
+	r := entity.LabResult{TestDate: fhirTestDate("2026-07-10"), OtherRemarks: "repeat in 4 weeks",
+		RecordStatus: entity.RecordStatusSigned, Observations: []entity.LabObservation{
+			{Code: "HB", ValueNumber: &hb, Unit: "g/dL", Flag: entity.LabFlagLow, ReferenceText: ">= 11 g/dL"},
+			{Code: "ANTI_HIV", ValueText: "Negative", Flag: entity.LabFlagNormal},
+		}}
+	r.Observations[0].ID, r.Observations[1].ID = 1, 2
+
+	report, observations := FhirLabResult(r, FhirReferenceTo("Patient", "42"))
+	if report.Status != "final" || len(report.Result) != 2 || report.Conclusion != r.OtherRemarks {
+		t.Fatalf("report = %+v", report)
+	}
+	if observations[0].Interpretation[0].Coding[0].Code != "L" {
+		t.Fatalf("HB interpretation = %+v", observations[0].Interpretation)
+	}
+
+	testDate, values, err := FhirLabValues(report, observations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !testDate.Equal(r.TestDate) {
+		t.Fatalf("test date = %v", testDate)
+	}
+	want := []FhirLabValue{{Code: "HB", Value: 10.2}, {Code: "ANTI_HIV", Value: "Negative"}}
+	if len(values) != len(want) || values[0] != want[0] || values[1] != want[1] {
+		t.Fatalf("values = %+v, want %+v", values, want)
+	}
+
+	observations[1].Code = &FhirCodeableConcept{Text: "Anti-HIV"}
+	if _, _, err := FhirLabValues(report, observations); err == nil {
+		t.Fatal("an Observation without a catalog code was accepted")
+	}
+}
+
+func TestFhirVaccinationRoundTrip(t *testing.T) {
+	dose1, dose2 := fhirTestDate("2026-06-01"), fhirTestDate("2026-07-01")
+	v := entity.Vaccination{Dose1DateDuringPreg: &dose1, Dose2DateDuringPreg: &dose2, Remarks: "left arm",
+		VaccineType: &entity.VaccineType{Name: "dT", Code: "DT"}}
+	v.ID = 5
+
+	immunizations := FhirVaccination(v, FhirReferenceTo("Patient", "42"))
+	if len(immunizations) != 2 {
+		t.Fatalf("%d Immunizations, want one per dose", len(immunizations))
+	}
+	var got entity.Vaccination
+	for _, res := range immunizations {
+		if err := ApplyFhirImmunization(res, &got); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got.Dose1DateDuringPreg == nil || !got.Dose1DateDuringPreg.Equal(dose1) ||
+		got.Dose2DateDuringPreg == nil || !got.Dose2DateDuringPreg.Equal(dose2) ||
+		got.Dose3DateDuringPreg != nil || got.Remarks != v.Remarks {
+		t.Fatalf("round trip = %+v", got)
+	}
+
+	refused := entity.Vaccination{ReasonForNotVaccinating: "declined"}
+	immunizations = FhirVaccination(refused, nil)
+	if len(immunizations) != 1 || immunizations[0].Status != "not-done" {
+		t.Fatalf("not vaccinated = %+v", immunizations)
+	}
+	got = entity.Vaccination{}
+	if err := ApplyFhirImmunization(immunizations[0], &got); err != nil || got.ReasonForNotVaccinating != "declined" {
+		t.Fatalf("reason = %q, %v", got.ReasonForNotVaccinating, err)
+	}
+}
@@ -0,0 +1,239 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+)
+
+// Follow-up rules
+const (
+	FollowUpGrace         = 7 * 24 * time.Hour // ผ่อนผันก่อนนับว่าค้าง
+	MissedAppointmentDays = 90                 // ขาดนัดที่เก่ากว่านี้ไม่ต้องตามแล้ว
+	dTDose2Interval       = 4 * 7 * 24 * time.Hour
+	dTDose3Interval       = 6 * 30 * 24 * time.Hour
+	dTBoosterYears        = 10
+	dTStartWeek           = 12 // เริ่มฉีด dT ได้ตั้งแต่ไตรมาสแรกที่มาฝากครรภ์
+)
+
+// Outreach methods and outcomes accepted when recording an attempt
+var (
+	OutreachMethods  = []string{"call", "message", "sms", "home_visit"}
+	OutreachOutcomes = []string{"reached", "no_answer", "wrong_number", "rescheduled", "declined"}
+)
+
+// LabPanel is a set of tests expected by a gestational age
+type LabPanel struct {
+	Code     string
+	Name     string
+	FromWeek int // ผลแลปนับได้ตั้งแต่อายุครรภ์นี้
+	DueWeek  int // ต้องมีผลภายในอายุครรภ์นี้
+}
+
+// ExpectedLabPanels follow the Thai ANC guideline: booking labs and a repeat in the third trimester
+var ExpectedLabPanels = []LabPanel{
+	{Code: "booking", Name: "แลปฝากครรภ์ครั้งแรก", FromWeek: 0, DueWeek: 20},
+	{Code: "third_trimester", Name: "แลปซ้ำไตรมาสที่ 3", FromWeek: 28, DueWeek: 34},
+}
+
+// VisitIntervalWeeks is the expected gap between ANC visits at a gestational age
+func VisitIntervalWeeks(gestationalWeeks int) int {
+	switch {
+	case gestationalWeeks >= 36:
+		return 1
+	case gestationalWeeks >= 28:
+		return 2
+	default:
+		return 4
+	}
+}
+
+// VisitDueDate is when the next visit is expected after the last one (or after the pregnancy was opened).
+// gapWeeks > 0 overrides the gestational-age schedule.
+func VisitDueDate(lmp, lastVisit time.Time, gapWeeks int) time.Time {
+	weeks := gapWeeks
+	if weeks <= 0 {
+		weeks = VisitIntervalWeeks(GestationalWeeks(lmp, lastVisit))
+	}
+	return lastVisit.AddDate(0, 0, weeks*7)
+}
+
+// IsOverdue reports whether a due date has passed by more than the grace period
+func IsOverdue(due, now time.Time) bool {
+	return now.After(due.Add(FollowUpGrace))
+}
+
+// IsDTVaccine tells whether a vaccine type is tetanus-diphtheria, by its code.
+// Td is the same vaccine under its other abbreviation.
+func IsDTVaccine(code string) bool {
+	code = strings.TrimSpace(code)
+	return strings.EqualFold(code, entity.VaccineCodeDT) || strings.EqualFold(code, "Td")
+}
+
+// CurrentDTVaccination picks the dT record of the pregnancy that started at lmp: the latest one
+// with a dose given on or after lmp, or recorded on or after lmp when it has no doses yet.
+// Records of earlier pregnancies are skipped, so their doses do not count for this one.
+func CurrentDTVaccination(vaccinations []entity.Vaccination, lmp time.Time) *entity.Vaccination {
+	var current *entity.Vaccination
+	for i := range vaccinations {
+		v := &vaccinations[i]
+		if v.VaccineType == nil || !IsDTVaccine(v.VaccineType.Code) {
+			continue
+		}
+		during := false
+		given := false
+		for _, d := range []*time.Time{v.Dose1DateDuringPreg, v.Dose2DateDuringPreg, v.Dose3DateDuringPreg} {
+			if d != nil {
+				given = true
+				during = during || !d.Before(lmp)
+			}
+		}
+		if !given {
+			during = !v.CreatedAt.Before(lmp)
+		}
+		if during && (current == nil || !v.CreatedAt.Before(current.CreatedAt)) {
+			current = v
+		}
+	}
+	return current
+}
+
+// NextDTDose returns the next dT dose a mother needs during pregnancy and when it is due.
+// Fully immunised mothers (3+ doses, last within 10 years) need none.
+func NextDTDose(v *entity.Vaccination, lmp, now time.Time) (dose int, due time.Time, ok bool) {
+	start := lmp.AddDate(0, 0, dTStartWeek*7)
+	if v == nil {
+		return 1, start, true
+	}
+	if v.ReasonForNotVaccinating != "" {
+		return 0, time.Time{}, false
+	}
+
+	required := 3
+	if v.IsPreviouslyVaccinated && !v.IsHistoryUnknown {
+		switch {
+		case v.PreviousDoses >= 3:
+			recent := v.LastPreviousDateYear != nil && v.LastPreviousDateYear.AddDate(dTBoosterYears, 0, 0).After(now)
+			if recent {
+				return 0, time.Time{}, false
+			}
+			required = 1
+		default:
+			required = 3 - v.PreviousDoses
+		}
+	}
+
+	given := []*time.Time{v.Dose1DateDuringPreg, v.Dose2DateDuringPreg, v.Dose3DateDuringPreg}
+	for i := 0; i < required && i < len(given); i++ {
+		if given[i] != nil {
+			continue
+		}
+		switch {
+		case i == 0:
+			return 1, start, true
+		case i == 1:
+			return 2, given[0].Add(dTDose2Interval), true
+		default:
+			return 3, given[1].Add(dTDose3Interval), true
+		}
+	}
+	return 0, time.Time{}, false
+}
+
+// labRequirements are the tests of a panel; any one of the codes satisfies a requirement
+var labRequirements = []struct {
+	Label string
+	Codes []string
+}{
+	{Label: "Hb/Hct", Codes: []string{LabTestHb, LabTestHct}},
+	{Label: "Anti-HIV", Codes: []string{LabTestAntiHIV}},
+	{Label: "DCIP/Hb typing", Codes: []string{LabTestDcip, LabTestHbTyping}},
+}
+
+// MissingLabTests lists the tests of a panel not found in the observations of the given lab results
+func MissingLabTests(labs []entity.LabResult) []string {
+	var found []string
+	for _, l := range labs {
+		for _, o := range l.Observations {
+			if o.ValueNumber != nil || o.ValueText != "" {
+				found = append(found, o.Code)
+			}
+		}
+	}
+	var missing []string
+	for _, req := range labRequirements {
+		done := false
+		for _, code := range req.Codes {
+			done = done || contains(found, code)
+		}
+		if !done {
+			missing = append(missing, req.Label)
+		}
+	}
+	return missing
+}
+
+// PanelMissingTests returns the missing tests of a panel, using only results taken in its window.
+// Thalassaemia screening is needed once, so the repeat panel does not ask for it.
+func PanelMissingTests(panel LabPanel, lmp time.Time, labs []entity.LabResult) []string {
+	from := lmp.AddDate(0, 0, panel.FromWeek*7)
+	var inWindow []entity.LabResult
+	for _, l := range labs {
+		if panel.FromWeek == 0 || !l.TestDate.Before(from) {
+			inWindow = append(inWindow, l)
+		}
+	}
+	missing := MissingLabTests(inWindow)
+	if panel.FromWeek > 0 {
+		var repeat []string
+		for _, m := range missing {
+			if m != "DCIP/Hb typing" {
+				repeat = append(repeat, m)
+			}
+		}
+		missing = repeat
+	}
+	return missing
+}
+
+// PlanItemFollowUpKey is the follow-up item key of a clinical note plan item
+func PlanItemFollowUpKey(planItemID uint) string {
+	return fmt.Sprintf("plan:%d", planItemID)
+}
+
+// EpdsFollowUpKey is the follow-up item key of a positive EPDS screen
+func EpdsFollowUpKey(screeningID uint) string {
+	return fmt.Sprintf("epds:%d", screeningID)
+}
+
+// EpdsAlertDetail describes a positive EPDS screen on the worklist
+func EpdsAlertDetail(s entity.EpdsScreening) string {
+	detail := fmt.Sprintf("EPDS %d คะแนน (%s)", s.TotalScore, s.RiskLevel)
+	if s.SelfHarmScore > 0 {
+		detail += " มีความคิดทำร้ายตนเอง"
+	}
+	return detail
+}
+
+// ValidateOutreach checks an outreach attempt
+func ValidateOutreach(method, outcome string) error {
+	if !contains(OutreachMethods, method) {
+		return errors.New("method must be one of " + strings.Join(OutreachMethods, ", "))
+	}
+	if outcome != "" && !contains(OutreachOutcomes, outcome) {
+		return errors.New("outcome must be one of " + strings.Join(OutreachOutcomes, ", "))
+	}
+	return nil
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
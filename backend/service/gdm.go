@@ -0,0 +1,263 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+)
+
+// GDM risk thresholds
+const (
+	GdmRiskAge         = 30
+	GdmRiskBMI         = 27.0
+	GdmMacrosomiaKg    = 4.0
+	GdmScreenFromWeeks = 24
+	GdmScreenToWeeks   = 28
+)
+
+// gdmLimits are the plasma glucose limits (fasting, 1 h, 2 h, 3 h) in mg/dL and the
+// number of values at or above them needed for a positive result
+var gdmLimits = map[string]struct {
+	test     string
+	limits   [4]float64
+	required int
+}{
+	entity.GdmCriteriaGCT140:           {entity.GdmTestGCT50, [4]float64{0, 140, 0, 0}, 1},
+	entity.GdmCriteriaGCT130:           {entity.GdmTestGCT50, [4]float64{0, 130, 0, 0}, 1},
+	entity.GdmCriteriaIADPSG:           {entity.GdmTestOGTT75, [4]float64{92, 180, 153, 0}, 1},
+	entity.GdmCriteriaCarpenterCoustan: {entity.GdmTestOGTT100, [4]float64{95, 180, 155, 140}, 2},
+	entity.GdmCriteriaNDDG:             {entity.GdmTestOGTT100, [4]float64{105, 190, 165, 145}, 2},
+}
+
+// defaultGdmCriteria is used when the doctor does not choose one
+var defaultGdmCriteria = map[string]string{
+	entity.GdmTestGCT50:   entity.GdmCriteriaGCT140,
+	entity.GdmTestOGTT75:  entity.GdmCriteriaIADPSG,
+	entity.GdmTestOGTT100: entity.GdmCriteriaCarpenterCoustan,
+}
+
+// IsGdmDiagnosticTest reports whether a test type can diagnose GDM (OGTT) rather than screen (GCT)
+func IsGdmDiagnosticTest(testType string) bool {
+	return testType == entity.GdmTestOGTT75 || testType == entity.GdmTestOGTT100
+}
+
+// InterpretGdmTest fills Criteria, Result, AbnormalValues and Interpretation of a test
+func InterpretGdmTest(s *entity.GdmScreening) error {
+	if _, ok := defaultGdmCriteria[s.TestType]; !ok {
+		return errors.New("test_type must be GCT50, OGTT75 or OGTT100")
+	}
+	if s.Criteria == "" {
+		s.Criteria = defaultGdmCriteria[s.TestType]
+	}
+	rule, ok := gdmLimits[s.Criteria]
+	if !ok || rule.test != s.TestType {
+		return fmt.Errorf("criteria %s cannot be used with %s", s.Criteria, s.TestType)
+	}
+
+	values := [4]*float64{s.Fasting, s.OneHour, s.TwoHour, s.ThreeHour}
+	names := [4]string{"fasting", "one_hour", "two_hour", "three_hour"}
+	var abnormal []string
+	s.AbnormalValues = 0
+	for i, limit := range rule.limits {
+		if limit == 0 {
+			continue
+		}
+		if values[i] == nil {
+			return errors.New(names[i] + " is required for " + s.TestType)
+		}
+		if *values[i] <= 0 || *values[i] > 700 {
+			return errors.New(names[i] + " must be in mg/dL")
+		}
+		if *values[i] >= limit {
+			s.AbnormalValues++
+			abnormal = append(abnormal, fmt.Sprintf("%s %g ≥ %g", names[i], *values[i], limit))
+		}
+	}
+
+	positive := s.AbnormalValues >= rule.required
+	switch {
+	case positive && s.TestType == entity.GdmTestGCT50:
+		s.Result = entity.GdmResultPositive
+		s.Interpretation = "Screen positive (" + strings.Join(abnormal, ", ") + "); diagnostic OGTT required"
+	case positive:
+		s.Result = entity.GdmResultPositive
+		s.Interpretation = "Gestational diabetes by " + s.Criteria + " (" + strings.Join(abnormal, ", ") + ")"
+	case s.TestType == entity.GdmTestGCT50:
+		s.Result = entity.GdmResultNegative
+		s.Interpretation = "Screen negative"
+	default:
+		s.Result = entity.GdmResultNegative
+		s.Interpretation = fmt.Sprintf("No GDM by %s (%d abnormal value(s), %d needed)", s.Criteria, s.AbnormalValues, rule.required)
+	}
+	return nil
+}
+
+// GdmRiskInput is what the risk assessment looks at
+type GdmRiskInput struct {
+	Woman            entity.PregnantWoman
+	Pregnancy        entity.Pregnancy
+	MedicalHistories []entity.MedicalHistory
+	Obstetric        []entity.ObstetricHistory
+	OtherPregnancies []entity.Pregnancy
+	Visits           []entity.AntenatalVisit
+}
+
+// GdmRiskFactors lists the risk factors that call for early GDM screening at booking
+func GdmRiskFactors(in GdmRiskInput) []string {
+	factors := []string{}
+
+	age := in.Woman.Age
+	if !in.Woman.BirthDate.IsZero() {
+		at := PregnancyLMP(in.Pregnancy)
+		if at.IsZero() {
+			at = time.Now()
+		}
+		age = at.Year() - in.Woman.BirthDate.Year()
+		if at.YearDay() < in.Woman.BirthDate.YearDay() {
+			age--
+		}
+	}
+	if age >= GdmRiskAge {
+		factors = append(factors, fmt.Sprintf("age %d", age))
+	}
+
+	bmi := in.Pregnancy.PrePregnancyBMI
+	if bmi == 0 && in.Pregnancy.Height > 0 && in.Pregnancy.PrePregnancyWeight > 0 {
+		m := in.Pregnancy.Height / 100
+		bmi = in.Pregnancy.PrePregnancyWeight / (m * m)
+	}
+	if bmi >= GdmRiskBMI {
+		factors = append(factors, fmt.Sprintf("pre-pregnancy BMI %.1f", bmi))
+	}
+
+	for _, h := range in.MedicalHistories {
+		if h.FamilyHistoryDiabetes {
+			factors = append(factors, "family history of diabetes")
+			break
+		}
+	}
+
+	priorGdm, macrosomia, stillbirth := false, false, false
+	for _, h := range in.Obstetric {
+		if mentionsDiabetes(h.Complications) {
+			priorGdm = true
+		}
+		if h.BirthWeight >= GdmMacrosomiaKg {
+			macrosomia = true
+		}
+		if h.Outcome == entity.ObstetricOutcomeStillbirth {
+			stillbirth = true
+		}
+	}
+	for _, p := range in.OtherPregnancies {
+		if p.ID != in.Pregnancy.ID && p.GdmDiagnosed {
+			priorGdm = true
+		}
+	}
+	if priorGdm {
+		factors = append(factors, "previous gestational diabetes")
+	}
+	if macrosomia {
+		factors = append(factors, "previous baby of 4 kg or more")
+	}
+	if stillbirth {
+		factors = append(factors, "previous stillbirth")
+	}
+
+	for _, v := range in.Visits {
+		if isGlycosuria(v.UrineSugar) {
+			factors = append(factors, "glycosuria")
+			break
+		}
+	}
+	return factors
+}
+
+func mentionsDiabetes(text string) bool {
+	text = strings.ToLower(text)
+	return strings.Contains(text, "gdm") || strings.Contains(text, "diabet") || strings.Contains(text, "เบาหวาน")
+}
+
+func isGlycosuria(urineSugar string) bool {
+	s := strings.ToLower(strings.TrimSpace(urineSugar))
+	switch s {
+	case "", "-", "neg", "negative", "trace", "nil", "ปกติ":
+		return false
+	}
+	return strings.Contains(s, "+") || strings.Contains(s, "pos")
+}
+
+// GdmNextStep describes what the screening workflow expects next
+type GdmNextStep struct {
+	Step   string `json:"step"`
+	DueNow bool   `json:"due_now"`
+}
+
+// NextGdmStep works out the next step from the risk, the gestational age and the tests done
+// so far (oldest first)
+func NextGdmStep(highRisk bool, weeks int, diagnosed bool, tests []entity.GdmScreening) GdmNextStep {
+	if diagnosed {
+		return GdmNextStep{Step: "GDM diagnosed: home glucose monitoring and follow-up plan"}
+	}
+
+	var lastGCT *entity.GdmScreening
+	diagnosticAfterGCT := false
+	screenedInWindow := false
+	for i := range tests {
+		t := &tests[i]
+		if t.TestType == entity.GdmTestGCT50 {
+			lastGCT = t
+			diagnosticAfterGCT = false
+		} else if IsGdmDiagnosticTest(t.TestType) {
+			diagnosticAfterGCT = true
+		}
+		if t.GestationalWeeks >= GdmScreenFromWeeks {
+			screenedInWindow = true
+		}
+	}
+
+	if lastGCT != nil && lastGCT.Result == entity.GdmResultPositive && !diagnosticAfterGCT {
+		return GdmNextStep{Step: "Positive GCT: diagnostic 100 g OGTT", DueNow: true}
+	}
+	if screenedInWindow {
+		return GdmNextStep{Step: "Screening complete"}
+	}
+	if highRisk && len(tests) == 0 {
+		return GdmNextStep{Step: "High risk: early screening at booking", DueNow: true}
+	}
+	if weeks >= GdmScreenFromWeeks {
+		if weeks > GdmScreenToWeeks {
+			return GdmNextStep{Step: "Overdue: screen now (due at 24-28 weeks)", DueNow: true}
+		}
+		return GdmNextStep{Step: "Screen at 24-28 weeks", DueNow: true}
+	}
+	if len(tests) > 0 {
+		return GdmNextStep{Step: "Early screen negative: repeat at 24-28 weeks"}
+	}
+	return GdmNextStep{Step: "Screen at 24-28 weeks"}
+}
+
+// GdmFollowUpPlan is the care plan that starts with a GDM diagnosis
+type GdmFollowUpPlan struct {
+	SelfMonitoring string                  `json:"self_monitoring"`
+	Targets        entity.MonitoringTarget `json:"targets"`
+	Actions        []string                `json:"actions"`
+}
+
+// BuildGdmPlan returns the follow-up plan for a diagnosed pregnancy
+func BuildGdmPlan(target entity.MonitoringTarget) GdmFollowUpPlan {
+	return GdmFollowUpPlan{
+		SelfMonitoring: "Capillary glucose 4 times a day: fasting and 1 or 2 hours after each main meal",
+		Targets:        target,
+		Actions: []string{
+			"Refer to dietitian for medical nutrition therapy",
+			"Review home glucose log every 1-2 weeks",
+			"Start insulin if targets are not met after 1-2 weeks of diet",
+			"Fetal growth ultrasound in the third trimester",
+			"75 g OGTT at 4-12 weeks postpartum",
+		},
+	}
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+)
+
+// Gestational age limits (weeks) used for the GTPAL classification
+const (
+	AbortionMaxWeeks = 20
+	TermMinWeeks     = 37
+)
+
+// ComputeGTPAL derives the GTPAL summary from the obstetric history rows and the
+// mother's current pregnancies. Multiple babies of the same pregnancy count once
+// for G, T, P and A but separately for L.
+func ComputeGTPAL(histories []entity.ObstetricHistory, pregnancies []entity.Pregnancy) entity.GTPAL {
+	var summary entity.GTPAL
+
+	type pregnancyResult struct {
+		gestationalAge int
+		loss           bool
+	}
+	results := map[string]*pregnancyResult{}
+	var order []string
+
+	for _, h := range histories {
+		// babies of one pregnancy share its ID or its number; a legacy row
+		// with neither is a pregnancy of its own
+		key := fmt.Sprintf("row:%d", h.ID)
+		switch {
+		case h.PregnancyID != nil:
+			key = fmt.Sprintf("id:%d", *h.PregnancyID)
+		case h.PregnancyNo > 0:
+			key = fmt.Sprintf("no:%d", h.PregnancyNo)
+		}
+		r, ok := results[key]
+		if !ok {
+			r = &pregnancyResult{}
+			results[key] = r
+			order = append(order, key)
+		}
+		if h.GestationalAge > r.gestationalAge {
+			r.gestationalAge = h.GestationalAge
+		}
+		if IsPregnancyLoss(h) {
+			r.loss = true
+		}
+
+		if h.Outcome == entity.ObstetricOutcomeLiveBirth && h.ChildStatus != "Deceased" {
+			summary.Living++
+		}
+	}
+
+	for _, key := range order {
+		r := results[key]
+		switch {
+		case r.loss:
+			summary.Abortion++
+		case r.gestationalAge >= TermMinWeeks:
+			summary.Term++
+		case r.gestationalAge > 0:
+			summary.Preterm++
+		default:
+			// Unknown gestational age of a birth is counted as term
+			summary.Term++
+		}
+	}
+
+	summary.Gravida = len(order)
+	for _, p := range pregnancies {
+		// Pregnancies not yet in the history (e.g. the current one) still count toward G
+		if _, ok := results[fmt.Sprintf("id:%d", p.ID)]; !ok {
+			summary.Gravida++
+		}
+	}
+
+	return summary
+}
+
+// IsPregnancyLoss reports whether the record ended before viability
+func IsPregnancyLoss(h entity.ObstetricHistory) bool {
+	switch h.Outcome {
+	case entity.ObstetricOutcomeMiscarriage, entity.ObstetricOutcomeTermination, entity.ObstetricOutcomeEctopic:
+		return true
+	}
+	return h.GestationalAge > 0 && h.GestationalAge < AbortionMaxWeeks
+}
+
+// InferObstetricOutcome guesses the outcome of legacy records that did not store one
+func InferObstetricOutcome(gestationalAge int, childStatus string) string {
+	if gestationalAge > 0 && gestationalAge < AbortionMaxWeeks {
+		return entity.ObstetricOutcomeMiscarriage
+	}
+	if childStatus == "Stillbirth" {
+		return entity.ObstetricOutcomeStillbirth
+	}
+	return entity.ObstetricOutcomeLiveBirth
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+)
+
+func gtpalTestRow(id uint, pregnancyID uint, pregnancyNo, weeks int, outcome string) entity.ObstetricHistory {
+	h := entity.ObstetricHistory{PregnancyNo: pregnancyNo, GestationalAge: weeks, Outcome: outcome}
+	h.ID = id
+	if pregnancyID > 0 {
+		h.PregnancyID = &pregnancyID
+	}
+	return h
+}
+
+func TestComputeGTPAL(t *testing.T) {
+	live, loss := entity.ObstetricOutcomeLiveBirth, entity.ObstetricOutcomeMiscarriage
+	current := entity.Pregnancy{Status: entity.PregnancyStatusActive}
+	current.ID = 9
+
+	tests := []struct {
+		name        string
+		histories   []entity.ObstetricHistory
+		pregnancies []entity.Pregnancy
+		want        entity.GTPAL
+	}{
+		{"first pregnancy", nil, []entity.Pregnancy{current}, entity.GTPAL{Gravida: 1}},
+		{"term, preterm and a miscarriage", []entity.ObstetricHistory{
+			gtpalTestRow(1, 0, 1, 39, live), gtpalTestRow(2, 0, 2, 34, live), gtpalTestRow(3, 0, 3, 10, loss),
+		}, []entity.Pregnancy{current}, entity.GTPAL{Gravida: 4, Term: 1, Preterm: 1, Abortion: 1, Living: 2}},
+		{"twins by pregnancy number", []entity.ObstetricHistory{
+			gtpalTestRow(1, 0, 1, 36, live), gtpalTestRow(2, 0, 1, 36, live),
+		}, nil, entity.GTPAL{Gravida: 1, Preterm: 1, Living: 2}},
+		{"twins by pregnancy record", []entity.ObstetricHistory{
+			gtpalTestRow(1, 5, 0, 38, live), gtpalTestRow(2, 5, 0, 38, entity.ObstetricOutcomeStillbirth),
+		}, nil, entity.GTPAL{Gravida: 1, Term: 1, Living: 1}},
+		{"legacy rows without a pregnancy number stay apart", []entity.ObstetricHistory{
+			gtpalTestRow(1, 0, 0, 39, live), gtpalTestRow(2, 0, 0, 12, loss), gtpalTestRow(3, 0, 0, 0, live),
+		}, nil, entity.GTPAL{Gravida: 3, Term: 2, Abortion: 1, Living: 2}},
+		{"current pregnancy already in the history", []entity.ObstetricHistory{
+			gtpalTestRow(1, 9, 2, 8, loss),
+		}, []entity.Pregnancy{current}, entity.GTPAL{Gravida: 1, Abortion: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ComputeGTPAL(tt.histories, tt.pregnancies); got != tt.want {
+				t.Fatalf("GTPAL = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,315 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HL7 v2 acknowledgment codes (MSA-1)
+const (
+	Hl7AckAccept = "AA" // stored; filed or queued for reconciliation
+	Hl7AckError  = "AE" // could not be processed, the sender may retry
+	Hl7AckReject = "AR" // malformed or unsupported, retrying will not help
+)
+
+// Hl7ApplicationName is sent as MSH-3 of our acknowledgments when the sender did not address one
+const Hl7ApplicationName = "PROJECTEIEI"
+
+// MLLP framing bytes: <VT> message <FS><CR>
+const (
+	mllpStart = 0x0b
+	mllpEnd   = 0x1c
+	mllpCR    = 0x0d
+)
+
+// Hl7Message is a parsed HL7 v2 message (ER7 encoding)
+type Hl7Message struct {
+	Segments []Hl7Segment
+
+	field, component, repetition, escape, subcomponent byte
+}
+
+// Hl7Segment keeps the raw fields of a segment. Fields[n] is field n, so for MSH
+// Fields[1] is the field separator and Fields[2] the encoding characters.
+type Hl7Segment struct {
+	Name   string
+	Fields []string
+
+	msg *Hl7Message
+}
+
+// ParseHl7 splits a message into segments using the separators declared in MSH
+func ParseHl7(raw string) (*Hl7Message, error) {
+	raw = strings.TrimLeft(raw, "\x0b\r\n \t")
+	if !strings.HasPrefix(raw, "MSH") || len(raw) < 8 {
+		return nil, errors.New("message must start with an MSH segment")
+	}
+	msg := &Hl7Message{
+		field:        raw[3],
+		component:    '^',
+		repetition:   '~',
+		escape:       '\\',
+		subcomponent: '&',
+	}
+	encoding := raw[4 : strings.IndexByte(raw[4:]+string(msg.field), msg.field)+4]
+	for i, target := range []*byte{&msg.component, &msg.repetition, &msg.escape, &msg.subcomponent} {
+		if i < len(encoding) {
+			*target = encoding[i]
+		}
+	}
+
+	// Segments end with <CR>; senders that use <LF> or <CR><LF> are accepted as well
+	raw = strings.ReplaceAll(raw, "\r\n", "\r")
+	raw = strings.ReplaceAll(raw, "\n", "\r")
+	for _, line := range strings.Split(raw, "\r") {
+		line = strings.TrimRight(line, "\x1c")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, string(msg.field))
+		if len(fields[0]) != 3 {
+			return nil, fmt.Errorf("invalid segment %q", line)
+		}
+		if fields[0] == "MSH" {
+			fields = append([]string{"MSH", string(msg.field)}, fields[1:]...)
+		}
+		msg.Segments = append(msg.Segments, Hl7Segment{Name: fields[0], Fields: fields, msg: msg})
+	}
+	return msg, nil
+}
+
+// Segment returns the first segment with the given name
+func (m *Hl7Message) Segment(name string) (Hl7Segment, bool) {
+	for _, s := range m.Segments {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return Hl7Segment{}, false
+}
+
+// Field returns a field with its components still encoded
+func (s Hl7Segment) Field(n int) string {
+	if n < len(s.Fields) {
+		return s.Fields[n]
+	}
+	return ""
+}
+
+// Repetitions splits a repeating field
+func (s Hl7Segment) Repetitions(n int) []string {
+	if s.Name == "MSH" && n <= 2 {
+		return []string{s.Field(n)}
+	}
+	value := s.Field(n)
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, string(s.msg.repetition))
+}
+
+// Value returns the decoded text of field n, component c (1-based) of its first repetition
+func (s Hl7Segment) Value(n, c int) string {
+	if s.Name == "MSH" && n <= 2 {
+		return s.Field(n)
+	}
+	reps := s.Repetitions(n)
+	if len(reps) == 0 {
+		return ""
+	}
+	return s.msg.Component(reps[0], c)
+}
+
+// Component returns component c (1-based) of an encoded field value, decoded.
+// Subcomponents are kept joined by a space.
+func (m *Hl7Message) Component(value string, c int) string {
+	components := strings.Split(value, string(m.component))
+	if c < 1 || c > len(components) {
+		return ""
+	}
+	parts := strings.Split(components[c-1], string(m.subcomponent))
+	for i := range parts {
+		parts[i] = m.unescape(parts[i])
+	}
+	return strings.TrimSpace(strings.Join(parts, " "))
+}
+
+// unescape decodes \F\ \S\ \T\ \R\ \E\ \.br\ and \Xhh\; other escapes are dropped
+func (m *Hl7Message) unescape(value string) string {
+	esc := string(m.escape)
+	if !strings.Contains(value, esc) {
+		return value
+	}
+	var out strings.Builder
+	for {
+		start := strings.Index(value, esc)
+		if start < 0 {
+			out.WriteString(value)
+			break
+		}
+		end := strings.Index(value[start+1:], esc)
+		if end < 0 {
+			out.WriteString(value)
+			break
+		}
+		out.WriteString(value[:start])
+		seq := value[start+1 : start+1+end]
+		value = value[start+2+end:]
+
+		switch {
+		case seq == "F":
+			out.WriteByte(m.field)
+		case seq == "S":
+			out.WriteByte(m.component)
+		case seq == "T":
+			out.WriteByte(m.subcomponent)
+		case seq == "R":
+			out.WriteByte(m.repetition)
+		case seq == "E":
+			out.WriteByte(m.escape)
+		case seq == ".br":
+			out.WriteByte('\n')
+		case strings.HasPrefix(seq, "X"):
+			for i := 1; i+1 < len(seq); i += 2 {
+				if b, err := strconv.ParseUint(seq[i:i+2], 16, 8); err == nil {
+					out.WriteByte(byte(b))
+				}
+			}
+		}
+	}
+	return out.String()
+}
+
+// escapeHl7 encodes the delimiters of a value written into one of our segments
+func escapeHl7(value string) string {
+	value = strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+	return strings.NewReplacer(`\`, `\E\`, "|", `\F\`, "^", `\S\`, "&", `\T\`, "~", `\R\`).Replace(value)
+}
+
+// Hl7Header is what the listener needs from MSH to file and acknowledge a message
+type Hl7Header struct {
+	SendingApplication   string
+	SendingFacility      string
+	ReceivingApplication string
+	ReceivingFacility    string
+	MessageType          string // e.g. ORU^R01
+	ControlID            string
+	ProcessingID         string
+	Version              string
+	SentAt               time.Time
+}
+
+// Header reads MSH
+func (m *Hl7Message) Header() Hl7Header {
+	msh, _ := m.Segment("MSH")
+	header := Hl7Header{
+		SendingApplication:   msh.Value(3, 1),
+		SendingFacility:      msh.Value(4, 1),
+		ReceivingApplication: msh.Value(5, 1),
+		ReceivingFacility:    msh.Value(6, 1),
+		MessageType:          strings.TrimSuffix(msh.Value(9, 1)+"^"+msh.Value(9, 2), "^"),
+		ControlID:            msh.Value(10, 1),
+		ProcessingID:         msh.Value(11, 1),
+		Version:              msh.Value(12, 1),
+	}
+	header.SentAt, _ = ParseHl7Time(msh.Value(7, 1))
+	return header
+}
+
+// ParseHl7Time reads an HL7 TS/DTM value (YYYY[MM[DD[HH[MM[SS[.S]]]]]][+/-ZZZZ]).
+// Values without an offset are in the server's local time zone.
+func ParseHl7Time(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	zone := ""
+	if i := strings.IndexAny(value, "+-"); i > 0 {
+		value, zone = value[:i], value[i:]
+	}
+	if i := strings.IndexByte(value, '.'); i > 0 {
+		value = value[:i]
+	}
+	layouts := map[int]string{4: "2006", 6: "200601", 8: "20060102", 10: "2006010215", 12: "200601021504", 14: "20060102150405"}
+	layout, ok := layouts[len(value)]
+	if !ok {
+		return time.Time{}, errors.New("invalid HL7 time " + value)
+	}
+	if zone != "" {
+		return time.Parse(layout+"-0700", value+zone)
+	}
+	return time.ParseInLocation(layout, value, time.Local)
+}
+
+// FormatHl7Time writes a DTM with seconds and offset
+func FormatHl7Time(t time.Time) string {
+	return t.Format("20060102150405-0700")
+}
+
+// Hl7Ack builds the acknowledgment for a received message. The original
+// header may be empty when the message could not be parsed.
+func Hl7Ack(original Hl7Header, code, text string, now time.Time) string {
+	application := original.ReceivingApplication
+	if application == "" {
+		application = Hl7ApplicationName
+	}
+	processing := original.ProcessingID
+	if processing == "" {
+		processing = "P"
+	}
+	version := original.Version
+	if version == "" {
+		version = "2.5.1"
+	}
+	trigger := "R01"
+	if parts := strings.Split(original.MessageType, "^"); len(parts) == 2 && parts[1] != "" {
+		trigger = parts[1]
+	}
+
+	msh := []string{
+		"MSH", `^~\&`,
+		escapeHl7(application), escapeHl7(original.ReceivingFacility),
+		escapeHl7(original.SendingApplication), escapeHl7(original.SendingFacility),
+		FormatHl7Time(now), "", "ACK^" + escapeHl7(trigger) + "^ACK",
+		"ACK" + strconv.FormatInt(now.UnixNano(), 36), processing, version,
+	}
+	msa := []string{"MSA", code, escapeHl7(original.ControlID), escapeHl7(text)}
+	return strings.Join(msh, "|") + "\r" + strings.Join(msa, "|") + "\r"
+}
+
+// ReadMllpFrame reads one <VT>message<FS><CR> frame. Bytes before <VT> are skipped.
+func ReadMllpFrame(r *bufio.Reader, maxBytes int) ([]byte, error) {
+	if _, err := r.ReadBytes(mllpStart); err != nil {
+		return nil, err
+	}
+	var frame bytes.Buffer
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b == mllpEnd {
+			if next, err := r.Peek(1); err == nil && next[0] == mllpCR {
+				r.ReadByte()
+			}
+			return frame.Bytes(), nil
+		}
+		if frame.Len() >= maxBytes {
+			return nil, fmt.Errorf("MLLP frame larger than %d bytes", maxBytes)
+		}
+		frame.WriteByte(b)
+	}
+}
+
+// MllpFrame wraps a message for sending over MLLP
+func MllpFrame(message string) []byte {
+	frame := make([]byte, 0, len(message)+3)
+	frame = append(frame, mllpStart)
+	frame = append(frame, message...)
+	return append(frame, mllpEnd, mllpCR)
+}
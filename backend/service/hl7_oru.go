@@ -0,0 +1,284 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+)
+
+// Hl7OruPatient is one patient of an ORU^R01 with the orders resulted for her
+type Hl7OruPatient struct {
+	HNs    []string      `json:"hns"`
+	CIDs   []string      `json:"cids"`
+	Name   string        `json:"name"`
+	Orders []Hl7OruOrder `json:"orders"`
+}
+
+// Hl7OruOrder is an OBR with its OBX results
+type Hl7OruOrder struct {
+	PlacerNumber string           `json:"placer_number"` // OBR-2 / ORC-2, our lab order ID when the LIS received the order from us
+	FillerNumber string           `json:"filler_number"` // OBR-3, the LIS accession number
+	Service      string           `json:"service"`       // OBR-4
+	ObservedAt   time.Time        `json:"observed_at"`   // OBR-7, falls back to the first OBX-14
+	Notes        []string         `json:"notes"`         // NTE
+	Observations []Hl7Observation `json:"observations"`
+}
+
+// Hl7Observation is one OBX
+type Hl7Observation struct {
+	Code           string `json:"code"`     // OBX-3.1
+	Text           string `json:"text"`     // OBX-3.2
+	AltCode        string `json:"alt_code"` // OBX-3.4
+	ValueType      string `json:"value_type"`
+	Value          string `json:"value"`
+	ValueCode      string `json:"value_code"` // OBX-5.1 of a coded value
+	Unit           string `json:"unit"`
+	ReferenceRange string `json:"reference_range"`
+	AbnormalFlags  string `json:"abnormal_flags"`
+	ResultStatus   string `json:"result_status"` // F final, C corrected, P preliminary, X cannot be obtained, D / W delete
+}
+
+// Skipped reports whether the OBX carries no result to file
+func (o Hl7Observation) Skipped() bool {
+	switch o.ResultStatus {
+	case "X", "D", "W":
+		return true
+	}
+	return strings.TrimSpace(o.Value) == ""
+}
+
+// Identifier types of PID-3 that carry the hospital number or the Thai citizen ID
+var (
+	hl7HNTypes  = []string{"MR", "PI", "PT", "HN"}
+	hl7CIDTypes = []string{"NI", "NN", "NNTHA", "CZ", "CID"}
+)
+
+// ParseOruR01 reads the patients, orders and results of an ORU^R01
+func ParseOruR01(msg *Hl7Message) ([]Hl7OruPatient, error) {
+	header := msg.Header()
+	if header.MessageType != "ORU^R01" {
+		return nil, errors.New("unsupported message type " + header.MessageType + "; only ORU^R01 is accepted")
+	}
+
+	var patients []Hl7OruPatient
+	var patient *Hl7OruPatient
+	var order *Hl7OruOrder
+	placer := ""
+	for _, s := range msg.Segments {
+		switch s.Name {
+		case "PID":
+			patients = append(patients, parseHl7Patient(s))
+			patient = &patients[len(patients)-1]
+			order = nil
+		case "ORC":
+			placer = s.Value(2, 1)
+		case "OBR":
+			if patient == nil {
+				return nil, errors.New("OBR before PID")
+			}
+			observedAt, err := ParseHl7Time(s.Value(7, 1))
+			if err != nil {
+				return nil, fmt.Errorf("OBR-7: %v", err)
+			}
+			o := Hl7OruOrder{
+				PlacerNumber: s.Value(2, 1),
+				FillerNumber: s.Value(3, 1),
+				Service:      firstNonEmpty(s.Value(4, 2), s.Value(4, 1)),
+				ObservedAt:   observedAt,
+			}
+			if o.PlacerNumber == "" {
+				o.PlacerNumber = placer
+			}
+			patient.Orders = append(patient.Orders, o)
+			order = &patient.Orders[len(patient.Orders)-1]
+			placer = ""
+		case "OBX":
+			if order == nil {
+				return nil, errors.New("OBX before OBR")
+			}
+			obs := Hl7Observation{
+				Code:           s.Value(3, 1),
+				Text:           s.Value(3, 2),
+				AltCode:        s.Value(3, 4),
+				ValueType:      s.Value(2, 1),
+				Value:          hl7ObservationValue(s),
+				ValueCode:      hl7ObservationCode(s),
+				Unit:           s.Value(6, 1),
+				ReferenceRange: s.Value(7, 1),
+				AbnormalFlags:  s.Value(8, 1),
+				ResultStatus:   s.Value(11, 1),
+			}
+			if obs.Code == "" && obs.AltCode == "" {
+				return nil, errors.New("OBX-3 observation identifier is required")
+			}
+			if order.ObservedAt.IsZero() {
+				order.ObservedAt, _ = ParseHl7Time(s.Value(14, 1))
+			}
+			order.Observations = append(order.Observations, obs)
+		case "NTE":
+			if order != nil {
+				if text := s.Value(3, 1); text != "" {
+					order.Notes = append(order.Notes, text)
+				}
+			}
+		}
+	}
+	if len(patients) == 0 {
+		return nil, errors.New("message has no PID segment")
+	}
+	for _, p := range patients {
+		if len(p.HNs) == 0 && len(p.CIDs) == 0 {
+			return nil, errors.New("PID has no hospital number or citizen ID")
+		}
+		if len(p.Orders) == 0 {
+			return nil, errors.New("PID has no OBR segment")
+		}
+	}
+	return patients, nil
+}
+
+// parseHl7Patient sorts the PID-3 identifiers into hospital numbers and citizen IDs.
+// Untyped identifiers are citizen IDs when they look like one, hospital numbers otherwise.
+func parseHl7Patient(s Hl7Segment) Hl7OruPatient {
+	p := Hl7OruPatient{Name: strings.TrimSpace(s.Value(5, 2) + " " + s.Value(5, 1))}
+	add := func(value, idType string) {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return
+		}
+		idType = strings.ToUpper(idType)
+		switch {
+		case contains(hl7CIDTypes, idType) || (idType == "" && IsThaiCitizenID(value)):
+			if !contains(p.CIDs, value) {
+				p.CIDs = append(p.CIDs, value)
+			}
+		case contains(hl7HNTypes, idType) || idType == "":
+			if !contains(p.HNs, value) {
+				p.HNs = append(p.HNs, value)
+			}
+		}
+	}
+	for _, rep := range s.Repetitions(3) {
+		add(s.msg.Component(rep, 1), s.msg.Component(rep, 5))
+	}
+	// PID-2 (external ID) and PID-19 (SSN) were used for the same purpose before v2.5
+	add(s.Value(2, 1), "")
+	if cid := s.Value(19, 1); IsThaiCitizenID(cid) {
+		add(cid, "NI")
+	}
+	return p
+}
+
+// hl7ObservationValue returns OBX-5. Coded values (CE, CWE) use their text when present.
+func hl7ObservationValue(s Hl7Segment) string {
+	switch s.Value(2, 1) {
+	case "CE", "CWE", "CNE":
+		return firstNonEmpty(s.Value(5, 2), s.Value(5, 1))
+	case "SN":
+		// Structured numeric: comparator^num1^separator^num2, e.g. ^12.5 or <^5
+		return strings.TrimSpace(s.Value(5, 1) + s.Value(5, 2) + s.Value(5, 3) + s.Value(5, 4))
+	}
+	var values []string
+	for _, rep := range s.Repetitions(5) {
+		if v := s.msg.Component(rep, 1); v != "" {
+			values = append(values, v)
+		}
+	}
+	return strings.Join(values, "\n")
+}
+
+// hl7ObservationCode returns the code of a coded OBX-5, tried when its text matches no catalog choice
+func hl7ObservationCode(s Hl7Segment) string {
+	switch s.Value(2, 1) {
+	case "CE", "CWE", "CNE":
+		return s.Value(5, 1)
+	}
+	return ""
+}
+
+// IsThaiCitizenID checks the length and the check digit of a 13-digit citizen ID
+func IsThaiCitizenID(value string) bool {
+	if len(value) != 13 {
+		return false
+	}
+	sum := 0
+	for i := 0; i < 13; i++ {
+		if value[i] < '0' || value[i] > '9' {
+			return false
+		}
+		if i < 12 {
+			sum += int(value[i]-'0') * (13 - i)
+		}
+	}
+	return (11-sum%11)%10 == int(value[12]-'0')
+}
+
+// MatchLabTest finds the catalog test of an OBX by its code or alternate code,
+// either equal to the catalog code or listed in the test's external codes
+func MatchLabTest(tests []entity.LabTest, obs Hl7Observation) (entity.LabTest, bool) {
+	for _, code := range []string{obs.Code, obs.AltCode} {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if code == "" {
+			continue
+		}
+		for _, test := range tests {
+			if test.Code == code || contains(SplitList(test.ExternalCodes), code) {
+				return test, true
+			}
+		}
+	}
+	return entity.LabTest{}, false
+}
+
+// Hl7LabValue fits an OBX value to a catalog test. Choice values are matched by
+// the text or the code of the value ignoring case, spaces and hyphens, then by
+// the usual positive / negative wording and abbreviations (NEG, NR).
+func Hl7LabValue(test entity.LabTest, obs Hl7Observation) (string, error) {
+	if unit := strings.TrimSpace(obs.Unit); test.ValueType == entity.LabValueNumeric && unit != "" && test.Unit != "" &&
+		!strings.EqualFold(strings.ReplaceAll(unit, " ", ""), strings.ReplaceAll(test.Unit, " ", "")) {
+		return "", fmt.Errorf("%s: unit %s does not match the catalog unit %s", test.Code, unit, test.Unit)
+	}
+	value := strings.TrimSpace(obs.Value)
+	if test.ValueType != entity.LabValueChoice {
+		return value, nil
+	}
+
+	squash := func(s string) string {
+		return strings.ToLower(strings.NewReplacer(" ", "", "-", "", "_", "").Replace(s))
+	}
+	candidates := []string{value, obs.ValueCode}
+	for _, v := range []string{value, obs.ValueCode} {
+		candidates = append(candidates, NormalizeCheckResult(v), hl7ChoiceAbbreviations[squash(v)])
+	}
+	for _, candidate := range candidates {
+		for _, choice := range SplitList(test.Choices) {
+			if candidate != "" && squash(choice) == squash(candidate) {
+				return choice, nil
+			}
+		}
+	}
+	return "", errors.New(test.Code + ": value " + value + " is not one of " + test.Choices)
+}
+
+// hl7ChoiceAbbreviations are the short result words analyzers commonly send
+var hl7ChoiceAbbreviations = map[string]string{
+	"neg":     "Negative",
+	"pos":     "Positive",
+	"nr":      "Non-reactive",
+	"r":       "Reactive",
+	"react":   "Reactive",
+	"nonreac": "Non-reactive",
+	"ng":      "No growth",
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+)
+
+// hl7TestMessage joins segments with the <CR> HL7 uses between them
+func hl7TestMessage(segments ...string) string {
+	return strings.Join(segments, "\r") + "\r"
+}
+
+const hl7TestMSH = `MSH|^~\&|LIS|LAB|PROJECTEIEI|ANC|20260701103000+0700||ORU^R01|MSG0001|P|2.5.1`
+
+func parseOru(t *testing.T, raw string) ([]Hl7OruPatient, error) {
+	t.Helper()
+	msg, err := ParseHl7(raw)
+	if err != nil {
+		t.Fatalf("ParseHl7: %v", err)
+	}
+	return ParseOruR01(msg)
+}
+
+func TestParseOruR01(t *testing.T) {
+	raw := hl7TestMessage(hl7TestMSH,
+		`PID|1||HN-0042^^^ANC^MR~1103700012346^^^MOI^NI||ใจดี^สมหญิง`,
+		`ORC|RE|17`,
+		`OBR|1||ACC-9001|CBC^Complete blood count|||20260701083000+0700`,
+		`OBX|1|NM|718-7^Hemoglobin^LN^HB||10.2|g/dL|11-14|L|||F`,
+		`OBX|2|CE|7918-6^Anti-HIV^LN||NEG^Negative|||N|||F`,
+		`OBX|3|NM|787-2^MCV^LN||||||||X`,
+		`NTE|1||repeat \T\ recheck in 4 weeks`,
+		`OBR|2||ACC-9002|GLU^Glucose`,
+		`OBX|1|SN|1504-0^GCT^LN||<^140|mg/dL|||||F|||20260702090000+0700`,
+	)
+	patients, err := parseOru(t, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patients) != 1 {
+		t.Fatalf("%d patients", len(patients))
+	}
+	p := patients[0]
+	if !reflect.DeepEqual(p.HNs, []string{"HN-0042"}) || !reflect.DeepEqual(p.CIDs, []string{"1103700012346"}) || p.Name != "สมหญิง ใจดี" {
+		t.Fatalf("patient = %+v", p)
+	}
+	if len(p.Orders) != 2 {
+		t.Fatalf("%d orders", len(p.Orders))
+	}
+
+	cbc, glucose := p.Orders[0], p.Orders[1]
+	if cbc.PlacerNumber != "17" || cbc.FillerNumber != "ACC-9001" || cbc.Service != "Complete blood count" {
+		t.Errorf("CBC order = %+v", cbc)
+	}
+	if want := time.Date(2026, 7, 1, 1, 30, 0, 0, time.UTC); !cbc.ObservedAt.Equal(want) {
+		t.Errorf("CBC observed at %v, want OBR-7 %v", cbc.ObservedAt, want)
+	}
+	if !reflect.DeepEqual(cbc.Notes, []string{"repeat & recheck in 4 weeks"}) {
+		t.Errorf("CBC notes = %q", cbc.Notes)
+	}
+	wantObs := []Hl7Observation{
+		{Code: "718-7", Text: "Hemoglobin", AltCode: "HB", ValueType: "NM", Value: "10.2", Unit: "g/dL",
+			ReferenceRange: "11-14", AbnormalFlags: "L", ResultStatus: "F"},
+		{Code: "7918-6", Text: "Anti-HIV", ValueType: "CE", Value: "Negative", ValueCode: "NEG", AbnormalFlags: "N", ResultStatus: "F"},
+		{Code: "787-2", Text: "MCV", ValueType: "NM", ResultStatus: "X"},
+	}
+	if !reflect.DeepEqual(cbc.Observations, wantObs) {
+		t.Errorf("CBC observations = %+v\nwant %+v", cbc.Observations, wantObs)
+	}
+	if cbc.Observations[0].Skipped() || !cbc.Observations[2].Skipped() {
+		t.Error("only the cancelled MCV should be skipped")
+	}
+
+	// the ORC placer number belongs to the first OBR only
+	if glucose.PlacerNumber != "" || glucose.Service != "Glucose" || glucose.Observations[0].Value != "<140" {
+		t.Errorf("glucose order = %+v", glucose)
+	}
+	if want := time.Date(2026, 7, 2, 2, 0, 0, 0, time.UTC); !glucose.ObservedAt.Equal(want) {
+		t.Errorf("glucose observed at %v, want OBX-14 %v", glucose.ObservedAt, want)
+	}
+}
+
+func TestParseOruR01PatientIdentifiers(t *testing.T) {
+	tests := []struct {
+		name string
+		pid  string
+		hns  []string
+		cids []string
+	}{
+		{"typed", `PID|1||HN-1^^^ANC^PI~1103700012346^^^MOI^CID`, []string{"HN-1"}, []string{"1103700012346"}},
+		{"untyped citizen ID", `PID|1||1103700012346`, nil, []string{"1103700012346"}},
+		{"untyped with a bad check digit is an HN", `PID|1||1103700012345`, []string{"1103700012345"}, nil},
+		{"PID-2 and PID-19", `PID|1|HN-2|||สมหญิง||||||||||||||1103700012346`, []string{"HN-2"}, []string{"1103700012346"}},
+		{"other identifier types are ignored", `PID|1||HN-3^^^ANC^MR~A1234^^^^PPN`, []string{"HN-3"}, nil},
+		{"repeated identifiers once", `PID|1|HN-4|HN-4^^^ANC^MR`, []string{"HN-4"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patients, err := parseOru(t, hl7TestMessage(hl7TestMSH, tt.pid, `OBR|1||A1|HB`, `OBX|1|NM|HB||11`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(patients[0].HNs, tt.hns) || !reflect.DeepEqual(patients[0].CIDs, tt.cids) {
+				t.Fatalf("HNs %q, CIDs %q; want %q, %q", patients[0].HNs, patients[0].CIDs, tt.hns, tt.cids)
+			}
+		})
+	}
+}
+
+func TestParseOruR01Errors(t *testing.T) {
+	pid := `PID|1||HN-1^^^ANC^MR`
+	tests := []struct {
+		name     string
+		segments []string
+		want     string
+	}{
+		{"not an ORU", []string{strings.Replace(hl7TestMSH, "ORU^R01", "ADT^A01", 1), pid}, "only ORU^R01"},
+		{"no PID", []string{hl7TestMSH}, "no PID"},
+		{"OBR before PID", []string{hl7TestMSH, `OBR|1||A1|HB`}, "OBR before PID"},
+		{"OBX before OBR", []string{hl7TestMSH, pid, `OBX|1|NM|HB||11`}, "OBX before OBR"},
+		{"OBX without a code", []string{hl7TestMSH, pid, `OBR|1||A1|HB`, `OBX|1|NM|||11`}, "OBX-3"},
+		{"bad OBR-7", []string{hl7TestMSH, pid, `OBR|1||A1|HB|||2026-07-01`}, "OBR-7"},
+		{"patient without identifiers", []string{hl7TestMSH, `PID|1`, `OBR|1||A1|HB`}, "no hospital number"},
+		{"patient without orders", []string{hl7TestMSH, pid}, "no OBR"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseOru(t, hl7TestMessage(tt.segments...))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestHl7LabValue(t *testing.T) {
+	hb := entity.LabTest{Code: "HB", Unit: "g/dL", ValueType: entity.LabValueNumeric}
+	vdrl := entity.LabTest{Code: "VDRL", ValueType: entity.LabValueChoice, Choices: "Non-reactive,Reactive"}
+	hiv := entity.LabTest{Code: "ANTI_HIV", ValueType: entity.LabValueChoice, Choices: "Negative,Positive,Inconclusive"}
+	tests := []struct {
+		name    string
+		test    entity.LabTest
+		obs     Hl7Observation
+		want    string
+		wantErr bool
+	}{
+		{"numeric", hb, Hl7Observation{Value: " 10.2 ", Unit: "g/dl"}, "10.2", false},
+		{"numeric in another unit", hb, Hl7Observation{Value: "102", Unit: "g/L"}, "", true},
+		{"choice text", vdrl, Hl7Observation{Value: "non reactive"}, "Non-reactive", false},
+		{"choice abbreviation", vdrl, Hl7Observation{Value: "NR"}, "Non-reactive", false},
+		{"coded value by its code", hiv, Hl7Observation{Value: "Not detected", ValueCode: "NEG"}, "Negative", false},
+		{"choice not in the catalog", hiv, Hl7Observation{Value: "Equivocal"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Hl7LabValue(tt.test, tt.obs)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Fatalf("Hl7LabValue = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchLabTest(t *testing.T) {
+	tests := []entity.LabTest{{Code: "HB", ExternalCodes: "718-7"}, {Code: "ANTI_HIV", ExternalCodes: "7918-6, HIV"}}
+	for _, tt := range []struct {
+		obs  Hl7Observation
+		want string
+	}{
+		{Hl7Observation{Code: "hb"}, "HB"},
+		{Hl7Observation{Code: "718-7"}, "HB"},
+		{Hl7Observation{Code: "LOCAL1", AltCode: "HIV"}, "ANTI_HIV"},
+		{Hl7Observation{Code: "2345-7"}, ""},
+	} {
+		got, ok := MatchLabTest(tests, tt.obs)
+		if got.Code != tt.want || ok != (tt.want != "") {
+			t.Errorf("MatchLabTest(%+v) = %s, %v; want %s", tt.obs, got.Code, ok, tt.want)
+		}
+	}
+}
+
+func TestHl7Ack(t *testing.T) {
+	now := time.Date(2026, 7, 1, 10, 30, 5, 0, time.FixedZone("ICT", 7*3600))
+	msg, err := ParseHl7(hl7TestMessage(hl7TestMSH))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ack, err := ParseHl7(Hl7Ack(msg.Header(), Hl7AckAccept, "Filed as lab result 3|4", now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := ack.Header()
+	if header.SendingApplication != "PROJECTEIEI" || header.SendingFacility != "ANC" ||
+		header.ReceivingApplication != "LIS" || header.ReceivingFacility != "LAB" ||
+		header.MessageType != "ACK^R01" || header.ProcessingID != "P" || header.Version != "2.5.1" {
+		t.Errorf("ACK header = %+v", header)
+	}
+	msa, _ := ack.Segment("MSA")
+	if msa.Value(1, 1) != Hl7AckAccept || msa.Value(2, 1) != "MSG0001" || msa.Value(3, 1) != "Filed as lab result 3|4" {
+		t.Errorf("MSA = %q", msa.Fields)
+	}
+
+	// a message that could not be parsed is still answered
+	ack, err = ParseHl7(Hl7Ack(Hl7Header{}, Hl7AckReject, "message must start with an MSH segment", now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header := ack.Header(); header.SendingApplication != Hl7ApplicationName || header.MessageType != "ACK^R01" {
+		t.Errorf("ACK of an unreadable message = %+v", header)
+	}
+}
+
+func TestMllpFrames(t *testing.T) {
+	var stream bytes.Buffer
+	stream.WriteString("noise")
+	stream.Write(MllpFrame("MSH|first"))
+	stream.Write(MllpFrame("MSH|second"))
+	r := bufio.NewReader(&stream)
+
+	for _, want := range []string{"MSH|first", "MSH|second"} {
+		frame, err := ReadMllpFrame(r, 64)
+		if err != nil || string(frame) != want {
+			t.Fatalf("frame = %q, %v; want %q", frame, err, want)
+		}
+	}
+	if _, err := ReadMllpFrame(bufio.NewReader(bytes.NewReader(MllpFrame(strings.Repeat("x", 65)))), 64); err == nil {
+		t.Fatal("a frame over the limit was read")
+	}
+}
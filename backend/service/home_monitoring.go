@@ -0,0 +1,249 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+)
+
+// GlucoseContexts are the accepted times of a capillary glucose reading
+var GlucoseContexts = []string{
+	entity.GlucoseFasting,
+	entity.GlucosePostMeal1h,
+	entity.GlucosePostMeal2h,
+	entity.GlucosePreMeal,
+	entity.GlucoseBedtime,
+	entity.GlucoseUnspecified,
+}
+
+// DefaultMonitoringTarget is used until the doctor sets targets for the pregnancy:
+// BP 140/90 (severe 160/110) and GDM glucose targets 95 fasting, 140 at 1 h, 120 at 2 h.
+func DefaultMonitoringTarget() entity.MonitoringTarget {
+	return entity.MonitoringTarget{
+		SystolicMax:     140,
+		DiastolicMax:    90,
+		SystolicSevere:  160,
+		DiastolicSevere: 110,
+		FastingMax:      95,
+		PostMeal1hMax:   140,
+		PostMeal2hMax:   120,
+		GlucoseMin:      60,
+	}
+}
+
+// ValidateMonitoringTarget checks that the thresholds are consistent
+func ValidateMonitoringTarget(t entity.MonitoringTarget) error {
+	if t.SystolicMax <= t.DiastolicMax || t.DiastolicMax <= 0 {
+		return errors.New("systolic_max must be above diastolic_max")
+	}
+	if t.SystolicSevere < t.SystolicMax || t.DiastolicSevere < t.DiastolicMax {
+		return errors.New("severe thresholds must not be below the target maximum")
+	}
+	if t.FastingMax <= t.GlucoseMin || t.PostMeal1hMax <= t.GlucoseMin || t.PostMeal2hMax <= t.GlucoseMin {
+		return errors.New("glucose targets must be above glucose_min")
+	}
+	return nil
+}
+
+// ValidateHomeReading checks the values of a reading of the given kind
+func ValidateHomeReading(r entity.HomeReading, now time.Time) error {
+	if r.MeasuredAt.After(now.Add(5 * time.Minute)) {
+		return errors.New("measured_at cannot be in the future")
+	}
+	switch r.Kind {
+	case entity.HomeReadingBloodPressure:
+		if r.Systolic < 50 || r.Systolic > 300 || r.Diastolic < 30 || r.Diastolic > 200 {
+			return errors.New("systolic and diastolic must be in mmHg")
+		}
+		if r.Diastolic >= r.Systolic {
+			return errors.New("systolic must be above diastolic")
+		}
+		if r.Pulse != nil && (*r.Pulse < 30 || *r.Pulse > 250) {
+			return errors.New("pulse must be in beats per minute")
+		}
+	case entity.HomeReadingGlucose:
+		if r.Glucose < 10 || r.Glucose > 700 {
+			return errors.New("glucose must be in mg/dL")
+		}
+		if !contains(GlucoseContexts, r.GlucoseContext) {
+			return errors.New("glucose_context must be one of " + strings.Join(GlucoseContexts, ", "))
+		}
+	default:
+		return errors.New("kind must be bp or glucose")
+	}
+	return nil
+}
+
+// glucoseLimit returns the upper target for a glucose context; random and bedtime readings use the 1 h target
+func glucoseLimit(t entity.MonitoringTarget, context string) float64 {
+	switch context {
+	case entity.GlucoseFasting, entity.GlucosePreMeal:
+		return t.FastingMax
+	case entity.GlucosePostMeal1h:
+		return t.PostMeal1hMax
+	case entity.GlucosePostMeal2h:
+		return t.PostMeal2hMax
+	}
+	return t.PostMeal1hMax
+}
+
+// AssessHomeReading compares a reading with the targets and returns the assessment and reason
+func AssessHomeReading(r entity.HomeReading, t entity.MonitoringTarget) (string, string) {
+	switch r.Kind {
+	case entity.HomeReadingBloodPressure:
+		bp := fmt.Sprintf("BP %d/%d", r.Systolic, r.Diastolic)
+		if r.Systolic >= t.SystolicSevere || r.Diastolic >= t.DiastolicSevere {
+			return entity.HomeReadingSevere, fmt.Sprintf("%s at or above severe range %d/%d", bp, t.SystolicSevere, t.DiastolicSevere)
+		}
+		if r.Systolic >= t.SystolicMax || r.Diastolic >= t.DiastolicMax {
+			return entity.HomeReadingOutOfRange, fmt.Sprintf("%s at or above target %d/%d", bp, t.SystolicMax, t.DiastolicMax)
+		}
+	case entity.HomeReadingGlucose:
+		value := fmt.Sprintf("glucose %g mg/dL (%s)", r.Glucose, r.GlucoseContext)
+		if r.Glucose < t.GlucoseMin {
+			return entity.HomeReadingSevere, fmt.Sprintf("%s below %g", value, t.GlucoseMin)
+		}
+		if limit := glucoseLimit(t, r.GlucoseContext); r.Glucose > limit {
+			// เกินเป้ามากกว่า 1.5 เท่าให้แพทย์ดูด่วน
+			if r.Glucose >= limit*1.5 {
+				return entity.HomeReadingSevere, fmt.Sprintf("%s far above target %g", value, limit)
+			}
+			return entity.HomeReadingOutOfRange, fmt.Sprintf("%s above target %g", value, limit)
+		}
+	}
+	return entity.HomeReadingNormal, ""
+}
+
+// HomeReadingAlertText builds the care team alert of a reading outside its target
+func HomeReadingAlertText(woman entity.PregnantWoman, r entity.HomeReading) (subject, body string) {
+	subject = "[" + r.Assessment + "] ค่าที่วัดเองที่บ้าน: " + woman.FullName
+	body = fmt.Sprintf("%s (HN %s, โทร %s) วัดเมื่อ %s น.: %s",
+		woman.FullName, woman.HN, woman.PhoneNumber, r.MeasuredAt.In(ClinicLocation).Format("02/01/2006 15:04"), r.AlertReason)
+	return subject, body
+}
+
+// SeriesPoint is one point of a chart series
+type SeriesPoint struct {
+	MeasuredAt time.Time `json:"measured_at"`
+	Value      float64   `json:"value"`
+	Assessment string    `json:"assessment"`
+}
+
+// ReadingSeries groups readings into named chart series: systolic/diastolic for BP,
+// one series per glucose context for glucose
+func ReadingSeries(readings []entity.HomeReading) map[string][]SeriesPoint {
+	series := map[string][]SeriesPoint{}
+	for _, r := range readings {
+		switch r.Kind {
+		case entity.HomeReadingBloodPressure:
+			series["systolic"] = append(series["systolic"], SeriesPoint{r.MeasuredAt, float64(r.Systolic), r.Assessment})
+			series["diastolic"] = append(series["diastolic"], SeriesPoint{r.MeasuredAt, float64(r.Diastolic), r.Assessment})
+		case entity.HomeReadingGlucose:
+			series[r.GlucoseContext] = append(series[r.GlucoseContext], SeriesPoint{r.MeasuredAt, r.Glucose, r.Assessment})
+		}
+	}
+	for name := range series {
+		points := series[name]
+		sort.Slice(points, func(i, j int) bool { return points[i].MeasuredAt.Before(points[j].MeasuredAt) })
+	}
+	return series
+}
+
+// WeeklySummary summarises the readings of one clinic week (Monday to Sunday)
+type WeeklySummary struct {
+	WeekStart string `json:"week_start"`
+
+	BPCount       int     `json:"bp_count"`
+	SystolicMean  float64 `json:"systolic_mean"`
+	DiastolicMean float64 `json:"diastolic_mean"`
+	SystolicMax   int     `json:"systolic_max"`
+	DiastolicMax  int     `json:"diastolic_max"`
+	BPOutOfRange  int     `json:"bp_out_of_range"`
+
+	GlucoseCount      int                `json:"glucose_count"`
+	GlucoseMean       map[string]float64 `json:"glucose_mean"` // ค่าเฉลี่ยแยกตามช่วงเวลา
+	GlucoseOutOfRange int                `json:"glucose_out_of_range"`
+	GlucoseInTarget   float64            `json:"glucose_in_target_percent"`
+}
+
+// WeekStart returns the Monday (clinic time) of the week containing t
+func WeekStart(t time.Time) time.Time {
+	local := t.In(ClinicLocation)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, ClinicLocation)
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+// WeeklySummaries builds the summary of each of the last n weeks up to now, newest first.
+// Weeks without readings are included so the chart has no gaps.
+func WeeklySummaries(readings []entity.HomeReading, weeks int, now time.Time) []WeeklySummary {
+	current := WeekStart(now)
+	summaries := make([]WeeklySummary, weeks)
+	index := map[string]int{}
+	for i := 0; i < weeks; i++ {
+		start := current.AddDate(0, 0, -7*i).Format("2006-01-02")
+		summaries[i] = WeeklySummary{WeekStart: start, GlucoseMean: map[string]float64{}}
+		index[start] = i
+	}
+
+	glucoseSum := make([]map[string]float64, weeks)
+	glucoseN := make([]map[string]int, weeks)
+	for _, r := range readings {
+		i, ok := index[WeekStart(r.MeasuredAt).Format("2006-01-02")]
+		if !ok {
+			continue
+		}
+		s := &summaries[i]
+		outOfRange := r.Assessment != "" && r.Assessment != entity.HomeReadingNormal
+		switch r.Kind {
+		case entity.HomeReadingBloodPressure:
+			s.BPCount++
+			s.SystolicMean += float64(r.Systolic)
+			s.DiastolicMean += float64(r.Diastolic)
+			if r.Systolic > s.SystolicMax {
+				s.SystolicMax = r.Systolic
+			}
+			if r.Diastolic > s.DiastolicMax {
+				s.DiastolicMax = r.Diastolic
+			}
+			if outOfRange {
+				s.BPOutOfRange++
+			}
+		case entity.HomeReadingGlucose:
+			if glucoseSum[i] == nil {
+				glucoseSum[i] = map[string]float64{}
+				glucoseN[i] = map[string]int{}
+			}
+			s.GlucoseCount++
+			glucoseSum[i][r.GlucoseContext] += r.Glucose
+			glucoseN[i][r.GlucoseContext]++
+			if outOfRange {
+				s.GlucoseOutOfRange++
+			}
+		}
+	}
+
+	for i := range summaries {
+		s := &summaries[i]
+		if s.BPCount > 0 {
+			s.SystolicMean = round1(s.SystolicMean / float64(s.BPCount))
+			s.DiastolicMean = round1(s.DiastolicMean / float64(s.BPCount))
+		}
+		for context, sum := range glucoseSum[i] {
+			s.GlucoseMean[context] = round1(sum / float64(glucoseN[i][context]))
+		}
+		if s.GlucoseCount > 0 {
+			s.GlucoseInTarget = round1(float64(s.GlucoseCount-s.GlucoseOutOfRange) / float64(s.GlucoseCount) * 100)
+		}
+	}
+	return summaries
+}
+
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+)
+
+const (
+	icsProductID = "-//Projecteiei//Antenatal Care Appointments//TH"
+	icsUIDDomain = "projecteiei"
+	icsLineLimit = 75
+)
+
+// icsStatusText tells the subscriber what happened to an appointment that is no longer just booked
+var icsStatusText = map[string]string{
+	entity.AppointmentStatusCheckedIn: "สถานะ: เช็คอินแล้ว",
+	entity.AppointmentStatusCompleted: "สถานะ: มาตามนัดแล้ว",
+	entity.AppointmentStatusNoShow:    "สถานะ: ไม่มาตามนัด",
+}
+
+// PublicBaseURLFromEnv reads PUBLIC_BASE_URL, the address the API is reached at
+// from outside (e.g. https://anc.example.go.th). Links handed out to users are
+// built from it, never from the Host header of the request.
+func PublicBaseURLFromEnv() (*url.URL, error) {
+	value := strings.TrimSpace(os.Getenv("PUBLIC_BASE_URL"))
+	if value == "" {
+		return nil, errors.New("PUBLIC_BASE_URL is not set")
+	}
+	base, err := url.Parse(value)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" || base.RawQuery != "" || base.Fragment != "" {
+		return nil, fmt.Errorf("PUBLIC_BASE_URL %q must be an http(s) address such as https://anc.example.go.th", value)
+	}
+	base.Path = strings.TrimSuffix(base.Path, "/")
+	return base, nil
+}
+
+// CalendarFeedURLs returns the https and webcal subscription URLs of a feed token
+func CalendarFeedURLs(base *url.URL, token string) (feed, webcal string) {
+	u := *base
+	u.Path = base.Path + "/calendar/feeds/" + token + ".ics"
+	feed = u.String()
+	u.Scheme = "webcal"
+	return feed, u.String()
+}
+
+// NewFeedToken returns a random token for a calendar feed URL
+func NewFeedToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// AppointmentUID is the stable iCalendar UID of an appointment, so updates and
+// cancellations replace the event already in the subscriber's calendar
+func AppointmentUID(id uint) string {
+	return fmt.Sprintf("appointment-%d@%s", id, icsUIDDomain)
+}
+
+// BuildICalendar renders appointments as an iCalendar (RFC 5545) document.
+// Cancelled appointments stay in the feed with STATUS:CANCELLED.
+func BuildICalendar(calendarName string, appointments []entity.Appointment, alarms []time.Duration) string {
+	var b strings.Builder
+	line := func(s string) { b.WriteString(foldICSLine(s)) }
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:" + icsProductID)
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	if calendarName != "" {
+		line("X-WR-CALNAME:" + escapeICSText(calendarName))
+	}
+	line("X-WR-TIMEZONE:" + ClinicLocation.String())
+
+	for _, appt := range appointments {
+		start := appt.AppointmentDate.UTC()
+		stamp := appt.UpdatedAt
+		if stamp.IsZero() {
+			stamp = time.Now()
+		}
+
+		line("BEGIN:VEVENT")
+		line("UID:" + AppointmentUID(appt.ID))
+		line(fmt.Sprintf("SEQUENCE:%d", appt.Sequence))
+		line("DTSTAMP:" + icsTime(stamp))
+		line("LAST-MODIFIED:" + icsTime(stamp))
+		line("DTSTART:" + icsTime(start))
+		line("DTEND:" + icsTime(start.Add(appointmentLength(appt))))
+		line("SUMMARY:" + escapeICSText(appt.Title))
+		if appt.Location != "" {
+			line("LOCATION:" + escapeICSText(appt.Location))
+		}
+		if description := appointmentDescription(appt); description != "" {
+			line("DESCRIPTION:" + escapeICSText(description))
+		}
+
+		switch appt.Status {
+		case entity.AppointmentStatusCancelled:
+			line("STATUS:CANCELLED")
+		case entity.AppointmentStatusCompleted, entity.AppointmentStatusNoShow:
+			// over: keep it in the calendar without reminders
+			line("STATUS:CONFIRMED")
+		default:
+			line("STATUS:CONFIRMED")
+			for _, before := range alarms {
+				line("BEGIN:VALARM")
+				line("ACTION:DISPLAY")
+				line("DESCRIPTION:" + escapeICSText(appt.Title))
+				line("TRIGGER:-" + icsDuration(before))
+				line("END:VALARM")
+			}
+		}
+		line("END:VEVENT")
+	}
+
+	line("END:VCALENDAR")
+	return b.String()
+}
+
+// appointmentLength is the length of the doctor's time held for the appointment,
+// or of a default slot for an appointment without a reservation
+func appointmentLength(appt entity.Appointment) time.Duration {
+	if appt.Slot != nil && appt.Slot.SlotEnd.After(appt.Slot.SlotStart) {
+		return appt.Slot.SlotEnd.Sub(appt.Slot.SlotStart)
+	}
+	return DefaultSlotMinutes * time.Minute
+}
+
+func appointmentDescription(appt entity.Appointment) string {
+	var parts []string
+	if text, ok := icsStatusText[appt.Status]; ok {
+		parts = append(parts, text)
+	}
+	if appt.Doctor != nil && appt.Doctor.FullName != "" {
+		parts = append(parts, "แพทย์: "+appt.Doctor.FullName)
+	}
+	if appt.PregnantWoman != nil && appt.PregnantWoman.FullName != "" {
+		parts = append(parts, "ผู้รับบริการ: "+appt.PregnantWoman.FullName)
+	}
+	if appt.Notes != "" {
+		parts = append(parts, appt.Notes)
+	}
+	return strings.Join(parts, "\n")
+}
+
+func icsTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// icsDuration formats a positive duration as an RFC 5545 dur-value (P1D, PT2H, PT30M)
+func icsDuration(d time.Duration) string {
+	if d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("P%dD", int(d.Hours()/24))
+	}
+	if d%time.Hour == 0 {
+		return fmt.Sprintf("PT%dH", int(d.Hours()))
+	}
+	return fmt.Sprintf("PT%dM", int(d.Minutes()))
+}
+
+func escapeICSText(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return r.Replace(s)
+}
+
+// foldICSLine splits content lines longer than 75 octets without breaking UTF-8 characters
+func foldICSLine(s string) string {
+	var b strings.Builder
+	width := 0
+	for _, r := range s {
+		size := len(string(r))
+		if width+size > icsLineLimit {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	b.WriteString("\r\n")
+	return b.String()
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+)
+
+// icsEvent returns the unfolded lines of the only VEVENT of a calendar
+func icsEvent(t *testing.T, ics string) []string {
+	t.Helper()
+	ics = strings.ReplaceAll(ics, "\r\n ", "")
+	begin, end := strings.Index(ics, "BEGIN:VEVENT"), strings.Index(ics, "END:VEVENT")
+	if begin < 0 || end < begin {
+		t.Fatalf("no event in %q", ics)
+	}
+	return strings.Split(ics[begin:end], "\r\n")
+}
+
+func icsValue(lines []string, name string) string {
+	for _, l := range lines {
+		if strings.HasPrefix(l, name+":") {
+			return strings.TrimPrefix(l, name+":")
+		}
+	}
+	return ""
+}
+
+func TestBuildICalendarEvent(t *testing.T) {
+	start := time.Date(2026, 11, 2, 9, 0, 0, 0, ClinicLocation)
+	slot := &entity.AppointmentSlot{SlotStart: start.UTC(), SlotEnd: start.Add(20 * time.Minute).UTC()}
+	tests := []struct {
+		name   string
+		appt   entity.Appointment
+		end    string
+		status string
+		text   string
+		alarms bool
+	}{
+		{"booked slot", entity.Appointment{Status: entity.AppointmentStatusScheduled, Slot: slot}, "20261102T022000Z", "CONFIRMED", "", true},
+		{"no reservation", entity.Appointment{Status: entity.AppointmentStatusScheduled}, "20261102T021500Z", "CONFIRMED", "", true},
+		{"checked in", entity.Appointment{Status: entity.AppointmentStatusCheckedIn, Slot: slot}, "20261102T022000Z", "CONFIRMED", "เช็คอินแล้ว", true},
+		{"completed", entity.Appointment{Status: entity.AppointmentStatusCompleted, Slot: slot}, "20261102T022000Z", "CONFIRMED", "มาตามนัดแล้ว", false},
+		{"no-show", entity.Appointment{Status: entity.AppointmentStatusNoShow, Slot: slot}, "20261102T022000Z", "CONFIRMED", "ไม่มาตามนัด", false},
+		{"cancelled", entity.Appointment{Status: entity.AppointmentStatusCancelled, Slot: slot}, "20261102T022000Z", "CANCELLED", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.appt.ID, tt.appt.AppointmentDate, tt.appt.Title, tt.appt.Sequence = 12, start, "ฝากครรภ์", 3
+			event := icsEvent(t, BuildICalendar("", []entity.Appointment{tt.appt}, []time.Duration{24 * time.Hour}))
+
+			if got := icsValue(event, "DTSTART"); got != "20261102T020000Z" {
+				t.Errorf("DTSTART = %s", got)
+			}
+			if got := icsValue(event, "DTEND"); got != tt.end {
+				t.Errorf("DTEND = %s, want %s", got, tt.end)
+			}
+			if got := icsValue(event, "STATUS"); got != tt.status {
+				t.Errorf("STATUS = %s, want %s", got, tt.status)
+			}
+			if got := icsValue(event, "SEQUENCE"); got != "3" {
+				t.Errorf("SEQUENCE = %s", got)
+			}
+			if description := icsValue(event, "DESCRIPTION"); !strings.Contains(description, tt.text) {
+				t.Errorf("DESCRIPTION = %q, want %q", description, tt.text)
+			}
+			if got := strings.Contains(strings.Join(event, "\n"), "BEGIN:VALARM"); got != tt.alarms {
+				t.Errorf("alarm = %v, want %v", got, tt.alarms)
+			}
+		})
+	}
+}
+
+func TestPublicBaseURLFromEnv(t *testing.T) {
+	tests := []struct {
+		value, feed, webcal string
+	}{
+		{"https://anc.example.go.th", "https://anc.example.go.th/calendar/feeds/abc.ics", "webcal://anc.example.go.th/calendar/feeds/abc.ics"},
+		{"https://example.go.th/anc/", "https://example.go.th/anc/calendar/feeds/abc.ics", "webcal://example.go.th/anc/calendar/feeds/abc.ics"},
+		{"http://localhost:8081", "http://localhost:8081/calendar/feeds/abc.ics", "webcal://localhost:8081/calendar/feeds/abc.ics"},
+		{"", "", ""},
+		{"anc.example.go.th", "", ""},
+		{"ftp://anc.example.go.th", "", ""},
+		{"https://anc.example.go.th/?x=1", "", ""},
+	}
+	for _, tt := range tests {
+		t.Setenv("PUBLIC_BASE_URL", tt.value)
+		base, err := PublicBaseURLFromEnv()
+		if tt.feed == "" {
+			if err == nil {
+				t.Errorf("PUBLIC_BASE_URL %q was accepted", tt.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("PUBLIC_BASE_URL %q: %v", tt.value, err)
+			continue
+		}
+		if feed, webcal := CalendarFeedURLs(base, "abc"); feed != tt.feed || webcal != tt.webcal {
+			t.Errorf("PUBLIC_BASE_URL %q: %s, %s", tt.value, feed, webcal)
+		}
+	}
+}
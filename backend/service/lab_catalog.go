@@ -0,0 +1,203 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+)
+
+// Codes of catalog tests the clinic logic relies on
+const (
+	LabTestHb       = "HB"
+	LabTestHct      = "HCT"
+	LabTestHbTyping = "HB_TYPING"
+	LabTestDcip     = "DCIP"
+	LabTestAntiHIV  = "ANTI_HIV"
+)
+
+// LabValueTypes accepted in the catalog
+var LabValueTypes = []string{entity.LabValueNumeric, entity.LabValueChoice, entity.LabValueText}
+
+// Trimester returns 1, 2 or 3 for a gestational age in completed weeks
+func Trimester(weeks int) int {
+	switch {
+	case weeks >= 28:
+		return 3
+	case weeks >= 14:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// ValidateLabTest normalises a catalog entry and checks its reference ranges
+func ValidateLabTest(test *entity.LabTest) error {
+	test.Code = strings.ToUpper(strings.TrimSpace(test.Code))
+	test.Name = strings.TrimSpace(test.Name)
+	if test.Code == "" || test.Name == "" {
+		return errors.New("code and name are required")
+	}
+	if test.ValueType == "" {
+		test.ValueType = entity.LabValueNumeric
+	}
+	if !contains(LabValueTypes, test.ValueType) {
+		return errors.New("value_type must be one of " + strings.Join(LabValueTypes, ", "))
+	}
+	choices := SplitList(test.Choices)
+	if test.ValueType == entity.LabValueChoice && len(choices) == 0 {
+		return errors.New("choices are required for a choice test")
+	}
+	test.Choices = strings.Join(choices, ",")
+	var external []string
+	for _, code := range SplitList(test.ExternalCodes) {
+		external = append(external, strings.ToUpper(code))
+	}
+	test.ExternalCodes = strings.Join(external, ",")
+
+	seen := map[int]bool{}
+	for _, r := range test.ReferenceRanges {
+		if r.Trimester < 0 || r.Trimester > 3 {
+			return errors.New("reference range trimester must be 0 (any) to 3")
+		}
+		if seen[r.Trimester] {
+			return fmt.Errorf("trimester %d has more than one reference range", r.Trimester)
+		}
+		seen[r.Trimester] = true
+		if r.Low != nil && r.High != nil && *r.Low > *r.High {
+			return errors.New("reference range low must not be above high")
+		}
+		for _, v := range SplitList(r.NormalValues) {
+			if test.ValueType == entity.LabValueChoice && !contains(choices, v) {
+				return errors.New("normal value " + v + " is not one of the choices")
+			}
+		}
+	}
+	return nil
+}
+
+// FindReferenceRange picks the range of the trimester, falling back to the range for any trimester
+func FindReferenceRange(test entity.LabTest, trimester int) *entity.LabReferenceRange {
+	var fallback *entity.LabReferenceRange
+	for i := range test.ReferenceRanges {
+		r := &test.ReferenceRanges[i]
+		if r.Trimester == trimester {
+			return r
+		}
+		if r.Trimester == 0 {
+			fallback = r
+		}
+	}
+	return fallback
+}
+
+// referenceText describes a range for display, e.g. "11-14 g/dL" or ">= 11 g/dL"
+func referenceText(test entity.LabTest, r *entity.LabReferenceRange) string {
+	if r == nil {
+		return ""
+	}
+	if test.ValueType != entity.LabValueNumeric {
+		return strings.Join(SplitList(r.NormalValues), ", ")
+	}
+	format := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	var text string
+	switch {
+	case r.Low != nil && r.High != nil:
+		text = format(*r.Low) + "-" + format(*r.High)
+	case r.Low != nil:
+		text = ">= " + format(*r.Low)
+	case r.High != nil:
+		text = "<= " + format(*r.High)
+	default:
+		return ""
+	}
+	return strings.TrimSpace(text + " " + test.Unit)
+}
+
+// EvaluateObservation flags an observation against the reference range of the trimester
+func EvaluateObservation(test entity.LabTest, obs *entity.LabObservation, trimester int) {
+	r := FindReferenceRange(test, trimester)
+	obs.ReferenceText = referenceText(test, r)
+	obs.Flag = ""
+	if r == nil {
+		return
+	}
+
+	switch test.ValueType {
+	case entity.LabValueNumeric:
+		if obs.ValueNumber == nil {
+			return
+		}
+		switch {
+		case r.Low != nil && *obs.ValueNumber < *r.Low:
+			obs.Flag = entity.LabFlagLow
+		case r.High != nil && *obs.ValueNumber > *r.High:
+			obs.Flag = entity.LabFlagHigh
+		case r.Low != nil || r.High != nil:
+			obs.Flag = entity.LabFlagNormal
+		}
+	case entity.LabValueChoice:
+		normal := SplitList(r.NormalValues)
+		// ค่าที่ไม่อยู่ในตัวเลือก (เช่น ข้อมูลเก่า) ไม่แปลผล
+		if len(normal) == 0 || !contains(SplitList(test.Choices), obs.ValueText) {
+			return
+		}
+		if contains(normal, obs.ValueText) {
+			obs.Flag = entity.LabFlagNormal
+		} else {
+			obs.Flag = entity.LabFlagAbnormal
+		}
+	}
+}
+
+// BuildObservation parses a value for a catalog test and flags it
+func BuildObservation(test entity.LabTest, value string, trimester int) (entity.LabObservation, error) {
+	testID := test.ID
+	obs := entity.LabObservation{LabTestID: &testID, Code: test.Code, Unit: test.Unit}
+
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return obs, errors.New(test.Code + ": value is required")
+	}
+	switch test.ValueType {
+	case entity.LabValueNumeric:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return obs, errors.New(test.Code + ": value must be a number")
+		}
+		obs.ValueNumber = &n
+	case entity.LabValueChoice:
+		// รับตัวพิมพ์เล็ก/ใหญ่ได้ แต่บันทึกตามตัวเลือกในแคตตาล็อก
+		matched := ""
+		for _, choice := range SplitList(test.Choices) {
+			if strings.EqualFold(choice, value) {
+				matched = choice
+			}
+		}
+		if matched == "" {
+			return obs, errors.New(test.Code + ": value must be one of " + test.Choices)
+		}
+		obs.ValueText = matched
+	default:
+		obs.ValueText = value
+	}
+
+	EvaluateObservation(test, &obs, trimester)
+	return obs, nil
+}
+
+// NormalizeCheckResult maps an old CheckResult name onto the catalog choices
+func NormalizeCheckResult(name string) string {
+	lower := strings.ToLower(strings.TrimSpace(name))
+	switch {
+	case lower == "":
+		return ""
+	case strings.Contains(lower, "pos") || strings.Contains(lower, "บวก") || strings.Contains(lower, "ผิดปกติ"):
+		return "Positive"
+	case strings.Contains(lower, "neg") || strings.Contains(lower, "ลบ") || strings.Contains(lower, "ปกติ"):
+		return "Negative"
+	}
+	return strings.TrimSpace(name)
+}
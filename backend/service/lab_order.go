@@ -0,0 +1,92 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+)
+
+// OrderableLabPanels are the panels a doctor can order; DueWeek is the default due gestational week
+var OrderableLabPanels = append(append([]LabPanel{}, ExpectedLabPanels...),
+	LabPanel{Code: "cbc", Name: "Hb/Hct"},
+	LabPanel{Code: "anti_hiv", Name: "Anti-HIV"},
+	LabPanel{Code: "thalassemia", Name: "DCIP/Hb typing"},
+)
+
+// LabOrderPriorities in order of urgency
+var LabOrderPriorities = []string{entity.LabPriorityStat, entity.LabPriorityUrgent, entity.LabPriorityRoutine}
+
+// OutstandingLabOrderStatuses are orders still waiting for a result or for the doctor to review it
+var OutstandingLabOrderStatuses = []string{entity.LabOrderOrdered, entity.LabOrderCollected, entity.LabOrderResulted}
+
+// labOrderNext lists the statuses each status may move to
+var labOrderNext = map[string][]string{
+	entity.LabOrderOrdered:   {entity.LabOrderCollected, entity.LabOrderResulted, entity.LabOrderCancelled},
+	entity.LabOrderCollected: {entity.LabOrderResulted, entity.LabOrderCancelled},
+	entity.LabOrderResulted:  {entity.LabOrderReviewed},
+}
+
+// FindOrderableLabPanel looks up a panel by code
+func FindOrderableLabPanel(code string) (LabPanel, bool) {
+	for _, p := range OrderableLabPanels {
+		if p.Code == code {
+			return p, true
+		}
+	}
+	return LabPanel{}, false
+}
+
+// ValidateLabOrder checks a new order and fills the defaults of its panel
+func ValidateLabOrder(order *entity.LabOrder) error {
+	panel, ok := FindOrderableLabPanel(order.Panel)
+	if !ok {
+		codes := make([]string, len(OrderableLabPanels))
+		for i, p := range OrderableLabPanels {
+			codes[i] = p.Code
+		}
+		return errors.New("panel must be one of " + strings.Join(codes, ", "))
+	}
+	if order.Priority == "" {
+		order.Priority = entity.LabPriorityRoutine
+	}
+	if !contains(LabOrderPriorities, order.Priority) {
+		return errors.New("priority must be one of " + strings.Join(LabOrderPriorities, ", "))
+	}
+	if order.DueWeek == 0 {
+		order.DueWeek = panel.DueWeek
+	}
+	if order.DueWeek < 0 || order.DueWeek > 42 {
+		return errors.New("due_week must be between 1 and 42")
+	}
+	return nil
+}
+
+// LabOrderDueDate is the date the pregnancy reaches the due week, or nil without one
+func LabOrderDueDate(lmp time.Time, dueWeek int) *time.Time {
+	if dueWeek <= 0 || lmp.IsZero() {
+		return nil
+	}
+	due := lmp.AddDate(0, 0, dueWeek*7)
+	return &due
+}
+
+// CheckLabOrderTransition reports whether an order may move from one status to another
+func CheckLabOrderTransition(from, to string) error {
+	if contains(labOrderNext[from], to) {
+		return nil
+	}
+	return errors.New("cannot change a lab order from " + from + " to " + to)
+}
+
+// IsLabOrderOverdue reports whether an order without a result has passed its due date
+func IsLabOrderOverdue(order entity.LabOrder, now time.Time) bool {
+	if order.DueDate == nil {
+		return false
+	}
+	if order.Status != entity.LabOrderOrdered && order.Status != entity.LabOrderCollected {
+		return false
+	}
+	return now.After(*order.DueDate)
+}
@@ -0,0 +1,781 @@
+package service
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+)
+
+// Moph43Files are the files of the MOPH 43-file standard (โครงสร้างมาตรฐานข้อมูลด้านสุขภาพ 43 แฟ้ม)
+// this system exports, in upload order
+var Moph43Files = []string{"PERSON", "ANC", "PRENATAL", "LABOR", "NEWBORN", "POSTNATAL"}
+
+// moph43Fields are the columns of each file in the order of the standard
+var moph43Fields = map[string][]string{
+	"PERSON": {"HOSPCODE", "CID", "PID", "HID", "PRENAME", "NAME", "LNAME", "HN", "SEX", "BIRTH", "MSTATUS",
+		"OCCUPATION_OLD", "OCCUPATION_NEW", "RACE", "NATION", "RELIGION", "EDUCATION", "FSTATUS", "FATHER", "MOTHER",
+		"COUPLE", "VSTATUS", "MOVEIN", "DISCHARGE", "DDISCHARGE", "ABOGROUP", "RHGROUP", "LABOR", "PASSPORT",
+		"TYPEAREA", "D_UPDATE", "TELEPHONE", "MOBILE"},
+	"ANC": {"HOSPCODE", "PID", "SEQ", "DATE_SERV", "GRAVIDA", "ANCNO", "GA", "ANCRESULT", "ANCPLACE", "PROVIDER",
+		"D_UPDATE", "CID"},
+	"PRENATAL": {"HOSPCODE", "PID", "GRAVIDA", "LMP", "EDC", "VDRL_RESULT", "HB_RESULT", "HIV_RESULT", "DATE_HCT",
+		"HCT_RESULT", "THALASSEMIA", "D_UPDATE", "PROVIDER", "CID"},
+	"LABOR": {"HOSPCODE", "PID", "GRAVIDA", "LMP", "EDC", "BDATE", "BRESULT", "BPLACE", "BHOSP", "BTYPE", "BDOCTOR",
+		"LBORN", "SBORN", "D_UPDATE", "CID"},
+	"NEWBORN": {"HOSPCODE", "PID", "MPID", "GRAVIDA", "GA", "BDATE", "BTIME", "BPLACE", "BHOSP", "BIRTHNO", "BTYPE",
+		"BDOCTOR", "BWEIGHT", "ASPHYXIA", "VITK", "TSH", "TSHRESULT", "D_UPDATE", "CID"},
+	"POSTNATAL": {"HOSPCODE", "PID", "SEQ", "GRAVIDA", "BDATE", "PPCARE", "PPPLACE", "PPRESULT", "PROVIDER",
+		"D_UPDATE", "CID"},
+}
+
+// moph43Rule validates a column. Columns without a rule are optional text up to 255 characters.
+type moph43Rule struct {
+	required bool
+	length   int      // maximum length
+	kind     string   // digits, date (YYYYMMDD), datetime (YYYYMMDDHHMMSS), time (HHMMSS), cid
+	codes    []string // allowed codes
+}
+
+var moph43Rules = map[string]moph43Rule{
+	"HOSPCODE": {required: true, length: 5, kind: "digits"},
+	"PID":      {required: true, length: 15},
+	"MPID":     {required: true, length: 15},
+	"SEQ":      {required: true, length: 16},
+	"D_UPDATE": {required: true, length: 14, kind: "datetime"},
+	"PROVIDER": {length: 15},
+
+	"CID":       {required: true, length: 13, kind: "cid"},
+	"PRENAME":   {length: 3, codes: []string{"001", "002", "003", "004", "005"}},
+	"NAME":      {required: true, length: 50},
+	"LNAME":     {required: true, length: 50},
+	"HN":        {length: 15},
+	"SEX":       {required: true, codes: []string{"1", "2"}},
+	"BIRTH":     {required: true, length: 8, kind: "date"},
+	"MSTATUS":   {codes: []string{"1", "2", "3", "4", "5", "6", "9"}},
+	"RACE":      {length: 3, kind: "digits"},
+	"NATION":    {required: true, length: 3, kind: "digits"},
+	"COUPLE":    {length: 13, kind: "cid"},
+	"DISCHARGE": {required: true, codes: []string{"1", "2", "3", "9"}},
+	"ABOGROUP":  {codes: []string{"1", "2", "3", "4"}},
+	"RHGROUP":   {codes: []string{"1", "2"}},
+	"TYPEAREA":  {required: true, codes: []string{"1", "2", "3", "4", "5"}},
+	"MOBILE":    {length: 10, kind: "digits"},
+
+	"DATE_SERV": {required: true, length: 8, kind: "date"},
+	"GRAVIDA":   {required: true, length: 2, kind: "digits"},
+	"ANCNO":     {required: true, codes: []string{"1", "2", "3", "4", "5"}},
+	"GA":        {required: true, length: 2, kind: "digits"},
+	"ANCRESULT": {required: true, codes: []string{"1", "2", "9"}},
+	"ANCPLACE":  {required: true, length: 5, kind: "digits"},
+
+	"LMP":         {required: true, length: 8, kind: "date"},
+	"EDC":         {required: true, length: 8, kind: "date"},
+	"VDRL_RESULT": {required: true, codes: []string{"1", "2", "3", "4"}},
+	"HB_RESULT":   {required: true, codes: []string{"1", "2", "3", "4"}},
+	"HIV_RESULT":  {required: true, codes: []string{"1", "2", "3", "4"}},
+	"DATE_HCT":    {length: 8, kind: "date"},
+	"HCT_RESULT":  {length: 2, kind: "digits"},
+	"THALASSEMIA": {required: true, codes: []string{"1", "2", "3", "4"}},
+
+	"BDATE":   {required: true, length: 8, kind: "date"},
+	"BRESULT": {required: true, length: 6},
+	"BPLACE":  {required: true, codes: []string{"1", "2", "3", "4", "5"}},
+	"BHOSP":   {length: 5, kind: "digits"},
+	"BTYPE":   {required: true, codes: []string{"1", "2", "3", "4", "5", "6"}},
+	"BDOCTOR": {required: true, codes: []string{"1", "2", "3", "4", "5", "6"}},
+	"LBORN":   {required: true, length: 1, kind: "digits"},
+	"SBORN":   {required: true, length: 1, kind: "digits"},
+
+	"BTIME":     {length: 6, kind: "time"},
+	"BIRTHNO":   {required: true, length: 1, kind: "digits"},
+	"BWEIGHT":   {required: true, length: 4, kind: "digits"},
+	"ASPHYXIA":  {codes: []string{"1", "2", "9"}},
+	"VITK":      {codes: []string{"1", "2", "9"}},
+	"TSH":       {codes: []string{"1", "2", "9"}},
+	"TSHRESULT": {length: 4},
+
+	"PPCARE":   {required: true, length: 8, kind: "date"},
+	"PPPLACE":  {required: true, length: 5, kind: "digits"},
+	"PPRESULT": {required: true, codes: []string{"1", "2", "9"}},
+}
+
+// moph43OptionalFields are required columns left empty in some files. Newborns
+// get a citizen ID when the birth is registered, usually after the export.
+var moph43OptionalFields = map[string][]string{"NEWBORN": {"CID"}}
+
+// moph43Missing says what to record when a required column is empty because the
+// data is not in the patient's record; other columns are reported as "is required"
+var moph43Missing = map[string]string{
+	"NATION":  "nationality is not recorded on the mother's record",
+	"BPLACE":  "delivery place is not recorded",
+	"BTYPE":   "delivery method is not recorded or not recognised",
+	"BDOCTOR": "birth attendant is not recorded",
+}
+
+// Moph43Options are the values the export needs that this system does not record per patient
+type Moph43Options struct {
+	HospCode string // รหัสสถานพยาบาล 5 หลัก
+	TypeArea string // สถานะบุคคลในเขตรับผิดชอบ ค่าเริ่มต้น 4 (อยู่นอกเขต มารับบริการ)
+}
+
+// Moph43Row is one line of a file by column name
+type Moph43Row map[string]string
+
+// Moph43Problem is a record left out of the export and why
+type Moph43Problem struct {
+	File      string `json:"file"`
+	Record    string `json:"record"` // e.g. antenatal_visit 12
+	PatientID uint   `json:"patient_id"`
+	Patient   string `json:"patient"` // HN and name for the clerk fixing the data
+	Field     string `json:"field"`
+	Problem   string `json:"problem"`
+}
+
+// Moph43Export holds the rows of every file for one period
+type Moph43Export struct {
+	HospCode    string                 `json:"hospcode"`
+	From        time.Time              `json:"from"`
+	To          time.Time              `json:"to"`
+	GeneratedAt time.Time              `json:"generated_at"`
+	Rows        map[string][]Moph43Row `json:"-"`
+	Problems    []Moph43Problem        `json:"problems"`
+}
+
+// NewMoph43Export starts an empty export
+func NewMoph43Export(opts Moph43Options, from, to, now time.Time) *Moph43Export {
+	return &Moph43Export{HospCode: opts.HospCode, From: from, To: to, GeneratedAt: now, Rows: map[string][]Moph43Row{}, Problems: []Moph43Problem{}}
+}
+
+// ValidateMoph43Options checks the hospital code and fills the default TYPEAREA
+func ValidateMoph43Options(opts *Moph43Options) error {
+	if len(opts.HospCode) != 5 || !isDigits(opts.HospCode) {
+		return fmt.Errorf("hospital code must be 5 digits, got %q", opts.HospCode)
+	}
+	if opts.TypeArea == "" {
+		opts.TypeArea = "4"
+	}
+	if !contains(moph43Rules["TYPEAREA"].codes, opts.TypeArea) {
+		return fmt.Errorf("TYPEAREA must be 1-5, got %q", opts.TypeArea)
+	}
+	return nil
+}
+
+// Add validates a row and keeps it, or records why it was left out
+func (e *Moph43Export) Add(file string, row Moph43Row, record string, woman entity.PregnantWoman) bool {
+	problems := ValidateMoph43Row(file, row)
+	for _, p := range problems {
+		e.Skip(file, record, woman, p.Field, p.Problem)
+	}
+	if len(problems) > 0 {
+		return false
+	}
+	e.Rows[file] = append(e.Rows[file], row)
+	return true
+}
+
+// Skip records a row that is left out
+func (e *Moph43Export) Skip(file, record string, woman entity.PregnantWoman, field, problem string) {
+	e.Problems = append(e.Problems, Moph43Problem{
+		File:      file,
+		Record:    record,
+		PatientID: woman.ID,
+		Patient:   strings.TrimSpace(woman.HN + " " + woman.FullName),
+		Field:     field,
+		Problem:   problem,
+	})
+}
+
+// Counts returns the number of exported rows of each file
+func (e *Moph43Export) Counts() map[string]int {
+	counts := map[string]int{}
+	for _, file := range Moph43Files {
+		counts[file] = len(e.Rows[file])
+	}
+	return counts
+}
+
+// FileName is the zip name the MOPH data center expects: F43_<hospcode>_<YYYYMMDDHHMMSS>.zip
+func (e *Moph43Export) FileName() string {
+	return "F43_" + e.HospCode + "_" + e.GeneratedAt.In(ClinicLocation).Format("20060102150405") + ".zip"
+}
+
+// WriteZip writes one pipe-delimited UTF-8 text file per 43-file table with a header line
+func (e *Moph43Export) WriteZip(w io.Writer) error {
+	zw := zip.NewWriter(w)
+	for _, file := range Moph43Files {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: strings.ToLower(file) + ".txt", Method: zip.Deflate, Modified: e.GeneratedAt})
+		if err != nil {
+			return err
+		}
+		fields := moph43Fields[file]
+		lines := []string{strings.Join(fields, "|")}
+		for _, row := range e.Rows[file] {
+			values := make([]string, len(fields))
+			for i, field := range fields {
+				values[i] = row[field]
+			}
+			lines = append(lines, strings.Join(values, "|"))
+		}
+		if _, err := io.WriteString(f, strings.Join(lines, "\r\n")+"\r\n"); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// ValidateMoph43Row checks every column of a row against the standard
+func ValidateMoph43Row(file string, row Moph43Row) []Moph43Problem {
+	var problems []Moph43Problem
+	fail := func(field, format string, args ...interface{}) {
+		problems = append(problems, Moph43Problem{File: file, Field: field, Problem: fmt.Sprintf(format, args...)})
+	}
+	for _, field := range moph43Fields[file] {
+		value := row[field]
+		rule, ok := moph43Rules[field]
+		if !ok {
+			rule = moph43Rule{length: 255}
+		}
+		if strings.ContainsAny(value, "|\r\n") {
+			fail(field, "must not contain | or line breaks")
+			continue
+		}
+		if value == "" {
+			if rule.required && !contains(moph43OptionalFields[file], field) {
+				if missing, ok := moph43Missing[field]; ok {
+					fail(field, "%s", missing)
+				} else {
+					fail(field, "is required")
+				}
+			}
+			continue
+		}
+		if rule.length > 0 && len([]rune(value)) > rule.length {
+			fail(field, "is longer than %d characters", rule.length)
+			continue
+		}
+		if len(rule.codes) > 0 && !contains(rule.codes, value) {
+			fail(field, "%q is not one of %s", value, strings.Join(rule.codes, ", "))
+			continue
+		}
+		switch rule.kind {
+		case "digits":
+			if !isDigits(value) {
+				fail(field, "%q must be digits", value)
+			}
+		case "cid":
+			if !IsThaiCitizenID(value) {
+				fail(field, "%q is not a valid 13-digit citizen ID", value)
+			}
+		case "date", "datetime", "time":
+			layout := map[string]string{"date": "20060102", "datetime": "20060102150405", "time": "150405"}[rule.kind]
+			if _, err := time.Parse(layout, value); err != nil {
+				fail(field, "%q is not a valid %s", value, rule.kind)
+			}
+		}
+	}
+	return problems
+}
+
+func isDigits(value string) bool {
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return value != ""
+}
+
+// moph43Date formats a date in the clinic's time zone; zero dates are empty
+func moph43Date(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(ClinicLocation).Format("20060102")
+}
+
+func moph43DateTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(ClinicLocation).Format("20060102150405")
+}
+
+func moph43ID(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+func moph43OptionalID(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return moph43ID(*id)
+}
+
+// moph43Number writes a positive count; 0 is left empty so a required field is reported as missing
+func moph43Number(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// moph43Prenames maps Thai and English titles to the MOPH prename codes
+var moph43Prenames = []struct{ title, code string }{
+	{"นางสาว", "004"}, {"น.ส.", "004"}, {"Miss", "004"}, {"Ms.", "004"},
+	{"นาง", "005"}, {"Mrs.", "005"},
+}
+
+// SplitMoph43Name splits a full name into the PRENAME code, first name and last name
+func SplitMoph43Name(fullName string) (prename, name, lastName string) {
+	fullName = strings.TrimSpace(fullName)
+	for _, p := range moph43Prenames {
+		if strings.HasPrefix(fullName, p.title) {
+			prename = p.code
+			fullName = strings.TrimSpace(strings.TrimPrefix(fullName, p.title))
+			break
+		}
+	}
+	parts := strings.Fields(fullName)
+	if len(parts) == 0 {
+		return prename, "", ""
+	}
+	return prename, parts[0], strings.Join(parts[1:], " ")
+}
+
+// moph43BloodGroups map the catalog choices of BLOOD_GROUP and RH to ABOGROUP and RHGROUP
+var (
+	moph43AboGroups = map[string]string{"A": "1", "B": "2", "AB": "3", "O": "4"}
+	moph43RhGroups  = map[string]string{"Positive": "1", "Negative": "2"}
+)
+
+// Moph43Person maps a mother to PERSON. bloodGroup and rh are her latest BLOOD_GROUP / RH results.
+func Moph43Person(opts Moph43Options, w entity.PregnantWoman, bloodGroup, rh string) Moph43Row {
+	prename, name, lastName := SplitMoph43Name(w.FullName)
+	mstatus, couple := "9", ""
+	if w.Husband != nil {
+		mstatus = "2" // คู่
+		if IsThaiCitizenID(w.Husband.CitizenID) {
+			couple = w.Husband.CitizenID
+		}
+	}
+	mobile := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, w.PhoneNumber)
+	if len(mobile) != 10 {
+		mobile = ""
+	}
+	return Moph43Row{
+		"HOSPCODE":  opts.HospCode,
+		"CID":       w.CitizenID,
+		"PID":       moph43ID(w.ID),
+		"PRENAME":   prename,
+		"NAME":      name,
+		"LNAME":     lastName,
+		"HN":        w.HN,
+		"SEX":       "2",
+		"BIRTH":     moph43Date(w.BirthDate),
+		"MSTATUS":   mstatus,
+		"RACE":      w.Race,
+		"NATION":    w.Nationality,
+		"COUPLE":    couple,
+		"DISCHARGE": "9", // ยังไม่จำหน่าย
+		"ABOGROUP":  moph43AboGroups[bloodGroup],
+		"RHGROUP":   moph43RhGroups[rh],
+		"TYPEAREA":  opts.TypeArea,
+		"D_UPDATE":  moph43DateTime(w.UpdatedAt),
+		"MOBILE":    mobile,
+	}
+}
+
+// Moph43AncNo is the ANC period of the MOPH five-visit schedule for a gestational age
+func Moph43AncNo(weeks int) string {
+	switch {
+	case weeks <= 0:
+		return ""
+	case weeks <= 12:
+		return "1"
+	case weeks <= 18:
+		return "2"
+	case weeks <= 26:
+		return "3"
+	case weeks <= 32:
+		return "4"
+	default:
+		return "5"
+	}
+}
+
+// parseBloodPressure reads "120/80"
+func parseBloodPressure(value string) (systolic, diastolic int, ok bool) {
+	m := bloodPressurePattern.FindStringSubmatch(value)
+	if m == nil {
+		return 0, 0, false
+	}
+	systolic, _ = strconv.Atoi(m[1])
+	diastolic, _ = strconv.Atoi(m[2])
+	return systolic, diastolic, true
+}
+
+func isHypertensive(bloodPressure string) bool {
+	systolic, diastolic, ok := parseBloodPressure(bloodPressure)
+	return ok && (systolic >= 140 || diastolic >= 90)
+}
+
+// isPositiveDipstick reports a urine dipstick reading of 1+ or more
+func isPositiveDipstick(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(NormalizeCheckResult(value))) {
+	case "", "negative", "trace", "-", "normal", "nil", "none":
+		return false
+	}
+	return true
+}
+
+// Moph43AncResult is 2 (abnormal) for hypertension or proteinuria / glycosuria of 1+ or more,
+// 1 when blood pressure was measured without findings and 9 when nothing was measured
+func Moph43AncResult(v entity.AntenatalVisit) string {
+	switch {
+	case isHypertensive(v.BloodPressure) || isPositiveDipstick(v.UrineProtein) || isPositiveDipstick(v.UrineSugar):
+		return "2"
+	case v.BloodPressure != "":
+		return "1"
+	}
+	return "9"
+}
+
+// Moph43Anc maps an antenatal visit to ANC
+func Moph43Anc(opts Moph43Options, w entity.PregnantWoman, p entity.Pregnancy, v entity.AntenatalVisit) Moph43Row {
+	ga := v.GestationalAge
+	if ga <= 0 {
+		if lmp := PregnancyLMP(p); !lmp.IsZero() {
+			ga = GestationalWeeks(lmp, v.VisitDate)
+		}
+	}
+	return Moph43Row{
+		"HOSPCODE":  opts.HospCode,
+		"PID":       moph43ID(w.ID),
+		"SEQ":       moph43ID(v.ID),
+		"DATE_SERV": moph43Date(v.VisitDate),
+		"GRAVIDA":   moph43Number(p.PregnancyNo),
+		"ANCNO":     Moph43AncNo(ga),
+		"GA":        moph43Number(ga),
+		"ANCRESULT": Moph43AncResult(v),
+		"ANCPLACE":  opts.HospCode,
+		"PROVIDER":  moph43OptionalID(v.DoctorID),
+		"D_UPDATE":  moph43DateTime(v.UpdatedAt),
+		"CID":       w.CitizenID,
+	}
+}
+
+// moph43PendingPanels are the lab panels whose outstanding order means a screening result is awaited
+var moph43PendingPanels = map[string][]string{
+	"VDRL":        {"booking", "third_trimester"},
+	"HBSAG":       {"booking"},
+	"ANTI_HIV":    {"booking", "third_trimester", "anti_hiv"},
+	"THALASSEMIA": {"booking", "thalassemia"},
+}
+
+// moph43Screening is 2 when any observation of the codes is flagged, 1 when all are normal,
+// 4 while an order covering the test is outstanding and 3 when the test was not done
+func moph43Screening(results []entity.LabResult, orders []entity.LabOrder, pending string, codes ...string) string {
+	normal, abnormal := false, false
+	for _, r := range results {
+		for _, o := range r.Observations {
+			if !contains(codes, o.Code) {
+				continue
+			}
+			switch o.Flag {
+			case entity.LabFlagNormal:
+				normal = true
+			case entity.LabFlagLow, entity.LabFlagHigh, entity.LabFlagAbnormal:
+				abnormal = true
+			}
+		}
+	}
+	switch {
+	case abnormal:
+		return "2"
+	case normal:
+		return "1"
+	}
+	for _, order := range orders {
+		if (order.Status == entity.LabOrderOrdered || order.Status == entity.LabOrderCollected) &&
+			contains(moph43PendingPanels[pending], order.Panel) {
+			return "4"
+		}
+	}
+	return "3"
+}
+
+// Moph43Prenatal maps a pregnancy and its screening results to PRENATAL.
+// Results must be sorted by test date with observations loaded.
+func Moph43Prenatal(opts Moph43Options, w entity.PregnantWoman, p entity.Pregnancy, results []entity.LabResult, orders []entity.LabOrder, provider *uint) Moph43Row {
+	row := Moph43Row{
+		"HOSPCODE":    opts.HospCode,
+		"PID":         moph43ID(w.ID),
+		"GRAVIDA":     moph43Number(p.PregnancyNo),
+		"LMP":         moph43Date(PregnancyLMP(p)),
+		"EDC":         moph43Date(p.EDC),
+		"VDRL_RESULT": moph43Screening(results, orders, "VDRL", "VDRL"),
+		"HB_RESULT":   moph43Screening(results, orders, "HBSAG", "HBSAG"),
+		"HIV_RESULT":  moph43Screening(results, orders, "ANTI_HIV", LabTestAntiHIV),
+		"THALASSEMIA": moph43Screening(results, orders, "THALASSEMIA", LabTestDcip, "MCV"),
+		"D_UPDATE":    moph43DateTime(p.UpdatedAt),
+		"PROVIDER":    moph43OptionalID(provider),
+		"CID":         w.CitizenID,
+	}
+	// Hct ครั้งแรกของการตั้งครรภ์
+	for _, r := range results {
+		for _, o := range r.Observations {
+			if o.Code == LabTestHct && o.ValueNumber != nil {
+				row["DATE_HCT"] = moph43Date(r.TestDate)
+				row["HCT_RESULT"] = strconv.Itoa(int(math.Round(*o.ValueNumber)))
+				return row
+			}
+		}
+	}
+	return row
+}
+
+// Moph43BirthResult is the ICD-10 code (without the dot) of the pregnancy outcome:
+// Z37.x for deliveries by number of live and stillborn babies, O00 / O03 / O04 for losses
+func Moph43BirthResult(status string, liveBorn, stillBorn int) string {
+	switch status {
+	case entity.PregnancyStatusEctopic:
+		return "O009"
+	case entity.PregnancyStatusMiscarriage:
+		return "O039"
+	case entity.PregnancyStatusTermination:
+		return "O049"
+	case entity.PregnancyStatusDelivered:
+	default:
+		return ""
+	}
+	switch total := liveBorn + stillBorn; {
+	case total == 1 && liveBorn == 1:
+		return "Z370"
+	case total == 1:
+		return "Z371"
+	case total == 2 && stillBorn == 0:
+		return "Z372"
+	case total == 2 && liveBorn == 1:
+		return "Z373"
+	case total == 2:
+		return "Z374"
+	case total > 2 && stillBorn == 0:
+		return "Z375"
+	case total > 2 && liveBorn > 0:
+		return "Z376"
+	case total > 2:
+		return "Z377"
+	}
+	return ""
+}
+
+// Moph43BirthPlace maps the recorded delivery place to BPLACE and BHOSP. A 5-digit
+// hospital code is a hospital delivery; an empty place is unknown and left empty.
+func Moph43BirthPlace(place string) (bplace, bhosp string) {
+	place = strings.TrimSpace(place)
+	lower := strings.ToLower(place)
+	switch {
+	case place == "":
+		return "", ""
+	case len(place) == 5 && isDigits(place):
+		return "1", place
+	case containsAny(lower, []string{"ระหว่างทาง", "en route", "transit", "ambulance"}):
+		return "4", ""
+	// a รพ.สต. is usually named after its village (บ้าน...), so test it before home
+	case containsAny(lower, []string{"รพ.สต", "สถานีอนามัย", "อนามัย", "health center", "health centre"}):
+		return "2", ""
+	case containsAny(lower, []string{"บ้าน", "home"}):
+		return "3", ""
+	case containsAny(lower, []string{"โรงพยาบาล", "รพ.", "hospital"}):
+		return "1", ""
+	}
+	return "5", ""
+}
+
+// Moph43BirthType maps the recorded delivery method to BTYPE; losses are 6 (abortion)
+func Moph43BirthType(status, method string) string {
+	if status != entity.PregnancyStatusDelivered {
+		return "6"
+	}
+	lower := strings.ToLower(strings.TrimSpace(method))
+	switch {
+	case containsAny(lower, []string{"c-section", "cesarean", "caesarean", "c/s", "ผ่า"}) || lower == "cs":
+		return "2"
+	case containsAny(lower, []string{"vacuum", "v/e", "สุญญากาศ"}):
+		return "3"
+	case containsAny(lower, []string{"forceps", "f/e", "คีม"}):
+		return "4"
+	case containsAny(lower, []string{"breech", "ท่าก้น"}):
+		return "5"
+	case containsAny(lower, []string{"normal", "vaginal", "spontaneous", "nl", "svd", "ปกติ"}):
+		return "1"
+	}
+	return ""
+}
+
+// moph43BirthAttendants map the recorded delivery attendant to BDOCTOR
+var moph43BirthAttendants = map[string]string{
+	entity.DeliveryAttendantDoctor:       "1",
+	entity.DeliveryAttendantNurse:        "2",
+	entity.DeliveryAttendantHealthWorker: "3",
+	entity.DeliveryAttendantTraditional:  "4",
+	entity.DeliveryAttendantSelf:         "5",
+	entity.DeliveryAttendantOther:        "6",
+}
+
+// Moph43BirthAttendant is BDOCTOR; an attendant that was not recorded is left empty
+func Moph43BirthAttendant(attendant string) string {
+	return moph43BirthAttendants[attendant]
+}
+
+// Moph43Labor maps an ended pregnancy and its babies to LABOR
+func Moph43Labor(opts Moph43Options, w entity.PregnantWoman, p entity.Pregnancy, babies []entity.ObstetricHistory) Moph43Row {
+	live, still := 0, 0
+	place, method, attendant := "", "", ""
+	for _, b := range babies {
+		switch b.Outcome {
+		case entity.ObstetricOutcomeLiveBirth:
+			live++
+		case entity.ObstetricOutcomeStillbirth:
+			still++
+		}
+		place = firstNonEmpty(place, b.DeliveryPlace)
+		method = firstNonEmpty(method, b.DeliveryMethod)
+		attendant = firstNonEmpty(attendant, b.DeliveryAttendant)
+	}
+	bdate := time.Time{}
+	if p.OutcomeDate != nil {
+		bdate = *p.OutcomeDate
+	}
+	bplace, bhosp := Moph43BirthPlace(place)
+	row := Moph43Row{
+		"HOSPCODE": opts.HospCode,
+		"PID":      moph43ID(w.ID),
+		"GRAVIDA":  moph43Number(p.PregnancyNo),
+		"LMP":      moph43Date(PregnancyLMP(p)),
+		"EDC":      moph43Date(p.EDC),
+		"BDATE":    moph43Date(bdate),
+		"BRESULT":  Moph43BirthResult(p.Status, live, still),
+		"BPLACE":   bplace,
+		"BHOSP":    bhosp,
+		"BTYPE":    Moph43BirthType(p.Status, method),
+		"BDOCTOR":  Moph43BirthAttendant(attendant),
+		"LBORN":    strconv.Itoa(live),
+		"SBORN":    strconv.Itoa(still),
+		"D_UPDATE": moph43DateTime(p.UpdatedAt),
+		"CID":      w.CitizenID,
+	}
+	if p.Status == entity.PregnancyStatusDelivered && live+still == 0 {
+		row["BRESULT"] = "" // ยังไม่ได้บันทึกผลทารก
+	}
+	return row
+}
+
+// Moph43NewbornPID identifies a live-born baby. Babies have no person record in this
+// system, so the PID is NB followed by the ID of the baby's obstetric history row.
+func Moph43NewbornPID(baby entity.ObstetricHistory) string {
+	return "NB" + moph43ID(baby.ID)
+}
+
+// Moph43Newborn maps a live-born baby to NEWBORN. Asphyxia, vitamin K and TSH screening are not recorded (9).
+func Moph43Newborn(opts Moph43Options, w entity.PregnantWoman, p entity.Pregnancy, baby entity.ObstetricHistory) Moph43Row {
+	birth := baby.DeliveryDate
+	if birth.IsZero() && p.OutcomeDate != nil {
+		birth = *p.OutcomeDate
+	}
+	ga := baby.GestationalAge
+	if ga <= 0 {
+		ga = p.OutcomeGestationalAge
+	}
+	btime := ""
+	if local := birth.In(ClinicLocation); !birth.IsZero() && (local.Hour() != 0 || local.Minute() != 0) {
+		btime = local.Format("150405")
+	}
+	bplace, bhosp := Moph43BirthPlace(baby.DeliveryPlace)
+	return Moph43Row{
+		"HOSPCODE": opts.HospCode,
+		"PID":      Moph43NewbornPID(baby),
+		"MPID":     moph43ID(w.ID),
+		"GRAVIDA":  moph43Number(p.PregnancyNo),
+		"GA":       moph43Number(ga),
+		"BDATE":    moph43Date(birth),
+		"BTIME":    btime,
+		"BPLACE":   bplace,
+		"BHOSP":    bhosp,
+		"BIRTHNO":  moph43Number(max(baby.BabyNo, 1)),
+		"BTYPE":    Moph43BirthType(p.Status, baby.DeliveryMethod),
+		"BDOCTOR":  Moph43BirthAttendant(baby.DeliveryAttendant),
+		"BWEIGHT":  moph43Number(int(math.Round(baby.BirthWeight * 1000))),
+		"ASPHYXIA": "9",
+		"VITK":     "9",
+		"TSH":      "9",
+		"D_UPDATE": moph43DateTime(baby.UpdatedAt),
+		"CID":      "",
+	}
+}
+
+// Moph43PostnatalResult is 2 for heavy or foul lochia, an infected or opened wound,
+// fever of 38 °C or hypertension, 1 when the mother was examined without these and 9 otherwise
+func Moph43PostnatalResult(v entity.PostpartumVisit) string {
+	switch {
+	case v.Bleeding == "heavy" || v.Bleeding == "foul_smelling",
+		v.WoundCondition == "infected" || v.WoundCondition == "dehiscence",
+		v.Temperature >= 38,
+		isHypertensive(v.BloodPressure):
+		return "2"
+	case v.BloodPressure != "" || v.Bleeding != "" || v.Temperature > 0:
+		return "1"
+	}
+	return "9"
+}
+
+// Moph43Postnatal maps a postpartum visit to POSTNATAL
+func Moph43Postnatal(opts Moph43Options, w entity.PregnantWoman, p entity.Pregnancy, v entity.PostpartumVisit) Moph43Row {
+	bdate := time.Time{}
+	if p.OutcomeDate != nil {
+		bdate = *p.OutcomeDate
+	}
+	return Moph43Row{
+		"HOSPCODE": opts.HospCode,
+		"PID":      moph43ID(w.ID),
+		"SEQ":      moph43ID(v.ID),
+		"GRAVIDA":  moph43Number(p.PregnancyNo),
+		"BDATE":    moph43Date(bdate),
+		"PPCARE":   moph43Date(v.VisitDate),
+		"PPPLACE":  opts.HospCode,
+		"PPRESULT": Moph43PostnatalResult(v),
+		"PROVIDER": moph43OptionalID(v.DoctorID),
+		"D_UPDATE": moph43DateTime(v.UpdatedAt),
+		"CID":      w.CitizenID,
+	}
+}
+
+// SortMoph43Problems orders the report by patient, file and record
+func SortMoph43Problems(problems []Moph43Problem) {
+	order := map[string]int{}
+	for i, file := range Moph43Files {
+		order[file] = i
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		a, b := problems[i], problems[j]
+		if a.PatientID != b.PatientID {
+			return a.PatientID < b.PatientID
+		}
+		if order[a.File] != order[b.File] {
+			return order[a.File] < order[b.File]
+		}
+		return a.Record < b.Record
+	})
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+)
+
+func TestMoph43BirthResult(t *testing.T) {
+	tests := []struct {
+		status      string
+		live, still int
+		want        string
+	}{
+		{entity.PregnancyStatusDelivered, 1, 0, "Z370"},
+		{entity.PregnancyStatusDelivered, 0, 1, "Z371"},
+		{entity.PregnancyStatusDelivered, 2, 0, "Z372"},
+		{entity.PregnancyStatusDelivered, 1, 1, "Z373"},
+		{entity.PregnancyStatusDelivered, 0, 2, "Z374"},
+		{entity.PregnancyStatusDelivered, 3, 0, "Z375"},
+		{entity.PregnancyStatusDelivered, 2, 1, "Z376"},
+		{entity.PregnancyStatusDelivered, 0, 3, "Z377"},
+		{entity.PregnancyStatusDelivered, 0, 0, ""},
+		{entity.PregnancyStatusEctopic, 0, 0, "O009"},
+		{entity.PregnancyStatusMiscarriage, 0, 0, "O039"},
+		{entity.PregnancyStatusTermination, 0, 0, "O049"},
+		{entity.PregnancyStatusActive, 1, 0, ""},
+		{entity.PregnancyStatusTransferredOut, 0, 0, ""},
+	}
+	for _, tt := range tests {
+		if got := Moph43BirthResult(tt.status, tt.live, tt.still); got != tt.want {
+			t.Errorf("Moph43BirthResult(%s, %d live, %d still) = %q, want %q", tt.status, tt.live, tt.still, got, tt.want)
+		}
+	}
+}
+
+func TestMoph43BirthType(t *testing.T) {
+	tests := []struct {
+		status, method, want string
+	}{
+		{entity.PregnancyStatusDelivered, "Normal", "1"},
+		{entity.PregnancyStatusDelivered, "SVD", "1"},
+		{entity.PregnancyStatusDelivered, "คลอดปกติ", "1"},
+		{entity.PregnancyStatusDelivered, "C-Section", "2"},
+		{entity.PregnancyStatusDelivered, "CS", "2"},
+		{entity.PregnancyStatusDelivered, "ผ่าตัดคลอด", "2"},
+		{entity.PregnancyStatusDelivered, "Vacuum", "3"},
+		{entity.PregnancyStatusDelivered, "Forceps extraction", "4"},
+		{entity.PregnancyStatusDelivered, "Breech", "5"},
+		{entity.PregnancyStatusDelivered, "", ""},
+		{entity.PregnancyStatusDelivered, "water birth", ""},
+		{entity.PregnancyStatusMiscarriage, "", "6"},
+		{entity.PregnancyStatusEctopic, "Normal", "6"},
+	}
+	for _, tt := range tests {
+		if got := Moph43BirthType(tt.status, tt.method); got != tt.want {
+			t.Errorf("Moph43BirthType(%s, %q) = %q, want %q", tt.status, tt.method, got, tt.want)
+		}
+	}
+}
+
+func TestMoph43AncNo(t *testing.T) {
+	tests := []struct {
+		weeks int
+		want  string
+	}{
+		{0, ""}, {-1, ""}, {1, "1"}, {12, "1"}, {13, "2"}, {18, "2"}, {19, "3"}, {26, "3"},
+		{27, "4"}, {32, "4"}, {33, "5"}, {42, "5"},
+	}
+	for _, tt := range tests {
+		if got := Moph43AncNo(tt.weeks); got != tt.want {
+			t.Errorf("Moph43AncNo(%d) = %q, want %q", tt.weeks, got, tt.want)
+		}
+	}
+}
+
+func TestMoph43BirthPlace(t *testing.T) {
+	tests := []struct {
+		place, bplace, bhosp string
+	}{
+		{"", "", ""},
+		{"  ", "", ""},
+		{"10669", "1", "10669"},
+		{"โรงพยาบาลมหาราช", "1", ""},
+		{"รพ.สต.บ้านใหม่", "2", ""},
+		{"home", "3", ""},
+		{"ในรถพยาบาล ระหว่างทาง", "4", ""},
+		{"วัด", "5", ""},
+	}
+	for _, tt := range tests {
+		if bplace, bhosp := Moph43BirthPlace(tt.place); bplace != tt.bplace || bhosp != tt.bhosp {
+			t.Errorf("Moph43BirthPlace(%q) = %q, %q; want %q, %q", tt.place, bplace, bhosp, tt.bplace, tt.bhosp)
+		}
+	}
+}
+
+func TestMoph43BirthAttendant(t *testing.T) {
+	for attendant, want := range map[string]string{
+		entity.DeliveryAttendantDoctor: "1", entity.DeliveryAttendantNurse: "2", entity.DeliveryAttendantSelf: "5",
+		entity.DeliveryAttendantOther: "6", "": "", "midwife": "",
+	} {
+		if got := Moph43BirthAttendant(attendant); got != want {
+			t.Errorf("Moph43BirthAttendant(%q) = %q, want %q", attendant, got, want)
+		}
+	}
+}
+
+// moph43ValidLabor is a LABOR row that passes every rule
+func moph43ValidLabor() Moph43Row {
+	return Moph43Row{
+		"HOSPCODE": "10669", "PID": "12", "GRAVIDA": "2", "LMP": "20260101", "EDC": "20261008",
+		"BDATE": "20261005", "BRESULT": "Z370", "BPLACE": "1", "BHOSP": "10669", "BTYPE": "1", "BDOCTOR": "2",
+		"LBORN": "1", "SBORN": "0", "D_UPDATE": "20261006093000", "CID": "1103700012346",
+	}
+}
+
+func TestValidateMoph43Row(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		change  Moph43Row
+		field   string
+		problem string
+	}{
+		{"valid", "LABOR", nil, "", ""},
+		{"missing required", "LABOR", Moph43Row{"LMP": ""}, "LMP", "is required"},
+		{"unknown delivery place", "LABOR", Moph43Row{"BPLACE": "", "BHOSP": ""}, "BPLACE", "delivery place is not recorded"},
+		{"unknown attendant", "LABOR", Moph43Row{"BDOCTOR": ""}, "BDOCTOR", "birth attendant is not recorded"},
+		{"unknown delivery method", "LABOR", Moph43Row{"BTYPE": ""}, "BTYPE", "delivery method"},
+		{"code not in the list", "LABOR", Moph43Row{"BDOCTOR": "7"}, "BDOCTOR", "is not one of"},
+		{"too long", "LABOR", Moph43Row{"GRAVIDA": "123"}, "GRAVIDA", "longer than 2"},
+		{"not digits", "LABOR", Moph43Row{"HOSPCODE": "1066A"}, "HOSPCODE", "must be digits"},
+		{"bad check digit", "LABOR", Moph43Row{"CID": "1103700012345"}, "CID", "not a valid 13-digit citizen ID"},
+		{"bad date", "LABOR", Moph43Row{"BDATE": "20261345"}, "BDATE", "not a valid date"},
+		{"bad datetime", "LABOR", Moph43Row{"D_UPDATE": "20261006"}, "D_UPDATE", "not a valid datetime"},
+		{"separator in a value", "LABOR", Moph43Row{"BRESULT": "Z37|0"}, "BRESULT", "must not contain |"},
+		{"newborn without a citizen ID yet", "NEWBORN", nil, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row := moph43ValidLabor()
+			if tt.file == "NEWBORN" {
+				row = Moph43Row{
+					"HOSPCODE": "10669", "PID": "NB4", "MPID": "12", "GRAVIDA": "2", "GA": "39", "BDATE": "20261005",
+					"BPLACE": "1", "BIRTHNO": "1", "BTYPE": "1", "BDOCTOR": "1", "BWEIGHT": "3150", "D_UPDATE": "20261006093000",
+				}
+			}
+			for field, value := range tt.change {
+				row[field] = value
+			}
+
+			problems := ValidateMoph43Row(tt.file, row)
+			if tt.field == "" {
+				if len(problems) > 0 {
+					t.Fatalf("problems = %+v", problems)
+				}
+				return
+			}
+			if len(problems) != 1 || problems[0].Field != tt.field || !strings.Contains(problems[0].Problem, tt.problem) {
+				t.Fatalf("problems = %+v, want one %s problem containing %q", problems, tt.field, tt.problem)
+			}
+		})
+	}
+}
+
+func TestMoph43PersonLeavesUnrecordedNationalityEmpty(t *testing.T) {
+	opts := Moph43Options{HospCode: "10669", TypeArea: "4"}
+	w := entity.PregnantWoman{FullName: "นางสมหญิง ใจดี", CitizenID: "1103700012346", BirthDate: time.Date(1995, 3, 14, 0, 0, 0, 0, time.UTC)}
+	w.ID, w.UpdatedAt = 12, time.Date(2026, 10, 1, 3, 0, 0, 0, time.UTC)
+
+	row := Moph43Person(opts, w, "", "")
+	if row["RACE"] != "" || row["NATION"] != "" {
+		t.Fatalf("RACE %q, NATION %q for a mother without them recorded", row["RACE"], row["NATION"])
+	}
+	problems := ValidateMoph43Row("PERSON", row)
+	if len(problems) != 1 || problems[0].Field != "NATION" {
+		t.Fatalf("problems = %+v, want the missing nationality", problems)
+	}
+
+	w.Race, w.Nationality = "099", "099"
+	if problems := ValidateMoph43Row("PERSON", Moph43Person(opts, w, "", "")); len(problems) != 0 {
+		t.Fatalf("problems = %+v", problems)
+	}
+}
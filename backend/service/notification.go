@@ -0,0 +1,251 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+)
+
+// Delivery policy of the notification outbox
+const (
+	NotificationMaxAttempts = 5
+	notificationHTTPTimeout = 10 * time.Second
+)
+
+// DefaultReminderOffsets are how long before an appointment reminders go out
+var DefaultReminderOffsets = []time.Duration{72 * time.Hour, 24 * time.Hour}
+
+// Channel delivers one outbox message
+type Channel interface {
+	Name() string
+	Send(n entity.Notification) error
+}
+
+// SMTPChannel sends e-mail through an SMTP server (SMTP_HOST, SMTP_PORT, SMTP_USER, SMTP_PASSWORD, SMTP_FROM)
+type SMTPChannel struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (s *SMTPChannel) Name() string { return entity.NotificationChannelEmail }
+
+func (s *SMTPChannel) Send(n entity.Notification) error {
+	// A line break in an address would let it add headers or recipients
+	if strings.ContainsAny(n.Destination, "\r\n") {
+		return ErrHeaderInjection
+	}
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+	msg := "From: " + s.From + "\r\n" +
+		"To: " + n.Destination + "\r\n" +
+		"Subject: " + mimeHeader(n.Subject) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + n.Body + "\r\n"
+	return smtp.SendMail(s.Host+":"+s.Port, auth, s.From, []string{n.Destination}, []byte(msg))
+}
+
+// mimeHeader encodes non-ASCII (Thai) subjects. Line breaks are folded into
+// spaces so that a subject cannot end the header and start another one.
+func mimeHeader(value string) string {
+	value = strings.Join(strings.FieldsFunc(value, func(r rune) bool { return r == '\r' || r == '\n' }), " ")
+	for _, r := range value {
+		if r > 127 {
+			return "=?UTF-8?B?" + base64.StdEncoding.EncodeToString([]byte(value)) + "?="
+		}
+	}
+	return value
+}
+
+// SMSChannel posts {"to","message"} as JSON to an SMS gateway (SMS_GATEWAY_URL, SMS_GATEWAY_TOKEN)
+type SMSChannel struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+func (s *SMSChannel) Name() string { return entity.NotificationChannelSMS }
+
+func (s *SMSChannel) Send(n entity.Notification) error {
+	body, _ := json.Marshal(map[string]string{"to": n.Destination, "message": n.Body})
+	req, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+	return doHTTP(s.Client, req)
+}
+
+// WebhookChannel posts a LINE Notify style form (message=...) to NOTIFY_WEBHOOK_URL.
+// Each recipient connects her own token, so a message only reaches that recipient.
+type WebhookChannel struct {
+	URL    string
+	Client *http.Client
+}
+
+func (w *WebhookChannel) Name() string { return entity.NotificationChannelWebhook }
+
+// Send posts the message with the recipient's token, which the caller puts in
+// Token; the outbox only stores who the recipient is
+func (w *WebhookChannel) Send(n entity.Notification) error {
+	if n.Token == "" {
+		return ErrNoRecipientToken
+	}
+	form := url.Values{"message": {n.Subject + "\n" + n.Body}}
+	req, err := http.NewRequest(http.MethodPost, w.URL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+n.Token)
+	return doHTTP(w.Client, req)
+}
+
+// WebhookDestination is the outbox destination of a mother's webhook messages
+func WebhookDestination(pregnantWomanID uint) string {
+	return "pregnant_woman:" + strconv.FormatUint(uint64(pregnantWomanID), 10)
+}
+
+func doHTTP(client *http.Client, req *http.Request) error {
+	if client == nil {
+		client = &http.Client{Timeout: notificationHTTPTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("gateway responded %s", resp.Status)
+	}
+	return nil
+}
+
+// ChannelsFromEnv builds the channels that are configured in the environment
+func ChannelsFromEnv() map[string]Channel {
+	channels := map[string]Channel{}
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "25"
+		}
+		from := os.Getenv("SMTP_FROM")
+		if from == "" {
+			from = "no-reply@localhost"
+		}
+		channels[entity.NotificationChannelEmail] = &SMTPChannel{
+			Host:     host,
+			Port:     port,
+			Username: os.Getenv("SMTP_USER"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}
+	}
+	if gateway := os.Getenv("SMS_GATEWAY_URL"); gateway != "" {
+		channels[entity.NotificationChannelSMS] = &SMSChannel{URL: gateway, Token: os.Getenv("SMS_GATEWAY_TOKEN")}
+	}
+	if hook := os.Getenv("NOTIFY_WEBHOOK_URL"); hook != "" {
+		channels[entity.NotificationChannelWebhook] = &WebhookChannel{URL: hook}
+	}
+	return channels
+}
+
+// ReminderOffsetsFromEnv reads REMINDER_OFFSETS ("72h,24h"), falling back to the defaults
+func ReminderOffsetsFromEnv() []time.Duration {
+	value := os.Getenv("REMINDER_OFFSETS")
+	if value == "" {
+		return DefaultReminderOffsets
+	}
+	var offsets []time.Duration
+	for _, item := range SplitList(value) {
+		if d, err := time.ParseDuration(item); err == nil && d > 0 {
+			offsets = append(offsets, d)
+		}
+	}
+	if len(offsets) == 0 {
+		return DefaultReminderOffsets
+	}
+	return offsets
+}
+
+// DueReminderOffset returns the reminder stage an appointment is in: the smallest
+// offset that has been reached. Earlier stages are skipped once a later one is due,
+// so an appointment booked two days ahead only gets the 1-day reminder when it is due.
+func DueReminderOffset(appointmentDate, now time.Time, offsets []time.Duration) (time.Duration, bool) {
+	if !appointmentDate.After(now) {
+		return 0, false
+	}
+	sorted := append([]time.Duration(nil), offsets...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	left := appointmentDate.Sub(now)
+	for _, offset := range sorted {
+		if left <= offset {
+			return offset, true
+		}
+	}
+	return 0, false
+}
+
+// ReminderDedupKey identifies one reminder of one appointment date on one channel.
+// A rescheduled appointment gets new keys, so it is reminded again.
+func ReminderDedupKey(appointmentID uint, appointmentDate time.Time, offset time.Duration, channel string) string {
+	return "appt:" + strconv.FormatUint(uint64(appointmentID), 10) +
+		":" + strconv.FormatInt(appointmentDate.Unix(), 10) +
+		":" + offset.String() + ":" + channel
+}
+
+// NotificationBackoff is the wait before the next attempt after the given number of failures
+func NotificationBackoff(attempts int) time.Duration {
+	switch {
+	case attempts <= 1:
+		return time.Minute
+	case attempts == 2:
+		return 5 * time.Minute
+	case attempts == 3:
+		return 30 * time.Minute
+	default:
+		return 2 * time.Hour
+	}
+}
+
+// AppointmentReminderText builds the subject and body of an appointment reminder
+func AppointmentReminderText(appt entity.Appointment) (subject, body string) {
+	at := appt.AppointmentDate.In(ClinicLocation)
+	subject = "แจ้งเตือนนัดหมาย: " + appt.Title
+	body = fmt.Sprintf("คุณมีนัด \"%s\" วันที่ %s เวลา %s น.", appt.Title, at.Format("02/01/2006"), at.Format("15:04"))
+	if appt.Location != "" {
+		body += " ที่ " + appt.Location
+	}
+	return subject, body
+}
+
+// Delivery errors
+var (
+	// ErrNoChannel is returned when a message uses a channel that is not configured
+	ErrNoChannel = errors.New("notification channel is not configured")
+	// ErrNoRecipientToken is returned when the recipient has not connected a webhook token
+	ErrNoRecipientToken = errors.New("recipient has no notify token")
+	// ErrHeaderInjection is returned when a destination contains a line break
+	ErrHeaderInjection = errors.New("destination must not contain line breaks")
+)
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+)
+
+func TestNotificationBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, time.Minute},
+		{1, time.Minute},
+		{2, 5 * time.Minute},
+		{3, 30 * time.Minute},
+		{4, 2 * time.Hour},
+		{10, 2 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := NotificationBackoff(tt.attempts); got != tt.want {
+			t.Errorf("NotificationBackoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestDueReminderOffset(t *testing.T) {
+	now := time.Date(2026, 10, 19, 9, 0, 0, 0, ClinicLocation)
+	offsets := []time.Duration{72 * time.Hour, 24 * time.Hour}
+	tests := []struct {
+		name string
+		at   time.Time
+		want time.Duration
+		ok   bool
+	}{
+		{"too far ahead", now.Add(100 * time.Hour), 0, false},
+		{"3-day reminder", now.Add(48 * time.Hour), 72 * time.Hour, true},
+		{"1-day reminder replaces the 3-day one", now.Add(23 * time.Hour), 24 * time.Hour, true},
+		{"exactly on the offset", now.Add(24 * time.Hour), 24 * time.Hour, true},
+		{"already past", now.Add(-time.Minute), 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := DueReminderOffset(tt.at, now, offsets)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s: got (%v, %v), want (%v, %v)", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestMimeHeaderStripsLineBreaks(t *testing.T) {
+	got := mimeHeader("Reminder\r\nBcc: someone@example.com")
+	if strings.ContainsAny(got, "\r\n") {
+		t.Fatalf("mimeHeader kept a line break: %q", got)
+	}
+	if got != "Reminder Bcc: someone@example.com" {
+		t.Errorf("mimeHeader = %q", got)
+	}
+	if got := mimeHeader("นัด\nหมาย"); strings.ContainsAny(got, "\r\n") || !strings.HasPrefix(got, "=?UTF-8?B?") {
+		t.Errorf("mimeHeader(thai) = %q", got)
+	}
+}
+
+func TestSMTPChannelRejectsLineBreakInDestination(t *testing.T) {
+	ch := &SMTPChannel{Host: "127.0.0.1", Port: "1", From: "clinic@example.com"}
+	err := ch.Send(entity.Notification{Destination: "mom@example.com\r\nRCPT TO:<x@example.com>", Subject: "s", Body: "b"})
+	if !errors.Is(err, ErrHeaderInjection) {
+		t.Fatalf("Send = %v, want ErrHeaderInjection", err)
+	}
+}
+
+func TestWebhookChannelUsesRecipientToken(t *testing.T) {
+	var gotAuth, gotMessage string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotMessage = r.FormValue("message")
+	}))
+	defer server.Close()
+
+	ch := &WebhookChannel{URL: server.URL}
+	n := entity.Notification{Destination: WebhookDestination(7), Subject: "s", Body: "b"}
+	if err := ch.Send(n); !errors.Is(err, ErrNoRecipientToken) {
+		t.Fatalf("Send without token = %v, want ErrNoRecipientToken", err)
+	}
+
+	n.Token = "mother-7-token"
+	if err := ch.Send(n); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if gotAuth != "Bearer mother-7-token" {
+		t.Errorf("Authorization = %q", gotAuth)
+	}
+	if gotMessage != "s\nb" {
+		t.Errorf("message = %q", gotMessage)
+	}
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+)
+
+// EPDS scoring
+const (
+	EpdsItemCount        = 10
+	EpdsPossibleCutoff   = 10 // 10-12 อาจมีภาวะซึมเศร้า
+	EpdsProbableCutoff   = 13 // ตั้งแต่ 13 น่าจะมีภาวะซึมเศร้า
+	PostpartumEarlyDays  = 7
+	PostpartumSixWeekMin = 35
+	PostpartumSixWeekMax = 56
+	PostpartumSixWeekDue = 42
+	PostpartumTrackDays  = 90 // ติดตามหลังคลอดนานเท่านี้
+)
+
+// epdsReverseScored marks the items whose first option scores 3 (items 3, 5-10)
+var epdsReverseScored = [EpdsItemCount]bool{false, false, true, false, true, true, true, true, true, true}
+
+// EpdsResult is the scored screening
+type EpdsResult struct {
+	TotalScore    int
+	SelfHarmScore int
+	RiskLevel     string
+	Alert         bool
+}
+
+// ScoreEPDS scores the chosen option (0 = first option) of each of the 10 items.
+// Any thought of self-harm (item 10) raises an alert whatever the total.
+func ScoreEPDS(answers []int) (EpdsResult, error) {
+	if len(answers) != EpdsItemCount {
+		return EpdsResult{}, fmt.Errorf("EPDS needs exactly %d answers", EpdsItemCount)
+	}
+	var result EpdsResult
+	for i, option := range answers {
+		if option < 0 || option > 3 {
+			return EpdsResult{}, fmt.Errorf("answer %d must be between 0 and 3", i+1)
+		}
+		score := option
+		if epdsReverseScored[i] {
+			score = 3 - option
+		}
+		result.TotalScore += score
+		if i == EpdsItemCount-1 {
+			result.SelfHarmScore = score
+		}
+	}
+
+	switch {
+	case result.TotalScore >= EpdsProbableCutoff:
+		result.RiskLevel = entity.EpdsRiskProbable
+	case result.TotalScore >= EpdsPossibleCutoff:
+		result.RiskLevel = entity.EpdsRiskPossible
+	default:
+		result.RiskLevel = entity.EpdsRiskLow
+	}
+	result.Alert = result.RiskLevel == entity.EpdsRiskProbable || result.SelfHarmScore > 0
+	return result, nil
+}
+
+// JoinAnswers stores EPDS answers as "0,1,2,..."
+func JoinAnswers(answers []int) string {
+	parts := make([]string, len(answers))
+	for i, a := range answers {
+		parts[i] = strconv.Itoa(a)
+	}
+	return strings.Join(parts, ",")
+}
+
+// SplitAnswers reads EPDS answers stored by JoinAnswers
+func SplitAnswers(stored string) ([]int, error) {
+	var answers []int
+	for _, part := range strings.Split(stored, ",") {
+		a, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, errors.New("answers must be comma-separated numbers")
+		}
+		answers = append(answers, a)
+	}
+	return answers, nil
+}
+
+// IsPostpartumPregnancy reports whether postpartum care applies to a pregnancy status
+func IsPostpartumPregnancy(status string) bool {
+	switch status {
+	case entity.PregnancyStatusDelivered, entity.PregnancyStatusMiscarriage,
+		entity.PregnancyStatusTermination, entity.PregnancyStatusEctopic:
+		return true
+	}
+	return false
+}
+
+// DaysPostpartum counts days from the end of the pregnancy (day of delivery = 0)
+func DaysPostpartum(outcomeDate, at time.Time) (int, error) {
+	from := outcomeDate.In(ClinicLocation)
+	to := at.In(ClinicLocation)
+	fromDay := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, ClinicLocation)
+	toDay := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, ClinicLocation)
+	if toDay.Before(fromDay) {
+		return 0, errors.New("visit date is before the end of the pregnancy")
+	}
+	return int(toDay.Sub(fromDay).Hours() / 24), nil
+}
+
+// PostpartumVisitType classifies a visit by day: day 1-7 early, week 5-8 the six-week check
+func PostpartumVisitType(day int) string {
+	switch {
+	case day <= PostpartumEarlyDays:
+		return entity.PostpartumVisitEarly
+	case day >= PostpartumSixWeekMin && day <= PostpartumSixWeekMax:
+		return entity.PostpartumVisitSixWeek
+	default:
+		return entity.PostpartumVisitOther
+	}
+}
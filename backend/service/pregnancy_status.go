@@ -0,0 +1,87 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+)
+
+// pregnancyTransitions lists the statuses a pregnancy may move to from each status.
+// Closed statuses can only go back to Active through Reopen.
+var pregnancyTransitions = map[string][]string{
+	entity.PregnancyStatusActive: {
+		entity.PregnancyStatusDelivered,
+		entity.PregnancyStatusMiscarriage,
+		entity.PregnancyStatusTermination,
+		entity.PregnancyStatusEctopic,
+		entity.PregnancyStatusTransferredOut,
+		entity.PregnancyStatusLostToFollowUp,
+	},
+	// A mother lost to follow-up may turn up again or be found to have delivered elsewhere
+	entity.PregnancyStatusLostToFollowUp: {
+		entity.PregnancyStatusActive,
+		entity.PregnancyStatusDelivered,
+		entity.PregnancyStatusMiscarriage,
+		entity.PregnancyStatusTransferredOut,
+	},
+}
+
+// PregnancyOutcome holds the details recorded when a pregnancy leaves the Active status
+type PregnancyOutcome struct {
+	Reason         string
+	OutcomeDate    *time.Time
+	GestationalAge int
+	TransferTo     string
+}
+
+// ValidatePregnancyTransition checks that moving from one status to another is allowed
+func ValidatePregnancyTransition(from, to string) error {
+	if !entity.IsPregnancyStatus(to) {
+		return fmt.Errorf("unknown pregnancy status %q", to)
+	}
+	for _, allowed := range pregnancyTransitions[from] {
+		if allowed == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot change pregnancy status from %s to %s", from, to)
+}
+
+// ValidatePregnancyOutcome checks the fields required by each outcome
+func ValidatePregnancyOutcome(to string, outcome PregnancyOutcome) error {
+	switch to {
+	case entity.PregnancyStatusMiscarriage, entity.PregnancyStatusTermination, entity.PregnancyStatusEctopic:
+		if outcome.OutcomeDate == nil {
+			return errors.New("outcome_date is required")
+		}
+		if outcome.GestationalAge <= 0 {
+			return errors.New("gestational_age is required")
+		}
+	case entity.PregnancyStatusTransferredOut:
+		if outcome.TransferTo == "" {
+			return errors.New("transfer_to is required")
+		}
+	case entity.PregnancyStatusLostToFollowUp, entity.PregnancyStatusActive:
+		if outcome.Reason == "" {
+			return errors.New("reason is required")
+		}
+	}
+	return nil
+}
+
+// ValidatePregnancyReopen checks a request to undo a status change: any closed
+// pregnancy may go back to Active, but only with a reason for the audit trail
+func ValidatePregnancyReopen(from, reason string) error {
+	if reason == "" {
+		return errors.New("reason is required")
+	}
+	if from == entity.PregnancyStatusActive {
+		return errors.New("Pregnancy is already active")
+	}
+	if !entity.IsPregnancyStatus(from) {
+		return fmt.Errorf("unknown pregnancy status %q", from)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+)
+
+func TestValidatePregnancyTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		allowed  bool
+	}{
+		{entity.PregnancyStatusActive, entity.PregnancyStatusDelivered, true},
+		{entity.PregnancyStatusActive, entity.PregnancyStatusMiscarriage, true},
+		{entity.PregnancyStatusActive, entity.PregnancyStatusTermination, true},
+		{entity.PregnancyStatusActive, entity.PregnancyStatusEctopic, true},
+		{entity.PregnancyStatusActive, entity.PregnancyStatusTransferredOut, true},
+		{entity.PregnancyStatusActive, entity.PregnancyStatusLostToFollowUp, true},
+		{entity.PregnancyStatusActive, entity.PregnancyStatusActive, false},
+		{entity.PregnancyStatusActive, "Cancelled", false},
+
+		{entity.PregnancyStatusLostToFollowUp, entity.PregnancyStatusActive, true},
+		{entity.PregnancyStatusLostToFollowUp, entity.PregnancyStatusDelivered, true},
+		{entity.PregnancyStatusLostToFollowUp, entity.PregnancyStatusMiscarriage, true},
+		{entity.PregnancyStatusLostToFollowUp, entity.PregnancyStatusTransferredOut, true},
+		{entity.PregnancyStatusLostToFollowUp, entity.PregnancyStatusTermination, false},
+		{entity.PregnancyStatusLostToFollowUp, entity.PregnancyStatusEctopic, false},
+
+		// closed pregnancies only go back to Active through Reopen
+		{entity.PregnancyStatusDelivered, entity.PregnancyStatusActive, false},
+		{entity.PregnancyStatusDelivered, entity.PregnancyStatusMiscarriage, false},
+		{entity.PregnancyStatusMiscarriage, entity.PregnancyStatusDelivered, false},
+		{entity.PregnancyStatusTermination, entity.PregnancyStatusActive, false},
+		{entity.PregnancyStatusEctopic, entity.PregnancyStatusLostToFollowUp, false},
+		{entity.PregnancyStatusTransferredOut, entity.PregnancyStatusDelivered, false},
+	}
+	for _, tt := range tests {
+		if err := ValidatePregnancyTransition(tt.from, tt.to); (err == nil) != tt.allowed {
+			t.Errorf("%s -> %s: err = %v, allowed = %v", tt.from, tt.to, err, tt.allowed)
+		}
+	}
+}
+
+func TestValidatePregnancyReopen(t *testing.T) {
+	tests := []struct {
+		from, reason string
+		allowed      bool
+	}{
+		{entity.PregnancyStatusDelivered, "recorded on the wrong mother", true},
+		{entity.PregnancyStatusMiscarriage, "ultrasound shows a viable pregnancy", true},
+		{entity.PregnancyStatusTermination, "wrong record", true},
+		{entity.PregnancyStatusEctopic, "wrong record", true},
+		{entity.PregnancyStatusTransferredOut, "came back to our clinic", true},
+		{entity.PregnancyStatusLostToFollowUp, "turned up again", true},
+		{entity.PregnancyStatusDelivered, "", false},
+		{entity.PregnancyStatusActive, "already active", false},
+		{"", "no status", false},
+	}
+	for _, tt := range tests {
+		if err := ValidatePregnancyReopen(tt.from, tt.reason); (err == nil) != tt.allowed {
+			t.Errorf("reopen %s with reason %q: err = %v, allowed = %v", tt.from, tt.reason, err, tt.allowed)
+		}
+	}
+}
+
+func TestValidatePregnancyOutcome(t *testing.T) {
+	date := fhirTestDate("2026-08-01")
+	tests := []struct {
+		name    string
+		to      string
+		outcome PregnancyOutcome
+		allowed bool
+	}{
+		{"miscarriage without a date", entity.PregnancyStatusMiscarriage, PregnancyOutcome{GestationalAge: 10}, false},
+		{"ectopic without gestational age", entity.PregnancyStatusEctopic, PregnancyOutcome{OutcomeDate: &date}, false},
+		{"termination", entity.PregnancyStatusTermination, PregnancyOutcome{OutcomeDate: &date, GestationalAge: 12}, true},
+		{"transfer without a destination", entity.PregnancyStatusTransferredOut, PregnancyOutcome{}, false},
+		{"transfer", entity.PregnancyStatusTransferredOut, PregnancyOutcome{TransferTo: "รพ.มหาราช"}, true},
+		{"lost to follow-up without a reason", entity.PregnancyStatusLostToFollowUp, PregnancyOutcome{}, false},
+		{"back to active", entity.PregnancyStatusActive, PregnancyOutcome{Reason: "came for a visit"}, true},
+	}
+	for _, tt := range tests {
+		if err := ValidatePregnancyOutcome(tt.to, tt.outcome); (err == nil) != tt.allowed {
+			t.Errorf("%s: err = %v, allowed = %v", tt.name, err, tt.allowed)
+		}
+	}
+}
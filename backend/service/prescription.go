@@ -0,0 +1,211 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/bestiesmile1845/Projecteiei/entity"
+)
+
+// PrescriptionCategories are the supplements tracked for every pregnancy
+var PrescriptionCategories = []string{"iron", "folic_acid", "iodine", "calcium", "other"}
+
+// drugSynonyms groups names that refer to the same substance, so an allergy
+// written as "ferrous" also matches "Ferrous fumarate" or "ธาตุเหล็ก"
+var drugSynonyms = [][]string{
+	{"iron", "ferrous", "ferric", "เหล็ก"},
+	{"folic", "folate", "โฟลิก", "โฟเลต"},
+	{"iodine", "iodide", "ไอโอดีน"},
+	{"calcium", "แคลเซียม"},
+	{"penicillin", "amoxicillin", "ampicillin", "เพนนิซิลลิน"},
+	{"sulfa", "sulfonamide", "sulfamethoxazole", "ซัลฟา"},
+}
+
+// ValidatePrescription checks the required fields and dates of a prescription
+func ValidatePrescription(p entity.Prescription) error {
+	if strings.TrimSpace(p.DrugName) == "" {
+		return errors.New("drug_name is required")
+	}
+	if p.Category != "" && !contains(PrescriptionCategories, p.Category) {
+		return errors.New("category must be one of " + strings.Join(PrescriptionCategories, ", "))
+	}
+	if p.DosesPerDay < 1 || p.DosesPerDay > 6 {
+		return errors.New("doses_per_day must be between 1 and 6")
+	}
+	if p.EndDate != nil && p.EndDate.Before(p.StartDate) {
+		return errors.New("end_date must be after start_date")
+	}
+	return nil
+}
+
+// genericDrugWords say nothing about the substance, so they never make a match on their own
+var genericDrugWords = map[string]bool{
+	"vitamin": true, "vitamins": true, "drug": true, "drugs": true, "medicine": true, "tablet": true, "tablets": true,
+	"tab": true, "cap": true, "capsule": true, "capsules": true, "syrup": true, "mg": true, "mcg": true, "ml": true,
+	"allergy": true, "allergic": true, "to": true, "and": true, "of": true, "the": true, "ยา": true, "วิตามิน": true, "แพ้": true,
+}
+
+// CheckDrugAllergy returns the recorded allergy that matches the drug name, if any.
+// allergies is the free text of MedicalHistory.DrugAllergies. Names are compared
+// as whole words, so "iron" does not match "spironolactone".
+func CheckDrugAllergy(allergies []string, drugName string) (string, bool) {
+	drug := drugTokens(drugName)
+	for _, text := range allergies {
+		for _, allergen := range splitAllergies(text) {
+			words := drugTokens(allergen)
+			if len(words) == 0 {
+				continue
+			}
+			all := true
+			for _, w := range words {
+				all = all && hasDrugWord(drug, w)
+			}
+			if all {
+				return allergen, true
+			}
+			for _, group := range drugSynonyms {
+				if hasAnyDrugWord(words, group) && hasAnyDrugWord(drug, group) {
+					return allergen, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// drugTokens splits a name into lower-case words, leaving out numbers and generic words
+func drugTokens(name string) []string {
+	var tokens []string
+	for _, t := range strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsMark(r) && !unicode.IsDigit(r)
+	}) {
+		if genericDrugWords[t] || strings.IndexFunc(t, func(r rune) bool { return !unicode.IsDigit(r) }) < 0 {
+			continue
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens
+}
+
+// drugWordMatches compares a token with a word: equal, or the plural of it. Thai is
+// written without spaces, so a Thai word may also appear inside a token.
+func drugWordMatches(token, word string) bool {
+	if token == word || token == word+"s" {
+		return true
+	}
+	return isThai(word) && strings.Contains(token, word)
+}
+
+func hasDrugWord(tokens []string, word string) bool {
+	for _, t := range tokens {
+		if drugWordMatches(t, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAnyDrugWord(tokens, words []string) bool {
+	for _, w := range words {
+		if hasDrugWord(tokens, w) {
+			return true
+		}
+	}
+	return false
+}
+
+func isThai(s string) bool {
+	for _, r := range s {
+		if unicode.Is(unicode.Thai, r) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitAllergies(text string) []string {
+	var items []string
+	for _, item := range strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || r == ';' || r == '\n' || r == '/'
+	}) {
+		item = strings.TrimSpace(item)
+		lower := strings.ToLower(item)
+		// "ไม่มี", "none", "-" mean no known allergy
+		if item == "" || item == "-" || lower == "none" || lower == "no" || lower == "nkda" || item == "ไม่มี" || item == "ปฏิเสธ" {
+			continue
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+func containsAny(s string, words []string) bool {
+	for _, w := range words {
+		if strings.Contains(s, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckAdherenceDay validates the day of an adherence entry against the prescription period
+func CheckAdherenceDay(p entity.Prescription, day string, now time.Time) error {
+	if _, err := time.ParseInLocation("2006-01-02", day, ClinicLocation); err != nil {
+		return errors.New("date must be YYYY-MM-DD")
+	}
+	if day > ClinicDay(now) {
+		return errors.New("cannot log doses for a future day")
+	}
+	if day < ClinicDay(p.StartDate) {
+		return errors.New("date is before the prescription started")
+	}
+	if p.EndDate != nil && day > ClinicDay(*p.EndDate) {
+		return errors.New("date is after the prescription ended")
+	}
+	return nil
+}
+
+// ComputeAdherence compares logged doses with the doses expected from the start
+// of the prescription until today (or its end). Days without a log count as missed.
+func ComputeAdherence(p entity.Prescription, logs []entity.AdherenceLog, now time.Time) entity.PrescriptionAdherence {
+	result := entity.PrescriptionAdherence{
+		PrescriptionID: p.ID,
+		DrugName:       p.DrugName,
+		Category:       p.Category,
+		Status:         p.Status,
+	}
+
+	perDay := p.DosesPerDay
+	if perDay <= 0 {
+		perDay = 1
+	}
+
+	last := ClinicDay(now)
+	if p.EndDate != nil && ClinicDay(*p.EndDate) < last {
+		last = ClinicDay(*p.EndDate)
+	}
+	first := ClinicDay(p.StartDate)
+	if last < first {
+		return result
+	}
+
+	from, _ := time.ParseInLocation("2006-01-02", first, ClinicLocation)
+	to, _ := time.ParseInLocation("2006-01-02", last, ClinicLocation)
+	days := int(math.Round(to.Sub(from).Hours()/24)) + 1
+	result.ExpectedDoses = days * perDay
+
+	for _, l := range logs {
+		if l.Day < first || l.Day > last {
+			continue
+		}
+		result.DaysLogged++
+		result.TakenDoses += int(math.Min(float64(l.DosesTaken), float64(perDay)))
+	}
+	if result.ExpectedDoses > 0 {
+		result.Percent = math.Round(float64(result.TakenDoses)/float64(result.ExpectedDoses)*1000) / 10
+	}
+	return result
+}
@@ -0,0 +1,34 @@
+package service
+
+import "testing"
+
+func TestCheckDrugAllergy(t *testing.T) {
+	tests := []struct {
+		allergies string
+		drug      string
+		want      string
+	}{
+		{"iron", "Spironolactone 25 mg", ""},
+		{"iron", "Ferrous fumarate 200 mg", "iron"},
+		{"Ferrous", "ธาตุเหล็ก", "Ferrous"},
+		{"เหล็ก", "Iron sucrose", "เหล็ก"},
+		{"vitamin", "Vitamin B1", ""},
+		{"vitamin C", "Vitamin B1", ""},
+		{"vitamin C", "Vitamin C 500 mg", "vitamin C"},
+		{"penicillin", "Amoxicillin 500 mg", "penicillin"},
+		{"penicillins", "Penicillin V", "penicillins"},
+		{"sulfa drugs", "Sulfamethoxazole/trimethoprim", "sulfa drugs"},
+		{"amoxicillin", "Amoxicillin", "amoxicillin"},
+		{"aspirin; NSAIDs", "Calcium carbonate", ""},
+		{"none", "Folic acid", ""},
+		{"ไม่มี", "Folic acid", ""},
+		{"fish oil", "Fish gelatin capsule", ""},
+		{"iodine", "Potassium iodide", "iodine"},
+	}
+	for _, tt := range tests {
+		got, ok := CheckDrugAllergy([]string{tt.allergies}, tt.drug)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("CheckDrugAllergy(%q, %q) = (%q, %v), want %q", tt.allergies, tt.drug, got, ok, tt.want)
+		}
+	}
+}
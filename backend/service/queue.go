@@ -0,0 +1,105 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// CheckInQRPrefix marks the payload of an appointment check-in QR code
+	CheckInQRPrefix = "projecteiei-checkin:"
+
+	defaultSessionEnd = "16:30"
+	kioskCodeLength   = 8
+)
+
+// ErrKioskDisabled is returned when CHECKIN_KIOSK_SECRET is not set: mothers
+// cannot check themselves in, staff still scan the appointment QR
+var ErrKioskDisabled = errors.New("self check-in is not configured (CHECKIN_KIOSK_SECRET is not set)")
+
+// Queue display states of a checked-in appointment
+const (
+	QueueWaiting = "Waiting"
+	QueueCalled  = "Called"
+	QueueDone    = "Done"
+)
+
+// ClinicDay returns the clinic-local date (YYYY-MM-DD) of a time
+func ClinicDay(t time.Time) string {
+	return t.In(ClinicLocation).Format("2006-01-02")
+}
+
+// QueueSessionKey identifies one clinic session: the clinic day and the doctor
+func QueueSessionKey(day string, doctorID *uint) string {
+	if doctorID == nil {
+		return day + "/d0"
+	}
+	return fmt.Sprintf("%s/d%d", day, *doctorID)
+}
+
+// CheckCheckInDay only allows check-in on the day of the appointment
+func CheckCheckInDay(appointmentDate, now time.Time) error {
+	if ClinicDay(appointmentDate) != ClinicDay(now) {
+		return errors.New("check-in is only possible on the day of the appointment")
+	}
+	return nil
+}
+
+// ParseCheckInQR extracts the token from a scanned appointment QR payload
+func ParseCheckInQR(payload string) (string, error) {
+	token := strings.TrimPrefix(strings.TrimSpace(payload), CheckInQRPrefix)
+	if token == "" || token == strings.TrimSpace(payload) {
+		return "", errors.New("not a check-in QR code")
+	}
+	return token, nil
+}
+
+// KioskCode is the code shown as a QR at the clinic on a given day; mothers scan it to check themselves in
+func KioskCode(day string) (string, error) {
+	secret := os.Getenv("CHECKIN_KIOSK_SECRET")
+	if secret == "" {
+		return "", ErrKioskDisabled
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(day))
+	return strings.ToUpper(hex.EncodeToString(mac.Sum(nil))[:kioskCodeLength]), nil
+}
+
+// CheckKioskCode validates a scanned kiosk code against today's code
+func CheckKioskCode(code string, now time.Time) error {
+	expected, err := KioskCode(ClinicDay(now))
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(strings.ToUpper(strings.TrimSpace(code))), []byte(expected)) {
+		return errors.New("invalid or expired clinic check-in code")
+	}
+	return nil
+}
+
+// NoShowCutoff returns the time before which still-Scheduled appointments count as no-shows.
+// Today's appointments only become no-shows after the session ends (CLINIC_SESSION_END, default 16:30).
+func NoShowCutoff(now time.Time) time.Time {
+	local := now.In(ClinicLocation)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, ClinicLocation)
+
+	end := os.Getenv("CLINIC_SESSION_END")
+	if end == "" {
+		end = defaultSessionEnd
+	}
+	minutes, err := ParseClock(end)
+	if err != nil {
+		minutes, _ = ParseClock(defaultSessionEnd)
+	}
+
+	if local.Before(midnight.Add(time.Duration(minutes) * time.Minute)) {
+		return midnight.UTC()
+	}
+	return midnight.AddDate(0, 0, 1).UTC()
+}
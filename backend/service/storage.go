@@ -0,0 +1,332 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Upload policy
+const (
+	DefaultUploadMaxBytes = 10 << 20 // 10 MB
+	maxFilenameLength     = 120
+	storageHTTPTimeout    = 30 * time.Second
+)
+
+// ErrObjectNotFound is returned when a stored object does not exist
+var ErrObjectNotFound = errors.New("file not found")
+
+// AllowedUploadTypes are the MIME types accepted for uploads, keyed by the extension stored with the object
+var AllowedUploadTypes = map[string]string{
+	"application/pdf": ".pdf",
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+}
+
+// ObjectStore keeps uploaded files under opaque keys
+type ObjectStore interface {
+	Name() string
+	Put(key string, data []byte, contentType string) error
+	Get(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+// LocalStore keeps objects on the local filesystem below Root (STORAGE_LOCAL_ROOT, default uploads)
+type LocalStore struct {
+	Root string
+}
+
+func (l *LocalStore) Name() string { return "local" }
+
+// path resolves a key inside Root and rejects keys that would escape it
+func (l *LocalStore) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "..") {
+		return "", errors.New("invalid object key")
+	}
+	return filepath.Join(l.Root, filepath.FromSlash(clean)), nil
+}
+
+func (l *LocalStore) Put(key string, data []byte, contentType string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o750); err != nil {
+		return err
+	}
+	return os.WriteFile(p, data, 0o640)
+}
+
+func (l *LocalStore) Get(key string) (io.ReadCloser, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return nil, ErrObjectNotFound
+	}
+	return f, err
+}
+
+func (l *LocalStore) Delete(key string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// S3Store keeps objects in an S3-compatible bucket (AWS S3, MinIO) using path-style requests
+// signed with AWS Signature Version 4 (S3_ENDPOINT, S3_BUCKET, S3_REGION, S3_ACCESS_KEY, S3_SECRET_KEY)
+type S3Store struct {
+	Endpoint  string // e.g. http://localhost:9000
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	Client    *http.Client
+}
+
+func (s *S3Store) Name() string { return "s3" }
+
+func (s *S3Store) Put(key string, data []byte, contentType string) error {
+	resp, err := s.do(http.MethodPut, key, data, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return s3Error(resp)
+}
+
+func (s *S3Store) Get(key string) (io.ReadCloser, error) {
+	resp, err := s.do(http.MethodGet, key, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrObjectNotFound
+	}
+	if err := s3Error(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *S3Store) Delete(key string) error {
+	resp, err := s.do(http.MethodDelete, key, nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	return s3Error(resp)
+}
+
+func s3Error(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("object storage responded %s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
+
+// do sends a signed request for one object
+func (s *S3Store) do(method, key string, body []byte, contentType string) (*http.Response, error) {
+	endpoint, err := url.Parse(strings.TrimRight(s.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	// Path holds the key as is and RawPath its Signature Version 4 escaping, so the
+	// request line and the signed path are the same single-escaped string
+	endpoint.Path = "/" + s.Bucket + "/" + key
+	endpoint.RawPath = "/" + s3Escape(s.Bucket) + "/" + s3Escape(key)
+
+	req, err := http.NewRequest(method, endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	s.sign(req, body, time.Now().UTC())
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: storageHTTPTimeout}
+	}
+	return client.Do(req)
+}
+
+// sign adds the AWS Signature Version 4 headers to req
+func (s *S3Store) sign(req *http.Request, body []byte, now time.Time) {
+	region := s.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		"",
+		"host:" + req.URL.Host + "\nx-amz-content-sha256:" + payloadHash + "\nx-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), day)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape URI-encodes a key the way Signature Version 4 expects, keeping the slashes
+func s3Escape(key string) string {
+	var b strings.Builder
+	for _, c := range []byte(key) {
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// ObjectStoreFromEnv builds the store selected by STORAGE_BACKEND (local or s3)
+func ObjectStoreFromEnv() (ObjectStore, error) {
+	switch backend := os.Getenv("STORAGE_BACKEND"); backend {
+	case "", "local":
+		root := os.Getenv("STORAGE_LOCAL_ROOT")
+		if root == "" {
+			root = "uploads"
+		}
+		return &LocalStore{Root: root}, nil
+	case "s3":
+		store := &S3Store{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			Region:    os.Getenv("S3_REGION"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		}
+		if store.Endpoint == "" || store.Bucket == "" {
+			return nil, errors.New("S3_ENDPOINT and S3_BUCKET are required for the s3 storage backend")
+		}
+		return store, nil
+	default:
+		return nil, errors.New("STORAGE_BACKEND must be local or s3")
+	}
+}
+
+// UploadMaxBytes reads UPLOAD_MAX_MB, falling back to the default limit
+func UploadMaxBytes() int64 {
+	if mb, err := strconv.Atoi(os.Getenv("UPLOAD_MAX_MB")); err == nil && mb > 0 {
+		return int64(mb) << 20
+	}
+	return DefaultUploadMaxBytes
+}
+
+// NewObjectKey returns a random, unguessable key below prefix, e.g. lab_results/2026/10/3f9c....pdf
+func NewObjectKey(prefix, contentType string, now time.Time) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return prefix + "/" + now.UTC().Format("2006/01") + "/" + hex.EncodeToString(random) + AllowedUploadTypes[contentType], nil
+}
+
+// SanitizeFilename keeps only the base name of an uploaded file without control or path characters
+func SanitizeFilename(name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	name = path.Base(name)
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case unicode.IsControl(r), strings.ContainsRune(`/\:*?"<>|`, r):
+			b.WriteRune('_')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	clean := strings.Trim(strings.TrimSpace(b.String()), ".")
+	if clean == "" {
+		return "file"
+	}
+	if runes := []rune(clean); len(runes) > maxFilenameLength {
+		ext := filepath.Ext(clean)
+		if len([]rune(ext)) >= maxFilenameLength {
+			ext = ""
+		}
+		clean = string(runes[:maxFilenameLength-len([]rune(ext))]) + ext
+	}
+	return clean
+}
+
+// DetectUploadType checks the size and the content of an upload and returns its MIME type.
+// The type is sniffed from the bytes, not taken from the client.
+func DetectUploadType(data []byte, maxBytes int64) (string, error) {
+	if len(data) == 0 {
+		return "", errors.New("file is empty")
+	}
+	if int64(len(data)) > maxBytes {
+		return "", fmt.Errorf("file is larger than %d MB", maxBytes>>20)
+	}
+	contentType := http.DetectContentType(data)
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+	if _, ok := AllowedUploadTypes[contentType]; !ok {
+		return "", errors.New("file type " + contentType + " is not allowed; upload PDF, JPEG or PNG")
+	}
+	return contentType, nil
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3 is a path-style bucket that checks every request's Signature Version 4
+// the way S3 does, independently of S3Store.sign
+type fakeS3 struct {
+	t         *testing.T
+	accessKey string
+	secretKey string
+	region    string
+
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if reason := f.verify(r, body); reason != "" {
+		http.Error(w, "SignatureDoesNotMatch: "+reason, http.StatusForbidden)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := strings.SplitN(r.RequestURI, "?", 2)[0]
+	switch r.Method {
+	case http.MethodPut:
+		f.objects[key] = body
+		f.types[key] = r.Header.Get("Content-Type")
+	case http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// verify returns why the request's signature is wrong, or "" when it is valid
+func (f *fakeS3) verify(r *http.Request, body []byte) string {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 ") {
+		return "not signed with AWS4-HMAC-SHA256"
+	}
+	fields := map[string]string{}
+	for _, part := range strings.Split(strings.TrimPrefix(auth, "AWS4-HMAC-SHA256 "), ",") {
+		if kv := strings.SplitN(strings.TrimSpace(part), "=", 2); len(kv) == 2 {
+			fields[kv[0]] = kv[1]
+		}
+	}
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	if len(amzDate) != len("20060102T150405Z") {
+		return "bad X-Amz-Date"
+	}
+	scope := amzDate[:8] + "/" + f.region + "/s3/aws4_request"
+	if fields["Credential"] != f.accessKey+"/"+scope {
+		return "credential " + fields["Credential"]
+	}
+	payload := sha256.Sum256(body)
+	if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(payload[:]) {
+		return "payload hash does not match the body"
+	}
+
+	var headers strings.Builder
+	for _, name := range strings.Split(fields["SignedHeaders"], ";") {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		headers.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	canonical := strings.Join([]string{
+		r.Method,
+		strings.SplitN(r.RequestURI, "?", 2)[0],
+		r.URL.RawQuery,
+		headers.String(),
+		fields["SignedHeaders"],
+		r.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+	hashed := sha256.Sum256([]byte(canonical))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	key := []byte("AWS4" + f.secretKey)
+	for _, part := range []string{amzDate[:8], f.region, "s3", "aws4_request", stringToSign} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+	if fields["Signature"] != hex.EncodeToString(key) {
+		return "signature"
+	}
+	return ""
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *httptest.Server) {
+	bucket := &fakeS3{t: t, accessKey: "minio", secretKey: "minio-secret", region: "ap-southeast-1",
+		objects: map[string][]byte{}, types: map[string]string{}}
+	server := httptest.NewServer(bucket)
+	t.Cleanup(server.Close)
+	return bucket, server
+}
+
+func TestS3StorePutGetDelete(t *testing.T) {
+	bucket, server := newFakeS3(t)
+	store := &S3Store{Endpoint: server.URL + "/", Bucket: "lab-files", Region: "ap-southeast-1",
+		AccessKey: "minio", SecretKey: "minio-secret"}
+
+	// Spaces and Thai characters must be escaped the same way on both sides of the signature
+	key := "lab_results/2026/10/ผลเลือด scan~1.pdf"
+	data := []byte("%PDF-1.4 result")
+	if err := store.Put(key, data, "application/pdf"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	stored := "/lab-files/" + s3Escape(key)
+	if string(bucket.objects[stored]) != string(data) || bucket.types[stored] != "application/pdf" {
+		t.Fatalf("bucket holds %v", bucket.objects)
+	}
+
+	body, err := store.Get(key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	got, _ := io.ReadAll(body)
+	body.Close()
+	if string(got) != string(data) {
+		t.Errorf("Get = %q", got)
+	}
+
+	if err := store.Delete(key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Get(key); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("Get after Delete = %v, want ErrObjectNotFound", err)
+	}
+	if err := store.Delete(key); err != nil {
+		t.Errorf("Delete of a missing object = %v", err)
+	}
+}
+
+func TestS3StoreRejectedSignature(t *testing.T) {
+	_, server := newFakeS3(t)
+	store := &S3Store{Endpoint: server.URL, Bucket: "lab-files", Region: "ap-southeast-1",
+		AccessKey: "minio", SecretKey: "wrong-secret"}
+
+	err := store.Put("lab_results/a.pdf", []byte("x"), "application/pdf")
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("Put with a wrong secret = %v, want a 403", err)
+	}
+	if _, err := store.Get("lab_results/a.pdf"); err == nil || errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("Get with a wrong secret = %v, want a 403", err)
+	}
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png" // PNG decoder for image.Decode
+)
+
+const (
+	// ThumbnailMaxSize is the longest side of a generated thumbnail in pixels
+	ThumbnailMaxSize = 256
+	// ThumbnailMaxPixels caps the decoded size of a source image (40 MP, about 160 MB
+	// as RGBA): a small file can declare a huge image and exhaust memory when decoded
+	ThumbnailMaxPixels = 40_000_000
+)
+
+// ErrImageTooLarge is returned for images with more than ThumbnailMaxPixels pixels
+var ErrImageTooLarge = errors.New("image is too large to make a thumbnail")
+
+// IsImageType reports whether thumbnails can be made for a content type
+func IsImageType(contentType string) bool {
+	return contentType == "image/jpeg" || contentType == "image/png"
+}
+
+// ImageThumbnail decodes a JPEG or PNG image and returns a JPEG no larger than
+// ThumbnailMaxSize on either side. Each thumbnail pixel averages the source
+// pixels it covers so photos of documents stay readable. The header is read
+// first and images over ThumbnailMaxPixels are refused without decoding.
+func ImageThumbnail(data []byte) ([]byte, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if config.Width <= 0 || config.Height <= 0 || int64(config.Width)*int64(config.Height) > ThumbnailMaxPixels {
+		return nil, ErrImageTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	tw, th := w, h
+	if w > ThumbnailMaxSize || h > ThumbnailMaxSize {
+		if w >= h {
+			tw, th = ThumbnailMaxSize, max(h*ThumbnailMaxSize/w, 1)
+		} else {
+			tw, th = max(w*ThumbnailMaxSize/h, 1), ThumbnailMaxSize
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0 := bounds.Min.Y + y*h/th
+		y1 := max(bounds.Min.Y+(y+1)*h/th, y0+1)
+		for x := 0; x < tw; x++ {
+			x0 := bounds.Min.X + x*w/tw
+			x1 := max(bounds.Min.X+(x+1)*w/tw, x0+1)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a, n = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa), n+1
+				}
+			}
+			// transparent areas become white since JPEG has no alpha channel
+			white := 0xffff - a/n
+			dst.Set(x, y, color.RGBA64{uint16(r/n + white), uint16(g/n + white), uint16(b/n + white), 0xffff})
+		}
+	}
+
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
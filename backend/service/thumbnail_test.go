@@ -0,0 +1,55 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{200, 100, 50, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestImageThumbnailScalesDown(t *testing.T) {
+	thumb, err := ImageThumbnail(encodePNG(t, 1024, 512))
+	if err != nil {
+		t.Fatalf("ImageThumbnail: %v", err)
+	}
+	config, err := jpeg.DecodeConfig(bytes.NewReader(thumb))
+	if err != nil {
+		t.Fatalf("thumbnail is not a JPEG: %v", err)
+	}
+	if config.Width != ThumbnailMaxSize || config.Height != ThumbnailMaxSize/2 {
+		t.Errorf("thumbnail is %dx%d", config.Width, config.Height)
+	}
+}
+
+func TestImageThumbnailRefusesHugeImage(t *testing.T) {
+	// A 1x1 PNG whose header claims 50000x50000 (2.5 GP): tiny on disk, gigabytes decoded
+	data := encodePNG(t, 1, 1)
+	ihdr := data[8+8 : 8+8+13]
+	binary.BigEndian.PutUint32(ihdr[0:4], 50000)
+	binary.BigEndian.PutUint32(ihdr[4:8], 50000)
+	binary.BigEndian.PutUint32(data[8+8+13:], crc32.ChecksumIEEE(data[8+4:8+8+13]))
+
+	if _, err := ImageThumbnail(data); !errors.Is(err, ErrImageTooLarge) {
+		t.Fatalf("ImageThumbnail = %v, want ErrImageTooLarge", err)
+	}
+}